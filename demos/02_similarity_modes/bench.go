@@ -0,0 +1,217 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"time"
+)
+
+// runBench implements `go run . bench`: it scores random vectors with the
+// per-pair functions in a loop over documents, and with Matrix using the
+// pure-Go and the assembly kernels, and checks that all agree.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	n := fs.Int("n", 100_000, "number of vectors")
+	dim := fs.Int("dim", 384, "dimensions per vector")
+	queries := fs.Int("queries", 20, "number of queries per measurement")
+	fs.Parse(args)
+	if *n <= 0 || *dim <= 0 || *queries <= 0 {
+		return fmt.Errorf("-n, -dim and -queries must be positive")
+	}
+
+	fmt.Printf("⚡ Exhaustive search over %d vectors of %d dimensions, %d queries each\n", *n, *dim, *queries)
+	random := randomVectors(*dim)
+
+	// One slice per document, the way []chromem.Document holds them
+	docs := make([][]float32, *n)
+	matrix := NewMatrix(*dim, *n)
+	for i := range docs {
+		docs[i] = random()
+		if err := matrix.Add(fmt.Sprint(i), docs[i]); err != nil {
+			return err
+		}
+	}
+	qs := make([][]float32, *queries)
+	for i := range qs {
+		qs[i] = random()
+	}
+
+	simd := dotKernel
+	defer func() { dotKernel = simd }()
+
+	for _, c := range []struct {
+		metric Metric
+		pair   func(a, b []float32) float32
+	}{
+		{Cosine, cosineSimilarity},
+		{Euclidean, euclideanDistance},
+		{Manhattan, manhattanDistance},
+	} {
+		fmt.Printf("\n📐 %s\n", c.metric)
+
+		want := make([][]float32, len(qs))
+		start := time.Now()
+		for qi, q := range qs {
+			want[qi] = make([]float32, len(docs))
+			for i, doc := range docs {
+				want[qi][i] = c.pair(q, doc)
+			}
+		}
+		baseline := time.Since(start)
+		report("per-document loop", baseline, baseline, *n**queries, 0)
+
+		kernels := []struct {
+			name string
+			dot  func(a, b []float32) float32
+		}{{"matrix, generic", dotGeneric}}
+		if kernelName != "generic" && c.metric != Manhattan {
+			kernels = append(kernels, struct {
+				name string
+				dot  func(a, b []float32) float32
+			}{"matrix, " + kernelName, simd})
+		}
+		out := make([]float32, *n)
+		for _, k := range kernels {
+			dotKernel = k.dot
+			var elapsed time.Duration
+			var worst float64
+			for qi, q := range qs {
+				start := time.Now()
+				var err error
+				if out, err = matrix.Scores(q, c.metric, out); err != nil {
+					return err
+				}
+				elapsed += time.Since(start)
+				for i, v := range out {
+					worst = max(worst, diff(v, want[qi][i]))
+				}
+			}
+			report(k.name, elapsed, baseline, *n**queries, worst)
+		}
+	}
+	return nil
+}
+
+// runBenchTopK implements `go run . bench-topk`: it selects the best k of n
+// scores by sorting all of them and with TopK, then times Matrix.Search on
+// one and on -workers goroutines.
+func runBenchTopK(args []string) error {
+	fs := flag.NewFlagSet("bench-topk", flag.ExitOnError)
+	n := fs.Int("n", 1_000_000, "number of vectors")
+	dim := fs.Int("dim", 64, "dimensions per vector")
+	k := fs.Int("k", 10, "number of results")
+	queries := fs.Int("queries", 5, "number of queries per measurement")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "goroutines for the parallel search")
+	fs.Parse(args)
+	if *n <= 0 || *dim <= 0 || *k <= 0 || *queries <= 0 || *workers <= 0 {
+		return fmt.Errorf("-n, -dim, -k, -queries and -workers must be positive")
+	}
+	if *k > *n {
+		return fmt.Errorf("-k can't be more than -n")
+	}
+
+	fmt.Printf("🏆 Top %d of %d vectors of %d dimensions, %d queries each\n", *k, *n, *dim, *queries)
+	random := randomVectors(*dim)
+	matrix := NewMatrix(*dim, *n)
+	for i := range *n {
+		if err := matrix.Add(fmt.Sprint(i), random()); err != nil {
+			return err
+		}
+	}
+	qs := make([][]float32, *queries)
+	for i := range qs {
+		qs[i] = random()
+	}
+
+	for _, metric := range []Metric{Cosine, Euclidean} {
+		fmt.Printf("\n📐 %s\n", metric)
+		better := betterMatch(metric)
+		var sortTime, heapTime time.Duration
+		want := make([][]Match, len(qs))
+		for qi, q := range qs {
+			scores, err := matrix.Scores(q, metric, nil)
+			if err != nil {
+				return err
+			}
+
+			start := time.Now()
+			all := make([]Match, len(scores))
+			for i, score := range scores {
+				all[i] = Match{Index: i, Score: score}
+			}
+			slices.SortFunc(all, func(a, b Match) int {
+				if better(a, b) {
+					return -1
+				}
+				return 1
+			})
+			want[qi] = all[:*k]
+			sortTime += time.Since(start)
+
+			start = time.Now()
+			top := NewTopK(*k, better)
+			for i, score := range scores {
+				top.Push(Match{Index: i, Score: score})
+			}
+			got := top.Sorted()
+			heapTime += time.Since(start)
+			if !sameMatches(got, want[qi]) {
+				return fmt.Errorf("TopK disagrees with sorting for %s", metric)
+			}
+		}
+		perQuery := func(d time.Duration) time.Duration { return (d / time.Duration(len(qs))).Round(time.Microsecond) }
+		fmt.Printf("   select, full sort      %10v/query\n", perQuery(sortTime))
+		fmt.Printf("   select, bounded heap   %10v/query  %5.1fx\n", perQuery(heapTime), float64(sortTime)/float64(heapTime))
+
+		for _, workers := range slices.Compact([]int{1, *workers}) {
+			var elapsed time.Duration
+			for qi, q := range qs {
+				start := time.Now()
+				got, err := matrix.Search(q, metric, *k, workers)
+				if err != nil {
+					return err
+				}
+				elapsed += time.Since(start)
+				if !sameMatches(got, want[qi]) {
+					return fmt.Errorf("Search on %d workers disagrees with sorting for %s", workers, metric)
+				}
+			}
+			fmt.Printf("   Search, %2d worker(s)   %10v/query  (scoring included)\n", workers, perQuery(elapsed))
+		}
+	}
+	return nil
+}
+
+func sameMatches(a, b []Match) bool {
+	return slices.EqualFunc(a, b, func(x, y Match) bool { return x.Index == y.Index && x.Score == y.Score })
+}
+
+// randomVectors returns a function that returns seeded random vectors.
+func randomVectors(dim int) func() []float32 {
+	rng := rand.New(rand.NewPCG(2, 37))
+	return func() []float32 {
+		v := make([]float32, dim)
+		for i := range v {
+			v[i] = float32(rng.NormFloat64())
+		}
+		return v
+	}
+}
+
+func report(name string, elapsed, baseline time.Duration, pairs int, worst float64) {
+	fmt.Printf("   %-20s %8.2f ns/vector  %5.1fx", name, float64(elapsed.Nanoseconds())/float64(pairs), float64(baseline)/float64(elapsed))
+	if worst > 0 {
+		fmt.Printf("  (max difference %.1e)", worst)
+	}
+	fmt.Println()
+}
+
+// diff is the absolute difference for scores up to 1 and the relative one
+// for larger distances.
+func diff(a, b float32) float64 {
+	return math.Abs(float64(a-b)) / max(math.Abs(float64(b)), 1)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// runCalibrate implements `go run . calibrate`: it fits a sigmoid per metric
+// on labelled training queries, then applies one minScore to held-out test
+// queries under every metric and normalizer and measures what comes back.
+func runCalibrate(args []string) error {
+	fs := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	minScore := fs.Float64("min-score", 0.5, "threshold applied to every normalized score")
+	normalize := fs.Bool("normalize", true, "normalize vectors first, as chromem-go stores them")
+	nQueries := fs.Int("queries", 20, "training queries, and as many test queries")
+	fs.Parse(args)
+	if *nQueries <= 0 {
+		return fmt.Errorf("-queries must be positive")
+	}
+
+	// Same topic means relevant: the synthetic corpus is its own labelled sample
+	docs := syntheticCorpus(2000)
+	rng := rand.New(rand.NewPCG(40, 0))
+	rng.Shuffle(len(docs), func(i, j int) { docs[i], docs[j] = docs[j], docs[i] })
+	train, test, corpus := docs[:*nQueries], docs[*nQueries:2**nQueries], docs[2**nQueries:]
+
+	matrix := NewMatrix(len(corpus[0].Embedding), len(corpus))
+	for _, doc := range corpus {
+		if err := matrix.Add(doc.ID, prepareVector(doc.Embedding, *normalize)); err != nil {
+			return err
+		}
+	}
+	relevant := func(query chromem.Document, row int) bool {
+		return corpus[row].Metadata["topic"] == query.Metadata["topic"]
+	}
+	metrics := []Metric{Cosine, Dot, Euclidean, Manhattan}
+
+	fmt.Printf("🏷️  Fitting P(relevant | score) on %d training queries × %d documents\n", len(train), matrix.Len())
+	calibrated := make(Calibrated)
+	for _, metric := range metrics {
+		var scores []float32
+		var labels []bool
+		for _, q := range train {
+			raw, err := matrix.Scores(prepareVector(q.Embedding, *normalize), metric, nil)
+			if err != nil {
+				return err
+			}
+			for row, s := range raw {
+				scores = append(scores, s)
+				labels = append(labels, relevant(q, row))
+			}
+		}
+		sig, err := FitSigmoid(scores, labels)
+		if err != nil {
+			return fmt.Errorf("couldn't calibrate %v: %w", metric, err)
+		}
+		calibrated[metric] = sig
+		fmt.Printf("   %-10s P = 1 / (1 + exp(%.2f·score %+.2f))\n", metric, sig.A, sig.B)
+	}
+
+	fmt.Printf("\n🎚️  minScore %.2f on %d test queries: results kept, precision, recall\n", *minScore, len(test))
+	header := fmt.Sprintf("   %-12s", "")
+	for _, metric := range metrics {
+		header += fmt.Sprintf(" %-21s", metric)
+	}
+	fmt.Println(header)
+	for _, norm := range []Normalizer{Reciprocal{}, MinMax{}, ZScore{}, UnitCosine{}, calibrated} {
+		row := fmt.Sprintf("   %-12s", norm.Name())
+		for _, metric := range metrics {
+			var kept, hits, total int
+			var failed error
+			for _, q := range test {
+				matches, err := matrix.SearchNormalized(prepareVector(q.Embedding, *normalize), metric, norm, float32(*minScore), matrix.Len())
+				if err != nil {
+					failed = err
+					break
+				}
+				kept += len(matches)
+				for _, m := range matches {
+					if relevant(q, m.Index) {
+						hits++
+					}
+				}
+				for r := range matrix.Len() {
+					if relevant(q, r) {
+						total++
+					}
+				}
+			}
+			switch {
+			case failed != nil:
+				row += fmt.Sprintf(" %-21s", "—")
+			case kept == 0:
+				row += fmt.Sprintf(" %5.0f   —    0.00    ", 0.0)
+			default:
+				row += fmt.Sprintf(" %5.0f  %.2f  %.2f    ", float64(kept)/float64(len(test)), float64(hits)/float64(kept), float64(hits)/float64(total))
+			}
+		}
+		fmt.Println(strings.TrimRight(row, " "))
+	}
+	fmt.Println("\n🎯 Key Insights:")
+	fmt.Println("   • 1/(1+d) and unit cosine only mean the same thing where the metrics agree")
+	fmt.Println("   • Min-max and z-score are relative to each query: every query gets a \"best\" match")
+	fmt.Println("   • Calibrated scores are probabilities, so one threshold works for every metric")
+	fmt.Println("   • Without normalized vectors distances mostly measure length, and nothing recovers that")
+	fmt.Println("   • — means the normalizer doesn't apply, like unit cosine for Manhattan distance")
+	fmt.Println("\n💡 Try: go run . calibrate -normalize=false")
+	return nil
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// metricPair is two metrics whose rankings are compared.
+type metricPair struct{ a, b Metric }
+
+var comparedPairs = []metricPair{
+	{Cosine, Dot}, {Cosine, Euclidean}, {Cosine, Manhattan},
+	{Dot, Euclidean}, {Dot, Manhattan}, {Euclidean, Manhattan},
+}
+
+func (p metricPair) String() string { return p.a.String() + " ~ " + p.b.String() }
+
+// Agreement is how similarly two metrics rank the documents for one query.
+type Agreement struct {
+	KendallTau  float64 // over all documents, -1 to 1
+	SpearmanRho float64 // over all documents, -1 to 1
+	Jaccard     float64 // overlap of the top k, 0 to 1
+}
+
+// CompareReport holds the agreement of every metric pair for every query.
+type CompareReport struct {
+	K       int
+	Queries []string
+	// Agreements[p][q] is the agreement of comparedPairs[p] for Queries[q]
+	Agreements [][]Agreement
+}
+
+// compareMetrics ranks the matrix rows for every query under every metric
+// and measures how much each pair of metrics agrees.
+func compareMetrics(matrix *Matrix, queryIDs []string, queries [][]float32, k int) (*CompareReport, error) {
+	metrics := []Metric{Cosine, Dot, Euclidean, Manhattan}
+	report := &CompareReport{K: k, Queries: queryIDs, Agreements: make([][]Agreement, len(comparedPairs))}
+	for p := range comparedPairs {
+		report.Agreements[p] = make([]Agreement, len(queries))
+	}
+
+	for q, query := range queries {
+		scores := make(map[Metric][]float64)
+		tops := make(map[Metric][]Match)
+		for _, metric := range metrics {
+			raw, err := matrix.Scores(query, metric, nil)
+			if err != nil {
+				return nil, err
+			}
+			// Orient every metric so that higher is better
+			oriented := make([]float64, len(raw))
+			for i, s := range raw {
+				oriented[i] = float64(s)
+				if metric.IsDistance() {
+					oriented[i] = -oriented[i]
+				}
+			}
+			scores[metric] = oriented
+			if tops[metric], err = matrix.Search(query, metric, k, 0); err != nil {
+				return nil, err
+			}
+		}
+		for p, pair := range comparedPairs {
+			report.Agreements[p][q] = Agreement{
+				KendallTau:  kendallTau(scores[pair.a], scores[pair.b]),
+				SpearmanRho: spearmanRho(scores[pair.a], scores[pair.b]),
+				Jaccard:     jaccard(tops[pair.a], tops[pair.b]),
+			}
+		}
+	}
+	return report, nil
+}
+
+// kendallTau returns Kendall's tau-b of two score lists, which accounts for
+// ties, in O(n log n) with Knight's algorithm: sort by x, then count the
+// swaps a merge sort by y needs - each swap is a discordant pair.
+func kendallTau(x, y []float64) float64 {
+	n := len(x)
+	if n < 2 {
+		return 1
+	}
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool {
+		a, b := idx[i], idx[j]
+		return x[a] < x[b] || (x[a] == x[b] && y[a] < y[b])
+	})
+
+	// Pairs tied in x, and tied in both
+	var tiedX, tiedXY int64
+	for i := 0; i < n; {
+		j := i
+		for j < n && x[idx[j]] == x[idx[i]] {
+			j++
+		}
+		tiedX += int64(j-i) * int64(j-i-1) / 2
+		for a := i; a < j; {
+			b := a
+			for b < j && y[idx[b]] == y[idx[a]] {
+				b++
+			}
+			tiedXY += int64(b-a) * int64(b-a-1) / 2
+			a = b
+		}
+		i = j
+	}
+
+	ys := make([]float64, n)
+	for i, id := range idx {
+		ys[i] = y[id]
+	}
+	swaps := mergeCount(ys, make([]float64, n))
+
+	var tiedY int64
+	for i := 0; i < n; {
+		j := i
+		for j < n && ys[j] == ys[i] {
+			j++
+		}
+		tiedY += int64(j-i) * int64(j-i-1) / 2
+		i = j
+	}
+
+	pairs := int64(n) * int64(n-1) / 2
+	denom := math.Sqrt(float64(pairs-tiedX) * float64(pairs-tiedY))
+	if denom == 0 {
+		return 1 // one of the rankings is all ties
+	}
+	return float64(pairs-tiedX-tiedY+tiedXY-2*swaps) / denom
+}
+
+// mergeCount sorts v ascending and returns the number of inversions.
+func mergeCount(v, buf []float64) int64 {
+	if len(v) < 2 {
+		return 0
+	}
+	mid := len(v) / 2
+	swaps := mergeCount(v[:mid], buf[:mid]) + mergeCount(v[mid:], buf[mid:])
+	i, j, k := 0, mid, 0
+	for i < mid && j < len(v) {
+		if v[j] < v[i] {
+			buf[k] = v[j]
+			swaps += int64(mid - i)
+			j++
+		} else {
+			buf[k] = v[i]
+			i++
+		}
+		k++
+	}
+	k += copy(buf[k:], v[i:mid])
+	copy(buf[k:], v[j:])
+	copy(v, buf[:len(v)])
+	return swaps
+}
+
+// spearmanRho returns Spearman's rho: the Pearson correlation of the ranks,
+// with tied scores sharing their average rank.
+func spearmanRho(x, y []float64) float64 {
+	rx, ry := ranks(x), ranks(y)
+	n := float64(len(x))
+	mean := (n + 1) / 2
+	var cov, vx, vy float64
+	for i := range rx {
+		dx, dy := rx[i]-mean, ry[i]-mean
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 1
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+func ranks(v []float64) []float64 {
+	idx := make([]int, len(v))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool { return v[idx[i]] < v[idx[j]] })
+	r := make([]float64, len(v))
+	for i := 0; i < len(idx); {
+		j := i
+		for j < len(idx) && v[idx[j]] == v[idx[i]] {
+			j++
+		}
+		avg := float64(i+j+1) / 2 // ranks i+1 … j
+		for _, id := range idx[i:j] {
+			r[id] = avg
+		}
+		i = j
+	}
+	return r
+}
+
+// jaccard returns the overlap of two result lists as sets of rows.
+func jaccard(a, b []Match) float64 {
+	set := make(map[int]bool, len(a))
+	for _, m := range a {
+		set[m.Index] = true
+	}
+	var both int
+	for _, m := range b {
+		if set[m.Index] {
+			both++
+		}
+	}
+	union := len(a) + len(b) - both
+	if union == 0 {
+		return 1
+	}
+	return float64(both) / float64(union)
+}
+
+// mean returns the average of f over the agreements of one pair.
+func mean(agreements []Agreement, f func(Agreement) float64) float64 {
+	var sum float64
+	for _, a := range agreements {
+		sum += f(a)
+	}
+	return sum / float64(len(agreements))
+}
+
+// print writes the mean agreement per metric pair.
+func (r *CompareReport) print() {
+	fmt.Printf("   %-24s %9s %11s %11s\n", "", "Kendall τ", "Spearman ρ", fmt.Sprintf("Jaccard@%d", r.K))
+	for p, pair := range comparedPairs {
+		a := r.Agreements[p]
+		fmt.Printf("   %-24s %9.3f %11.3f %11.3f\n", pair,
+			mean(a, func(a Agreement) float64 { return a.KendallTau }),
+			mean(a, func(a Agreement) float64 { return a.SpearmanRho }),
+			mean(a, func(a Agreement) float64 { return a.Jaccard }))
+	}
+}
+
+// printDisagreements lists the n queries whose top results differ most
+// between any two metrics.
+func (r *CompareReport) printDisagreements(n int) {
+	type worst struct {
+		query   int
+		pair    metricPair
+		jaccard float64
+		tau     float64
+	}
+	perQuery := make([]worst, len(r.Queries))
+	for q := range r.Queries {
+		perQuery[q] = worst{query: q, jaccard: 2}
+		for p, pair := range comparedPairs {
+			a := r.Agreements[p][q]
+			if a.Jaccard < perQuery[q].jaccard || (a.Jaccard == perQuery[q].jaccard && a.KendallTau < perQuery[q].tau) {
+				perQuery[q] = worst{query: q, pair: pair, jaccard: a.Jaccard, tau: a.KendallTau}
+			}
+		}
+	}
+	slices.SortStableFunc(perQuery, func(a, b worst) int {
+		if a.jaccard != b.jaccard {
+			return cmp.Compare(a.jaccard, b.jaccard)
+		}
+		return cmp.Compare(a.tau, b.tau)
+	})
+	for _, w := range perQuery[:min(n, len(perQuery))] {
+		fmt.Printf("   ⚠️  %-12s Jaccard@%d %.2f, τ %.3f  (%s)\n", r.Queries[w.query], r.K, w.jaccard, w.tau, w.pair)
+	}
+}
+
+// runCompare implements `go run . compare`: it compares the rankings of all
+// metrics over a collection, once with the vectors as stored and once with
+// every vector normalized.
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	dbPath := fs.String("db", "", "persistent chromem-go DB directory; a synthetic corpus if empty")
+	collection := fs.String("collection", "", "collection in -db to compare")
+	nQueries := fs.Int("queries", 40, "documents held out of the collection and used as queries")
+	k := fs.Int("k", 10, "size of the top-k sets compared with Jaccard")
+	show := fs.Int("show", 5, "number of most disagreeing queries to list")
+	fs.Parse(args)
+	if *nQueries <= 0 || *k <= 0 {
+		return fmt.Errorf("-queries and -k must be positive")
+	}
+
+	var docs []chromem.Document
+	if *dbPath == "" {
+		docs = syntheticCorpus(2000)
+		fmt.Printf("📚 Synthetic corpus: %d documents in 20 topics, with lengths that vary like unnormalized embeddings\n", len(docs))
+	} else {
+		if *collection == "" {
+			return fmt.Errorf("-collection is required with -db")
+		}
+		db, err := chromem.NewPersistentDB(*dbPath, false)
+		if err != nil {
+			return fmt.Errorf("couldn't open DB: %w", err)
+		}
+		if docs, err = readDocuments(db, *collection); err != nil {
+			return err
+		}
+		fmt.Printf("📚 %s/%s: %d documents\n", *dbPath, *collection, len(docs))
+	}
+	if len(docs) <= *nQueries {
+		return fmt.Errorf("need more than %d documents to hold %d out as queries", *nQueries, *nQueries)
+	}
+
+	// Hold out a seeded sample as queries, so no query finds itself
+	rng := rand.New(rand.NewPCG(39, 0))
+	rng.Shuffle(len(docs), func(i, j int) { docs[i], docs[j] = docs[j], docs[i] })
+	held, corpus := docs[:*nQueries], docs[*nQueries:]
+
+	for _, normalized := range []bool{false, true} {
+		dim := len(corpus[0].Embedding)
+		matrix := NewMatrix(dim, len(corpus))
+		for _, doc := range corpus {
+			if err := matrix.Add(doc.ID, prepareVector(doc.Embedding, normalized)); err != nil {
+				return err
+			}
+		}
+		ids := make([]string, len(held))
+		queries := make([][]float32, len(held))
+		for i, doc := range held {
+			ids[i], queries[i] = doc.ID, prepareVector(doc.Embedding, normalized)
+		}
+		report, err := compareMetrics(matrix, ids, queries, *k)
+		if err != nil {
+			return err
+		}
+
+		if normalized {
+			fmt.Printf("\n📏 Normalized vectors\n")
+		} else {
+			fmt.Printf("\n📐 Vectors as stored, %d queries over %d documents\n", len(queries), matrix.Len())
+		}
+		report.print()
+		if !normalized {
+			fmt.Printf("\n🔎 Queries where metrics disagree most:\n")
+			report.printDisagreements(*show)
+		} else {
+			fmt.Println()
+			for p, pair := range comparedPairs {
+				lowest := slices.MinFunc(report.Agreements[p], func(a, b Agreement) int { return cmp.Compare(a.KendallTau, b.KendallTau) })
+				if lowest.KendallTau > 0.9999 {
+					fmt.Printf("   ✅ %s: identical rankings for every query\n", pair)
+				} else {
+					fmt.Printf("   ❌ %s: still differ, τ as low as %.3f\n", pair, lowest.KendallTau)
+				}
+			}
+			fmt.Println("\n💡 On unit vectors the dot product is the cosine and Euclidean distance is √(2 - 2·cosine),")
+			fmt.Println("   so normalizing makes the choice between them moot; Manhattan distance stays a real choice.")
+		}
+	}
+	return nil
+}
+
+// prepareVector returns v, or a normalized copy of it.
+func prepareVector(v []float32, normalize bool) []float32 {
+	if !normalize {
+		return v
+	}
+	norm := vectorNorm(v)
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out
+}
+
+// syntheticCorpus returns n documents around 20 random topic directions in
+// 64 dimensions, with log-normally distributed lengths.
+func syntheticCorpus(n int) []chromem.Document {
+	const dim, topics = 64, 20
+	rng := rand.New(rand.NewPCG(39, 1))
+	centers := make([][]float32, topics)
+	for t := range centers {
+		centers[t] = make([]float32, dim)
+		for i := range centers[t] {
+			centers[t][i] = float32(rng.NormFloat64())
+		}
+	}
+	docs := make([]chromem.Document, n)
+	for d := range docs {
+		topic := d % topics
+		length := float32(math.Exp(rng.NormFloat64() * 0.5))
+		vec := make([]float32, dim)
+		for i := range vec {
+			vec[i] = (centers[topic][i] + float32(rng.NormFloat64())*0.9) * length
+		}
+		docs[d] = chromem.Document{
+			ID:        fmt.Sprintf("doc-%04d", d),
+			Embedding: vec,
+			Metadata:  map[string]string{"topic": fmt.Sprint(topic)},
+		}
+	}
+	return docs
+}
+
+// readDocuments returns all documents of a collection with their
+// embeddings. chromem-go has no API to list them, but its export is a gob of
+// the same structs.
+func readDocuments(db *chromem.DB, name string) ([]chromem.Document, error) {
+	var buf bytes.Buffer
+	if err := db.ExportToWriter(&buf, false, "", name); err != nil {
+		return nil, fmt.Errorf("couldn't export collection %q: %w", name, err)
+	}
+	persisted := struct {
+		Collections map[string]*struct {
+			Documents map[string]*chromem.Document
+		}
+	}{}
+	if err := gob.NewDecoder(&buf).Decode(&persisted); err != nil {
+		return nil, fmt.Errorf("couldn't decode collection %q: %w", name, err)
+	}
+	pc, ok := persisted.Collections[name]
+	if !ok {
+		return nil, fmt.Errorf("collection %q not found", name)
+	}
+	docs := make([]chromem.Document, 0, len(pc.Documents))
+	for _, doc := range pc.Documents {
+		docs = append(docs, *doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	return docs, nil
+}
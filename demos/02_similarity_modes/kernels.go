@@ -0,0 +1,64 @@
+package main
+
+import "math"
+
+// dotKernel computes the dot product of two equally long vectors. It is the
+// inner loop of every exhaustive search, so the architecture files replace it
+// with an assembly version when the CPU supports one.
+var dotKernel = dotGeneric
+
+// kernelName names the dot product kernel in use.
+var kernelName = "generic"
+
+// dotGeneric is the pure-Go dot product, unrolled four ways with independent
+// sums so the CPU can overlap the multiplications.
+func dotGeneric(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i <= len(a)-4; i += 4 {
+		s0 += a[i] * b[i]
+		s1 += a[i+1] * b[i+1]
+		s2 += a[i+2] * b[i+2]
+		s3 += a[i+3] * b[i+3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// l1Scaled returns the Manhattan distance between a and scale·b, so rows that
+// were normalized for the dot product can still be compared as stored.
+func l1Scaled(a, b []float32, scale float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i <= len(a)-4; i += 4 {
+		s0 += abs32(a[i] - scale*b[i])
+		s1 += abs32(a[i+1] - scale*b[i+1])
+		s2 += abs32(a[i+2] - scale*b[i+2])
+		s3 += abs32(a[i+3] - scale*b[i+3])
+	}
+	for ; i < len(a); i++ {
+		s0 += abs32(a[i] - scale*b[i])
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// l2Scaled returns the Euclidean distance between a and scale·b, summed in
+// float64 for vectors too close for the dot-product identity.
+func l2Scaled(a, b []float32, scale float32) float32 {
+	b = b[:len(a)]
+	var s float64
+	for i := range a {
+		d := float64(a[i]) - float64(scale)*float64(b[i])
+		s += d * d
+	}
+	return float32(math.Sqrt(s))
+}
+
+// abs32 clears the sign bit; a branch would be mispredicted half the time.
+func abs32(x float32) float32 {
+	return math.Float32frombits(math.Float32bits(x) &^ (1 << 31))
+}
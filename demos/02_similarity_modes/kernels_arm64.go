@@ -0,0 +1,30 @@
+//go:build !purego
+
+package main
+
+// Advanced SIMD is part of every ARMv8-A CPU, so there is nothing to detect.
+func init() {
+	dotKernel = dotNEON
+	kernelName = "neon"
+}
+
+func dotNEON(a, b []float32) float32 {
+	b = b[:len(a)]
+	n := len(a) &^ 15
+	var sum float32
+	if n > 0 {
+		var acc [4]float32
+		dotNEONAsm(&a[0], &b[0], n, &acc)
+		sum = (acc[0] + acc[1]) + (acc[2] + acc[3])
+	}
+	for i := n; i < len(a); i++ {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// dotNEONAsm adds up the products of n floats, a multiple of 16, into four
+// lanes of acc.
+//
+//go:noescape
+func dotNEONAsm(a, b *float32, n int, acc *[4]float32)
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"os"
 
 	"github.com/philippgille/chromem-go"
 )
 
-// Custom similarity functions to demonstrate different approaches
+// Custom similarity functions to demonstrate different approaches. They
+// compare one pair at a time; Matrix does the same for a whole collection.
 func cosineSimilarity(a, b []float32) float32 {
 	var dotProduct, normA, normB float32
 	for i := range a {
@@ -36,12 +38,23 @@ func manhattanDistance(a, b []float32) float32 {
 	return sum
 }
 
-// Convert distance to similarity (lower distance = higher similarity)
-func distanceToSimilarity(distance float32) float32 {
-	return 1.0 / (1.0 + distance)
-}
-
 func main() {
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string) error{
+			"bench":      runBench,
+			"bench-topk": runBenchTopK,
+			"compare":    runCompare,
+			"calibrate":  runCalibrate,
+		}
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	fmt.Println("🔍 Similarity Modes Demo - Same Query, Different Perspectives")
 	fmt.Println("==========================================================")
 
@@ -117,69 +130,20 @@ func main() {
 	fmt.Println("   Lower values = more similar")
 	fmt.Println("   ─────────────────────────────")
 
-	type docDistance struct {
-		id         string
-		content    string
-		distance   float32
-		similarity float32
-	}
-
-	var euclideanResults []docDistance
+	// The same vectors in one contiguous matrix, searched in a single pass
+	matrix := NewMatrix(len(queryEmbedding), len(documents))
 	for _, doc := range documents {
-		distance := euclideanDistance(queryEmbedding, doc.Embedding)
-		similarity := distanceToSimilarity(distance)
-		euclideanResults = append(euclideanResults, docDistance{
-			id:         doc.ID,
-			content:    doc.Content,
-			distance:   distance,
-			similarity: similarity,
-		})
-	}
-
-	// Sort by distance (ascending)
-	for i := 0; i < len(euclideanResults); i++ {
-		for j := i + 1; j < len(euclideanResults); j++ {
-			if euclideanResults[i].distance > euclideanResults[j].distance {
-				euclideanResults[i], euclideanResults[j] = euclideanResults[j], euclideanResults[i]
-			}
+		if err := matrix.Add(doc.ID, doc.Embedding); err != nil {
+			panic(err)
 		}
 	}
-
-	for i, result := range euclideanResults {
-		fmt.Printf("   %d. [%s] Distance: %.4f, Similarity: %.4f\n", i+1, result.id, result.distance, result.similarity)
-		fmt.Printf("      %s\n", result.content)
-	}
+	printDistances(documents, matrix, queryEmbedding, Euclidean)
 
 	// 3. Manual Manhattan Distance calculation
 	fmt.Println("\n📊 3. MANHATTAN DISTANCE")
 	fmt.Println("   Lower values = more similar")
 	fmt.Println("   ─────────────────────────────")
-
-	var manhattanResults []docDistance
-	for _, doc := range documents {
-		distance := manhattanDistance(queryEmbedding, doc.Embedding)
-		similarity := distanceToSimilarity(distance)
-		manhattanResults = append(manhattanResults, docDistance{
-			id:         doc.ID,
-			content:    doc.Content,
-			distance:   distance,
-			similarity: similarity,
-		})
-	}
-
-	// Sort by distance (ascending)
-	for i := 0; i < len(manhattanResults); i++ {
-		for j := i + 1; j < len(manhattanResults); j++ {
-			if manhattanResults[i].distance > manhattanResults[j].distance {
-				manhattanResults[i], manhattanResults[j] = manhattanResults[j], manhattanResults[i]
-			}
-		}
-	}
-
-	for i, result := range manhattanResults {
-		fmt.Printf("   %d. [%s] Distance: %.4f, Similarity: %.4f\n", i+1, result.id, result.distance, result.similarity)
-		fmt.Printf("      %s\n", result.content)
-	}
+	printDistances(documents, matrix, queryEmbedding, Manhattan)
 
 	fmt.Println("\n🎯 Key Insights:")
 	fmt.Println("   • Cosine similarity focuses on vector direction (angle)")
@@ -187,4 +151,21 @@ func main() {
 	fmt.Println("   • Manhattan distance measures grid-like distance")
 	fmt.Println("   • Different metrics can rank results differently!")
 	fmt.Println("   • chromem-go uses cosine similarity for best semantic matching")
+	fmt.Println("   • Raw scores aren't comparable across metrics; normalize before thresholding")
+	fmt.Printf("   • Custom metrics ran on the %s dot product kernel\n", kernelName)
+	fmt.Println("\n💡 Try: go run . bench -n 200000 -dim 384")
+	fmt.Println("💡 Try: go run . calibrate -min-score 0.5")
+}
+
+// printDistances ranks every document by a distance metric, with the
+// distance rescaled to [0, 1] so 1 is the best match whatever the metric.
+func printDistances(documents []chromem.Document, matrix *Matrix, query []float32, metric Metric) {
+	matches, err := matrix.SearchNormalized(query, metric, MinMax{}, 0, matrix.Len())
+	if err != nil {
+		panic(err)
+	}
+	for i, match := range matches {
+		fmt.Printf("   %d. [%s] Distance: %.4f, Score: %.4f\n", i+1, match.ID, match.Score, match.Normalized)
+		fmt.Printf("      %s\n", documents[match.Index].Content)
+	}
 }
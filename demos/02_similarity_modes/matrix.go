@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Metric selects how Matrix.Scores compares a query with the stored vectors.
+type Metric int
+
+const (
+	Cosine    Metric = iota // higher is more similar
+	Dot                     // higher is more similar
+	Euclidean               // a distance: lower is more similar
+	Manhattan               // a distance: lower is more similar
+)
+
+func (m Metric) String() string {
+	switch m {
+	case Cosine:
+		return "cosine"
+	case Dot:
+		return "dot"
+	case Euclidean:
+		return "euclidean"
+	case Manhattan:
+		return "manhattan"
+	}
+	return fmt.Sprintf("Metric(%d)", int(m))
+}
+
+// IsDistance reports whether lower scores are better.
+func (m Metric) IsDistance() bool {
+	return m == Euclidean || m == Manhattan
+}
+
+// Matrix stores vectors for exhaustive search in one contiguous, row-major
+// []float32. Rows are normalized when added and their original lengths are
+// kept beside them, so cosine similarity is a plain dot product and the other
+// metrics are derived from it:
+//
+//	dot(q, v)    = |q|·|v|·dot(q̂, v̂)
+//	|q - v|²     = |q|² + |v|² - 2·|q|·|v|·dot(q̂, v̂)
+//
+// Manhattan distance can't be derived, so it scales each row back up as it
+// goes. Scanning rows in memory order keeps the prefetcher busy, unlike
+// chasing a pointer per document.
+type Matrix struct {
+	dim   int
+	ids   []string
+	data  []float32
+	norms []float32
+}
+
+// NewMatrix returns an empty matrix for vectors of dim dimensions, with room
+// for capacity rows.
+func NewMatrix(dim, capacity int) *Matrix {
+	return &Matrix{
+		dim:   dim,
+		ids:   make([]string, 0, capacity),
+		data:  make([]float32, 0, dim*capacity),
+		norms: make([]float32, 0, capacity),
+	}
+}
+
+// Add appends a copy of vec, normalized.
+func (m *Matrix) Add(id string, vec []float32) error {
+	if len(vec) != m.dim {
+		return fmt.Errorf("vector %s has %d dimensions, matrix has %d", id, len(vec), m.dim)
+	}
+	norm := vectorNorm(vec)
+	if norm == 0 {
+		return fmt.Errorf("vector %s has zero length", id)
+	}
+	for _, v := range vec {
+		m.data = append(m.data, v/norm)
+	}
+	m.ids = append(m.ids, id)
+	m.norms = append(m.norms, norm)
+	return nil
+}
+
+// Len returns the number of rows.
+func (m *Matrix) Len() int { return len(m.ids) }
+
+// ID returns the ID of row i.
+func (m *Matrix) ID(i int) string { return m.ids[i] }
+
+// Row returns the normalized vector of row i. It must not be modified.
+func (m *Matrix) Row(i int) []float32 { return m.data[i*m.dim : (i+1)*m.dim] }
+
+// Scores compares query with every row and writes one score per row into
+// out, which it grows if needed and returns. Scores of distance metrics are
+// distances.
+func (m *Matrix) Scores(query []float32, metric Metric, out []float32) ([]float32, error) {
+	q, err := m.prepare(query, metric)
+	if err != nil {
+		return nil, err
+	}
+	if cap(out) < m.Len() {
+		out = make([]float32, m.Len())
+	}
+	out = out[:m.Len()]
+	m.scoreRange(q, metric, 0, out)
+	return out, nil
+}
+
+// preparedQuery is a query checked against a matrix, with its length and
+// unit vector computed once for all rows.
+type preparedQuery struct {
+	vec, unit []float32
+	norm      float32
+}
+
+func (m *Matrix) prepare(query []float32, metric Metric) (preparedQuery, error) {
+	if metric < Cosine || metric > Manhattan {
+		return preparedQuery{}, fmt.Errorf("unknown metric %v", metric)
+	}
+	if len(query) != m.dim {
+		return preparedQuery{}, fmt.Errorf("query has %d dimensions, matrix has %d", len(query), m.dim)
+	}
+	norm := vectorNorm(query)
+	if norm == 0 {
+		return preparedQuery{}, fmt.Errorf("query vector has zero length")
+	}
+	unit := make([]float32, m.dim)
+	for i, v := range query {
+		unit[i] = v / norm
+	}
+	return preparedQuery{vec: query, unit: unit, norm: norm}, nil
+}
+
+// scoreRange scores the len(out) rows starting at row from.
+func (m *Matrix) scoreRange(q preparedQuery, metric Metric, from int, out []float32) {
+	if metric == Manhattan {
+		for i := range out {
+			out[i] = l1Scaled(q.vec, m.Row(from+i), m.norms[from+i])
+		}
+		return
+	}
+
+	dot := dotKernel
+	for i := range out {
+		out[i] = dot(q.unit, m.data[(from+i)*m.dim:(from+i+1)*m.dim])
+	}
+	switch metric {
+	case Dot:
+		for i := range out {
+			out[i] *= q.norm * m.norms[from+i]
+		}
+	case Euclidean:
+		// |q|²+|d|²-2|q||d|cos cancels for near-identical vectors, where the
+		// rounding error of cos outweighs the distance, so rows that close
+		// are measured directly.
+		qn := float64(q.norm)
+		for i, cos := range out {
+			if cos > 0.99 {
+				out[i] = l2Scaled(q.vec, m.Row(from+i), m.norms[from+i])
+				continue
+			}
+			n := float64(m.norms[from+i])
+			out[i] = float32(math.Sqrt(max(0, qn*qn+n*n-2*qn*n*float64(cos))))
+		}
+	}
+}
+
+func vectorNorm(v []float32) float32 {
+	return float32(math.Sqrt(float64(dotGeneric(v, v))))
+}
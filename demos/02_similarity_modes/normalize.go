@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Normalizer turns the raw scores of one query under a metric into scores
+// where higher is better and a threshold means the same for every metric.
+type Normalizer interface {
+	Name() string
+	Normalize(metric Metric, raw []float32) ([]float32, error)
+}
+
+// MinMax rescales each query's scores to [0, 1]: the best becomes 1 and the
+// worst 0. Cheap and always defined, but relative - the best match of a query
+// with no good matches still scores 1.
+type MinMax struct{}
+
+func (MinMax) Name() string { return "min-max" }
+
+func (MinMax) Normalize(metric Metric, raw []float32) ([]float32, error) {
+	out := oriented(metric, raw)
+	lo, hi := float32(math.Inf(1)), float32(math.Inf(-1))
+	for _, s := range out {
+		lo, hi = min(lo, s), max(hi, s)
+	}
+	for i, s := range out {
+		if hi > lo {
+			out[i] = (s - lo) / (hi - lo)
+		} else {
+			out[i] = 1
+		}
+	}
+	return out, nil
+}
+
+// ZScore standardizes each query's scores by their mean and standard
+// deviation and maps them to [0, 1] with the normal CDF, so a score is
+// roughly the share of this query's candidates that score lower. Unlike
+// MinMax it isn't pulled around by a single outlier.
+type ZScore struct{}
+
+func (ZScore) Name() string { return "z-score" }
+
+func (ZScore) Normalize(metric Metric, raw []float32) ([]float32, error) {
+	out := oriented(metric, raw)
+	var mean, variance float64
+	for _, s := range out {
+		mean += float64(s)
+	}
+	mean /= float64(len(out))
+	for _, s := range out {
+		variance += (float64(s) - mean) * (float64(s) - mean)
+	}
+	std := math.Sqrt(variance / float64(len(out)))
+	for i, s := range out {
+		z := 0.0
+		if std > 0 {
+			z = (float64(s) - mean) / std
+		}
+		out[i] = float32(0.5 * math.Erfc(-z/math.Sqrt2))
+	}
+	return out, nil
+}
+
+// Calibrated maps raw scores to the probability that a document is relevant,
+// with a sigmoid per metric fitted to labelled examples by FitSigmoid (Platt
+// scaling). It is the only normalizer whose scores mean the same across
+// queries as well as across metrics: 0.8 is "80% of documents scoring like
+// this were relevant".
+type Calibrated map[Metric]Sigmoid
+
+func (Calibrated) Name() string { return "calibrated" }
+
+func (c Calibrated) Normalize(metric Metric, raw []float32) ([]float32, error) {
+	sig, ok := c[metric]
+	if !ok {
+		return nil, fmt.Errorf("no calibration for %v", metric)
+	}
+	out := make([]float32, len(raw))
+	for i, s := range raw {
+		out[i] = float32(sig.Probability(float64(s)))
+	}
+	return out, nil
+}
+
+// UnitCosine converts every metric to the cosine similarity it implies for
+// unit vectors, where the dot product is the cosine and Euclidean distance d
+// is √(2 - 2·cosine), so cosine = 1 - d²/2. Scores stay in [-1, 1] and a
+// cosine threshold carries over exactly. Manhattan distance has no such
+// relation, and vectors that aren't normalized give wrong answers.
+type UnitCosine struct{}
+
+func (UnitCosine) Name() string { return "unit cosine" }
+
+func (UnitCosine) Normalize(metric Metric, raw []float32) ([]float32, error) {
+	out := make([]float32, len(raw))
+	for i, s := range raw {
+		switch metric {
+		case Cosine, Dot:
+			out[i] = s
+		case Euclidean:
+			out[i] = EuclideanToCosine(s)
+		default:
+			return nil, fmt.Errorf("%v distance can't be converted to cosine similarity", metric)
+		}
+	}
+	return out, nil
+}
+
+// Reciprocal maps distances to 1/(1+d) and leaves similarities as they are.
+// That is what this demo used to print as "similarity", and it's kept as the
+// baseline the others are measured against: its scores depend on the scale
+// of each metric, so no threshold means the same for two of them.
+type Reciprocal struct{}
+
+func (Reciprocal) Name() string { return "1/(1+d)" }
+
+func (Reciprocal) Normalize(metric Metric, raw []float32) ([]float32, error) {
+	out := make([]float32, len(raw))
+	for i, s := range raw {
+		if metric.IsDistance() {
+			s = 1 / (1 + s)
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+// EuclideanToCosine returns the cosine similarity of two unit vectors at
+// Euclidean distance d.
+func EuclideanToCosine(d float32) float32 { return 1 - d*d/2 }
+
+// CosineToEuclidean returns the Euclidean distance of two unit vectors with
+// cosine similarity c.
+func CosineToEuclidean(c float32) float32 {
+	return float32(math.Sqrt(float64(max(0, 2-2*c))))
+}
+
+// oriented returns a copy of raw where higher is better.
+func oriented(metric Metric, raw []float32) []float32 {
+	out := make([]float32, len(raw))
+	for i, s := range raw {
+		if metric.IsDistance() {
+			s = -s
+		}
+		out[i] = s
+	}
+	return out
+}
+
+// Sigmoid is P(relevant | score) = 1 / (1 + exp(A·score + B)).
+type Sigmoid struct{ A, B float64 }
+
+func (s Sigmoid) Probability(score float64) float64 {
+	return 1 / (1 + math.Exp(s.A*score+s.B))
+}
+
+// FitSigmoid fits a sigmoid to raw scores labelled relevant or not, with
+// Platt's method: Newton's method on the log loss, against targets pulled
+// slightly away from 0 and 1 so a separable sample doesn't diverge.
+func FitSigmoid(scores []float32, relevant []bool) (Sigmoid, error) {
+	var pos, neg float64
+	for _, r := range relevant {
+		if r {
+			pos++
+		} else {
+			neg++
+		}
+	}
+	if len(scores) != len(relevant) || pos == 0 || neg == 0 {
+		return Sigmoid{}, errors.New("calibration needs both relevant and irrelevant examples")
+	}
+	hiTarget, loTarget := (pos+1)/(pos+2), 1/(neg+2)
+
+	s := Sigmoid{A: 0, B: math.Log((neg + 1) / (pos + 1))}
+	for range 100 {
+		// Gradient and Hessian of the log loss in (A, B)
+		var gA, gB, hAA, hAB, hBB float64
+		for i, score := range scores {
+			x := float64(score)
+			t := loTarget
+			if relevant[i] {
+				t = hiTarget
+			}
+			p := s.Probability(x)
+			d := t - p // the derivative of the loss w.r.t. A·x+B
+			w := max(p*(1-p), 1e-12)
+			gA += x * d
+			gB += d
+			hAA += x * x * w
+			hAB += x * w
+			hBB += w
+		}
+		det := hAA*hBB - hAB*hAB
+		if det <= 0 {
+			break
+		}
+		dA := (hBB*gA - hAB*gB) / det
+		dB := (hAA*gB - hAB*gA) / det
+		s.A -= dA
+		s.B -= dB
+		if math.Abs(dA) < 1e-10 && math.Abs(dB) < 1e-10 {
+			break
+		}
+	}
+	if math.IsNaN(s.A) || math.IsNaN(s.B) {
+		return Sigmoid{}, errors.New("calibration didn't converge")
+	}
+	return s, nil
+}
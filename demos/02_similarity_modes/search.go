@@ -0,0 +1,104 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Match is a row found by Matrix.Search.
+type Match struct {
+	Index      int // row in the matrix
+	ID         string
+	Score      float32 // a similarity, or a distance for distance metrics
+	Normalized float32 // set by SearchNormalized
+}
+
+// searchChunk is how many rows a worker scores before selecting from them,
+// small enough for the scores to stay in L1 cache.
+const searchChunk = 1024
+
+// Search returns the k rows that match query best under metric, best first;
+// equal scores are ordered by row. The rows are split into one contiguous
+// range per worker, each worker keeps its own top k, and the partial results
+// are merged at the end - O(n log k) in total instead of sorting all n
+// scores. workers <= 0 means GOMAXPROCS.
+func (m *Matrix) Search(query []float32, metric Metric, k, workers int) ([]Match, error) {
+	q, err := m.prepare(query, metric)
+	if err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = max(1, min(workers, m.Len()/searchChunk))
+	better := betterMatch(metric)
+
+	partial := make([]*TopK[Match], workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		from, to := m.Len()*w/workers, m.Len()*(w+1)/workers
+		partial[w] = NewTopK(k, better)
+		wg.Add(1)
+		go func(top *TopK[Match]) {
+			defer wg.Done()
+			scores := make([]float32, searchChunk)
+			for start := from; start < to; start += searchChunk {
+				chunk := scores[:min(searchChunk, to-start)]
+				m.scoreRange(q, metric, start, chunk)
+				for i, score := range chunk {
+					top.Push(Match{Index: start + i, Score: score})
+				}
+			}
+		}(partial[w])
+	}
+	wg.Wait()
+
+	top := partial[0]
+	for _, p := range partial[1:] {
+		top.Merge(p)
+	}
+	matches := top.Sorted()
+	for i := range matches {
+		matches[i].ID = m.ids[matches[i].Index]
+	}
+	return matches, nil
+}
+
+// betterMatch orders matches best first for metric, breaking ties by row.
+func betterMatch(metric Metric) func(a, b Match) bool {
+	if metric.IsDistance() {
+		return func(a, b Match) bool {
+			return a.Score < b.Score || (a.Score == b.Score && a.Index < b.Index)
+		}
+	}
+	return func(a, b Match) bool {
+		return a.Score > b.Score || (a.Score == b.Score && a.Index < b.Index)
+	}
+}
+
+// SearchNormalized is Search with scores normalized by norm: it returns up to
+// k rows whose normalized score is at least minScore, best first. Since all
+// scores are normalized together, it always scores every row.
+func (m *Matrix) SearchNormalized(query []float32, metric Metric, norm Normalizer, minScore float32, k int) ([]Match, error) {
+	raw, err := m.Scores(query, metric, nil)
+	if err != nil {
+		return nil, err
+	}
+	normalized, err := norm.Normalize(metric, raw)
+	if err != nil {
+		return nil, err
+	}
+	top := NewTopK(k, func(a, b Match) bool {
+		return a.Normalized > b.Normalized || (a.Normalized == b.Normalized && a.Index < b.Index)
+	})
+	for i, s := range normalized {
+		if s >= minScore {
+			top.Push(Match{Index: i, Score: raw[i], Normalized: s})
+		}
+	}
+	matches := top.Sorted()
+	for i := range matches {
+		matches[i].ID = m.ids[matches[i].Index]
+	}
+	return matches, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import "slices"
+
+// TopK keeps the k best of a stream of items in O(log k) per item, without
+// holding on to the rest. It is a bounded min-heap: the worst of the kept
+// items sits at the root, so a new item only has to beat the root to get in.
+type TopK[T any] struct {
+	k      int
+	better func(a, b T) bool
+	heap   []T
+}
+
+// NewTopK returns a selector for the k best items, where better(a, b)
+// reports whether a ranks above b. Ties must be broken inside better for the
+// result to be deterministic.
+func NewTopK[T any](k int, better func(a, b T) bool) *TopK[T] {
+	return &TopK[T]{k: k, better: better, heap: make([]T, 0, max(k, 0))}
+}
+
+// Push offers an item.
+func (t *TopK[T]) Push(item T) {
+	if len(t.heap) < t.k {
+		t.heap = append(t.heap, item)
+		t.up(len(t.heap) - 1)
+		return
+	}
+	if t.k > 0 && t.better(item, t.heap[0]) {
+		t.heap[0] = item
+		t.down(0)
+	}
+}
+
+// Merge pushes every item of other, which is left unchanged.
+func (t *TopK[T]) Merge(other *TopK[T]) {
+	for _, item := range other.heap {
+		t.Push(item)
+	}
+}
+
+// Len returns the number of items kept, at most k.
+func (t *TopK[T]) Len() int { return len(t.heap) }
+
+// Sorted returns the kept items, best first.
+func (t *TopK[T]) Sorted() []T {
+	items := slices.Clone(t.heap)
+	slices.SortFunc(items, func(a, b T) int {
+		switch {
+		case t.better(a, b):
+			return -1
+		case t.better(b, a):
+			return 1
+		}
+		return 0
+	})
+	return items
+}
+
+// up and down restore the heap property with the worst item at the root.
+func (t *TopK[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !t.better(t.heap[parent], t.heap[i]) {
+			return
+		}
+		t.heap[parent], t.heap[i] = t.heap[i], t.heap[parent]
+		i = parent
+	}
+}
+
+func (t *TopK[T]) down(i int) {
+	for {
+		worst, left, right := i, 2*i+1, 2*i+2
+		if left < len(t.heap) && t.better(t.heap[worst], t.heap[left]) {
+			worst = left
+		}
+		if right < len(t.heap) && t.better(t.heap[worst], t.heap[right]) {
+			worst = right
+		}
+		if worst == i {
+			return
+		}
+		t.heap[worst], t.heap[i] = t.heap[i], t.heap[worst]
+		i = worst
+	}
+}
@@ -163,7 +163,9 @@ func loadAndQuery(ctx context.Context, dbPath string) {
 	for name, collection := range collections {
 		fmt.Printf("   - %s (%d documents)\n", name, collection.Count())
 
-		// Get the collection (we need to provide a dummy embedding function)
+		// Get the collection. Passing nil binds chromem-go's default (OpenAI)
+		// embedding function, so we stick to QueryEmbedding here - see
+		// 11_embedder_registry for text queries on reloaded collections
 		coll := db.GetCollection(name, nil)
 		if coll == nil {
 			continue
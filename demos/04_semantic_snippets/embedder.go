@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// embedderFlags are the flags that pick an embedder, shared by the demo and
+// the REPL.
+type embedderFlags struct {
+	ollamaURL, model string
+	local            bool
+	fixture, record  string
+}
+
+func (f *embedderFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.ollamaURL, "ollama", "", "Ollama server, defaults to $OLLAMA_HOST or http://localhost:11434")
+	fs.StringVar(&f.model, "model", "nomic-embed-text", "Ollama embedding model")
+	fs.BoolVar(&f.local, "local", false, "use the built-in local embedder even if Ollama is available")
+	fs.StringVar(&f.fixture, "fixture", "", "replay Ollama embeddings recorded with -record instead of calling Ollama")
+	fs.StringVar(&f.record, "record", "", "record Ollama's embeddings to this file for -fixture")
+}
+
+// Embedder is the embedding function the flags picked.
+type Embedder struct {
+	Func        chromem.EmbeddingFunc
+	Name        string
+	Concurrency int       // for AddDocuments
+	Ollama      *Ollama   // nil for the built-in embedder
+	Recorder    *Recorder // set with -record
+
+	server *httptest.Server
+}
+
+// open picks an embedder: Ollama if it's there, the built-in one if not.
+// With -fixture or -record Ollama is required.
+func (f *embedderFlags) open(ctx context.Context) (*Embedder, error) {
+	e := &Embedder{Func: newLocalEmbeddingFunc(), Name: "built-in local embedder", Concurrency: runtime.NumCPU()}
+	if f.local {
+		return e, nil
+	}
+
+	cfg := OllamaConfig{BaseURL: f.ollamaURL, Model: f.model}
+	switch {
+	case f.fixture != "":
+		fixture, err := LoadFixture(f.fixture)
+		if err != nil {
+			return nil, err
+		}
+		e.server = NewFixtureServer(fixture)
+		cfg.BaseURL, cfg.Model = e.server.URL, fixture.Model
+		fmt.Printf("🎞️  Replaying %d recorded %s embeddings from %s\n", len(fixture.Embeddings), fixture.Model, f.fixture)
+	case f.record != "":
+		e.Recorder = NewRecorder()
+		cfg.HTTPClient = &http.Client{Transport: e.Recorder}
+	}
+
+	ollama := NewOllama(cfg)
+	if err := ollama.Available(ctx, time.Second); err != nil {
+		ollama.Close()
+		if f.fixture != "" || f.record != "" {
+			e.Close()
+			return nil, err
+		}
+		fmt.Printf("⚠️  %v\n", err)
+		fmt.Println("   Falling back to the built-in local embedder")
+		return e, nil
+	}
+	e.Ollama = ollama
+	e.Func = ollama.EmbeddingFunc()
+	e.Concurrency = ollama.Concurrency()
+	e.Name = "Ollama " + ollama.Model()
+	return e, nil
+}
+
+// Close stops Ollama's workers and the fixture server, if any.
+func (e *Embedder) Close() {
+	if e.Ollama != nil {
+		e.Ollama.Close()
+	}
+	if e.server != nil {
+		e.server.Close()
+	}
+}
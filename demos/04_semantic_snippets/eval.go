@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// evalQuery is a query with the snippets that answer it, judged by hand.
+type evalQuery struct {
+	query    string
+	relevant []string
+}
+
+// evalQueries are short queries, the kind expansion is for, over the
+// documentation snippets. synonyms.txt wasn't written for them: ten have a
+// word it has an entry for, like "k8s" or "crash", and five don't, like
+// "security" or "layout", so the harness shows what expansion costs where it
+// can't help as well as where it can.
+var evalQueries = []evalQuery{
+	{"monitoring", []string{"do-003", "perf-001", "debug-001", "debug-003"}},
+	{"observability", []string{"do-003", "debug-001", "debug-003"}},
+	{"deploy", []string{"do-001", "do-002", "db-003"}},
+	{"k8s", []string{"do-001"}},
+	{"ci", []string{"do-002"}},
+	{"auth", []string{"sec-001", "be-001"}},
+	{"security", []string{"sec-001", "sec-002", "sec-003", "be-004"}},
+	{"caching", []string{"be-003", "db-001"}},
+	{"slow queries", []string{"db-001", "perf-001"}},
+	{"tests", []string{"test-001", "test-002", "test-003"}},
+	{"backup", []string{"db-002"}},
+	{"crash", []string{"debug-001", "debug-002"}},
+	{"layout", []string{"fe-001"}},
+	{"speed up web pages", []string{"fe-003", "perf-001"}},
+	{"state management", []string{"fe-002"}},
+}
+
+// runEval implements `go run . eval`: it searches for evalQueries as typed,
+// with synonyms, with relevance feedback and with both, then as typed with
+// every reranker reordering the top candidates, and scores each way by how
+// many relevant snippets come back and how high.
+func runEval(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	k := fs.Int("k", 5, "results per query")
+	synonymsPath := fs.String("synonyms", defaultSynonymsPath, "synonym dictionary")
+	feedback := fs.Int("prf", 3, "top results averaged into the query vector")
+	beta := fs.Float64("beta", 0.75, "weight of the feedback against the query's 1")
+	verbose := fs.Bool("v", false, "show every query's recall")
+	var flags embedderFlags
+	flags.register(fs)
+	var rerank rerankerFlags
+	rerank.register(fs)
+	fs.Parse(args)
+	if *k <= 0 || *feedback <= 0 {
+		return fmt.Errorf("-k and -prf must be positive")
+	}
+	if err := rerank.validate(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	embedder, err := flags.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer embedder.Close()
+	synonyms, err := LoadSynonyms(*synonymsPath)
+	if err != nil {
+		return err
+	}
+
+	collection, err := chromem.NewDB().CreateCollection("docs", nil, embedder.Func)
+	if err != nil {
+		return err
+	}
+	if err := collection.AddDocuments(ctx, createDocumentationSnippets(), embedder.Concurrency); err != nil {
+		return err
+	}
+
+	type config struct {
+		name     string
+		expander *Expander
+		reranker Reranker // nil to keep the search's order
+	}
+	configs := []config{
+		{name: "as typed", expander: NewExpander(embedder.Func, nil, 0)},
+		{name: "synonyms", expander: NewExpander(embedder.Func, synonyms, 0)},
+		{name: "prf", expander: NewExpander(embedder.Func, nil, *feedback)},
+		{name: "synonyms + prf", expander: NewExpander(embedder.Func, synonyms, *feedback)},
+	}
+	for _, c := range configs {
+		c.expander.Beta = float32(*beta)
+	}
+	for _, name := range rerankerNames {
+		reranker, stop, err := rerank.open(name, embedder.Func)
+		if err != nil {
+			return err
+		}
+		defer stop()
+		configs = append(configs, config{name: "rerank " + name, expander: configs[0].expander, reranker: reranker})
+	}
+
+	fmt.Printf("📏 %d queries over %d snippets, embedded with the %s\n", len(evalQueries), collection.Count(), embedder.Name)
+	fmt.Printf("   recall@%d: relevant snippets found · MRR: 1/rank of the first · nDCG@%d: how high they all are\n", *k, *k)
+	fmt.Printf("   rerankers reorder the top %d as typed", max(*k, rerank.candidates))
+	if rerank.url == "" {
+		fmt.Print("; http is a local fake scoring by BM25")
+	}
+	fmt.Print("\n\n")
+	fmt.Printf("   %-16s %9s %7s %8s\n", "", fmt.Sprintf("recall@%d", *k), "MRR", fmt.Sprintf("nDCG@%d", *k))
+	perQuery := make([][]float64, len(configs))
+	for ci, c := range configs {
+		var recall, mrr, ndcg float64
+		for _, q := range evalQueries {
+			fetch := *k
+			if c.reranker != nil {
+				fetch = max(*k, rerank.candidates)
+			}
+			results, _, err := c.expander.Search(ctx, collection, q.query, fetch, nil, nil)
+			if err != nil {
+				return err
+			}
+			if c.reranker != nil {
+				reranked, err := RerankTop(ctx, c.reranker, q.query, results, fetch, *k)
+				if err != nil {
+					return err
+				}
+				results = results[:0]
+				for _, r := range reranked {
+					results = append(results, r.Result)
+				}
+			}
+			r, rr, n := scoreResults(results, q.relevant, *k)
+			recall += r
+			mrr += rr
+			ndcg += n
+			perQuery[ci] = append(perQuery[ci], r)
+		}
+		n := float64(len(evalQueries))
+		fmt.Printf("   %-16s %9.2f %7.2f %8.2f\n", c.name, recall/n, mrr/n, ndcg/n)
+	}
+
+	if *verbose {
+		fmt.Printf("\n   recall@%d by query\n", *k)
+		fmt.Printf("   %-20s", "")
+		for _, c := range configs {
+			fmt.Printf(" %15s", c.name)
+		}
+		fmt.Println()
+		for qi, q := range evalQueries {
+			fmt.Printf("   %-20s", q.query)
+			for ci := range configs {
+				fmt.Printf(" %15.2f", perQuery[ci][qi])
+			}
+			fmt.Println()
+		}
+	}
+
+	fmt.Println("\n🎯 Key Insights:")
+	fmt.Println("   • Synonyms give a short query the words its answers use, which only helps where the dictionary has them")
+	fmt.Println("   • Feedback assumes the top results are relevant: it pulls in their neighbours, right or wrong")
+	fmt.Println("   • Expanded scores are higher because the query moved towards the documents, not because they match better")
+	fmt.Println("   • A reranker only reorders what the search found: it can lift a relevant snippet into the top k, never add one")
+	fmt.Println("\n💡 Try: go run . eval -v -prf 1 -beta 0.5, or -candidates 20 -lambda 0.5")
+	return nil
+}
+
+// scoreResults returns the share of relevant IDs in results, the reciprocal
+// rank of the first one, and the nDCG of the first k with binary relevance.
+func scoreResults(results []chromem.Result, relevant []string, k int) (recall, rr, ndcg float64) {
+	isRelevant := make(map[string]bool, len(relevant))
+	for _, id := range relevant {
+		isRelevant[id] = true
+	}
+	var found int
+	var dcg, ideal float64
+	for i, r := range results[:min(k, len(results))] {
+		if !isRelevant[r.ID] {
+			continue
+		}
+		found++
+		dcg += 1 / math.Log2(float64(i+2))
+		if rr == 0 {
+			rr = 1 / float64(i+1)
+		}
+	}
+	for i := range min(k, len(relevant)) {
+		ideal += 1 / math.Log2(float64(i+2))
+	}
+	return float64(found) / float64(len(relevant)), rr, dcg / ideal
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Synonyms maps a lowercased word to the words and phrases a query that uses
+// it should also look for.
+type Synonyms map[string][]string
+
+// defaultSynonymsPath is the -synonyms default. The dictionary there is also
+// built into the binary, so the demo runs from any directory.
+const defaultSynonymsPath = "synonyms.txt"
+
+//go:embed synonyms.txt
+var defaultSynonyms string
+
+// LoadSynonyms reads a synonym dictionary: one word per line, a colon and the
+// synonyms it expands to, separated by commas. Expansion is one way, so
+// "monitoring: metrics" doesn't make "metrics" look for monitoring. Blank
+// lines and lines starting with "#" are skipped.
+//
+//	monitoring: metrics, observability
+//
+// If path is the default and doesn't exist, the built-in copy is read.
+func LoadSynonyms(path string) (Synonyms, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) && path == defaultSynonymsPath {
+		return parseSynonyms(strings.NewReader(defaultSynonyms), path)
+	} else if err != nil {
+		return nil, fmt.Errorf("couldn't open synonyms: %w", err)
+	}
+	defer f.Close()
+	return parseSynonyms(f, path)
+}
+
+func parseSynonyms(r io.Reader, path string) (Synonyms, error) {
+	synonyms := make(Synonyms)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, list, ok := strings.Cut(line, ":")
+		word = strings.ToLower(strings.TrimSpace(word))
+		if !ok || word == "" || strings.IndexFunc(word, isWordSeparator) >= 0 {
+			return nil, fmt.Errorf("%s:%d: want \"word: synonym, synonym\", not %q", path, n, line)
+		}
+		for _, syn := range strings.Split(list, ",") {
+			if syn = strings.ToLower(strings.TrimSpace(syn)); syn != "" && !slices.Contains(synonyms[word], syn) {
+				synonyms[word] = append(synonyms[word], syn)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read synonyms: %w", err)
+	}
+	return synonyms, nil
+}
+
+// Expand returns the synonyms of the words of query that query doesn't
+// already contain, in the order they're found.
+func (s Synonyms) Expand(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), isWordSeparator)
+	var added []string
+	for _, word := range words {
+		for _, syn := range s[word] {
+			if !slices.Contains(words, syn) && !slices.Contains(added, syn) {
+				added = append(added, syn)
+			}
+		}
+	}
+	return added
+}
+
+// Expander rewrites queries before they're searched: it appends synonyms to
+// the query text, which changes the words the query is embedded and
+// highlighted with, and moves the query vector towards the top results of a
+// first search - Rocchio's relevance feedback, with the top results assumed
+// relevant rather than judged so.
+type Expander struct {
+	Embed    chromem.EmbeddingFunc
+	Synonyms Synonyms // nil for none
+	// Feedback is how many of the first search's results are averaged into
+	// the query vector, 0 for no second search.
+	Feedback int
+	// Alpha and Beta weigh the query vector and the average of the feedback
+	// results: q' = Alpha·q + Beta·mean(d).
+	Alpha, Beta float32
+}
+
+// NewExpander returns an expander with Rocchio's usual weights, 1 for the
+// query and 0.75 for the feedback.
+func NewExpander(embed chromem.EmbeddingFunc, synonyms Synonyms, feedback int) *Expander {
+	return &Expander{Embed: embed, Synonyms: synonyms, Feedback: feedback, Alpha: 1, Beta: 0.75}
+}
+
+// Expansion is how a query was rewritten.
+type Expansion struct {
+	Query    string   `json:"query"`
+	Text     string   `json:"text"`               // the query with its synonyms, as embedded
+	Added    []string `json:"added,omitempty"`    // the synonyms
+	Feedback []string `json:"feedback,omitempty"` // the IDs of the results averaged in
+}
+
+// Vector returns the normalized vector to search for query with: the
+// embedding of the query and its synonyms, moved towards the results of a
+// first search with it if e.Feedback is set. The first search uses the same
+// filters as the second, so feedback never comes from documents the second
+// can't return.
+func (e *Expander) Vector(ctx context.Context, collection *chromem.Collection, query string, where, whereDocument map[string]string) ([]float32, *Expansion, error) {
+	exp := &Expansion{Query: query, Text: query, Added: e.Synonyms.Expand(query)}
+	if len(exp.Added) > 0 {
+		exp.Text += " " + strings.Join(exp.Added, " ")
+	}
+	vec, err := e.Embed(ctx, exp.Text)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't embed query: %w", err)
+	}
+	vec = normalized(vec)
+	if e.Feedback <= 0 || collection.Count() == 0 {
+		return vec, exp, nil
+	}
+
+	first, err := collection.QueryEmbedding(ctx, vec, min(e.Feedback, collection.Count()), where, whereDocument)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't run the feedback search: %w", err)
+	}
+	if len(first) == 0 {
+		return vec, exp, nil
+	}
+	moved := make([]float32, len(vec))
+	for i := range vec {
+		moved[i] = e.Alpha * vec[i]
+	}
+	for _, r := range first {
+		exp.Feedback = append(exp.Feedback, r.ID)
+		for i, x := range r.Embedding {
+			moved[i] += e.Beta * x / float32(len(first))
+		}
+	}
+	return normalized(moved), exp, nil
+}
+
+// Search returns the best n results for query, expanded.
+func (e *Expander) Search(ctx context.Context, collection *chromem.Collection, query string, n int, where, whereDocument map[string]string) ([]chromem.Result, *Expansion, error) {
+	vec, exp, err := e.Vector(ctx, collection, query, where, whereDocument)
+	if err != nil {
+		return nil, nil, err
+	}
+	results, err := collection.QueryEmbedding(ctx, vec, min(n, collection.Count()), where, whereDocument)
+	if err != nil {
+		return nil, nil, err
+	}
+	return results, exp, nil
+}
+
+// String describes the expansion in one line, or returns "" if there was
+// none.
+func (exp *Expansion) String() string {
+	var parts []string
+	if len(exp.Added) > 0 {
+		parts = append(parts, "synonyms "+strings.Join(exp.Added, ", "))
+	}
+	if len(exp.Feedback) > 0 {
+		parts = append(parts, "feedback from "+strings.Join(exp.Feedback, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Explainer reports why results ranked where they did, as text under each
+// result or as one JSON document per query.
+type Explainer struct {
+	Format string // "text" or "json"
+	Embed  chromem.EmbeddingFunc
+	// Local is set for the built-in embedder, whose dimensions can be traced
+	// back to the words and trigrams that hash to them.
+	Local bool
+	Out   io.Writer
+}
+
+// QueryExplanation explains the results of one query.
+type QueryExplanation struct {
+	Query      string            `json:"query"`
+	Metric     string            `json:"metric"`
+	Where      map[string]string `json:"where,omitempty"`
+	Contains   string            `json:"contains,omitempty"`
+	Candidates int               `json:"candidates"` // documents that passed the filters
+	Expansion  *Expansion        `json:"expansion,omitempty"`
+	Reranker   string            `json:"reranker,omitempty"`
+	Results    []Explanation     `json:"results"`
+}
+
+// Explanation explains one result.
+type Explanation struct {
+	Rank int    `json:"rank"`
+	ID   string `json:"id"`
+	// Scores are the similarity or distance under every metric, of the query
+	// and the document as chromem-go stores it, normalized.
+	Scores map[string]float32 `json:"scores"`
+	// Normalized is the score under the ranking metric rescaled over all
+	// candidates, so 1 is the best of them and 0 the worst.
+	Normalized float32 `json:"normalized"`
+	// Filters says how the document passed each filter.
+	Filters []string `json:"filters"`
+	// TopDimensions are the dimensions that added most to the cosine
+	// similarity, which is the sum of the products of all of them.
+	TopDimensions []DimensionContribution `json:"top_dimensions"`
+	// Source is where the result came from. chromem-go compares the query
+	// with every document, so there's no index to come from.
+	Source string `json:"source"`
+	// SearchRank and RerankScore are set when a Reranker reordered the
+	// results: the rank the search gave it and the reranker's score.
+	SearchRank  int      `json:"search_rank,omitempty"`
+	RerankScore *float32 `json:"rerank_score,omitempty"`
+}
+
+// DimensionContribution is one dimension's share of a cosine similarity.
+type DimensionContribution struct {
+	Dimension    int     `json:"dimension"`
+	Query        float32 `json:"query"`
+	Document     float32 `json:"document"`
+	Contribution float32 `json:"contribution"`
+	// Features are the words and trigrams the query and the document share in
+	// this dimension, for the built-in embedder. None means the dimension is
+	// shared by hash collision, or comes from relevance feedback.
+	Features []string `json:"features,omitempty"`
+}
+
+// topDimensions is how many dimensions an explanation lists.
+const topDimensions = 5
+
+// Search runs a query like performSemanticSearch does and explains the best
+// n results. It fetches every document that passes the filters, to know how
+// the results compare with the rest.
+func (e *Explainer) Search(ctx context.Context, collection *chromem.Collection, query string, n int, where, whereDocument map[string]string) ([]chromem.Result, *QueryExplanation, error) {
+	vec, err := e.Embed(ctx, query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't embed query: %w", err)
+	}
+	return e.SearchEmbedding(ctx, collection, query, normalized(vec), n, where, whereDocument)
+}
+
+// SearchEmbedding is Search for a query that's already embedded, such as one
+// an Expander rewrote. Like Search, it fetches every document per query, so
+// it suits collections of the size this demo holds.
+func (e *Explainer) SearchEmbedding(ctx context.Context, collection *chromem.Collection, query string, vec []float32, n int, where, whereDocument map[string]string) ([]chromem.Result, *QueryExplanation, error) {
+	var candidates []chromem.Result
+	if count := collection.Count(); count > 0 {
+		var err error
+		candidates, err = collection.QueryEmbedding(ctx, vec, count, where, whereDocument)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	results := candidates[:min(n, len(candidates))]
+	return results, e.Explain(query, vec, "cosine", candidates, results, where, whereDocument), nil
+}
+
+// Explain explains results, the best of candidates under metric, for a
+// normalized query vector.
+func (e *Explainer) Explain(query string, vec []float32, metric string, candidates, results []chromem.Result, where, whereDocument map[string]string) *QueryExplanation {
+	qe := &QueryExplanation{
+		Query:      query,
+		Metric:     metric,
+		Where:      where,
+		Contains:   whereDocument["$contains"],
+		Candidates: len(candidates),
+		Results:    make([]Explanation, 0, len(results)),
+	}
+
+	m := metrics[metric]
+	lo, hi := float32(0), float32(0)
+	for i, c := range candidates {
+		s := m.score(vec, c.Embedding)
+		if i == 0 || s < lo {
+			lo = s
+		}
+		if i == 0 || s > hi {
+			hi = s
+		}
+	}
+
+	var queryFeatures map[int][]string
+	if e.Local {
+		queryFeatures = featuresByDimension(query)
+	}
+	for i, r := range results {
+		exp := Explanation{Rank: i + 1, ID: r.ID, Scores: make(map[string]float32), Normalized: 1, Source: "exhaustive scan"}
+		for name, m := range metrics {
+			exp.Scores[name] = m.score(vec, r.Embedding)
+		}
+		if hi > lo {
+			exp.Normalized = (exp.Scores[metric] - lo) / (hi - lo)
+			if m.distance {
+				exp.Normalized = 1 - exp.Normalized
+			}
+		}
+		exp.Filters = passedFilters(r, where, whereDocument)
+		exp.TopDimensions = contributions(vec, r.Embedding)
+		if e.Local {
+			docFeatures := featuresByDimension(r.Content)
+			for j, d := range exp.TopDimensions {
+				for _, f := range queryFeatures[d.Dimension] {
+					if slices.Contains(docFeatures[d.Dimension], f) {
+						exp.TopDimensions[j].Features = append(exp.TopDimensions[j].Features, f)
+					}
+				}
+			}
+		}
+		qe.Results = append(qe.Results, exp)
+	}
+	return qe
+}
+
+// Rerank reorders the explained results like reranker did, keeping only
+// those it returned. The explanation must cover every candidate it reranked.
+func (qe *QueryExplanation) Rerank(reranker string, reranked []Reranked) {
+	if qe == nil {
+		return
+	}
+	results := make([]Explanation, len(reranked))
+	for i, r := range reranked {
+		exp := qe.Results[r.Rank-1]
+		exp.Rank, exp.SearchRank, exp.RerankScore = i+1, r.Rank, &r.Score
+		results[i] = exp
+	}
+	qe.Reranker, qe.Results = reranker, results
+}
+
+// passedFilters describes how a result passed each filter.
+func passedFilters(r chromem.Result, where, whereDocument map[string]string) []string {
+	filters := []string{}
+	for _, key := range slices.Sorted(maps.Keys(where)) {
+		filters = append(filters, fmt.Sprintf("%s = %q", key, r.Metadata[key]))
+	}
+	for _, op := range slices.Sorted(maps.Keys(whereDocument)) {
+		switch op {
+		case "$contains":
+			filters = append(filters, fmt.Sprintf("contains %q at byte %d", whereDocument[op], strings.Index(r.Content, whereDocument[op])))
+		case "$not_contains":
+			filters = append(filters, fmt.Sprintf("doesn't contain %q", whereDocument[op]))
+		}
+	}
+	return filters
+}
+
+// contributions returns the dimensions whose products added most to the
+// dot product of two vectors.
+func contributions(q, v []float32) []DimensionContribution {
+	dims := make([]DimensionContribution, len(q))
+	for i := range q {
+		dims[i] = DimensionContribution{Dimension: i, Query: q[i], Document: v[i], Contribution: q[i] * v[i]}
+	}
+	slices.SortFunc(dims, func(a, b DimensionContribution) int {
+		return cmp.Or(cmp.Compare(b.Contribution, a.Contribution), cmp.Compare(a.Dimension, b.Dimension))
+	})
+	return dims[:min(topDimensions, len(dims))]
+}
+
+// featuresByDimension groups the built-in embedder's features of text by
+// the dimension they hash to, without the "w:" and "t:" prefixes.
+func featuresByDimension(text string) map[int][]string {
+	byDim := make(map[int][]string)
+	for _, f := range localFeatures(text) {
+		dim, _ := featureDimension(f.name)
+		name := f.name[2:]
+		if !slices.Contains(byDim[dim], name) {
+			byDim[dim] = append(byDim[dim], name)
+		}
+	}
+	return byDim
+}
+
+// Header prints what applies to all results of a query, in text mode.
+func (e *Explainer) Header(qe *QueryExplanation) {
+	if e == nil || e.Format != "text" {
+		return
+	}
+	fmt.Fprintf(e.Out, "🔬 Documents passing the filters: %d, all scored by an exhaustive scan -\n", qe.Candidates)
+	if qe.Reranker == "" {
+		fmt.Fprintln(e.Out, "   chromem-go has no ANN index, so nothing came from one or was re-ranked")
+		return
+	}
+	fmt.Fprintf(e.Out, "   chromem-go has no ANN index; the best of them were re-ranked by %s\n", qe.Reranker)
+}
+
+// Result prints the explanation of the i-th result, in text mode.
+func (e *Explainer) Result(qe *QueryExplanation, i int) {
+	if e == nil || e.Format != "text" {
+		return
+	}
+	exp := qe.Results[i]
+	var scores []string
+	for _, name := range slices.Sorted(maps.Keys(exp.Scores)) {
+		scores = append(scores, fmt.Sprintf("%s %.4f", name, exp.Scores[name]))
+	}
+	fmt.Fprintf(e.Out, "   🔬 %s · normalized %.2f of %d\n", strings.Join(scores, " · "), exp.Normalized, qe.Candidates)
+	if exp.RerankScore != nil {
+		fmt.Fprintf(e.Out, "   🔬 %s %.4f, ranked #%d by the search\n", qe.Reranker, *exp.RerankScore, exp.SearchRank)
+	}
+	if len(exp.Filters) > 0 {
+		fmt.Fprintf(e.Out, "   🔬 passed %s\n", strings.Join(exp.Filters, ", "))
+	}
+	var dims []string
+	for _, d := range exp.TopDimensions {
+		s := fmt.Sprintf("#%d %+.3f", d.Dimension, d.Contribution)
+		switch {
+		case len(d.Features) > 0:
+			s += " (" + strings.Join(d.Features, " ") + ")"
+		case e.Local && qe.Expansion != nil && len(qe.Expansion.Feedback) > 0:
+			s += " (collision or feedback)"
+		case e.Local:
+			s += " (collision)"
+		}
+		dims = append(dims, s)
+	}
+	fmt.Fprintf(e.Out, "   🔬 top dimensions: %s\n", strings.Join(dims, ", "))
+}
+
+// Done prints the whole explanation of a query, in JSON mode.
+func (e *Explainer) Done(qe *QueryExplanation) error {
+	if e == nil || e.Format != "json" {
+		return nil
+	}
+	enc := json.NewEncoder(e.Out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(qe); err != nil {
+		return fmt.Errorf("couldn't encode explanation: %w", err)
+	}
+	return nil
+}
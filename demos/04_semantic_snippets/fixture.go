@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Fixture is a recording of the embeddings an Ollama model returned, keyed by
+// input text. Batches are recorded text by text, so a replay doesn't have to
+// batch the same way as the recording.
+type Fixture struct {
+	Model      string               `json:"model"`
+	Recorded   time.Time            `json:"recorded"`
+	Embeddings map[string][]float32 `json:"embeddings"`
+}
+
+// LoadFixture reads a fixture written by Save.
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read fixture: %w", err)
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("couldn't decode fixture %s: %w", path, err)
+	}
+	if f.Model == "" || len(f.Embeddings) == 0 {
+		return nil, fmt.Errorf("fixture %s has no model or no embeddings", path)
+	}
+	return &f, nil
+}
+
+// Save writes the fixture as JSON, creating its directory if needed.
+func (f *Fixture) Save(path string) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("couldn't encode fixture: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("couldn't write fixture: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("couldn't write fixture: %w", err)
+	}
+	return nil
+}
+
+// Recorder is an http.RoundTripper that passes requests to a real Ollama
+// server and records every successful /api/embed exchange.
+type Recorder struct {
+	Transport http.RoundTripper
+
+	mu      sync.Mutex
+	fixture Fixture
+}
+
+// NewRecorder returns a recorder that uses http.DefaultTransport.
+func NewRecorder() *Recorder {
+	return &Recorder{Transport: http.DefaultTransport, fixture: Fixture{Embeddings: make(map[string][]float32)}}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path != "/api/embed" || req.Body == nil {
+		return r.Transport.RoundTrip(req)
+	}
+	reqBody, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(reqBody))
+
+	resp, err := r.Transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	var in struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	var out struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if json.Unmarshal(reqBody, &in) == nil && json.Unmarshal(respBody, &out) == nil && len(in.Input) == len(out.Embeddings) {
+		r.mu.Lock()
+		r.fixture.Model = in.Model
+		for i, text := range in.Input {
+			r.fixture.Embeddings[text] = out.Embeddings[i]
+		}
+		r.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// Fixture returns everything recorded so far.
+func (r *Recorder) Fixture() *Fixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := Fixture{Model: r.fixture.Model, Recorded: time.Now().UTC(), Embeddings: make(map[string][]float32, len(r.fixture.Embeddings))}
+	for text, vec := range r.fixture.Embeddings {
+		f.Embeddings[text] = vec
+	}
+	return &f
+}
+
+// NewFixtureServer returns a started server that replays f the way Ollama
+// would answer: /api/tags lists the model and /api/embed returns recorded
+// embeddings. Texts that weren't recorded get a 400 naming the text, so a
+// stale fixture fails loudly instead of returning made-up vectors.
+func NewFixtureServer(f *Fixture) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"models": []map[string]string{{"name": f.Model + ":latest"}}})
+	})
+	mux.HandleFunc("POST /api/embed", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if req.Model != f.Model && req.Model != f.Model+":latest" {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("model %q not found, try pulling it first", req.Model)})
+			return
+		}
+		embeddings := make([][]float32, len(req.Input))
+		for i, text := range req.Input {
+			vec, ok := f.Embeddings[text]
+			if !ok {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("fixture has no embedding for %q; record it again", text)})
+				return
+			}
+			embeddings[i] = vec
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"model": f.Model, "embeddings": embeddings})
+	})
+	return httptest.NewServer(mux)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
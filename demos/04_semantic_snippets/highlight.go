@@ -0,0 +1,58 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// stopWords aren't worth highlighting: they match almost every snippet.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"do": true, "for": true, "from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+	"of": true, "on": true, "or": true, "the": true, "to": true, "what": true, "with": true,
+}
+
+// minStem is the shortest word that marks the longer terms it starts.
+const minStem = 4
+
+// queryTerms returns the distinct lowercased words of a query that are worth
+// highlighting.
+func queryTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), isWordSeparator) {
+		if len(word) > 1 && !stopWords[word] && !slices.Contains(terms, word) {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// termSpans returns the byte ranges of the words in text that start with one
+// of the terms, ignoring case, so "deploy" marks "Deployment" too - or that
+// one of the terms starts with, so "debugging" marks "Debug". That only
+// counts for words of at least minStem letters, or "a" would match all.
+func termSpans(text string, terms []string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text + " " {
+		switch {
+		case !isWordSeparator(r) && start < 0:
+			start = i
+		case isWordSeparator(r) && start >= 0:
+			word := strings.ToLower(text[start:i])
+			for _, term := range terms {
+				if strings.HasPrefix(word, term) || len(word) >= minStem && strings.HasPrefix(term, word) {
+					spans = append(spans, [2]int{start, i})
+					break
+				}
+			}
+			start = -1
+		}
+	}
+	return spans
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
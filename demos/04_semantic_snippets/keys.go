@@ -0,0 +1,54 @@
+package main
+
+import "bufio"
+
+// key is a key press read from a terminal in raw mode: a rune, including
+// control characters such as 3 for Ctrl-C, or the name of a key that sends
+// an escape sequence.
+type key struct {
+	r    rune
+	name string // "up", "down", "left", "right", "home", "end", "delete", "pgup", "pgdn", "btab" or "esc"
+}
+
+// escapeKeys maps the escape sequences terminals send, without the ESC, to
+// key names. Some keys have several: xterm and vt220 disagree about Home.
+var escapeKeys = map[string]string{
+	"[A": "up", "OA": "up",
+	"[B": "down", "OB": "down",
+	"[C": "right", "OC": "right",
+	"[D": "left", "OD": "left",
+	"[H": "home", "OH": "home", "[1~": "home", "[7~": "home",
+	"[F": "end", "OF": "end", "[4~": "end", "[8~": "end",
+	"[3~": "delete",
+	"[5~": "pgup",
+	"[6~": "pgdn",
+	"[Z":  "btab",
+}
+
+// readKey reads one key press. An ESC with nothing after it in the buffer is
+// the Escape key itself; terminals send a sequence in one write.
+func readKey(in *bufio.Reader) (key, error) {
+	r, _, err := in.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	if r != 27 {
+		return key{r: r}, nil
+	}
+	if in.Buffered() == 0 {
+		return key{name: "esc"}, nil
+	}
+	var seq []byte
+	for len(seq) < 8 && in.Buffered() > 0 {
+		b, err := in.ReadByte()
+		if err != nil {
+			return key{}, err
+		}
+		seq = append(seq, b)
+		if len(seq) > 1 && (b == '~' || b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z') {
+			break
+		}
+	}
+	// An unknown sequence has an empty name and is ignored
+	return key{name: escapeKeys[string(seq)]}, nil
+}
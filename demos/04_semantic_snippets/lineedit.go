@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned by ReadLine when the user presses Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// LineEditor reads lines from a terminal with Emacs-style editing and a
+// history that persists between sessions:
+//
+//	←/→, Ctrl-B/F      move by character     Ctrl-A/E, Home/End   line start/end
+//	↑/↓, Ctrl-P/N      previous/next line    Ctrl-U/K             delete to start/end
+//	Backspace, Del     delete a character    Ctrl-W               delete a word
+//	Ctrl-L             clear the screen      Ctrl-C / Ctrl-D      cancel / quit
+//
+// When stdin isn't a terminal - a pipe, a file - it reads plain lines, so a
+// session can be scripted.
+type LineEditor struct {
+	in          *bufio.Reader
+	out         *os.File
+	fd          int
+	history     []string
+	historyPath string
+}
+
+// maxHistory is the number of lines kept in the history file.
+const maxHistory = 1000
+
+// NewLineEditor returns an editor on stdin and stdout that loads its history
+// from historyPath, if set, and appends to it.
+func NewLineEditor(historyPath string) *LineEditor {
+	e := &LineEditor{in: bufio.NewReader(os.Stdin), out: os.Stdout, fd: int(os.Stdin.Fd()), historyPath: historyPath}
+	if historyPath != "" {
+		if data, err := os.ReadFile(historyPath); err == nil {
+			e.history = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+		}
+	}
+	return e
+}
+
+// ReadLine prints prompt and returns the line entered, without the newline.
+// It returns io.EOF at the end of input and errInterrupted on Ctrl-C.
+func (e *LineEditor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		// Input that isn't a terminal doesn't echo, so echo it ourselves
+		return e.readPlain(prompt, isNotTerminal(err))
+	}
+	line, err := e.edit(prompt)
+	restore()
+	fmt.Fprint(e.out, "\n")
+	if err == nil {
+		e.remember(line)
+	}
+	return line, err
+}
+
+// readPlain reads a line without editing, echoing it if asked to so the
+// transcript of a scripted session reads like an interactive one.
+func (e *LineEditor) readPlain(prompt string, echo bool) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		fmt.Fprintln(e.out)
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if echo {
+		fmt.Fprintln(e.out, line)
+	}
+	return line, nil
+}
+
+// edit runs the editing loop in raw mode until Enter, Ctrl-C or Ctrl-D.
+func (e *LineEditor) edit(prompt string) (string, error) {
+	var line []rune
+	pos := 0
+	// Browsing the history edits a copy of it; the new line is the last entry
+	entries := append(slices.Clone(e.history), "")
+	current := len(entries) - 1
+
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+		if back := len(line) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	recall := func(i int) {
+		entries[current] = string(line)
+		current = i
+		line = []rune(entries[current])
+		pos = len(line)
+	}
+	redraw()
+
+	for {
+		k, err := readKey(e.in)
+		if err != nil {
+			return "", err
+		}
+		// Named keys do what their Emacs control keys do
+		switch k.name {
+		case "up":
+			k.r = 16
+		case "down":
+			k.r = 14
+		case "left":
+			k.r = 2
+		case "right":
+			k.r = 6
+		case "home":
+			k.r = 1
+		case "end":
+			k.r = 5
+		case "delete":
+			if pos < len(line) {
+				line = slices.Delete(line, pos, pos+1)
+			}
+		}
+		switch r := k.r; r {
+		case 0: // a key without a rune, handled above
+		case '\r', '\n':
+			return string(line), nil
+		case 3: // Ctrl-C
+			return "", errInterrupted
+		case 4: // Ctrl-D quits on an empty line and deletes otherwise
+			if len(line) == 0 {
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = slices.Delete(line, pos, pos+1)
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 2: // Ctrl-B
+			pos = max(pos-1, 0)
+		case 6: // Ctrl-F
+			pos = min(pos+1, len(line))
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 21: // Ctrl-U
+			line = slices.Delete(line, 0, pos)
+			pos = 0
+		case 23: // Ctrl-W
+			start := pos
+			for start > 0 && unicode.IsSpace(line[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(line[start-1]) {
+				start--
+			}
+			line = slices.Delete(line, start, pos)
+			pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			if current > 0 {
+				recall(current - 1)
+			}
+		case 14: // Ctrl-N
+			if current < len(entries)-1 {
+				recall(current + 1)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = slices.Delete(line, pos-1, pos)
+				pos--
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line = slices.Insert(line, pos, r)
+				pos++
+			}
+		}
+		redraw()
+	}
+}
+
+// remember adds a line to the history, skipping blanks and repeats, and
+// appends it to the history file.
+func (e *LineEditor) remember(line string) {
+	if strings.TrimSpace(line) == "" || len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+	if e.historyPath == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(e.historyPath), 0o755); err != nil {
+		return
+	}
+	// History is a convenience, so failing to save it isn't an error
+	os.WriteFile(e.historyPath, []byte(strings.Join(e.history, "\n")+"\n"), 0o600)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// localDimension is the dimension of the built-in local embedder.
+const localDimension = 512
+
+// newLocalEmbeddingFunc returns the built-in embedder used when Ollama isn't
+// available. It needs no model and no network: words and their character
+// trigrams are feature-hashed into 512 dimensions, so "deploy" also matches
+// "deployment". It finds shared words, not shared meaning.
+func newLocalEmbeddingFunc() chromem.EmbeddingFunc {
+	return func(_ context.Context, text string) ([]float32, error) {
+		vec := make([]float32, localDimension)
+		for _, f := range localFeatures(text) {
+			dim, sign := featureDimension(f.name)
+			vec[dim] += sign * f.weight
+		}
+
+		var sum float64
+		for _, v := range vec {
+			sum += float64(v) * float64(v)
+		}
+		if sum == 0 {
+			return nil, fmt.Errorf("text %q has no words to embed", text)
+		}
+		norm := float32(math.Sqrt(sum))
+		for i := range vec {
+			vec[i] /= norm
+		}
+		return vec, nil
+	}
+}
+
+// localFeature is a word ("w:deploy") or a character trigram ("t:dep") with
+// its weight.
+type localFeature struct {
+	name   string
+	weight float32
+}
+
+// localFeatures returns the features of text, words counting twice as much
+// as trigrams.
+func localFeatures(text string) []localFeature {
+	var features []localFeature
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		features = append(features, localFeature{"w:" + word, 1})
+		padded := "^" + word + "$"
+		for i := 0; i+3 <= len(padded); i++ {
+			features = append(features, localFeature{"t:" + padded[i:i+3], 0.5})
+		}
+	}
+	return features
+}
+
+// featureDimension returns the dimension a feature hashes to and its sign:
+// another bit of the hash picks it, so collisions cancel out instead of
+// piling up.
+func featureDimension(feature string) (int, float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	sign := float32(1)
+	if sum&1 == 1 {
+		sign = -1
+	}
+	return int((sum >> 1) % localDimension), sign
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"flag"
 	"fmt"
+	"maps"
+	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -10,15 +15,83 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string) error{
+			"repl": runREPL,
+			"tui":  runTUI,
+			"eval": runEval,
+		}
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	var flags embedderFlags
+	flags.register(flag.CommandLine)
+	var rerank rerankerFlags
+	rerank.register(flag.CommandLine)
+	explain := flag.String("explain", "", "explain every result, as text or json")
+	excerpt := flag.Int("excerpt", 1, "sentences of each result to show, the ones closest to the query; 0 shows all")
+	synonymsPath := flag.String("synonyms", defaultSynonymsPath, "synonym dictionary for query expansion, empty for none")
+	feedback := flag.Int("prf", 3, "top results averaged into the query vector by query expansion, 0 for none")
+	expand := flag.Bool("expand", false, "expand every query, not just the query expansion example")
+	flag.Parse()
+	if *explain != "" && *explain != "text" && *explain != "json" {
+		fmt.Fprintln(os.Stderr, "error: -explain takes text or json")
+		os.Exit(2)
+	}
+	if err := rerank.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+
 	fmt.Println("📚 Semantic Snippets Demo - Real Documentation Search")
 	fmt.Println("====================================================")
 
 	ctx := context.Background()
 	start := time.Now()
 
+	// Pick an embedder: Ollama if it's there, the built-in one if not
+	embedder, err := flags.open(ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer embedder.Close()
+	fmt.Printf("🧠 Embedding with the %s\n", embedder.Name)
+	var display searchDisplay
+	if *explain != "" {
+		display.explainer = &Explainer{Format: *explain, Embed: embedder.Func, Local: embedder.Ollama == nil, Out: os.Stdout}
+	}
+	if *excerpt > 0 {
+		display.snippeter = NewSnippeter(embedder.Func, *excerpt)
+	}
+	var synonyms Synonyms
+	if *synonymsPath != "" {
+		if synonyms, err = LoadSynonyms(*synonymsPath); err != nil {
+			panic(err)
+		}
+	}
+	expander := NewExpander(embedder.Func, synonyms, *feedback)
+	if *expand {
+		display.expander = expander
+	}
+	reranker, stopReranker, err := rerank.open(rerank.name, embedder.Func)
+	if err != nil {
+		panic(err)
+	}
+	defer stopReranker()
+	if rerank.name == "http" && rerank.url == "" {
+		fmt.Println("🧪 Reranking with a local fake server; -rerank-url for a real one")
+	}
+	display.reranker, display.candidates = reranker, rerank.candidates
+
 	// Create database and collection
 	db := chromem.NewDB()
-	collection, err := db.CreateCollection("docs", nil, nil)
+	collection, err := db.CreateCollection("docs", nil, embedder.Func)
 	if err != nil {
 		panic(err)
 	}
@@ -28,14 +101,20 @@ func main() {
 
 	fmt.Printf("📝 Loading %d documentation snippets...\n", len(documents))
 
-	// Add documents to collection
-	err = collection.AddDocuments(ctx, documents, 1)
+	// Add documents to collection. With Ollama, the concurrency lets the
+	// batch workers fill their batches.
+	err = collection.AddDocuments(ctx, documents, embedder.Concurrency)
 	if err != nil {
 		panic(err)
 	}
 
 	loadTime := time.Since(start)
-	fmt.Printf("⚡ Loaded in: %v\n\n", loadTime)
+	fmt.Printf("⚡ Loaded in: %v\n", loadTime)
+	if embedder.Ollama != nil {
+		stats := embedder.Ollama.Stats()
+		fmt.Printf("   %d texts in %d requests to /api/embed (%d retries)\n", stats.Texts, stats.Requests, stats.Retries)
+	}
+	fmt.Println()
 
 	// Interactive search examples
 	searchQueries := []struct {
@@ -52,7 +131,7 @@ func main() {
 	}
 
 	for _, sq := range searchQueries {
-		performSemanticSearch(ctx, collection, sq.query, sq.description, sq.resultCount)
+		performSemanticSearch(ctx, collection, sq.query, sq.description, sq.resultCount, nil, display)
 		fmt.Println()
 	}
 
@@ -61,47 +140,37 @@ func main() {
 	fmt.Println("==========================================")
 
 	// Search within specific categories
-	fmt.Println("\n📋 Backend-only search for 'data processing':")
-	backendResults, err := collection.QueryEmbedding(ctx,
-		[]float32{0.4, 0.8, 0.2, 0.9, 0.3, 0.7, 0.5, 0.6, 0.1, 0.8, 0.4, 0.9, 0.2, 0.7, 0.3, 0.6},
-		3,
-		map[string]string{"category": "backend"},
-		nil)
-	if err != nil {
-		panic(err)
-	}
-
-	for i, result := range backendResults {
-		fmt.Printf("   %d. [%s] Score: %.4f\n", i+1, result.ID, result.Similarity)
-		fmt.Printf("      %s\n", result.Content)
-		fmt.Printf("      Category: %s | Difficulty: %s\n",
-			result.Metadata["category"], result.Metadata["difficulty"])
-	}
-
-	fmt.Println("\n📋 DevOps-only search for 'monitoring':")
-	devopsResults, err := collection.QueryEmbedding(ctx,
-		[]float32{0.6, 0.7, 0.3, 0.8, 0.4, 0.9, 0.2, 0.5, 0.7, 0.6, 0.8, 0.3, 0.9, 0.4, 0.5, 0.2},
-		3,
-		map[string]string{"category": "devops"},
-		nil)
-	if err != nil {
-		panic(err)
-	}
-
-	for i, result := range devopsResults {
-		fmt.Printf("   %d. [%s] Score: %.4f\n", i+1, result.ID, result.Similarity)
-		fmt.Printf("      %s\n", result.Content)
-		fmt.Printf("      Category: %s | Difficulty: %s\n",
-			result.Metadata["category"], result.Metadata["difficulty"])
-	}
+	fmt.Println()
+	performSemanticSearch(ctx, collection, "data processing", "Backend-only search", 3,
+		map[string]string{"category": "backend"}, display)
+
+	fmt.Println()
+	performSemanticSearch(ctx, collection, "monitoring", "DevOps-only search", 3,
+		map[string]string{"category": "devops"}, display)
+
+	// Query expansion: a one-word query has little to match, so it's
+	// rewritten with synonyms and the results of a first search
+	fmt.Println("\n🔁 QUERY EXPANSION - The same search, rewritten")
+	fmt.Println("==============================================")
+	fmt.Println()
+	expanded := display
+	expanded.expander = expander
+	performSemanticSearch(ctx, collection, "monitoring", "DevOps-only search, expanded", 3,
+		map[string]string{"category": "devops"}, expanded)
+
+	// Re-ranking: the search's best candidates, reordered by each reranker
+	fmt.Println("\n🏅 RE-RANKING - The same candidates, another judge")
+	fmt.Println("=================================================")
+	fmt.Println()
+	compareRerankers(ctx, collection, "handle errors in production", &rerank, embedder.Func, 3)
 
 	// Content-based filtering
 	fmt.Println("\n🔍 CONTENT FILTERING - Documents mentioning specific terms")
 	fmt.Println("=========================================================")
 
 	fmt.Println("\n📋 All documents containing 'Docker':")
-	dockerResults, err := collection.QueryEmbedding(ctx,
-		[]float32{0.5, 0.6, 0.4, 0.7, 0.3, 0.8, 0.2, 0.9, 0.1, 0.6, 0.5, 0.7, 0.4, 0.8, 0.3, 0.9},
+	dockerResults, err := collection.Query(ctx,
+		"Docker containers",
 		10,
 		nil,
 		map[string]string{"$contains": "Docker"})
@@ -114,10 +183,8 @@ func main() {
 	} else {
 		for i, result := range dockerResults {
 			fmt.Printf("   %d. [%s] Score: %.4f\n", i+1, result.ID, result.Similarity)
-			// Highlight the Docker mention
-			content := result.Content
-			content = strings.ReplaceAll(content, "Docker", "**Docker**")
-			fmt.Printf("      %s\n", content)
+			// The sentence that matches best, with the words of the query in bold
+			fmt.Printf("      %s\n", display.content(ctx, "Docker containers", result.Content))
 		}
 	}
 
@@ -127,237 +194,315 @@ func main() {
 	fmt.Printf("=========\n")
 	fmt.Printf("📊 Documents: %d\n", len(documents))
 	fmt.Printf("⚡ Total time: %v\n", totalTime)
-	fmt.Printf("🔍 Queries performed: %d\n", len(searchQueries)+3)
-	fmt.Printf("💡 Average query time: ~%.2fms\n", float64(totalTime.Nanoseconds())/float64(len(searchQueries)+3)/1000000)
-	fmt.Printf("🚀 Pure in-memory semantic search - no external services!\n")
+	fmt.Printf("🔍 Queries performed: %d\n", len(searchQueries)+5)
+	fmt.Printf("💡 Average query time: ~%.2fms\n", float64(totalTime.Nanoseconds())/float64(len(searchQueries)+5)/1000000)
+	if embedder.Ollama != nil {
+		fmt.Printf("🚀 In-memory semantic search with real embeddings from a local model\n")
+	} else {
+		fmt.Printf("🚀 Pure in-memory semantic search - no external services!\n")
+	}
+
+	if embedder.Recorder != nil {
+		if err := embedder.Recorder.Fixture().Save(flags.record); err != nil {
+			panic(err)
+		}
+		fmt.Printf("🎞️  Recorded embeddings to %s; replay with -fixture %s\n", flags.record, flags.record)
+	}
+}
+
+// searchDisplay is how performSemanticSearch shows results.
+type searchDisplay struct {
+	explainer *Explainer // nil to not explain
+	snippeter *Snippeter // nil to show whole documents
+	expander  *Expander  // nil to search for queries as they are
+	reranker  Reranker   // nil to keep the search's order
+	// candidates is how many of the search's results the reranker reorders.
+	candidates int
+}
+
+// content returns what to show of a result: its excerpt for the query, or
+// all of it, with the words of the query in bold.
+func (d searchDisplay) content(ctx context.Context, query, content string) string {
+	terms := queryTerms(query)
+	bold := func(word string) string { return "**" + word + "**" }
+	if d.snippeter == nil {
+		return markTerms(content, terms, bold)
+	}
+	excerpt, err := d.snippeter.Excerpt(ctx, query, content)
+	if err != nil {
+		panic(err)
+	}
+	return excerpt.Render(terms, bold)
 }
 
-func performSemanticSearch(ctx context.Context, collection *chromem.Collection, query, description string, count int) {
+func performSemanticSearch(ctx context.Context, collection *chromem.Collection, query, description string, count int, where map[string]string, display searchDisplay) {
+	explainer := display.explainer
 	fmt.Printf("🔍 %s\n", description)
 	fmt.Printf("Query: \"%s\"\n", query)
+	for _, key := range slices.Sorted(maps.Keys(where)) {
+		fmt.Printf("Filter: %s = %s\n", key, where[key])
+	}
 	fmt.Println(strings.Repeat("─", 50))
 
 	start := time.Now()
 
-	// Create a simple embedding for the query (in a real app, you'd use the same model as for documents)
-	queryEmbedding := generateQueryEmbedding(query)
-
-	results, err := collection.QueryEmbedding(ctx, queryEmbedding, count, nil, nil)
+	// The query is embedded with the same model as the documents. Explaining
+	// and expanding embed it separately, to compare it with every document or
+	// to rewrite it first.
+	// A reranker gets more candidates than are shown, to have something to
+	// reorder.
+	n := count
+	if display.reranker != nil {
+		n = max(count, display.candidates)
+	}
+	var results []chromem.Result
+	var explanation *QueryExplanation
+	var expansion *Expansion
+	var err error
+	switch {
+	case display.expander != nil && explainer != nil:
+		var vec []float32
+		if vec, expansion, err = display.expander.Vector(ctx, collection, query, where, nil); err == nil {
+			results, explanation, err = explainer.SearchEmbedding(ctx, collection, expansion.Text, vec, n, where, nil)
+		}
+	case display.expander != nil:
+		results, expansion, err = display.expander.Search(ctx, collection, query, n, where, nil)
+	case explainer != nil:
+		results, explanation, err = explainer.Search(ctx, collection, query, n, where, nil)
+	default:
+		results, err = collection.Query(ctx, query, min(n, collection.Count()), where, nil)
+	}
 	if err != nil {
 		panic(err)
 	}
+	if expansion != nil {
+		// The synonyms are reranked, highlighted and picked excerpts with too
+		query = expansion.Text
+	}
+	var reranked []Reranked
+	if display.reranker != nil {
+		if reranked, err = RerankTop(ctx, display.reranker, query, results, n, count); err != nil {
+			panic(err)
+		}
+		explanation.Rerank(display.reranker.Name(), reranked)
+	}
 
 	queryTime := time.Since(start)
 
+	if expansion != nil {
+		fmt.Printf("🔁 Expanded: %s\n", cmp.Or(expansion.String(), "nothing to add"))
+		if explanation != nil {
+			explanation.Expansion = expansion
+		}
+	}
+	explainer.Header(explanation)
+	if reranked != nil {
+		fmt.Printf("🏅 Reranked by %s, the best %d of %d\n", display.reranker.Name(), len(reranked), len(results))
+		results = results[:0]
+		for _, r := range reranked {
+			results = append(results, r.Result)
+		}
+	}
 	for i, result := range results {
-		fmt.Printf("%d. [%s] Score: %.4f\n", i+1, result.ID, result.Similarity)
-		fmt.Printf("   %s\n", result.Content)
+		if reranked != nil {
+			fmt.Printf("%d. [%s] Score: %.4f (search %.4f, was #%d)\n", i+1, result.ID, reranked[i].Score, result.Similarity, reranked[i].Rank)
+		} else {
+			fmt.Printf("%d. [%s] Score: %.4f\n", i+1, result.ID, result.Similarity)
+		}
+		fmt.Printf("   %s\n", display.content(ctx, query, result.Content))
 		fmt.Printf("   📂 %s | 🎯 %s\n",
 			result.Metadata["category"], result.Metadata["difficulty"])
+		explainer.Result(explanation, i)
+	}
+	if err := explainer.Done(explanation); err != nil {
+		panic(err)
 	}
 
 	fmt.Printf("⚡ Query time: %v\n", queryTime)
 }
 
-// Simple embedding generator based on query content (for demo purposes)
-func generateQueryEmbedding(query string) []float32 {
-	// This is a simplified approach - in reality you'd use the same embedding model
-	// that was used to create the document embeddings
-	hash := 0
-	for _, char := range query {
-		hash = hash*31 + int(char)
+// compareRerankers searches for query once and shows the best k of its
+// candidates as the search ranked them and as every reranker reorders them.
+func compareRerankers(ctx context.Context, collection *chromem.Collection, query string, flags *rerankerFlags, embed chromem.EmbeddingFunc, k int) {
+	candidates, err := collection.Query(ctx, query, min(flags.candidates, collection.Count()), nil, nil)
+	if err != nil {
+		panic(err)
 	}
-
-	// Generate a 16-dimensional embedding based on query characteristics
-	embedding := make([]float32, 16)
-	base := float32(hash%1000) / 1000.0
-
-	for i := range embedding {
-		embedding[i] = base + float32(i)*0.1 + float32(len(query)%10)*0.01
-		// Normalize to 0-1 range
-		for embedding[i] > 1.0 {
-			embedding[i] -= 1.0
+	fmt.Printf("Query: \"%s\", the best %d of %d candidates\n", query, k, len(candidates))
+	fmt.Println(strings.Repeat("─", 50))
+	fmt.Printf("%-24s", "search")
+	for i, c := range candidates[:min(k, len(candidates))] {
+		fmt.Printf("  %d. %-9s %.4f", i+1, c.ID, c.Similarity)
+	}
+	fmt.Println()
+	for _, name := range rerankerNames {
+		reranker, stop, err := flags.open(name, embed)
+		if err != nil {
+			panic(err)
+		}
+		start := time.Now()
+		reranked, err := RerankTop(ctx, reranker, query, candidates, len(candidates), k)
+		stop()
+		if err != nil {
+			panic(err)
 		}
-		for embedding[i] < 0.0 {
-			embedding[i] += 1.0
+		fmt.Printf("%-24s", reranker.Name())
+		for i, r := range reranked {
+			fmt.Printf("  %d. %-9s %.4f", i+1, r.ID, r.Score)
 		}
+		fmt.Printf("  (%v)\n", time.Since(start).Round(time.Microsecond))
+	}
+	if flags.url == "" {
+		fmt.Println("💡 http is a local fake scoring by BM25; -rerank-url points it at a real reranker")
 	}
-
-	return embedding
 }
 
 func createDocumentationSnippets() []chromem.Document {
 	return []chromem.Document{
 		// Backend Development
 		{
-			ID:        "be-001",
-			Content:   "Set up a REST API using Node.js and Express framework. Configure middleware for logging, CORS, and authentication. Define routes for CRUD operations.",
-			Embedding: []float32{0.1, 0.9, 0.2, 0.8, 0.3, 0.7, 0.4, 0.6, 0.5, 0.8, 0.1, 0.9, 0.2, 0.7, 0.3, 0.6},
-			Metadata:  map[string]string{"category": "backend", "difficulty": "intermediate", "topic": "api"},
+			ID:       "be-001",
+			Content:  "Set up a REST API using Node.js and Express framework. Configure middleware for logging, CORS, and authentication. Define routes for CRUD operations.",
+			Metadata: map[string]string{"category": "backend", "difficulty": "intermediate", "topic": "api"},
 		},
 		{
-			ID:        "be-002",
-			Content:   "Database connection pooling in PostgreSQL. Configure maximum connections, timeout settings, and connection retry logic for production environments.",
-			Embedding: []float32{0.2, 0.8, 0.3, 0.7, 0.4, 0.6, 0.5, 0.9, 0.1, 0.7, 0.2, 0.8, 0.3, 0.6, 0.4, 0.9},
-			Metadata:  map[string]string{"category": "backend", "difficulty": "advanced", "topic": "database"},
+			ID:       "be-002",
+			Content:  "Database connection pooling in PostgreSQL. Configure maximum connections, timeout settings, and connection retry logic for production environments.",
+			Metadata: map[string]string{"category": "backend", "difficulty": "advanced", "topic": "database"},
 		},
 		{
-			ID:        "be-003",
-			Content:   "Implement caching strategies using Redis. Set up cache invalidation policies, handle distributed caching, and optimize cache hit ratios.",
-			Embedding: []float32{0.3, 0.7, 0.4, 0.6, 0.5, 0.9, 0.1, 0.8, 0.2, 0.6, 0.3, 0.7, 0.4, 0.9, 0.5, 0.8},
-			Metadata:  map[string]string{"category": "backend", "difficulty": "advanced", "topic": "performance"},
+			ID:       "be-003",
+			Content:  "Implement caching strategies using Redis. Set up cache invalidation policies, handle distributed caching, and optimize cache hit ratios.",
+			Metadata: map[string]string{"category": "backend", "difficulty": "advanced", "topic": "performance"},
 		},
 		{
-			ID:        "be-004",
-			Content:   "Handle file uploads securely. Validate file types, limit file sizes, scan for malware, and store files in cloud storage with proper access controls.",
-			Embedding: []float32{0.4, 0.6, 0.5, 0.9, 0.1, 0.8, 0.2, 0.7, 0.3, 0.5, 0.4, 0.6, 0.7, 0.8, 0.9, 0.2},
-			Metadata:  map[string]string{"category": "backend", "difficulty": "intermediate", "topic": "security"},
+			ID:       "be-004",
+			Content:  "Handle file uploads securely. Validate file types, limit file sizes, scan for malware, and store files in cloud storage with proper access controls.",
+			Metadata: map[string]string{"category": "backend", "difficulty": "intermediate", "topic": "security"},
 		},
 
 		// Frontend Development
 		{
-			ID:        "fe-001",
-			Content:   "Create responsive layouts using CSS Grid and Flexbox. Implement mobile-first design patterns and ensure cross-browser compatibility.",
-			Embedding: []float32{0.5, 0.5, 0.6, 0.4, 0.7, 0.3, 0.8, 0.2, 0.9, 0.4, 0.5, 0.6, 0.7, 0.8, 0.3, 0.2},
-			Metadata:  map[string]string{"category": "frontend", "difficulty": "intermediate", "topic": "css"},
+			ID:       "fe-001",
+			Content:  "Create responsive layouts using CSS Grid and Flexbox. Implement mobile-first design patterns and ensure cross-browser compatibility.",
+			Metadata: map[string]string{"category": "frontend", "difficulty": "intermediate", "topic": "css"},
 		},
 		{
-			ID:        "fe-002",
-			Content:   "State management in React applications. Use Redux Toolkit for complex state, Context API for simple state, and implement proper state normalization.",
-			Embedding: []float32{0.6, 0.4, 0.7, 0.3, 0.8, 0.2, 0.9, 0.1, 0.5, 0.3, 0.6, 0.7, 0.8, 0.2, 0.9, 0.1},
-			Metadata:  map[string]string{"category": "frontend", "difficulty": "advanced", "topic": "react"},
+			ID:       "fe-002",
+			Content:  "State management in React applications. Use Redux Toolkit for complex state, Context API for simple state, and implement proper state normalization.",
+			Metadata: map[string]string{"category": "frontend", "difficulty": "advanced", "topic": "react"},
 		},
 		{
-			ID:        "fe-003",
-			Content:   "Optimize web performance using lazy loading, code splitting, and image optimization. Implement service workers for offline functionality.",
-			Embedding: []float32{0.7, 0.3, 0.8, 0.2, 0.9, 0.1, 0.6, 0.4, 0.5, 0.2, 0.7, 0.8, 0.9, 0.1, 0.6, 0.3},
-			Metadata:  map[string]string{"category": "frontend", "difficulty": "advanced", "topic": "performance"},
+			ID:       "fe-003",
+			Content:  "Optimize web performance using lazy loading, code splitting, and image optimization. Implement service workers for offline functionality.",
+			Metadata: map[string]string{"category": "frontend", "difficulty": "advanced", "topic": "performance"},
 		},
 		{
-			ID:        "fe-004",
-			Content:   "Form validation and user input handling. Implement client-side validation, sanitize inputs, provide meaningful error messages, and handle accessibility.",
-			Embedding: []float32{0.8, 0.2, 0.9, 0.1, 0.6, 0.4, 0.5, 0.3, 0.7, 0.1, 0.8, 0.9, 0.6, 0.4, 0.5, 0.7},
-			Metadata:  map[string]string{"category": "frontend", "difficulty": "intermediate", "topic": "forms"},
+			ID:       "fe-004",
+			Content:  "Form validation and user input handling. Implement client-side validation, sanitize inputs, provide meaningful error messages, and handle accessibility.",
+			Metadata: map[string]string{"category": "frontend", "difficulty": "intermediate", "topic": "forms"},
 		},
 
 		// DevOps & Infrastructure
 		{
-			ID:        "do-001",
-			Content:   "Deploy applications using Docker containers. Create optimized Dockerfiles, manage multi-stage builds, and implement container orchestration with Kubernetes.",
-			Embedding: []float32{0.9, 0.1, 0.8, 0.2, 0.7, 0.3, 0.6, 0.4, 0.5, 0.9, 0.1, 0.8, 0.7, 0.6, 0.4, 0.5},
-			Metadata:  map[string]string{"category": "devops", "difficulty": "advanced", "topic": "containers"},
+			ID:       "do-001",
+			Content:  "Deploy applications using Docker containers. Create optimized Dockerfiles, manage multi-stage builds, and implement container orchestration with Kubernetes.",
+			Metadata: map[string]string{"category": "devops", "difficulty": "advanced", "topic": "containers"},
 		},
 		{
-			ID:        "do-002",
-			Content:   "Set up CI/CD pipelines using GitHub Actions. Automate testing, building, and deployment processes. Configure environment-specific deployments.",
-			Embedding: []float32{0.1, 0.8, 0.2, 0.9, 0.3, 0.6, 0.4, 0.7, 0.5, 0.8, 0.1, 0.9, 0.2, 0.7, 0.3, 0.6},
-			Metadata:  map[string]string{"category": "devops", "difficulty": "intermediate", "topic": "cicd"},
+			ID:       "do-002",
+			Content:  "Set up CI/CD pipelines using GitHub Actions. Automate testing, building, and deployment processes. Configure environment-specific deployments.",
+			Metadata: map[string]string{"category": "devops", "difficulty": "intermediate", "topic": "cicd"},
 		},
 		{
-			ID:        "do-003",
-			Content:   "Monitor applications using Prometheus and Grafana. Set up metrics collection, create alerting rules, and build comprehensive dashboards.",
-			Embedding: []float32{0.2, 0.7, 0.3, 0.8, 0.4, 0.5, 0.6, 0.9, 0.1, 0.7, 0.2, 0.8, 0.3, 0.6, 0.4, 0.9},
-			Metadata:  map[string]string{"category": "devops", "difficulty": "advanced", "topic": "monitoring"},
+			ID:       "do-003",
+			Content:  "Monitor applications using Prometheus and Grafana. Set up metrics collection, create alerting rules, and build comprehensive dashboards.",
+			Metadata: map[string]string{"category": "devops", "difficulty": "advanced", "topic": "monitoring"},
 		},
 		{
-			ID:        "do-004",
-			Content:   "Infrastructure as Code using Terraform. Define cloud resources, manage state files, and implement proper resource lifecycle management.",
-			Embedding: []float32{0.3, 0.6, 0.4, 0.7, 0.5, 0.4, 0.7, 0.8, 0.2, 0.6, 0.3, 0.7, 0.4, 0.9, 0.5, 0.8},
-			Metadata:  map[string]string{"category": "devops", "difficulty": "advanced", "topic": "infrastructure"},
+			ID:       "do-004",
+			Content:  "Infrastructure as Code using Terraform. Define cloud resources, manage state files, and implement proper resource lifecycle management.",
+			Metadata: map[string]string{"category": "devops", "difficulty": "advanced", "topic": "infrastructure"},
 		},
 
 		// Security
 		{
-			ID:        "sec-001",
-			Content:   "Implement OAuth 2.0 authentication flow. Configure authorization servers, handle token refresh, and secure API endpoints with proper scopes.",
-			Embedding: []float32{0.4, 0.5, 0.6, 0.3, 0.7, 0.2, 0.8, 0.1, 0.9, 0.5, 0.4, 0.6, 0.7, 0.8, 0.3, 0.2},
-			Metadata:  map[string]string{"category": "security", "difficulty": "advanced", "topic": "authentication"},
+			ID:       "sec-001",
+			Content:  "Implement OAuth 2.0 authentication flow. Configure authorization servers, handle token refresh, and secure API endpoints with proper scopes.",
+			Metadata: map[string]string{"category": "security", "difficulty": "advanced", "topic": "authentication"},
 		},
 		{
-			ID:        "sec-002",
-			Content:   "Secure API endpoints against common attacks. Implement rate limiting, input validation, SQL injection prevention, and CSRF protection.",
-			Embedding: []float32{0.5, 0.4, 0.7, 0.2, 0.8, 0.1, 0.9, 0.3, 0.6, 0.4, 0.5, 0.7, 0.8, 0.2, 0.9, 0.1},
-			Metadata:  map[string]string{"category": "security", "difficulty": "intermediate", "topic": "api-security"},
+			ID:       "sec-002",
+			Content:  "Secure API endpoints against common attacks. Implement rate limiting, input validation, SQL injection prevention, and CSRF protection.",
+			Metadata: map[string]string{"category": "security", "difficulty": "intermediate", "topic": "api-security"},
 		},
 		{
-			ID:        "sec-003",
-			Content:   "Data encryption at rest and in transit. Use AES encryption for stored data, implement TLS properly, and manage encryption keys securely.",
-			Embedding: []float32{0.6, 0.3, 0.8, 0.1, 0.9, 0.2, 0.7, 0.4, 0.5, 0.3, 0.6, 0.8, 0.9, 0.1, 0.7, 0.2},
-			Metadata:  map[string]string{"category": "security", "difficulty": "advanced", "topic": "encryption"},
+			ID:       "sec-003",
+			Content:  "Data encryption at rest and in transit. Use AES encryption for stored data, implement TLS properly, and manage encryption keys securely.",
+			Metadata: map[string]string{"category": "security", "difficulty": "advanced", "topic": "encryption"},
 		},
 
 		// Database
 		{
-			ID:        "db-001",
-			Content:   "Optimize database queries for better performance. Use proper indexing strategies, analyze query execution plans, and implement query caching.",
-			Embedding: []float32{0.7, 0.2, 0.9, 0.1, 0.8, 0.3, 0.6, 0.5, 0.4, 0.2, 0.7, 0.9, 0.8, 0.3, 0.6, 0.1},
-			Metadata:  map[string]string{"category": "database", "difficulty": "advanced", "topic": "performance"},
+			ID:       "db-001",
+			Content:  "Optimize database queries for better performance. Use proper indexing strategies, analyze query execution plans, and implement query caching.",
+			Metadata: map[string]string{"category": "database", "difficulty": "advanced", "topic": "performance"},
 		},
 		{
-			ID:        "db-002",
-			Content:   "Database backup and recovery strategies. Implement automated backups, test restore procedures, and set up point-in-time recovery.",
-			Embedding: []float32{0.8, 0.1, 0.7, 0.3, 0.6, 0.4, 0.5, 0.2, 0.9, 0.1, 0.8, 0.7, 0.6, 0.4, 0.5, 0.3},
-			Metadata:  map[string]string{"category": "database", "difficulty": "intermediate", "topic": "backup"},
+			ID:       "db-002",
+			Content:  "Database backup and recovery strategies. Implement automated backups, test restore procedures, and set up point-in-time recovery.",
+			Metadata: map[string]string{"category": "database", "difficulty": "intermediate", "topic": "backup"},
 		},
 		{
-			ID:        "db-003",
-			Content:   "Database migration best practices. Plan schema changes, handle data transformations, and ensure zero-downtime deployments.",
-			Embedding: []float32{0.9, 0.2, 0.6, 0.4, 0.5, 0.8, 0.3, 0.7, 0.1, 0.2, 0.9, 0.6, 0.5, 0.8, 0.4, 0.7},
-			Metadata:  map[string]string{"category": "database", "difficulty": "intermediate", "topic": "migration"},
+			ID:       "db-003",
+			Content:  "Database migration best practices. Plan schema changes, handle data transformations, and ensure zero-downtime deployments.",
+			Metadata: map[string]string{"category": "database", "difficulty": "intermediate", "topic": "migration"},
 		},
 
 		// Testing
 		{
-			ID:        "test-001",
-			Content:   "Write comprehensive unit tests using Jest and React Testing Library. Test components, hooks, and async operations with proper mocking.",
-			Embedding: []float32{0.1, 0.9, 0.5, 0.6, 0.2, 0.8, 0.4, 0.7, 0.3, 0.9, 0.1, 0.5, 0.6, 0.7, 0.8, 0.4},
-			Metadata:  map[string]string{"category": "testing", "difficulty": "intermediate", "topic": "unit-testing"},
+			ID:       "test-001",
+			Content:  "Write comprehensive unit tests using Jest and React Testing Library. Test components, hooks, and async operations with proper mocking.",
+			Metadata: map[string]string{"category": "testing", "difficulty": "intermediate", "topic": "unit-testing"},
 		},
 		{
-			ID:        "test-002",
-			Content:   "Integration testing for API endpoints. Test database interactions, external service calls, and end-to-end workflows with realistic data.",
-			Embedding: []float32{0.2, 0.8, 0.6, 0.5, 0.3, 0.7, 0.5, 0.8, 0.4, 0.8, 0.2, 0.6, 0.7, 0.8, 0.9, 0.5},
-			Metadata:  map[string]string{"category": "testing", "difficulty": "advanced", "topic": "integration-testing"},
+			ID:       "test-002",
+			Content:  "Integration testing for API endpoints. Test database interactions, external service calls, and end-to-end workflows with realistic data.",
+			Metadata: map[string]string{"category": "testing", "difficulty": "advanced", "topic": "integration-testing"},
 		},
 		{
-			ID:        "test-003",
-			Content:   "Automated browser testing with Playwright. Create reliable end-to-end tests, handle dynamic content, and implement visual regression testing.",
-			Embedding: []float32{0.3, 0.7, 0.7, 0.4, 0.4, 0.6, 0.6, 0.9, 0.5, 0.7, 0.3, 0.7, 0.8, 0.9, 0.1, 0.6},
-			Metadata:  map[string]string{"category": "testing", "difficulty": "advanced", "topic": "e2e-testing"},
+			ID:       "test-003",
+			Content:  "Automated browser testing with Playwright. Create reliable end-to-end tests, handle dynamic content, and implement visual regression testing.",
+			Metadata: map[string]string{"category": "testing", "difficulty": "advanced", "topic": "e2e-testing"},
 		},
 
 		// Performance
 		{
-			ID:        "perf-001",
-			Content:   "Application performance monitoring and optimization. Use profiling tools, identify bottlenecks, and implement performance improvements.",
-			Embedding: []float32{0.4, 0.6, 0.8, 0.3, 0.5, 0.5, 0.7, 0.1, 0.6, 0.6, 0.4, 0.8, 0.9, 0.1, 0.2, 0.7},
-			Metadata:  map[string]string{"category": "performance", "difficulty": "advanced", "topic": "monitoring"},
+			ID:       "perf-001",
+			Content:  "Application performance monitoring and optimization. Use profiling tools, identify bottlenecks, and implement performance improvements.",
+			Metadata: map[string]string{"category": "performance", "difficulty": "advanced", "topic": "monitoring"},
 		},
 		{
-			ID:        "perf-002",
-			Content:   "Load testing and capacity planning. Use tools like JMeter or k6 to simulate traffic, identify system limits, and plan for scaling.",
-			Embedding: []float32{0.5, 0.5, 0.9, 0.2, 0.6, 0.4, 0.8, 0.2, 0.7, 0.5, 0.5, 0.9, 0.1, 0.2, 0.3, 0.8},
-			Metadata:  map[string]string{"category": "performance", "difficulty": "advanced", "topic": "load-testing"},
+			ID:       "perf-002",
+			Content:  "Load testing and capacity planning. Use tools like JMeter or k6 to simulate traffic, identify system limits, and plan for scaling.",
+			Metadata: map[string]string{"category": "performance", "difficulty": "advanced", "topic": "load-testing"},
 		},
 
 		// Debugging
 		{
-			ID:        "debug-001",
-			Content:   "Debug production issues using logging and monitoring tools. Set up structured logging, analyze error patterns, and implement alerting.",
-			Embedding: []float32{0.6, 0.4, 0.1, 0.1, 0.7, 0.3, 0.9, 0.3, 0.8, 0.4, 0.6, 0.1, 0.2, 0.3, 0.4, 0.9},
-			Metadata:  map[string]string{"category": "debugging", "difficulty": "intermediate", "topic": "production"},
+			ID:       "debug-001",
+			Content:  "Debug production issues using logging and monitoring tools. Set up structured logging, analyze error patterns, and implement alerting.",
+			Metadata: map[string]string{"category": "debugging", "difficulty": "intermediate", "topic": "production"},
 		},
 		{
-			ID:        "debug-002",
-			Content:   "Memory leak detection and resolution. Use memory profiling tools, identify leak sources, and implement proper memory management.",
-			Embedding: []float32{0.7, 0.3, 0.2, 0.9, 0.8, 0.2, 0.1, 0.4, 0.9, 0.3, 0.7, 0.2, 0.3, 0.4, 0.5, 0.1},
-			Metadata:  map[string]string{"category": "debugging", "difficulty": "advanced", "topic": "memory"},
+			ID:       "debug-002",
+			Content:  "Memory leak detection and resolution. Use memory profiling tools, identify leak sources, and implement proper memory management.",
+			Metadata: map[string]string{"category": "debugging", "difficulty": "advanced", "topic": "memory"},
 		},
 		{
-			ID:        "debug-003",
-			Content:   "Distributed tracing for microservices. Implement OpenTelemetry, trace requests across services, and analyze performance bottlenecks.",
-			Embedding: []float32{0.8, 0.2, 0.3, 0.8, 0.9, 0.1, 0.2, 0.5, 0.1, 0.2, 0.8, 0.3, 0.4, 0.5, 0.6, 0.2},
-			Metadata:  map[string]string{"category": "debugging", "difficulty": "advanced", "topic": "tracing"},
+			ID:       "debug-003",
+			Content:  "Distributed tracing for microservices. Implement OpenTelemetry, trace requests across services, and analyze performance bottlenecks.",
+			Metadata: map[string]string{"category": "debugging", "difficulty": "advanced", "topic": "tracing"},
 		},
 	}
 }
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// OllamaConfig configures an Ollama embedder.
+type OllamaConfig struct {
+	// BaseURL of the Ollama server. Defaults to $OLLAMA_HOST or
+	// http://localhost:11434.
+	BaseURL string
+	// Model is an embedding model that has been pulled, e.g.
+	// "nomic-embed-text". Defaults to "nomic-embed-text".
+	Model string
+	// BatchSize is the maximum number of texts per /api/embed request.
+	// Defaults to 16.
+	BatchSize int
+	// Workers is the number of requests in flight at once. Defaults to 2.
+	Workers int
+	// Linger is how long a worker waits for more texts before it sends a
+	// batch that isn't full. Defaults to 2ms.
+	Linger time.Duration
+	// MaxRetries is the number of retries after a failed request. Zero
+	// means 3 and a negative value none; only network errors, timeouts, 429
+	// and 5xx are retried.
+	MaxRetries int
+	// Timeout limits each request. Defaults to 60s, which leaves room for
+	// Ollama to load the model on the first request.
+	Timeout time.Duration
+	// HTTPClient defaults to a client without a timeout of its own; Timeout
+	// applies per request instead.
+	HTTPClient *http.Client
+}
+
+// Ollama embeds texts with Ollama's /api/embed endpoint, which takes many
+// texts per request.
+//
+// chromem-go calls an embedding function once per document, from up to
+// AddDocuments' concurrency goroutines at a time. Ollama's EmbeddingFunc
+// queues those calls, and a fixed number of workers drain the queue in
+// batches - so passing Concurrency() to AddDocuments keeps every worker busy
+// with full batches.
+type Ollama struct {
+	cfg    OllamaConfig
+	client *http.Client
+
+	queue     chan embedJob
+	startOnce sync.Once
+	stop      chan struct{}
+
+	mu    sync.Mutex
+	stats OllamaStats
+}
+
+// OllamaStats counts what an Ollama embedder did.
+type OllamaStats struct {
+	Texts    int
+	Requests int // including retries
+	Retries  int
+}
+
+// OllamaError is an error response from Ollama.
+type OllamaError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *OllamaError) Error() string {
+	return fmt.Sprintf("ollama returned %d: %s", e.StatusCode, e.Message)
+}
+
+type embedJob struct {
+	ctx    context.Context
+	text   string
+	result chan embedResult
+}
+
+type embedResult struct {
+	vec []float32
+	err error
+}
+
+// NewOllama returns an Ollama embedder for cfg, filling in defaults. It
+// doesn't contact the server; use Available for that.
+func NewOllama(cfg OllamaConfig) *Ollama {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = os.Getenv("OLLAMA_HOST")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "http://localhost:11434"
+	}
+	if !strings.Contains(cfg.BaseURL, "://") {
+		cfg.BaseURL = "http://" + cfg.BaseURL
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.Model == "" {
+		cfg.Model = "nomic-embed-text"
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 16
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.Linger <= 0 {
+		cfg.Linger = 2 * time.Millisecond
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 60 * time.Second
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+	return &Ollama{
+		cfg:    cfg,
+		client: cfg.HTTPClient,
+		queue:  make(chan embedJob),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Model returns the model name.
+func (o *Ollama) Model() string {
+	return o.cfg.Model
+}
+
+// Concurrency returns the AddDocuments concurrency that fills every worker's
+// batches.
+func (o *Ollama) Concurrency() int {
+	return o.cfg.BatchSize * o.cfg.Workers
+}
+
+// Stats returns what the embedder did so far.
+func (o *Ollama) Stats() OllamaStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.stats
+}
+
+// Available reports whether the server is reachable and has the model
+// pulled. It waits at most timeout.
+func (o *Ollama) Available(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.cfg.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return fmt.Errorf("couldn't create request: %w", err)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama isn't reachable at %s: %w", o.cfg.BaseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama at %s returned %s", o.cfg.BaseURL, resp.Status)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("couldn't decode model list: %w", err)
+	}
+	for _, m := range tags.Models {
+		if m.Name == o.cfg.Model || m.Name == o.cfg.Model+":latest" {
+			return nil
+		}
+	}
+	return fmt.Errorf("ollama at %s doesn't have %s; run: ollama pull %s", o.cfg.BaseURL, o.cfg.Model, o.cfg.Model)
+}
+
+// EmbeddingFunc returns a chromem.EmbeddingFunc that queues each text for the
+// batch workers, which are started on first use.
+func (o *Ollama) EmbeddingFunc() chromem.EmbeddingFunc {
+	return func(ctx context.Context, text string) ([]float32, error) {
+		o.startOnce.Do(o.start)
+		job := embedJob{ctx: ctx, text: text, result: make(chan embedResult, 1)}
+		select {
+		case o.queue <- job:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-o.stop:
+			return nil, errors.New("ollama embedder is closed")
+		}
+		select {
+		case r := <-job.result:
+			return r.vec, r.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Close stops the batch workers. Pending calls fail.
+func (o *Ollama) Close() {
+	select {
+	case <-o.stop:
+	default:
+		close(o.stop)
+	}
+}
+
+func (o *Ollama) start() {
+	for range o.cfg.Workers {
+		go o.work()
+	}
+}
+
+// work collects up to BatchSize queued texts, waiting at most Linger for
+// more after the first one, and embeds them in one request.
+func (o *Ollama) work() {
+	for {
+		var batch []embedJob
+		select {
+		case job := <-o.queue:
+			batch = append(batch, job)
+		case <-o.stop:
+			return
+		}
+		linger := time.NewTimer(o.cfg.Linger)
+	collect:
+		for len(batch) < o.cfg.BatchSize {
+			select {
+			case job := <-o.queue:
+				batch = append(batch, job)
+			case <-linger.C:
+				break collect
+			}
+		}
+		linger.Stop()
+
+		// Callers that gave up don't need embeddings
+		live := batch[:0]
+		for _, job := range batch {
+			if job.ctx.Err() == nil {
+				live = append(live, job)
+			}
+		}
+		if len(live) == 0 {
+			continue
+		}
+		texts := make([]string, len(live))
+		for i, job := range live {
+			texts[i] = job.text
+		}
+		vecs, err := o.embed(live[0].ctx, texts)
+		for i, job := range live {
+			if err != nil {
+				job.result <- embedResult{err: err}
+			} else {
+				job.result <- embedResult{vec: vecs[i]}
+			}
+		}
+	}
+}
+
+// embed sends one batch, retrying with exponential backoff.
+func (o *Ollama) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": o.cfg.Model, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't encode request: %w", err)
+	}
+	// One canceled caller shouldn't fail the batch for the others
+	ctx = context.WithoutCancel(ctx)
+
+	for attempt := 0; ; attempt++ {
+		vecs, err := o.post(ctx, body, len(texts))
+		if err == nil {
+			o.mu.Lock()
+			o.stats.Texts += len(texts)
+			o.mu.Unlock()
+			return vecs, nil
+		}
+		var ollamaErr *OllamaError
+		if errors.As(err, &ollamaErr) && ollamaErr.StatusCode != http.StatusTooManyRequests && ollamaErr.StatusCode < 500 {
+			return nil, err
+		}
+		if errors.Is(err, errInvalidResponse) {
+			return nil, err
+		}
+		if attempt == o.cfg.MaxRetries {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		o.mu.Lock()
+		o.stats.Retries++
+		o.mu.Unlock()
+		select {
+		case <-time.After(backoff(attempt)):
+		case <-o.stop:
+			return nil, errors.New("ollama embedder is closed")
+		}
+	}
+}
+
+// errInvalidResponse marks responses that won't get better by retrying.
+var errInvalidResponse = errors.New("invalid ollama response")
+
+// backoff returns the delay before retry attempt+1: 200ms doubling up to 5s,
+// with up to 50% jitter. The shift is clamped, since 200ms<<36 overflows to a
+// negative delay.
+func backoff(attempt int) time.Duration {
+	d := min(200*time.Millisecond<<min(attempt, 5), 5*time.Second)
+	return d/2 + rand.N(d/2+1)
+}
+
+func (o *Ollama) post(ctx context.Context, body []byte, n int) ([][]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.BaseURL+"/api/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	o.mu.Lock()
+	o.stats.Requests++
+	o.mu.Unlock()
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read response: %w", err)
+	}
+
+	var parsed struct {
+		Embeddings [][]float32 `json:"embeddings"`
+		Error      string      `json:"error"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &parsed) == nil && parsed.Error != "" {
+			msg = parsed.Error
+		}
+		return nil, &OllamaError{StatusCode: resp.StatusCode, Message: msg}
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidResponse, err)
+	}
+	if len(parsed.Embeddings) != n {
+		return nil, fmt.Errorf("%w: got %d embeddings for %d texts", errInvalidResponse, len(parsed.Embeddings), n)
+	}
+	return parsed.Embeddings, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Passage is a sentence of a document: its byte offsets in the document and
+// its cosine similarity to the query.
+type Passage struct {
+	Start int     `json:"start"`
+	End   int     `json:"end"`
+	Score float32 `json:"score"`
+}
+
+// Excerpt is the part of a document that matches a query best: its most
+// similar sentences, in document order.
+type Excerpt struct {
+	Text     string // the whole document
+	Passages []Passage
+}
+
+// Snippeter extracts excerpts by embedding every sentence of a document and
+// keeping the ones most similar to the query. Embeddings are cached by
+// sentence, so a document is only split and embedded once however often it
+// matches.
+type Snippeter struct {
+	Embed     chromem.EmbeddingFunc
+	Sentences int // in an excerpt
+
+	mu    sync.Mutex
+	cache map[string][]float32
+}
+
+// NewSnippeter returns a snippeter that keeps the best n sentences.
+func NewSnippeter(embed chromem.EmbeddingFunc, n int) *Snippeter {
+	return &Snippeter{Embed: embed, Sentences: n, cache: make(map[string][]float32)}
+}
+
+// Excerpt returns the sentences of text most similar to query.
+func (s *Snippeter) Excerpt(ctx context.Context, query, text string) (Excerpt, error) {
+	q, err := s.embed(ctx, query)
+	if err != nil {
+		return Excerpt{}, err
+	}
+	var passages []Passage
+	for _, span := range splitSentences(text) {
+		v, err := s.embed(ctx, text[span[0]:span[1]])
+		if err != nil {
+			// A sentence of nothing but punctuation can't be embedded, or matched
+			continue
+		}
+		var dot float32
+		for i := range q {
+			dot += q[i] * v[i]
+		}
+		passages = append(passages, Passage{Start: span[0], End: span[1], Score: dot})
+	}
+
+	slices.SortStableFunc(passages, func(a, b Passage) int { return cmp.Compare(b.Score, a.Score) })
+	passages = passages[:min(s.Sentences, len(passages))]
+	slices.SortFunc(passages, func(a, b Passage) int { return cmp.Compare(a.Start, b.Start) })
+	return Excerpt{Text: text, Passages: passages}, nil
+}
+
+// embed returns the normalized embedding of text, from the cache if it can.
+func (s *Snippeter) embed(ctx context.Context, text string) ([]float32, error) {
+	s.mu.Lock()
+	v, ok := s.cache[text]
+	s.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := s.Embed(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't embed %q: %w", text, err)
+	}
+	v = normalized(v)
+	s.mu.Lock()
+	s.cache[text] = v
+	s.mu.Unlock()
+	return v, nil
+}
+
+// Render returns the excerpt's passages joined by "…" where sentences were
+// left out, with the words that start with one of terms passed through mark.
+func (e Excerpt) Render(terms []string, mark func(string) string) string {
+	if len(e.Passages) == 0 {
+		return markTerms(e.Text, terms, mark)
+	}
+	var sb strings.Builder
+	for i, p := range e.Passages {
+		gap := p.Start > 0
+		if i > 0 {
+			gap = strings.TrimSpace(e.Text[e.Passages[i-1].End:p.Start]) != ""
+			sb.WriteString(" ")
+		}
+		if gap {
+			sb.WriteString("… ")
+		}
+		sb.WriteString(markTerms(e.Text[p.Start:p.End], terms, mark))
+	}
+	if last := e.Passages[len(e.Passages)-1]; strings.TrimSpace(e.Text[last.End:]) != "" {
+		sb.WriteString(" …")
+	}
+	return sb.String()
+}
+
+// markTerms passes the words of text that start with one of terms through
+// mark.
+func markTerms(text string, terms []string, mark func(string) string) string {
+	var sb strings.Builder
+	pos := 0
+	for _, span := range termSpans(text, terms) {
+		sb.WriteString(text[pos:span[0]])
+		sb.WriteString(mark(text[span[0]:span[1]]))
+		pos = span[1]
+	}
+	sb.WriteString(text[pos:])
+	return sb.String()
+}
+
+// splitSentences returns the byte ranges of the sentences of text. A
+// sentence ends at ".", "!" or "?" followed by a space and a capital letter
+// or digit, so "Node.js", "e.g. this" and "2.0" don't end one.
+func splitSentences(text string) [][2]int {
+	var spans [][2]int
+	start := 0
+	for i, r := range text {
+		if r != '.' && r != '!' && r != '?' {
+			continue
+		}
+		end := i + 1
+		rest := text[end:]
+		trimmed := strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if len(trimmed) == len(rest) || trimmed == "" {
+			continue
+		}
+		if next, _ := utf8.DecodeRuneInString(trimmed); !unicode.IsUpper(next) && !unicode.IsDigit(next) {
+			continue
+		}
+		spans = appendSentence(spans, text, start, end)
+		start = len(text) - len(trimmed)
+	}
+	return appendSentence(spans, text, start, len(text))
+}
+
+func appendSentence(spans [][2]int, text string, start, end int) [][2]int {
+	s := text[start:end]
+	start += len(s) - len(strings.TrimLeftFunc(s, unicode.IsSpace))
+	end -= len(s) - len(strings.TrimRightFunc(s, unicode.IsSpace))
+	if start >= end {
+		return spans
+	}
+	return append(spans, [2]int{start, end})
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// runREPL implements `go run . repl`: an interactive search shell over a
+// collection in a persistent DB.
+func runREPL(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	dbPath := fs.String("db", "./snippets-db", "persistent DB directory")
+	name := fs.String("collection", "docs", "collection to search")
+	historyPath := fs.String("history", defaultHistoryPath(), "history file, empty to keep none")
+	excerpt := fs.Int("excerpt", 1, "sentences of each result to show, the ones closest to the query; 0 shows all")
+	synonymsPath := fs.String("synonyms", defaultSynonymsPath, "synonym dictionary :synonyms on loads")
+	var flags embedderFlags
+	flags.register(fs)
+	var rerank rerankerFlags
+	rerank.register(fs)
+	fs.Parse(args)
+	if err := rerank.validate(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	embedder, err := flags.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer embedder.Close()
+
+	_, collection, err := openCollection(ctx, *dbPath, *name, embedder)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📚 %d documents in %s/%s, embedding queries with the %s\n", collection.Count(), *dbPath, *name, embedder.Name)
+	fmt.Println("   Type a query, :help for commands, Ctrl-D to quit")
+
+	s := &session{ctx: ctx, collection: collection, embed: embedder.Func, local: embedder.Ollama == nil, k: 5, metric: "cosine", synonymsPath: *synonymsPath, rerankFlags: &rerank, stopReranker: func() {}}
+	if *excerpt > 0 {
+		s.display.snippeter = NewSnippeter(embedder.Func, *excerpt)
+	}
+	if err := s.setReranker(rerank.name); err != nil {
+		return err
+	}
+	defer func() { s.stopReranker() }()
+	editor := NewLineEditor(*historyPath)
+	for {
+		line, err := editor.ReadLine("search> ")
+		switch {
+		case errors.Is(err, errInterrupted):
+			continue
+		case errors.Is(err, io.EOF):
+			fmt.Println("👋 Bye")
+			return nil
+		case err != nil:
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line == ":quit" || line == ":q" {
+			fmt.Println("👋 Bye")
+			return nil
+		}
+		if strings.HasPrefix(line, ":") {
+			err = s.command(line)
+		} else {
+			err = s.search(line)
+		}
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+		}
+	}
+}
+
+func defaultHistoryPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "searchless", "snippets-history")
+}
+
+// metric scores a normalized query against a stored document vector, which
+// chromem-go has normalized too.
+type metric struct {
+	distance bool // lower is better
+	score    func(q, v []float32) float32
+}
+
+var metrics = map[string]metric{
+	"cosine": {score: func(q, v []float32) float32 {
+		var dot float32
+		for i := range q {
+			dot += q[i] * v[i]
+		}
+		return dot
+	}},
+	"euclidean": {distance: true, score: func(q, v []float32) float32 {
+		var sum float32
+		for i := range q {
+			sum += (q[i] - v[i]) * (q[i] - v[i])
+		}
+		return float32(math.Sqrt(float64(sum)))
+	}},
+	"manhattan": {distance: true, score: func(q, v []float32) float32 {
+		var sum float32
+		for i := range q {
+			sum += float32(math.Abs(float64(q[i] - v[i])))
+		}
+		return sum
+	}},
+}
+
+// session is the state of a REPL: the settings the commands change and the
+// results of the last search, which :open can refer to by number.
+type session struct {
+	ctx        context.Context
+	collection *chromem.Collection
+	embed      chromem.EmbeddingFunc
+	display    searchDisplay // for excerpts; explaining is set by :explain
+
+	k        int
+	where    map[string]string
+	contains string
+	metric   string
+	explain  string // "", "text" or "json"
+	local    bool   // the built-in embedder, for Explainer.Local
+	last     []hit
+
+	// Query expansion, off until :synonyms or :prf turns it on
+	synonymsPath string
+	synonyms     Synonyms
+	feedback     int
+
+	// Reranking the best candidates, off until -rerank or :rerank
+	rerankFlags  *rerankerFlags
+	reranker     Reranker
+	stopReranker func()
+}
+
+// hit is a search result scored by the session's metric.
+type hit struct {
+	chromem.Result
+	Score float32
+}
+
+// search embeds query and prints the best k documents that pass the filters.
+// chromem-go only ranks by cosine similarity, so for the other metrics every
+// document that passes the filters is fetched and scored here.
+func (s *session) search(query string) error {
+	start := time.Now()
+	var whereDocument map[string]string
+	if s.contains != "" {
+		whereDocument = map[string]string{"$contains": s.contains}
+	}
+	var vec []float32
+	var expansion *Expansion
+	var err error
+	if s.synonyms != nil || s.feedback > 0 {
+		vec, expansion, err = NewExpander(s.embed, s.synonyms, s.feedback).Vector(s.ctx, s.collection, query, s.where, whereDocument)
+		if err != nil {
+			return err
+		}
+		// The synonyms are explained, highlighted and picked excerpts with too
+		query = expansion.Text
+	} else {
+		if vec, err = s.embed(s.ctx, query); err != nil {
+			return fmt.Errorf("couldn't embed query: %w", err)
+		}
+		vec = normalized(vec)
+	}
+	// Fetching everything that passes the filters lets explain count them.
+	// The reranker reorders the best candidates, whatever the metric.
+	candidates := s.k
+	if s.reranker != nil {
+		candidates = max(s.k, s.rerankFlags.candidates)
+	}
+	n := candidates
+	if s.metric != "cosine" || s.explain != "" {
+		n = s.collection.Count()
+	}
+	results, err := s.collection.QueryEmbedding(s.ctx, vec, min(n, s.collection.Count()), s.where, whereDocument)
+	if err != nil {
+		return fmt.Errorf("couldn't query: %w", err)
+	}
+
+	m := metrics[s.metric]
+	hits := make([]hit, len(results))
+	for i, r := range results {
+		hits[i] = hit{Result: r, Score: m.score(vec, r.Embedding)}
+	}
+	slices.SortStableFunc(hits, func(a, b hit) int {
+		if m.distance {
+			return cmp.Compare(a.Score, b.Score)
+		}
+		return cmp.Compare(b.Score, a.Score)
+	})
+	hits = hits[:min(candidates, len(hits))]
+	shown := make([]chromem.Result, len(hits))
+	for i, h := range hits {
+		shown[i] = h.Result
+	}
+	var reranked []Reranked
+	if s.reranker != nil {
+		if reranked, err = RerankTop(s.ctx, s.reranker, query, shown, len(shown), s.k); err != nil {
+			return err
+		}
+	}
+	elapsed := time.Since(start)
+
+	var explainer *Explainer
+	var explanation *QueryExplanation
+	if s.explain != "" {
+		explainer = &Explainer{Format: s.explain, Embed: s.embed, Local: s.local, Out: os.Stdout}
+		explanation = explainer.Explain(query, vec, s.metric, results, shown, s.where, whereDocument)
+		explanation.Expansion = expansion
+		if reranked != nil {
+			explanation.Rerank(s.reranker.Name(), reranked)
+		}
+	}
+	if reranked != nil {
+		byMetric := hits
+		hits = make([]hit, len(reranked))
+		for i, r := range reranked {
+			hits[i] = byMetric[r.Rank-1]
+		}
+	}
+	s.last = hits
+	if expansion != nil {
+		fmt.Printf("🔁 Expanded: %s\n", cmp.Or(expansion.String(), "nothing to add"))
+	}
+	explainer.Header(explanation)
+	if len(hits) == 0 {
+		fmt.Println("   No documents match the filters")
+	}
+	label := "Score"
+	if m.distance {
+		label = "Distance"
+	}
+	for i, h := range hits {
+		if reranked != nil {
+			fmt.Printf("%d. [%s] %s: %.4f (%s %.4f, was #%d)\n", i+1, h.ID, s.reranker.Name(), reranked[i].Score, s.metric, h.Score, reranked[i].Rank)
+		} else {
+			fmt.Printf("%d. [%s] %s: %.4f\n", i+1, h.ID, label, h.Score)
+		}
+		fmt.Printf("   %s\n", s.display.content(s.ctx, query, h.Content))
+		fmt.Printf("   📂 %s | 🎯 %s\n", h.Metadata["category"], h.Metadata["difficulty"])
+		explainer.Result(explanation, i)
+	}
+	if err := explainer.Done(explanation); err != nil {
+		return err
+	}
+	fmt.Printf("⚡ %v\n", elapsed.Round(time.Microsecond))
+	return nil
+}
+
+// command runs a line starting with ":".
+func (s *session) command(line string) error {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case ":help", ":h", ":?":
+		fmt.Println(`   <query>                 search
+   :k [n]                  show or set the number of results
+   :where [key=value ...]  filter by metadata, nothing to clear
+   :contains [text]        only documents containing text, nothing to clear
+   :metric [name]          show or set cosine, euclidean or manhattan
+   :explain [on|off|json]  explain every result, as text or JSON
+   :synonyms [on|off]      expand queries with the -synonyms dictionary
+   :prf [n|off]            move queries towards their top n results
+   :rerank [name|off]      rerank the top -candidates with lexical, mmr, model or http
+   :open <id|n>            show a document, by ID or number in the last results
+   :settings               show the current settings
+   :quit                   quit, as does Ctrl-D`)
+	case ":k":
+		if arg != "" {
+			k, err := strconv.Atoi(arg)
+			if err != nil || k <= 0 {
+				return fmt.Errorf("k must be a positive number, not %q", arg)
+			}
+			s.k = k
+		}
+		fmt.Printf("   k = %d\n", s.k)
+	case ":where":
+		where := make(map[string]string)
+		for _, pair := range strings.Fields(arg) {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("filters look like key=value, not %q", pair)
+			}
+			where[key] = value
+		}
+		s.where = where
+		fmt.Printf("   filters: %s\n", s.filters())
+	case ":contains":
+		s.contains = arg
+		fmt.Printf("   filters: %s\n", s.filters())
+	case ":metric":
+		if arg != "" {
+			if _, ok := metrics[arg]; !ok {
+				return fmt.Errorf("unknown metric %q, try %s", arg, strings.Join(slices.Sorted(maps.Keys(metrics)), ", "))
+			}
+			s.metric = arg
+		}
+		fmt.Printf("   metric = %s\n", s.metric)
+	case ":explain":
+		switch arg {
+		case "":
+			if s.explain == "" {
+				s.explain = "text"
+			} else {
+				s.explain = ""
+			}
+		case "on":
+			s.explain = "text"
+		case "off":
+			s.explain = ""
+		case "json":
+			s.explain = "json"
+		default:
+			return fmt.Errorf(":explain takes on, off or json, not %q", arg)
+		}
+		fmt.Printf("   explain = %s\n", cmp.Or(s.explain, "off"))
+	case ":synonyms":
+		switch arg {
+		case "", "on":
+			if arg == "" && s.synonyms != nil {
+				s.synonyms = nil
+				break
+			}
+			synonyms, err := LoadSynonyms(s.synonymsPath)
+			if err != nil {
+				return err
+			}
+			s.synonyms = synonyms
+		case "off":
+			s.synonyms = nil
+		default:
+			return fmt.Errorf(":synonyms takes on or off, not %q", arg)
+		}
+		fmt.Printf("   expansion: %s\n", s.expansion())
+	case ":prf":
+		switch arg {
+		case "":
+		case "off":
+			s.feedback = 0
+		default:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				return fmt.Errorf(":prf takes a number of results or off, not %q", arg)
+			}
+			s.feedback = n
+		}
+		fmt.Printf("   expansion: %s\n", s.expansion())
+	case ":rerank":
+		switch arg {
+		case "":
+		case "off":
+			if err := s.setReranker(""); err != nil {
+				return err
+			}
+		default:
+			if err := s.setReranker(arg); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("   rerank: %s\n", s.reranking())
+	case ":open":
+		return s.open(arg)
+	case ":settings":
+		fmt.Printf("   k = %d, metric = %s, explain = %s, expansion: %s, rerank: %s, filters: %s\n", s.k, s.metric, cmp.Or(s.explain, "off"), s.expansion(), s.reranking(), s.filters())
+	default:
+		return fmt.Errorf("unknown command %s, try :help", name)
+	}
+	return nil
+}
+
+// open prints a whole document with its metadata.
+func (s *session) open(arg string) error {
+	if arg == "" {
+		return errors.New(":open needs a document ID or a result number")
+	}
+	id := arg
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(s.last) {
+			return fmt.Errorf("the last search has no result %d", n)
+		}
+		id = s.last[n-1].ID
+	}
+	doc, err := s.collection.GetByID(s.ctx, id)
+	if err != nil {
+		return fmt.Errorf("couldn't open %s: %w", id, err)
+	}
+	fmt.Printf("📄 %s\n", doc.ID)
+	for _, key := range slices.Sorted(maps.Keys(doc.Metadata)) {
+		fmt.Printf("   %s: %s\n", key, doc.Metadata[key])
+	}
+	fmt.Println()
+	fmt.Printf("   %s\n", doc.Content)
+	fmt.Printf("   (%d dimensions)\n", len(doc.Embedding))
+	return nil
+}
+
+// expansion describes how queries are expanded.
+func (s *session) expansion() string {
+	var parts []string
+	if s.synonyms != nil {
+		parts = append(parts, fmt.Sprintf("synonyms of %d words from %s", len(s.synonyms), s.synonymsPath))
+	}
+	if s.feedback > 0 {
+		parts = append(parts, fmt.Sprintf("feedback from the top %d", s.feedback))
+	}
+	if len(parts) == 0 {
+		return "off"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// setReranker replaces the reranker with the one named name, "" for none,
+// stopping the old one's fake server if it had one.
+func (s *session) setReranker(name string) error {
+	reranker, stop, err := s.rerankFlags.open(name, s.embed)
+	if err != nil {
+		return err
+	}
+	s.stopReranker()
+	s.reranker, s.stopReranker = reranker, stop
+	if name == "http" && s.rerankFlags.url == "" {
+		fmt.Println("   🧪 a local fake server; -rerank-url for a real one")
+	}
+	return nil
+}
+
+// reranking describes how results are reranked.
+func (s *session) reranking() string {
+	if s.reranker == nil {
+		return "off"
+	}
+	return fmt.Sprintf("%s over the top %d", s.reranker.Name(), max(s.k, s.rerankFlags.candidates))
+}
+
+// filters describes the active filters.
+func (s *session) filters() string {
+	var parts []string
+	for _, key := range slices.Sorted(maps.Keys(s.where)) {
+		parts = append(parts, key+"="+s.where[key])
+	}
+	if s.contains != "" {
+		parts = append(parts, fmt.Sprintf("contains %q", s.contains))
+	}
+	if len(parts) == 0 {
+		return "no filters"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// normalized returns v scaled to unit length, as chromem-go stores vectors.
+func normalized(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	norm := float32(math.Sqrt(sum))
+	if norm == 0 {
+		return v
+	}
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x / norm
+	}
+	return out
+}
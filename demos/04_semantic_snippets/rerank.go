@@ -0,0 +1,227 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Reranker reorders the candidates of a search by a score of its own. The
+// candidates can come from any search - as typed, expanded, under another
+// metric - in the order it ranked them.
+type Reranker interface {
+	Name() string
+	// Rerank returns every candidate, best first.
+	Rerank(ctx context.Context, query string, candidates []chromem.Result) ([]Reranked, error)
+}
+
+// Reranked is a candidate with both of its scores. The Result's Similarity
+// is still the one the search gave it.
+type Reranked struct {
+	chromem.Result
+	Rank  int     // in the search's results, from 1
+	Score float32 // the reranker's, higher is better
+}
+
+// RerankTop reranks the first n candidates and returns the best k of them.
+// Reranking costs more per document than the search that found them, so n
+// is usually a few times k, not the whole collection.
+func RerankTop(ctx context.Context, r Reranker, query string, candidates []chromem.Result, n, k int) ([]Reranked, error) {
+	reranked, err := r.Rerank(ctx, query, candidates[:min(n, len(candidates))])
+	if err != nil {
+		return nil, fmt.Errorf("couldn't rerank with %s: %w", r.Name(), err)
+	}
+	return reranked[:min(k, len(reranked))], nil
+}
+
+// byScore pairs candidates with their scores and sorts them, best first.
+// Ties keep the search's order.
+func byScore(candidates []chromem.Result, scores []float32) []Reranked {
+	reranked := make([]Reranked, len(candidates))
+	for i, c := range candidates {
+		reranked[i] = Reranked{Result: c, Rank: i + 1, Score: scores[i]}
+	}
+	slices.SortStableFunc(reranked, func(a, b Reranked) int { return cmp.Compare(b.Score, a.Score) })
+	return reranked
+}
+
+// Lexical scores a candidate by the share of the query's words it contains,
+// each weighted by how rare it is among the candidates: the words every
+// candidate has don't tell them apart. Words match like highlighting does,
+// so "deploy" finds "Deployment".
+type Lexical struct{}
+
+func (Lexical) Name() string { return "lexical" }
+
+func (Lexical) Rerank(_ context.Context, query string, candidates []chromem.Result) ([]Reranked, error) {
+	terms := queryTerms(query)
+	has := make([][]bool, len(candidates))
+	df := make([]int, len(terms))
+	for i, c := range candidates {
+		has[i] = make([]bool, len(terms))
+		for t, term := range terms {
+			if len(termSpans(c.Content, []string{term})) > 0 {
+				has[i][t] = true
+				df[t]++
+			}
+		}
+	}
+	var total float64
+	idf := make([]float64, len(terms))
+	for t := range terms {
+		idf[t] = math.Log((float64(len(candidates)) + 1) / (float64(df[t]) + 0.5))
+		total += idf[t]
+	}
+	scores := make([]float32, len(candidates))
+	for i := range candidates {
+		var sum float64
+		for t := range terms {
+			if has[i][t] {
+				sum += idf[t]
+			}
+		}
+		if total > 0 {
+			scores[i] = float32(sum / total)
+		}
+	}
+	return byScore(candidates, scores), nil
+}
+
+// MMR is maximal marginal relevance: it picks candidates one at a time, each
+// the one most similar to the query and least similar to those picked
+// before it, so five results aren't five versions of the same answer.
+// Relevance is the similarity the search gave a candidate, so it works for
+// expanded queries too; redundancy is the cosine similarity of the
+// candidates' embeddings.
+type MMR struct {
+	// Lambda weighs relevance against redundancy: 1 is the search's order,
+	// 0 ignores the query after the first pick.
+	Lambda float32
+}
+
+func (m MMR) Name() string { return fmt.Sprintf("mmr λ=%.1f", m.Lambda) }
+
+// Rerank returns the candidates in the order they were picked. Each Score is
+// its marginal relevance when it was picked, so scores can rise again after
+// a redundant pick.
+func (m MMR) Rerank(_ context.Context, _ string, candidates []chromem.Result) ([]Reranked, error) {
+	picked := make([]bool, len(candidates))
+	redundancy := make([]float32, len(candidates)) // the most similar pick so far
+	reranked := make([]Reranked, 0, len(candidates))
+	for range candidates {
+		best, bestScore := -1, float32(0)
+		for i, c := range candidates {
+			if picked[i] {
+				continue
+			}
+			score := m.Lambda*c.Similarity - (1-m.Lambda)*redundancy[i]
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		picked[best] = true
+		reranked = append(reranked, Reranked{Result: candidates[best], Rank: best + 1, Score: bestScore})
+		for i, c := range candidates {
+			if picked[i] {
+				continue
+			}
+			var dot float32
+			for d := range c.Embedding {
+				dot += c.Embedding[d] * candidates[best].Embedding[d]
+			}
+			// The first pick sets it, whatever its sign
+			if len(reranked) == 1 || dot > redundancy[i] {
+				redundancy[i] = dot
+			}
+		}
+	}
+	return reranked, nil
+}
+
+// ModelReranker scores a candidate by its sentence most similar to the
+// query, embedded by the local model - Ollama's or the built-in one. A
+// cross-encoder reads the query and the document together; a bi-encoder
+// can't, but comparing the query with each sentence rather than the whole
+// document lets one sentence that answers it count in full.
+type ModelReranker struct {
+	snippeter *Snippeter
+}
+
+// NewModelReranker returns a reranker that embeds with embed. Sentences are
+// cached, like for excerpts, so a document is embedded once per session.
+func NewModelReranker(embed chromem.EmbeddingFunc) *ModelReranker {
+	return &ModelReranker{snippeter: NewSnippeter(embed, 1)}
+}
+
+func (*ModelReranker) Name() string { return "model" }
+
+func (m *ModelReranker) Rerank(ctx context.Context, query string, candidates []chromem.Result) ([]Reranked, error) {
+	scores := make([]float32, len(candidates))
+	for i, c := range candidates {
+		excerpt, err := m.snippeter.Excerpt(ctx, query, c.Content)
+		if err != nil {
+			return nil, err
+		}
+		if len(excerpt.Passages) > 0 {
+			scores[i] = excerpt.Passages[0].Score
+		}
+	}
+	return byScore(candidates, scores), nil
+}
+
+// rerankerNames are the rerankers -rerank takes.
+var rerankerNames = []string{"lexical", "mmr", "model", "http"}
+
+// rerankerFlags are the flags that pick a reranker, shared by the demo, the
+// REPL and the eval harness.
+type rerankerFlags struct {
+	name, url, model string
+	candidates       int
+	lambda           float64
+}
+
+func (f *rerankerFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.name, "rerank", "", "rerank the top candidates with "+strings.Join(rerankerNames, ", ")+", empty for none")
+	fs.IntVar(&f.candidates, "candidates", 10, "results of the search to rerank")
+	fs.Float64Var(&f.lambda, "lambda", 0.7, "MMR's weight of relevance against redundancy")
+	fs.StringVar(&f.url, "rerank-url", "", "rerank API for -rerank http, e.g. http://localhost:8080/v1; empty starts a local fake")
+	fs.StringVar(&f.model, "rerank-model", "bge-reranker-v2-m3", "model the rerank API should use")
+}
+
+// open returns the reranker named name, or nil for "". The HTTP reranker
+// talks to a local fake server unless there's a URL; stop stops it.
+func (f *rerankerFlags) validate() error {
+	if f.name != "" && !slices.Contains(rerankerNames, f.name) {
+		return fmt.Errorf("-rerank takes %s", strings.Join(rerankerNames, ", "))
+	}
+	if f.candidates <= 0 {
+		return fmt.Errorf("-candidates must be positive")
+	}
+	return nil
+}
+
+func (f *rerankerFlags) open(name string, embed chromem.EmbeddingFunc) (r Reranker, stop func(), err error) {
+	switch name {
+	case "":
+		return nil, func() {}, nil
+	case "lexical":
+		return Lexical{}, func() {}, nil
+	case "mmr":
+		return MMR{Lambda: float32(f.lambda)}, func() {}, nil
+	case "model":
+		return NewModelReranker(embed), func() {}, nil
+	case "http":
+		if f.url != "" {
+			return NewHTTPReranker(HTTPRerankerConfig{BaseURL: f.url, Model: f.model}), func() {}, nil
+		}
+		srv := NewFakeRerankServer(f.model)
+		return NewHTTPReranker(HTTPRerankerConfig{BaseURL: srv.URL + "/v1", Model: f.model}), srv.Close, nil
+	}
+	return nil, nil, fmt.Errorf("unknown reranker %q, try %s", name, strings.Join(rerankerNames, ", "))
+}
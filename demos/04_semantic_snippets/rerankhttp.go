@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// HTTPRerankerConfig configures a client for a rerank API in the shape
+// Cohere and Jina defined and local servers copied: llama.cpp's server,
+// vLLM, LocalAI and Infinity.
+type HTTPRerankerConfig struct {
+	// BaseURL is the API root, e.g. "http://localhost:8080/v1". The client
+	// posts to BaseURL + "/rerank".
+	BaseURL string
+	// APIKey is sent as a bearer token if set.
+	APIKey string
+	// Model is the reranker model the server expects.
+	Model string
+	// MaxRetries is the number of retries after a failed request. Zero
+	// means 3 and a negative value none; only network errors, timeouts, 429
+	// and 5xx are retried.
+	MaxRetries int
+	// Timeout limits each request. Defaults to 30s.
+	Timeout time.Duration
+	// HTTPClient defaults to a client without a timeout of its own; Timeout
+	// applies per request instead.
+	HTTPClient *http.Client
+}
+
+// HTTPReranker reranks with a model behind a rerank API, typically a
+// cross-encoder that reads the query and each document together. All
+// candidates go in one request.
+type HTTPReranker struct {
+	cfg HTTPRerankerConfig
+
+	mu    sync.Mutex
+	stats HTTPRerankerStats
+}
+
+// HTTPRerankerStats counts what an HTTP reranker did.
+type HTTPRerankerStats struct {
+	Documents int
+	Requests  int // including retries
+	Retries   int
+}
+
+// RerankError is an error response from a rerank API.
+type RerankError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *RerankError) Error() string {
+	return fmt.Sprintf("rerank API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// NewHTTPReranker returns a reranker for cfg, filling in defaults. It doesn't
+// contact the server.
+func NewHTTPReranker(cfg HTTPRerankerConfig) *HTTPReranker {
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+	return &HTTPReranker{cfg: cfg}
+}
+
+func (h *HTTPReranker) Name() string { return "http " + h.cfg.Model }
+
+// Stats returns what the reranker did so far.
+func (h *HTTPReranker) Stats() HTTPRerankerStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stats
+}
+
+// Rerank sends the query and the candidates' contents in one request,
+// retrying with the Ollama embedder's backoff, and orders the candidates by the
+// relevance scores the server returns.
+func (h *HTTPReranker) Rerank(ctx context.Context, query string, candidates []chromem.Result) ([]Reranked, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	documents := make([]string, len(candidates))
+	for i, c := range candidates {
+		documents[i] = c.Content
+	}
+	body, err := json.Marshal(map[string]any{"model": h.cfg.Model, "query": query, "documents": documents, "top_n": len(documents)})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't encode request: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		scores, err := h.post(ctx, body, len(documents))
+		if err == nil {
+			h.mu.Lock()
+			h.stats.Documents += len(documents)
+			h.mu.Unlock()
+			return byScore(candidates, scores), nil
+		}
+		var rerankErr *RerankError
+		if errors.As(err, &rerankErr) && rerankErr.StatusCode != http.StatusTooManyRequests && rerankErr.StatusCode < 500 {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if attempt == h.cfg.MaxRetries {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		h.mu.Lock()
+		h.stats.Retries++
+		h.mu.Unlock()
+		select {
+		case <-time.After(backoff(attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// post sends one request and returns a score per document, in the order
+// they were sent. Servers return results best first, so they're put back in
+// order by index.
+func (h *HTTPReranker) post(ctx context.Context, body []byte, n int) ([]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.BaseURL+"/rerank", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.cfg.APIKey)
+	}
+
+	h.mu.Lock()
+	h.stats.Requests++
+	h.mu.Unlock()
+	resp, err := h.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(data))
+		var parsed struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &parsed) == nil && parsed.Error != "" {
+			msg = parsed.Error
+		}
+		return nil, &RerankError{StatusCode: resp.StatusCode, Message: msg}
+	}
+
+	var parsed struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float32 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("couldn't decode response: %w", err)
+	}
+	scores := make([]float32, n)
+	seen := make([]bool, n)
+	for _, r := range parsed.Results {
+		if r.Index < 0 || r.Index >= n || seen[r.Index] {
+			return nil, fmt.Errorf("rerank API returned index %d for %d documents", r.Index, n)
+		}
+		scores[r.Index], seen[r.Index] = r.RelevanceScore, true
+	}
+	if len(parsed.Results) != n {
+		return nil, fmt.Errorf("rerank API returned %d scores for %d documents", len(parsed.Results), n)
+	}
+	return scores, nil
+}
+
+// NewFakeRerankServer returns a started rerank API for tests and offline
+// runs, at the server's URL + "/v1". It scores documents by BM25 over the
+// documents of the request, squashed into 0..1 like a model's relevance
+// score, and answers like a real server: results best first, cut to top_n,
+// a 404 for another model and a 400 for an empty query or no documents.
+func NewFakeRerankServer(model string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/rerank", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model     string   `json:"model"`
+			Query     string   `json:"query"`
+			Documents []string `json:"documents"`
+			TopN      int      `json:"top_n"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if req.Model != model {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("model %q not found", req.Model)})
+			return
+		}
+		if strings.TrimSpace(req.Query) == "" || len(req.Documents) == 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "query and documents are required"})
+			return
+		}
+
+		type result struct {
+			Index          int     `json:"index"`
+			RelevanceScore float32 `json:"relevance_score"`
+		}
+		results := make([]result, len(req.Documents))
+		for i, s := range bm25(queryTerms(req.Query), req.Documents) {
+			results[i] = result{Index: i, RelevanceScore: float32(s / (1 + s))}
+		}
+		slices.SortStableFunc(results, func(a, b result) int { return cmp.Compare(b.RelevanceScore, a.RelevanceScore) })
+		if req.TopN > 0 {
+			results = results[:min(req.TopN, len(results))]
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"model": model, "results": results})
+	})
+	return httptest.NewServer(mux)
+}
+
+// bm25 scores documents for terms with BM25's usual k1 = 1.2 and b = 0.75,
+// counting words that start with a term like highlighting does.
+func bm25(terms []string, documents []string) []float64 {
+	const k1, b = 1.2, 0.75
+	tf := make([][]int, len(documents))
+	lengths := make([]float64, len(documents))
+	df := make([]int, len(terms))
+	var avg float64
+	for i, doc := range documents {
+		lengths[i] = float64(len(strings.FieldsFunc(doc, isWordSeparator)))
+		avg += lengths[i] / float64(len(documents))
+		tf[i] = make([]int, len(terms))
+		for t, term := range terms {
+			if tf[i][t] = len(termSpans(doc, []string{term})); tf[i][t] > 0 {
+				df[t]++
+			}
+		}
+	}
+	scores := make([]float64, len(documents))
+	for i := range documents {
+		for t := range terms {
+			if tf[i][t] == 0 {
+				continue
+			}
+			idf := math.Log(1 + (float64(len(documents))-float64(df[t])+0.5)/(float64(df[t])+0.5))
+			f := float64(tf[i][t])
+			scores[i] += idf * f * (k1 + 1) / (f + k1*(1-b+b*lengths[i]/max(avg, 1)))
+		}
+	}
+	return scores
+}
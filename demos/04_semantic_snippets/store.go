@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// metaEmbedder is the collection metadata key openCollection records the
+// name of the embedder that seeded a collection under.
+const metaEmbedder = "embedder"
+
+// openCollection opens a collection in the persistent DB at dbPath. If the
+// DB has no collections yet, it creates this one and seeds it with the
+// snippets, so the interactive modes work out of the box.
+//
+// An existing collection is refused if it was seeded by another embedder or
+// its embeddings don't have the dimension embedder produces: every query
+// would fail, or worse, compare vectors of two models.
+func openCollection(ctx context.Context, dbPath, name string, embedder *Embedder) (*chromem.DB, *chromem.Collection, error) {
+	db, err := chromem.NewPersistentDB(dbPath, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't open DB: %w", err)
+	}
+	if _, ok := db.ListCollections()[name]; ok {
+		if err := checkEmbedder(ctx, db, dbPath, name, embedder); err != nil {
+			return nil, nil, err
+		}
+		return db, db.GetCollection(name, embedder.Func), nil
+	}
+	if names := slices.Sorted(maps.Keys(db.ListCollections())); len(names) > 0 {
+		return nil, nil, fmt.Errorf("no collection %q in %s, it has: %s", name, dbPath, strings.Join(names, ", "))
+	}
+
+	fmt.Printf("🌱 Seeding %s with the documentation snippets...\n", dbPath)
+	collection, err := db.CreateCollection(name, map[string]string{metaEmbedder: embedder.Name}, embedder.Func)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't create collection: %w", err)
+	}
+	if err := collection.AddDocuments(ctx, createDocumentationSnippets(), embedder.Concurrency); err != nil {
+		return nil, nil, fmt.Errorf("couldn't add snippets: %w", err)
+	}
+	return db, collection, nil
+}
+
+// checkEmbedder compares the embedder recorded with a collection, if any,
+// and the dimension of its stored embeddings with embedder's, which it finds
+// by embedding one of the stored documents again.
+func checkEmbedder(ctx context.Context, db *chromem.DB, dbPath, name string, embedder *Embedder) error {
+	metadata, docs, err := exportCollection(db, name)
+	if err != nil {
+		return err
+	}
+	if recorded, ok := metadata[metaEmbedder]; ok && recorded != embedder.Name {
+		return fmt.Errorf("collection %q in %s was embedded by the %s, not the %s; open it with that embedder or use another -db",
+			name, dbPath, recorded, embedder.Name)
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	vec, err := embedder.Func(ctx, docs[0].Content)
+	if err != nil {
+		return fmt.Errorf("couldn't embed with the %s: %w", embedder.Name, err)
+	}
+	if stored := len(docs[0].Embedding); stored != len(vec) {
+		return fmt.Errorf("collection %q in %s has %d-dimensional embeddings, the %s makes %d; open it with the embedder that built it or use another -db",
+			name, dbPath, stored, embedder.Name, len(vec))
+	}
+	return nil
+}
+
+// readDocuments returns all documents of a collection, sorted by ID.
+func readDocuments(db *chromem.DB, name string) ([]chromem.Document, error) {
+	_, docs, err := exportCollection(db, name)
+	return docs, err
+}
+
+// exportCollection returns the metadata and the documents of a collection,
+// sorted by ID. chromem-go exposes neither, but its export is a gob of the
+// same structs.
+func exportCollection(db *chromem.DB, name string) (map[string]string, []chromem.Document, error) {
+	var buf bytes.Buffer
+	if err := db.ExportToWriter(&buf, false, "", name); err != nil {
+		return nil, nil, fmt.Errorf("couldn't export collection %q: %w", name, err)
+	}
+	persisted := struct {
+		Collections map[string]*struct {
+			Metadata  map[string]string
+			Documents map[string]*chromem.Document
+		}
+	}{}
+	if err := gob.NewDecoder(&buf).Decode(&persisted); err != nil {
+		return nil, nil, fmt.Errorf("couldn't decode collection %q: %w", name, err)
+	}
+	pc, ok := persisted.Collections[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("collection %q not found", name)
+	}
+	docs := make([]chromem.Document, 0, len(pc.Documents))
+	for _, doc := range pc.Documents {
+		docs = append(docs, *doc)
+	}
+	slices.SortFunc(docs, func(a, b chromem.Document) int { return strings.Compare(a.ID, b.ID) })
+	return pc.Metadata, docs, nil
+}
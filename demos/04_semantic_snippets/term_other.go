@@ -0,0 +1,22 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// makeRaw isn't supported here, so LineEditor reads plain lines.
+func makeRaw(fd int) (func() error, error) {
+	return nil, errors.New("raw terminal mode isn't supported on this platform")
+}
+
+// isNotTerminal can't tell here, since makeRaw fails for every fd.
+func isNotTerminal(err error) bool { return false }
+
+func terminalSize(fd int) (width, height int, err error) {
+	return 0, 0, errors.New("terminal size isn't supported on this platform")
+}
+
+func resizeSignals() []os.Signal { return nil }
@@ -0,0 +1,51 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// isNotTerminal reports whether makeRaw failed because fd isn't a terminal.
+func isNotTerminal(err error) bool { return errors.Is(err, syscall.ENOTTY) }
+
+// makeRaw switches the terminal fd to raw mode, so keys arrive one at a time
+// and unechoed, and returns a function that restores the previous mode. It
+// fails if fd isn't a terminal.
+func makeRaw(fd int) (func() error, error) {
+	var old syscall.Termios
+	if err := termios(fd, ioctlGetTermios, &old); err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.ICRNL | syscall.INLCR | syscall.IGNCR | syscall.IXON | syscall.ISTRIP
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := termios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	return func() error { return termios(fd, ioctlSetTermios, &old) }, nil
+}
+
+func termios(fd int, req uintptr, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// terminalSize returns the size of the terminal fd in columns and rows.
+func terminalSize(fd int) (width, height int, err error) {
+	var ws struct{ Row, Col, Xpixel, Ypixel uint16 }
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); errno != 0 {
+		return 0, 0, errno
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
+
+// resizeSignals are the signals sent when the terminal is resized.
+func resizeSignals() []os.Signal { return []os.Signal{syscall.SIGWINCH} }
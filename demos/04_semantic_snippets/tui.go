@@ -0,0 +1,630 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// runTUI implements `go run . tui`: a full-screen browser over a collection
+// in a persistent DB, with a query box that searches as you type, a result
+// list, a preview of the selected document and a panel of metadata facets.
+func runTUI(args []string) error {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	dbPath := fs.String("db", "./snippets-db", "persistent DB directory")
+	name := fs.String("collection", "docs", "collection to browse")
+	facets := fs.String("facets", "category,difficulty", "comma-separated metadata keys to filter by")
+	debounce := fs.Duration("debounce", 150*time.Millisecond, "how long typing has to pause before a search")
+	limit := fs.Int("limit", 50, "maximum number of results")
+	var flags embedderFlags
+	flags.register(fs)
+	fs.Parse(args)
+	if *limit <= 0 {
+		return fmt.Errorf("-limit must be positive")
+	}
+
+	ctx := context.Background()
+	embedder, err := flags.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer embedder.Close()
+	db, collection, err := openCollection(ctx, *dbPath, *name, embedder)
+	if err != nil {
+		return err
+	}
+	docs, err := readDocuments(db, *name)
+	if err != nil {
+		return err
+	}
+
+	fd := int(os.Stdin.Fd())
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("the TUI needs a terminal, try repl instead: %w", err)
+	}
+	defer restore()
+	// The alternate screen keeps the shell's scrollback as it was
+	fmt.Print("\x1b[?1049h")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	b := &browser{
+		ctx:        ctx,
+		collection: collection,
+		docs:       docs,
+		facets:     strings.Split(*facets, ","),
+		limit:      *limit,
+		embedder:   embedder.Name,
+		fd:         fd,
+		out:        bufio.NewWriter(os.Stdout),
+		filters:    make(map[string]string),
+		snippeter:  NewSnippeter(embedder.Func, 1),
+	}
+	return b.run(*debounce)
+}
+
+// Focus is the pane keys go to.
+const (
+	focusQuery = iota
+	focusResults
+	focusFacets
+	focusCount
+)
+
+// browser is the state of the TUI. Only run's goroutine touches it; searches
+// run on their own goroutines and send back a searchDone.
+type browser struct {
+	ctx        context.Context
+	collection *chromem.Collection
+	docs       []chromem.Document
+	facets     []string
+	limit      int
+	embedder   string
+	snippeter  *Snippeter
+	fd         int
+	out        *bufio.Writer
+
+	query         []rune
+	filters       map[string]string // the selected value of each facet
+	focus         int
+	results       []chromem.Result
+	excerpts      map[string]Excerpt // by document ID
+	selected      int
+	listTop       int
+	facetCursor   int
+	previewScroll int
+
+	seq      int  // of the latest search started
+	pending  bool // typing or searching, the results are stale
+	took     time.Duration
+	err      error
+	shownFor string // the query the results are for
+}
+
+// searchDone carries the results of one search back to the event loop.
+type searchDone struct {
+	seq      int
+	query    string
+	results  []chromem.Result
+	excerpts map[string]Excerpt
+	took     time.Duration
+	err      error
+}
+
+// action is what a key press asks the event loop to do.
+type action int
+
+const (
+	actionNone     action = iota
+	actionDebounce        // the query changed: search once typing pauses
+	actionSearch          // the filters changed: search now
+	actionQuit
+)
+
+// run is the event loop: it redraws after every event, restarts the debounce
+// timer on every change to the query, and drops results of searches that a
+// newer one has replaced.
+func (b *browser) run(debounce time.Duration) error {
+	keys := make(chan key)
+	keyErrs := make(chan error, 1)
+	go func() {
+		in := bufio.NewReader(os.Stdin)
+		for {
+			k, err := readKey(in)
+			if err != nil {
+				keyErrs <- err
+				return
+			}
+			keys <- k
+		}
+	}()
+	resized := make(chan os.Signal, 1)
+	if sigs := resizeSignals(); len(sigs) > 0 {
+		signal.Notify(resized, sigs...)
+		defer signal.Stop(resized)
+	}
+	done := make(chan searchDone, 8)
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	b.startSearch(done)
+	for {
+		b.draw()
+		select {
+		case k := <-keys:
+			switch b.handle(k) {
+			case actionQuit:
+				return nil
+			case actionDebounce:
+				b.pending = true
+				timer.Reset(debounce)
+			case actionSearch:
+				timer.Stop()
+				b.startSearch(done)
+			}
+		case err := <-keyErrs:
+			return err
+		case <-timer.C:
+			b.startSearch(done)
+		case d := <-done:
+			if d.seq != b.seq {
+				continue // a newer search is on its way
+			}
+			b.pending = false
+			b.results, b.excerpts, b.took, b.err, b.shownFor = d.results, d.excerpts, d.took, d.err, d.query
+			b.selected, b.listTop, b.previewScroll = 0, 0, 0
+		case <-resized:
+		}
+	}
+}
+
+// startSearch searches for the current query and filters in the background.
+func (b *browser) startSearch(done chan<- searchDone) {
+	b.seq++
+	b.pending = true
+	seq, query, where := b.seq, strings.TrimSpace(string(b.query)), maps.Clone(b.filters)
+	go func() {
+		start := time.Now()
+		var results []chromem.Result
+		var err error
+		if query == "" {
+			// Nothing to rank by: list what passes the filters
+			for _, doc := range b.docs {
+				if matchesFilters(doc.Metadata, where, "") {
+					results = append(results, chromem.Result{ID: doc.ID, Metadata: doc.Metadata, Content: doc.Content})
+				}
+			}
+		} else {
+			results, err = b.collection.Query(b.ctx, query, min(b.limit, b.collection.Count()), where, nil)
+		}
+		took := time.Since(start)
+		excerpts := make(map[string]Excerpt)
+		for _, r := range results {
+			if query == "" || err != nil {
+				break
+			}
+			// Without an excerpt the preview just doesn't mark a sentence
+			if excerpt, err := b.snippeter.Excerpt(b.ctx, query, r.Content); err == nil {
+				excerpts[r.ID] = excerpt
+			}
+		}
+		done <- searchDone{seq: seq, query: query, results: results, excerpts: excerpts, took: took, err: err}
+	}()
+}
+
+// handle applies a key press to the state.
+func (b *browser) handle(k key) action {
+	switch {
+	case k.r == 3 || k.name == "esc": // Ctrl-C
+		return actionQuit
+	case k.r == '\t':
+		b.focus = (b.focus + 1) % focusCount
+		return actionNone
+	case k.name == "btab":
+		b.focus = (b.focus + focusCount - 1) % focusCount
+		return actionNone
+	case k.name == "pgdn":
+		b.previewScroll++
+		return actionNone
+	case k.name == "pgup":
+		b.previewScroll = max(b.previewScroll-1, 0)
+		return actionNone
+	}
+
+	if b.focus == focusFacets {
+		rows := b.facetValues()
+		switch {
+		case k.name == "up":
+			b.facetCursor = max(b.facetCursor-1, 0)
+		case k.name == "down":
+			b.facetCursor = min(b.facetCursor+1, len(rows)-1)
+		case (k.r == ' ' || k.r == '\r') && b.facetCursor < len(rows):
+			row := rows[b.facetCursor]
+			if b.filters[row.key] == row.value {
+				delete(b.filters, row.key)
+			} else {
+				b.filters[row.key] = row.value
+			}
+			return actionSearch
+		case unicode.IsPrint(k.r):
+			// Typing anywhere goes to the query
+			b.focus = focusQuery
+			return b.handle(k)
+		}
+		return actionNone
+	}
+
+	switch {
+	case k.name == "up" || k.r == 16: // Ctrl-P
+		b.selected = max(b.selected-1, 0)
+		b.previewScroll = 0
+	case k.name == "down" || k.r == 14: // Ctrl-N
+		b.selected = max(min(b.selected+1, len(b.results)-1), 0)
+		b.previewScroll = 0
+	case k.name == "home" && b.focus == focusResults:
+		b.selected, b.previewScroll = 0, 0
+	case k.name == "end" && b.focus == focusResults:
+		b.selected, b.previewScroll = max(len(b.results)-1, 0), 0
+	case k.r == '\r':
+		b.focus = focusResults
+	case k.r == 127 || k.r == 8: // Backspace
+		if len(b.query) > 0 {
+			b.query = b.query[:len(b.query)-1]
+			return actionDebounce
+		}
+	case k.r == 21: // Ctrl-U
+		b.query = b.query[:0]
+		return actionDebounce
+	case k.r == 23: // Ctrl-W
+		end := len(b.query)
+		for end > 0 && unicode.IsSpace(b.query[end-1]) {
+			end--
+		}
+		for end > 0 && !unicode.IsSpace(b.query[end-1]) {
+			end--
+		}
+		b.query = b.query[:end]
+		return actionDebounce
+	case unicode.IsPrint(k.r):
+		b.focus = focusQuery
+		b.query = append(b.query, k.r)
+		return actionDebounce
+	}
+	return actionNone
+}
+
+// facetValue is one value of a facet with the number of documents that
+// have it and pass the filters on the other facets.
+type facetValue struct {
+	key, value string
+	count      int
+}
+
+// facetValues counts the values of every facet. Each facet is counted with
+// the filters on the others only, so choosing a category still shows how
+// many documents every other category has.
+func (b *browser) facetValues() []facetValue {
+	var values []facetValue
+	for _, key := range b.facets {
+		counts := make(map[string]int)
+		for _, doc := range b.docs {
+			if v, ok := doc.Metadata[key]; ok && matchesFilters(doc.Metadata, b.filters, key) {
+				counts[v]++
+			}
+		}
+		if selected, ok := b.filters[key]; ok {
+			if _, seen := counts[selected]; !seen {
+				counts[selected] = 0 // keep it on screen so it can be unchecked
+			}
+		}
+		for _, v := range slices.Sorted(maps.Keys(counts)) {
+			values = append(values, facetValue{key: key, value: v, count: counts[v]})
+		}
+	}
+	return values
+}
+
+// matchesFilters reports whether metadata has every filtered value, except
+// the one for the key except.
+func matchesFilters(metadata, filters map[string]string, except string) bool {
+	for key, value := range filters {
+		if key != except && metadata[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Styles, as SGR parameters.
+const (
+	styleTitle     = "1"
+	styleDim       = "2"
+	styleSelected  = "7"
+	styleInactive  = "4"
+	styleHighlight = "1;30;43"
+	stylePassage   = "1;36"
+	styleStatus    = "7"
+	styleError     = "1;31"
+)
+
+// draw renders the whole screen:
+//
+//	 Search: query
+//	─────────────┬───────────────┬──────────────────
+//	 facets      │ results       │ preview
+//	─────────────┴───────────────┴──────────────────
+//	 status
+func (b *browser) draw() {
+	width, height, err := terminalSize(b.fd)
+	if err != nil || width == 0 {
+		width, height = 80, 24
+	}
+	w := b.out
+	w.WriteString("\x1b[?25l")
+	defer func() {
+		if b.focus == focusQuery {
+			fmt.Fprintf(w, "\x1b[1;%dH\x1b[?25h", min(len(" Search: ")+len(b.query)+1, width))
+		}
+		w.Flush()
+	}()
+	row := func(y int, content string) {
+		fmt.Fprintf(w, "\x1b[%d;1H%s\x1b[K", y, content)
+	}
+	if width < 60 || height < 8 {
+		w.WriteString("\x1b[2J")
+		row(1, "Make the terminal at least 60×8")
+		return
+	}
+
+	queryStyle := styleDim
+	if b.focus == focusQuery {
+		queryStyle = styleTitle
+	}
+	row(1, cell(" Search: ", 9, queryStyle, nil)+cell(string(b.query), width-9, "", nil))
+
+	facetWidth := 24
+	listWidth := (width - facetWidth - 2) * 2 / 5
+	previewWidth := width - facetWidth - listWidth - 2
+	bodyHeight := height - 4
+	row(2, strings.Repeat("─", facetWidth)+"┬"+strings.Repeat("─", listWidth)+"┬"+strings.Repeat("─", previewWidth))
+	row(height-1, strings.Repeat("─", facetWidth)+"┴"+strings.Repeat("─", listWidth)+"┴"+strings.Repeat("─", previewWidth))
+
+	facets := b.facetLines(bodyHeight, facetWidth)
+	list := b.listLines(bodyHeight, listWidth)
+	preview := b.previewLines(bodyHeight, previewWidth)
+	for i := range bodyHeight {
+		row(3+i, facets[i]+"│"+list[i]+"│"+preview[i])
+	}
+
+	status := fmt.Sprintf(" %d results · %v · %s", len(b.results), b.took.Round(10*time.Microsecond), b.embedder)
+	if b.pending {
+		status = " searching…"
+	}
+	help := "Tab: pane  ↑↓: move  Space: facet  PgUp/PgDn: preview  Esc: quit "
+	if b.err != nil {
+		row(height, cell(" "+b.err.Error(), width, styleError, nil))
+		return
+	}
+	gap := width - len([]rune(status)) - len([]rune(help))
+	if gap < 1 {
+		help, gap = "Esc: quit ", max(width-len([]rune(status))-10, 1)
+	}
+	row(height, cell(status+strings.Repeat(" ", gap)+help, width, styleStatus, nil))
+}
+
+// facetLines renders the facet panel: a heading per facet and its values
+// with counts, the selected ones checked.
+func (b *browser) facetLines(height, width int) []string {
+	lines := make([]string, 0, height)
+	values := b.facetValues()
+	b.facetCursor = max(min(b.facetCursor, len(values)-1), 0)
+	key := ""
+	for i, v := range values {
+		if v.key != key {
+			key = v.key
+			lines = append(lines, cell(" "+key, width, styleTitle, nil))
+		}
+		mark := "[ ]"
+		if b.filters[v.key] == v.value {
+			mark = "[x]"
+		}
+		style := ""
+		if i == b.facetCursor && b.focus == focusFacets {
+			style = styleSelected
+		}
+		lines = append(lines, cell(fmt.Sprintf("  %s %s (%d)", mark, v.value, v.count), width, style, nil))
+	}
+	return fill(lines, height, width)
+}
+
+// listLines renders the results, scrolled so the selected one is visible.
+func (b *browser) listLines(height, width int) []string {
+	b.selected = max(min(b.selected, len(b.results)-1), 0)
+	if b.selected < b.listTop {
+		b.listTop = b.selected
+	}
+	if b.selected >= b.listTop+height {
+		b.listTop = b.selected - height + 1
+	}
+	lines := make([]string, 0, height)
+	if len(b.results) == 0 && !b.pending {
+		lines = append(lines, cell(" No documents match", width, styleDim, nil))
+	}
+	for i := b.listTop; i < len(b.results) && len(lines) < height; i++ {
+		r := b.results[i]
+		text := fmt.Sprintf(" %s  %s", r.ID, r.Content)
+		if b.shownFor != "" {
+			text = fmt.Sprintf(" %.3f %s  %s", r.Similarity, r.ID, r.Content)
+		}
+		style := ""
+		if i == b.selected {
+			style = styleInactive
+			if b.focus == focusResults {
+				style = styleSelected
+			}
+		}
+		lines = append(lines, cell(text, width, style, nil))
+	}
+	return fill(lines, height, width)
+}
+
+// previewLines renders the selected document: its ID, metadata, score and
+// content wrapped to the pane, with the sentence closest to the query in
+// bold and the words of the query highlighted.
+func (b *browser) previewLines(height, width int) []string {
+	if len(b.results) == 0 {
+		return fill(nil, height, width)
+	}
+	r := b.results[b.selected]
+	excerpt := b.excerpts[r.ID]
+	lines := []string{cell(" "+r.ID, width, styleTitle, nil)}
+	for _, key := range slices.Sorted(maps.Keys(r.Metadata)) {
+		lines = append(lines, cell(fmt.Sprintf(" %s: %s", key, r.Metadata[key]), width, styleDim, nil))
+	}
+	if b.shownFor != "" {
+		lines = append(lines, cell(fmt.Sprintf(" similarity: %.4f", r.Similarity), width, styleDim, nil))
+	}
+	for _, p := range excerpt.Passages {
+		lines = append(lines, cell(fmt.Sprintf(" best sentence: %.4f", p.Score), width, styleDim, nil))
+	}
+	lines = append(lines, cell("", width, "", nil))
+
+	terms := queryTerms(b.shownFor)
+	for _, line := range wrap(r.Content, width-2) {
+		// Offsets in the line are one more than in the content, for the margin
+		from, to := line[0], line[1]
+		var spans []span
+		for _, p := range excerpt.Passages {
+			if p.Start < to && p.End > from {
+				spans = append(spans, span{max(p.Start, from) - from + 1, min(p.End, to) - from + 1, stylePassage})
+			}
+		}
+		for _, t := range termSpans(r.Content[from:to], terms) {
+			spans = append(spans, span{t[0] + 1, t[1] + 1, styleHighlight})
+		}
+		lines = append(lines, cell(" "+r.Content[from:to], width, "", spans))
+	}
+	b.previewScroll = min(b.previewScroll, max(len(lines)-height, 0))
+	return fill(lines[b.previewScroll:], height, width)
+}
+
+// fill pads lines with blank ones, or cuts them, to exactly height.
+func fill(lines []string, height, width int) []string {
+	for len(lines) < height {
+		lines = append(lines, strings.Repeat(" ", width))
+	}
+	return lines[:height]
+}
+
+// span styles the bytes from start to end of a cell.
+type span struct {
+	start, end int
+	style      string
+}
+
+// cell renders text in exactly width columns, cut with an ellipsis if it's
+// longer, in style, except where spans style it otherwise. Later spans win
+// where they overlap.
+func cell(text string, width int, style string, spans []span) string {
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	if len(runes) > width {
+		text = string(runes[:width-1]) + "…"
+		runes = []rune(text)
+	}
+	styles := make([]string, len(text))
+	for i := range styles {
+		styles[i] = style
+	}
+	for _, sp := range spans {
+		for i := max(sp.start, 0); i < min(sp.end, len(text)); i++ {
+			styles[i] = sp.style
+		}
+	}
+
+	var sb strings.Builder
+	current := "none"
+	setStyle := func(s string) {
+		current = s
+		sb.WriteString("\x1b[0m")
+		if s != "" {
+			sb.WriteString("\x1b[" + s + "m")
+		}
+	}
+	for i, r := range text {
+		if styles[i] != current {
+			setStyle(styles[i])
+		}
+		sb.WriteRune(r)
+	}
+	if current != style {
+		setStyle(style)
+	}
+	sb.WriteString(strings.Repeat(" ", width-len(runes)))
+	sb.WriteString("\x1b[0m")
+	return sb.String()
+}
+
+// wrap breaks text into lines of at most width runes at spaces, and words
+// longer than a line anywhere. It returns the byte range of each line.
+func wrap(text string, width int) [][2]int {
+	var lines [][2]int
+	lineStart, lineEnd, lineLen := 0, 0, 0 // no line is open while lineLen is 0
+	flush := func() {
+		if lineLen > 0 {
+			lines = append(lines, [2]int{lineStart, lineEnd})
+			lineLen = 0
+		}
+	}
+	for i := 0; i < len(text); {
+		if r, size := utf8.DecodeRuneInString(text[i:]); unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+		// The word is text[i:j], n runes long
+		j, n := i, 0
+		for j < len(text) {
+			r, size := utf8.DecodeRuneInString(text[j:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			j += size
+			n++
+		}
+		for n > width {
+			flush()
+			k := i
+			for range width {
+				_, size := utf8.DecodeRuneInString(text[k:])
+				k += size
+			}
+			lines = append(lines, [2]int{i, k})
+			i, n = k, n-width
+		}
+		switch {
+		case lineLen == 0:
+			lineStart, lineEnd, lineLen = i, j, n
+		case lineLen+1+n <= width:
+			lineEnd, lineLen = j, lineLen+1+n
+		default:
+			flush()
+			lineStart, lineEnd, lineLen = i, j, n
+		}
+		i = j
+	}
+	flush()
+	return lines
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Late interaction (ColBERT) parameters: 128-dimensional token vectors, a
+// typical passage length and a typical short query
+const (
+	tokenDimension  = 128
+	tokensPerDoc    = 64
+	tokensPerQuery  = 16
+	rerankCandidate = 100
+)
+
+// LateInteractionResult holds the cost of keeping token vectors next to a
+// collection and re-ranking its top candidates with them
+type LateInteractionResult struct {
+	DatasetSize     int
+	VectorMemory    uint64 // the collection, one vector per document
+	TokenMemory     uint64 // the token vectors
+	AvgFirstStage   time.Duration
+	AvgRerank       time.Duration
+	P95TotalLatency time.Duration
+}
+
+// runLateInteractionBenchmark measures the memory token vectors take and
+// the time a MaxSim re-rank of the top candidates adds to a query
+func runLateInteractionBenchmark(datasetSize int, queryCount int, dimension int) LateInteractionResult {
+	fmt.Printf("Benchmarking late interaction with %d documents...\n", datasetSize)
+
+	ctx := context.Background()
+	documents := generateTestDocuments(datasetSize, dimension)
+	queryEmbedding := generateRandomEmbedding(dimension)
+	queryTokens := make([][]float32, tokensPerQuery)
+	for i := range queryTokens {
+		queryTokens[i] = generateRandomEmbedding(tokenDimension)
+	}
+
+	var memBefore, memAfterVectors, memAfterTokens runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&memBefore)
+
+	collection, err := chromem.NewDB().CreateCollection("benchmark", nil, nil)
+	if err != nil {
+		log.Fatalf("Failed to create collection: %v", err)
+	}
+	if err := collection.AddDocuments(ctx, documents, 100); err != nil {
+		log.Fatalf("Failed to add documents: %v", err)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&memAfterVectors)
+
+	// One contiguous slice of token vectors per document, as a token store
+	// would keep them
+	tokens := make(map[string][]float32, datasetSize)
+	for _, doc := range documents {
+		bag := make([]float32, 0, tokensPerDoc*tokenDimension)
+		for range tokensPerDoc {
+			bag = append(bag, generateRandomEmbedding(tokenDimension)...)
+		}
+		tokens[doc.ID] = bag
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&memAfterTokens)
+
+	candidates := min(rerankCandidate, datasetSize)
+	var firstStage, rerank time.Duration
+	totals := make([]time.Duration, queryCount)
+	for i := range queryCount {
+		start := time.Now()
+		results, err := collection.QueryEmbedding(ctx, queryEmbedding, candidates, nil, nil)
+		if err != nil {
+			log.Fatalf("Query failed: %v", err)
+		}
+		firstDone := time.Now()
+		scores := make([]float32, len(results))
+		for j, r := range results {
+			scores[j] = maxSim(queryTokens, tokens[r.ID])
+		}
+		sort.Slice(scores, func(a, b int) bool { return scores[a] > scores[b] })
+		end := time.Now()
+
+		firstStage += firstDone.Sub(start)
+		rerank += end.Sub(firstDone)
+		totals[i] = end.Sub(start)
+	}
+	sort.Slice(totals, func(a, b int) bool { return totals[a] < totals[b] })
+	runtime.KeepAlive(tokens)
+
+	return LateInteractionResult{
+		DatasetSize:     datasetSize,
+		VectorMemory:    memAfterVectors.Alloc - memBefore.Alloc,
+		TokenMemory:     memAfterTokens.Alloc - memAfterVectors.Alloc,
+		AvgFirstStage:   firstStage / time.Duration(queryCount),
+		AvgRerank:       rerank / time.Duration(queryCount),
+		P95TotalLatency: totals[int(float64(queryCount)*0.95)],
+	}
+}
+
+// maxSim sums, over the query tokens, the best dot product with any of the
+// document's tokens
+func maxSim(queryTokens [][]float32, bag []float32) float32 {
+	var score float32
+	for _, q := range queryTokens {
+		best := float32(-1 << 30)
+		for off := 0; off < len(bag); off += tokenDimension {
+			d := bag[off : off+tokenDimension]
+			var dot float32
+			for k := range q {
+				dot += q[k] * d[k]
+			}
+			best = max(best, dot)
+		}
+		score += best
+	}
+	return score
+}
+
+// printLateInteractionResults shows what token vectors cost next to the
+// single vectors chromem-go keeps
+func printLateInteractionResults(results []LateInteractionResult) {
+	fmt.Println("\n" + strings.Repeat("=", 80))
+	fmt.Printf("LATE INTERACTION COST - %d×%d token vectors per document, MaxSim over the top %d\n", tokensPerDoc, tokenDimension, rerankCandidate)
+	fmt.Println(strings.Repeat("=", 80))
+
+	fmt.Printf("%-12s %-14s %-14s %-8s %-12s %-12s %-12s\n",
+		"Dataset", "Vectors(MB)", "Tokens(MB)", "Ratio", "Query(μs)", "Rerank(μs)", "P95 all(μs)")
+	fmt.Println(strings.Repeat("-", 80))
+
+	for _, r := range results {
+		fmt.Printf("%-12d %-14.2f %-14.2f %-8.1f %-12.0f %-12.0f %-12.0f\n",
+			r.DatasetSize,
+			float64(r.VectorMemory)/1024/1024,
+			float64(r.TokenMemory)/1024/1024,
+			float64(r.TokenMemory)/float64(r.VectorMemory),
+			float64(r.AvgFirstStage.Nanoseconds())/1000,
+			float64(r.AvgRerank.Nanoseconds())/1000,
+			float64(r.P95TotalLatency.Nanoseconds())/1000)
+	}
+}
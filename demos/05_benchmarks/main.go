@@ -229,6 +229,15 @@ func main() {
 	// Show detailed stats for the largest dataset
 	showDetailedStats(results[len(results)-1])
 
+	// What precision costs: token vectors for late-interaction re-ranking.
+	// Each query re-ranks 100 candidates, so fewer queries are enough
+	fmt.Println()
+	lateResults := make([]LateInteractionResult, len(datasetSizes))
+	for i, size := range datasetSizes {
+		lateResults[i] = runLateInteractionBenchmark(size, queryCount/10, dimension)
+	}
+	printLateInteractionResults(lateResults)
+
 	// Performance claims validation
 	fmt.Println("\n" + strings.Repeat("=", 50))
 	fmt.Println("PERFORMANCE ASSESSMENT")
@@ -272,6 +281,12 @@ func main() {
 	fmt.Printf("✅ Scalable throughput: %.0f QPS (100 docs) → %.0f QPS (1K docs) → %.0f QPS (10K docs)\n",
 		smallQPS, mediumQPS, largeQPS)
 
+	// Late interaction is a memory trade, not a free upgrade
+	largestLate := lateResults[len(lateResults)-1]
+	fmt.Printf("⚠️  Late interaction costs memory: %.0f× the single vectors (%.0f MB for %d docs), +%v per query\n",
+		float64(largestLate.TokenMemory)/float64(largestLate.VectorMemory),
+		float64(largestLate.TokenMemory)/1024/1024, largestLate.DatasetSize, largestLate.AvgRerank.Round(time.Microsecond))
+
 	// Zero infrastructure complexity
 	fmt.Printf("✅ Zero infrastructure: No Docker, no services, no configuration\n")
 
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"runtime"
+	"sort"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// collectionHeader describes a collection independently of the export format.
+type collectionHeader struct {
+	Name      string            `json:"name"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Dimension int               `json:"dimension"`
+	Count     int               `json:"count"`
+}
+
+// readCollection returns the header and all documents of a collection, sorted
+// by ID so that exports of the same data are byte-for-byte identical.
+//
+// chromem-go doesn't expose a way to list documents, but DB.ExportToWriter
+// writes a plain gob stream that we can decode into a mirror of its
+// persistence structs.
+func readCollection(db *chromem.DB, name string) (collectionHeader, []chromem.Document, error) {
+	var buf bytes.Buffer
+	if err := db.ExportToWriter(&buf, false, "", name); err != nil {
+		return collectionHeader{}, nil, fmt.Errorf("couldn't export collection %q: %w", name, err)
+	}
+
+	persisted := struct {
+		Collections map[string]*struct {
+			Name      string
+			Metadata  map[string]string
+			Documents map[string]*chromem.Document
+		}
+	}{}
+	if err := gob.NewDecoder(&buf).Decode(&persisted); err != nil {
+		return collectionHeader{}, nil, fmt.Errorf("couldn't decode collection %q: %w", name, err)
+	}
+
+	pc, ok := persisted.Collections[name]
+	if !ok {
+		return collectionHeader{}, nil, fmt.Errorf("collection %q not found", name)
+	}
+
+	docs := make([]chromem.Document, 0, len(pc.Documents))
+	for _, doc := range pc.Documents {
+		docs = append(docs, *doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+
+	header := collectionHeader{
+		Name:     pc.Name,
+		Metadata: pc.Metadata,
+		Count:    len(docs),
+	}
+	if len(docs) > 0 {
+		header.Dimension = len(docs[0].Embedding)
+	}
+	for _, doc := range docs {
+		if len(doc.Embedding) != header.Dimension {
+			return collectionHeader{}, nil, fmt.Errorf("document %q has %d dimensions, expected %d",
+				doc.ID, len(doc.Embedding), header.Dimension)
+		}
+	}
+
+	return header, docs, nil
+}
+
+// writeCollection creates the collection described by header and adds all
+// documents to it. A collection of that name that already holds documents is
+// only added to if merge is set and its dimension matches the header, since
+// imported documents would otherwise silently mix with the existing ones.
+func writeCollection(ctx context.Context, db *chromem.DB, header collectionHeader, docs []chromem.Document, merge bool) (*chromem.Collection, error) {
+	if existing := db.GetCollection(header.Name, nil); existing != nil && existing.Count() > 0 {
+		if !merge {
+			return nil, fmt.Errorf("collection %q already exists with %d documents; import it under another name or merge into it", header.Name, existing.Count())
+		}
+		current, _, err := readCollection(db, header.Name)
+		if err != nil {
+			return nil, err
+		}
+		if header.Count > 0 && current.Dimension != header.Dimension {
+			return nil, fmt.Errorf("collection %q has %d dimensions, the import has %d", header.Name, current.Dimension, header.Dimension)
+		}
+	}
+
+	collection, err := db.GetOrCreateCollection(header.Name, header.Metadata, nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create collection %q: %w", header.Name, err)
+	}
+	if len(docs) == 0 {
+		return collection, nil
+	}
+
+	err = collection.AddDocuments(ctx, docs, runtime.NumCPU())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't add documents to %q: %w", header.Name, err)
+	}
+
+	return collection, nil
+}
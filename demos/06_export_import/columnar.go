@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// The columnar format (.svec) stores one column per field instead of one
+// record per document:
+//
+//	header   "SVEC" | version u16 | reserved u16 | dimension u32 | count u64
+//	section  tag [4]byte | length u64 | crc32c u32 | payload   (repeated)
+//	footer   "SEND" | sections u32 | sha256 of everything before the footer
+//
+// Sections:
+//
+//	"COLL"  string table: collection name followed by sorted metadata key/value pairs
+//	"IDS "  string table with one document ID per row
+//	"TEXT"  string table with one content string per row
+//	"MCOL"  one per metadata key: key, presence bitmap, string table with one value per row
+//	"VECS"  count x dimension float32 matrix, row-major
+//
+// All integers and floats are little-endian. A string table is
+// n u32 | n+1 offsets u32 | concatenated bytes.
+const (
+	columnarMagic   = "SVEC"
+	columnarFooter  = "SEND"
+	columnarVersion = 1
+)
+
+var (
+	tagCollection = [4]byte{'C', 'O', 'L', 'L'}
+	tagIDs        = [4]byte{'I', 'D', 'S', ' '}
+	tagContents   = [4]byte{'T', 'E', 'X', 'T'}
+	tagMetaColumn = [4]byte{'M', 'C', 'O', 'L'}
+	tagVectors    = [4]byte{'V', 'E', 'C', 'S'}
+
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// exportColumnar writes a collection to w in the columnar format.
+func exportColumnar(w io.Writer, header collectionHeader, docs []chromem.Document) error {
+	bw := bufio.NewWriter(w)
+	h := sha256.New()
+	out := io.MultiWriter(bw, h)
+
+	fixed := make([]byte, 0, 20)
+	fixed = append(fixed, columnarMagic...)
+	fixed = binary.LittleEndian.AppendUint16(fixed, columnarVersion)
+	fixed = binary.LittleEndian.AppendUint16(fixed, 0)
+	fixed = binary.LittleEndian.AppendUint32(fixed, uint32(header.Dimension))
+	fixed = binary.LittleEndian.AppendUint64(fixed, uint64(len(docs)))
+	if _, err := out.Write(fixed); err != nil {
+		return fmt.Errorf("couldn't write header: %w", err)
+	}
+
+	sections := 0
+	writeSection := func(tag [4]byte, payload []byte) error {
+		sections++
+		buf := make([]byte, 0, 16)
+		buf = append(buf, tag[:]...)
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(len(payload)))
+		buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(payload, castagnoli))
+		if _, err := out.Write(buf); err != nil {
+			return fmt.Errorf("couldn't write section %q: %w", tag[:], err)
+		}
+		if _, err := out.Write(payload); err != nil {
+			return fmt.Errorf("couldn't write section %q: %w", tag[:], err)
+		}
+		return nil
+	}
+
+	// Collection name and metadata
+	collStrings := []string{header.Name}
+	for _, k := range sortedKeys(header.Metadata) {
+		collStrings = append(collStrings, k, header.Metadata[k])
+	}
+	if err := writeSection(tagCollection, encodeStringTable(collStrings)); err != nil {
+		return err
+	}
+
+	// IDs and contents
+	ids := make([]string, len(docs))
+	contents := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+		contents[i] = doc.Content
+	}
+	if err := writeSection(tagIDs, encodeStringTable(ids)); err != nil {
+		return err
+	}
+	if err := writeSection(tagContents, encodeStringTable(contents)); err != nil {
+		return err
+	}
+
+	// One column per metadata key
+	keySet := make(map[string]string)
+	for _, doc := range docs {
+		for k := range doc.Metadata {
+			keySet[k] = ""
+		}
+	}
+	for _, key := range sortedKeys(keySet) {
+		present := make([]byte, (len(docs)+7)/8)
+		values := make([]string, len(docs))
+		for i, doc := range docs {
+			if v, ok := doc.Metadata[key]; ok {
+				present[i/8] |= 1 << (i % 8)
+				values[i] = v
+			}
+		}
+		payload := encodeStringTable([]string{key})
+		payload = append(payload, present...)
+		payload = append(payload, encodeStringTable(values)...)
+		if err := writeSection(tagMetaColumn, payload); err != nil {
+			return err
+		}
+	}
+
+	// Embedding matrix
+	matrix := make([]byte, 0, len(docs)*header.Dimension*4)
+	for _, doc := range docs {
+		for _, v := range doc.Embedding {
+			matrix = binary.LittleEndian.AppendUint32(matrix, math.Float32bits(v))
+		}
+	}
+	if err := writeSection(tagVectors, matrix); err != nil {
+		return err
+	}
+
+	footer := make([]byte, 0, 40)
+	footer = append(footer, columnarFooter...)
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(sections))
+	footer = append(footer, h.Sum(nil)...)
+	if _, err := bw.Write(footer); err != nil {
+		return fmt.Errorf("couldn't write footer: %w", err)
+	}
+
+	return bw.Flush()
+}
+
+// importColumnar reads a columnar export from r, verifying each section's CRC
+// and the whole-file SHA-256 before returning any documents.
+func importColumnar(r io.Reader) (collectionHeader, []chromem.Document, error) {
+	br := bufio.NewReader(r)
+	h := sha256.New()
+	in := io.TeeReader(br, h)
+
+	fixed := make([]byte, 20)
+	if _, err := io.ReadFull(in, fixed); err != nil {
+		return collectionHeader{}, nil, fmt.Errorf("couldn't read header: %w", err)
+	}
+	if string(fixed[:4]) != columnarMagic {
+		return collectionHeader{}, nil, errors.New("not a columnar export (bad magic)")
+	}
+	if v := binary.LittleEndian.Uint16(fixed[4:]); v != columnarVersion {
+		return collectionHeader{}, nil, fmt.Errorf("unsupported version %d", v)
+	}
+	dim := int(binary.LittleEndian.Uint32(fixed[8:]))
+	count := int(binary.LittleEndian.Uint64(fixed[12:]))
+
+	header := collectionHeader{Dimension: dim, Count: count}
+	var docs []chromem.Document
+	var sawIDs, sawVectors bool
+	sections := 0
+
+	for {
+		// Read the tag without hashing it, since the footer tag is not covered
+		// by the file checksum.
+		var tag [4]byte
+		if _, err := io.ReadFull(br, tag[:]); err != nil {
+			return collectionHeader{}, nil, fmt.Errorf("couldn't read section tag: %w", err)
+		}
+		if string(tag[:]) == columnarFooter {
+			if err := verifyColumnarFooter(br, h, sections); err != nil {
+				return collectionHeader{}, nil, err
+			}
+			break
+		}
+		h.Write(tag[:])
+		sections++
+
+		payload, err := readSection(in, tag)
+		if err != nil {
+			return collectionHeader{}, nil, err
+		}
+
+		// Every per-document section needs at least one byte per row, which
+		// bounds the allocation even if the header count is corrupted.
+		if docs == nil && isRowSection(tag) {
+			if count > len(payload) {
+				return collectionHeader{}, nil, fmt.Errorf("section %q too small for %d documents", tag[:], count)
+			}
+			docs = make([]chromem.Document, count)
+		}
+
+		switch tag {
+		case tagCollection:
+			strs, _, err := decodeStringTable(payload)
+			if err != nil || len(strs) == 0 || len(strs)%2 != 1 {
+				return collectionHeader{}, nil, errors.New("malformed collection section")
+			}
+			header.Name = strs[0]
+			if len(strs) > 1 {
+				header.Metadata = make(map[string]string, len(strs)/2)
+				for i := 1; i < len(strs); i += 2 {
+					header.Metadata[strs[i]] = strs[i+1]
+				}
+			}
+		case tagIDs, tagContents:
+			strs, _, err := decodeStringTable(payload)
+			if err != nil || len(strs) != count {
+				return collectionHeader{}, nil, fmt.Errorf("malformed %q section", tag[:])
+			}
+			for i, s := range strs {
+				if tag == tagIDs {
+					docs[i].ID = s
+				} else {
+					docs[i].Content = s
+				}
+			}
+			sawIDs = sawIDs || tag == tagIDs
+		case tagMetaColumn:
+			if err := decodeMetaColumn(payload, docs); err != nil {
+				return collectionHeader{}, nil, err
+			}
+		case tagVectors:
+			if len(payload) != count*dim*4 {
+				return collectionHeader{}, nil, fmt.Errorf("vector section has %d bytes, expected %d", len(payload), count*dim*4)
+			}
+			for i := range docs {
+				docs[i].Embedding = make([]float32, dim)
+				for j := range docs[i].Embedding {
+					off := (i*dim + j) * 4
+					docs[i].Embedding[j] = math.Float32frombits(binary.LittleEndian.Uint32(payload[off:]))
+				}
+			}
+			sawVectors = true
+		default:
+			// Unknown sections are skipped so that newer writers can add
+			// columns without breaking older readers.
+		}
+	}
+
+	if header.Name == "" || !sawIDs || (!sawVectors && count > 0) {
+		return collectionHeader{}, nil, errors.New("export is missing required sections")
+	}
+
+	return header, docs, nil
+}
+
+func isRowSection(tag [4]byte) bool {
+	return tag == tagIDs || tag == tagContents || tag == tagMetaColumn || tag == tagVectors
+}
+
+func readSection(r io.Reader, tag [4]byte) ([]byte, error) {
+	var meta [12]byte
+	if _, err := io.ReadFull(r, meta[:]); err != nil {
+		return nil, fmt.Errorf("couldn't read section %q: %w", tag[:], err)
+	}
+	length := binary.LittleEndian.Uint64(meta[:8])
+	sum := binary.LittleEndian.Uint32(meta[8:])
+
+	// Copy through a LimitedReader rather than allocating length bytes up
+	// front, so a corrupted length can't make us allocate gigabytes.
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(r, int64(length)))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read section %q: %w", tag[:], err)
+	}
+	if uint64(n) != length {
+		return nil, fmt.Errorf("section %q truncated: got %d of %d bytes", tag[:], n, length)
+	}
+	if crc32.Checksum(buf.Bytes(), castagnoli) != sum {
+		return nil, fmt.Errorf("section %q failed CRC check", tag[:])
+	}
+	return buf.Bytes(), nil
+}
+
+func verifyColumnarFooter(r io.Reader, h hash.Hash, sections int) error {
+	var footer [36]byte
+	if _, err := io.ReadFull(r, footer[:]); err != nil {
+		return fmt.Errorf("couldn't read footer: %w", err)
+	}
+	if n := int(binary.LittleEndian.Uint32(footer[:4])); n != sections {
+		return fmt.Errorf("footer announces %d sections, file has %d", n, sections)
+	}
+	if !bytes.Equal(footer[4:], h.Sum(nil)) {
+		return errors.New("file checksum mismatch")
+	}
+	return nil
+}
+
+func decodeMetaColumn(payload []byte, docs []chromem.Document) error {
+	keys, n, err := decodeStringTable(payload)
+	if err != nil || len(keys) != 1 {
+		return errors.New("malformed metadata column")
+	}
+	key := keys[0]
+	payload = payload[n:]
+
+	bitmapLen := (len(docs) + 7) / 8
+	if len(payload) < bitmapLen {
+		return fmt.Errorf("metadata column %q truncated", key)
+	}
+	present := payload[:bitmapLen]
+	values, _, err := decodeStringTable(payload[bitmapLen:])
+	if err != nil || len(values) != len(docs) {
+		return fmt.Errorf("malformed metadata column %q", key)
+	}
+
+	for i := range docs {
+		if present[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		if docs[i].Metadata == nil {
+			docs[i].Metadata = make(map[string]string)
+		}
+		docs[i].Metadata[key] = values[i]
+	}
+	return nil
+}
+
+func encodeStringTable(strs []string) []byte {
+	size := 4 + 4*(len(strs)+1)
+	for _, s := range strs {
+		size += len(s)
+	}
+	buf := make([]byte, 0, size)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(strs)))
+	offset := uint32(0)
+	buf = binary.LittleEndian.AppendUint32(buf, offset)
+	for _, s := range strs {
+		offset += uint32(len(s))
+		buf = binary.LittleEndian.AppendUint32(buf, offset)
+	}
+	for _, s := range strs {
+		buf = append(buf, s...)
+	}
+	return buf
+}
+
+// decodeStringTable decodes a string table from the start of buf and returns
+// the strings and the number of bytes consumed.
+func decodeStringTable(buf []byte) ([]string, int, error) {
+	if len(buf) < 4 {
+		return nil, 0, errors.New("string table truncated")
+	}
+	n := int(binary.LittleEndian.Uint32(buf))
+	offsetsEnd := 4 + 4*(n+1)
+	if offsetsEnd > len(buf) {
+		return nil, 0, errors.New("string table truncated")
+	}
+	blob := buf[offsetsEnd:]
+	strs := make([]string, n)
+	prev := binary.LittleEndian.Uint32(buf[4:])
+	for i := 0; i < n; i++ {
+		next := binary.LittleEndian.Uint32(buf[4+4*(i+1):])
+		if prev > next || int(next) > len(blob) {
+			return nil, 0, errors.New("string table offsets out of range")
+		}
+		strs[i] = string(blob[prev:next])
+		prev = next
+	}
+	return strs, offsetsEnd + int(prev), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
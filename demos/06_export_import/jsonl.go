@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/philippgille/chromem-go"
+)
+
+const (
+	jsonlFormat  = "searchless-jsonl"
+	jsonlVersion = 1
+)
+
+// jsonlRecord is a single line of a JSONL export. The first line carries the
+// format and collection header, the last line the checksum, and every line in
+// between exactly one document.
+type jsonlRecord struct {
+	Format     string            `json:"format,omitempty"`
+	Version    int               `json:"version,omitempty"`
+	Collection *collectionHeader `json:"collection,omitempty"`
+
+	ID        string            `json:"id,omitempty"`
+	Content   string            `json:"content,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Embedding []float32         `json:"embedding,omitempty"`
+
+	Checksum *jsonlChecksum `json:"checksum,omitempty"`
+}
+
+// jsonlChecksum covers every byte of the file that precedes the checksum line.
+type jsonlChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Documents int    `json:"documents"`
+	Value     string `json:"value"`
+}
+
+// exportJSONL streams a collection to w, one document per line.
+func exportJSONL(w io.Writer, header collectionHeader, docs []chromem.Document) error {
+	bw := bufio.NewWriter(w)
+	h := sha256.New()
+	enc := json.NewEncoder(io.MultiWriter(bw, h))
+	enc.SetEscapeHTML(false)
+
+	err := enc.Encode(jsonlRecord{Format: jsonlFormat, Version: jsonlVersion, Collection: &header})
+	if err != nil {
+		return fmt.Errorf("couldn't write header: %w", err)
+	}
+
+	for _, doc := range docs {
+		err := enc.Encode(jsonlRecord{
+			ID:        doc.ID,
+			Content:   doc.Content,
+			Metadata:  doc.Metadata,
+			Embedding: doc.Embedding,
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't write document %q: %w", doc.ID, err)
+		}
+	}
+
+	// The checksum line itself must not be hashed, so write it to bw only.
+	trailer := json.NewEncoder(bw)
+	err = trailer.Encode(jsonlRecord{Checksum: &jsonlChecksum{
+		Algorithm: "sha256",
+		Documents: len(docs),
+		Value:     hex.EncodeToString(h.Sum(nil)),
+	}})
+	if err != nil {
+		return fmt.Errorf("couldn't write checksum: %w", err)
+	}
+
+	return bw.Flush()
+}
+
+// importJSONL reads a JSONL export from r. Documents are only returned once
+// the trailing checksum has been verified, so a truncated or edited file never
+// results in a partial import.
+func importJSONL(r io.Reader) (collectionHeader, []chromem.Document, error) {
+	br := bufio.NewReader(r)
+	h := sha256.New()
+
+	var header *collectionHeader
+	var docs []chromem.Document
+	for lineNo := 1; ; lineNo++ {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			return collectionHeader{}, nil, errors.New("unexpected end of file: checksum line missing")
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return collectionHeader{}, nil, fmt.Errorf("couldn't read line %d: %w", lineNo, err)
+		}
+
+		var rec jsonlRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return collectionHeader{}, nil, fmt.Errorf("line %d: invalid JSON: %w", lineNo, err)
+		}
+
+		switch {
+		case lineNo == 1:
+			if rec.Format != jsonlFormat || rec.Collection == nil {
+				return collectionHeader{}, nil, fmt.Errorf("line 1: not a %s file", jsonlFormat)
+			}
+			if rec.Version != jsonlVersion {
+				return collectionHeader{}, nil, fmt.Errorf("line 1: unsupported version %d", rec.Version)
+			}
+			header = rec.Collection
+		case rec.Checksum != nil:
+			if err := verifyJSONLChecksum(rec.Checksum, h, len(docs)); err != nil {
+				return collectionHeader{}, nil, err
+			}
+			if len(docs) != header.Count {
+				return collectionHeader{}, nil, fmt.Errorf("header announces %d documents, file has %d", header.Count, len(docs))
+			}
+			return *header, docs, nil
+		default:
+			if rec.ID == "" {
+				return collectionHeader{}, nil, fmt.Errorf("line %d: document without ID", lineNo)
+			}
+			if len(rec.Embedding) != header.Dimension {
+				return collectionHeader{}, nil, fmt.Errorf("line %d: document %q has %d dimensions, expected %d",
+					lineNo, rec.ID, len(rec.Embedding), header.Dimension)
+			}
+			docs = append(docs, chromem.Document{
+				ID:        rec.ID,
+				Content:   rec.Content,
+				Metadata:  rec.Metadata,
+				Embedding: rec.Embedding,
+			})
+		}
+
+		h.Write(line)
+		if errors.Is(err, io.EOF) {
+			return collectionHeader{}, nil, errors.New("unexpected end of file: checksum line missing")
+		}
+	}
+}
+
+func verifyJSONLChecksum(c *jsonlChecksum, h hash.Hash, docCount int) error {
+	if c.Algorithm != "sha256" {
+		return fmt.Errorf("unsupported checksum algorithm %q", c.Algorithm)
+	}
+	if c.Documents != docCount {
+		return fmt.Errorf("checksum covers %d documents, file has %d", c.Documents, docCount)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != c.Value {
+		return fmt.Errorf("checksum mismatch: file says %s, content hashes to %s", c.Value, got)
+	}
+	return nil
+}
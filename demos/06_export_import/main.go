@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func main() {
+	ctx := context.Background()
+
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "export":
+			err = runExport(os.Args[2:])
+		case "import":
+			err = runImport(ctx, os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command %q (want export or import)", os.Args[1])
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	runDemo(ctx)
+}
+
+// runExport implements `go run . export`.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPath := fs.String("db", "../03_persist_reload/chromem-data", "persistent DB directory")
+	name := fs.String("collection", "knowledge-base", "collection to export")
+	format := fs.String("format", "jsonl", "export format: jsonl or columnar")
+	out := fs.String("out", "", "output file (default: <collection>.jsonl or <collection>.svec)")
+	fs.Parse(args)
+
+	db, err := chromem.NewPersistentDB(*dbPath, false)
+	if err != nil {
+		return fmt.Errorf("couldn't open DB: %w", err)
+	}
+
+	header, docs, err := readCollection(db, *name)
+	if err != nil {
+		return err
+	}
+
+	path := *out
+	if path == "" {
+		path = *name + formatExtension(*format)
+	}
+	if err := exportToFile(path, *format, header, docs); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d documents (%d dimensions) from %q to %s\n",
+		header.Count, header.Dimension, header.Name, path)
+	return nil
+}
+
+// runImport implements `go run . import`.
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dbPath := fs.String("db", "./imported-data", "persistent DB directory to import into")
+	in := fs.String("in", "", "export file to import (format is detected automatically)")
+	rename := fs.String("collection", "", "import under a different collection name")
+	merge := fs.Bool("merge", false, "add to the collection if it already exists")
+	fs.Parse(args)
+
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+
+	header, docs, err := importFromFile(*in)
+	if err != nil {
+		return err
+	}
+	if *rename != "" {
+		header.Name = *rename
+	}
+
+	db, err := chromem.NewPersistentDB(*dbPath, false)
+	if err != nil {
+		return fmt.Errorf("couldn't open DB: %w", err)
+	}
+	if _, err := writeCollection(ctx, db, header, docs, *merge); err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d documents into %q in %s\n", len(docs), header.Name, *dbPath)
+	return nil
+}
+
+func formatExtension(format string) string {
+	if format == "columnar" {
+		return ".svec"
+	}
+	return ".jsonl"
+}
+
+// exportToFile writes the export to a temporary file first and renames it into
+// place, so readers never observe a half-written export.
+func exportToFile(path, format string, header collectionHeader, docs []chromem.Document) error {
+	var export func(io.Writer, collectionHeader, []chromem.Document) error
+	switch format {
+	case "jsonl":
+		export = exportJSONL
+	case "columnar":
+		export = exportColumnar
+	default:
+		return fmt.Errorf("unknown format %q (want jsonl or columnar)", format)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("couldn't create output directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("couldn't create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := export(tmp, header, docs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("couldn't sync export: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("couldn't close export: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// importFromFile detects the format from the first bytes of the file.
+func importFromFile(path string) (collectionHeader, []chromem.Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return collectionHeader{}, nil, fmt.Errorf("couldn't open export: %w", err)
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(len(columnarMagic))
+	if err != nil {
+		return collectionHeader{}, nil, fmt.Errorf("couldn't read %s: %w", path, err)
+	}
+	if string(magic) == columnarMagic {
+		return importColumnar(br)
+	}
+	return importJSONL(br)
+}
+
+func runDemo(ctx context.Context) {
+	fmt.Println("📦 Export & Import Demo - Portable Collections")
+	fmt.Println("=============================================")
+
+	outDir := "./exports"
+
+	// Create a small collection to export
+	db := chromem.NewDB()
+	collection, err := db.CreateCollection("knowledge-base",
+		map[string]string{
+			"description": "Technical documentation snippets",
+			"version":     "1.0",
+		}, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	err = collection.AddDocuments(ctx, knowledgeBase(), 1)
+	if err != nil {
+		panic(err)
+	}
+
+	header, docs, err := readCollection(db, "knowledge-base")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("\n📝 Source collection %q: %d documents, %d dimensions\n",
+		header.Name, header.Count, header.Dimension)
+
+	// Export to both formats
+	fmt.Println("\n📤 Exporting...")
+	for _, format := range []string{"jsonl", "columnar"} {
+		path := filepath.Join(outDir, header.Name+formatExtension(format))
+		if err := exportToFile(path, format, header, docs); err != nil {
+			panic(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("   %-9s → %s (%d bytes)\n", format, path, info.Size())
+	}
+
+	// JSONL is diffable: show the first lines
+	fmt.Println("\n🔎 JSONL is plain text, one document per line:")
+	jsonlPath := filepath.Join(outDir, header.Name+".jsonl")
+	data, err := os.ReadFile(jsonlPath)
+	if err != nil {
+		panic(err)
+	}
+	for i, line := range strings.SplitN(string(data), "\n", 3)[:2] {
+		if len(line) > 100 {
+			line = line[:100] + "…"
+		}
+		fmt.Printf("   %d │ %s\n", i+1, line)
+	}
+
+	// Import both exports into fresh databases and compare
+	fmt.Println("\n📥 Importing into fresh databases...")
+	queryEmbedding := []float32{0.15, 0.85, 0.35, 0.65, 0.45, 0.75, 0.25, 0.55, 0.4, 0.8, 0.15, 0.7, 0.35, 0.6, 0.45, 0.65}
+	for _, format := range []string{"jsonl", "columnar"} {
+		path := filepath.Join(outDir, header.Name+formatExtension(format))
+		gotHeader, gotDocs, err := importFromFile(path)
+		if err != nil {
+			panic(err)
+		}
+
+		target := chromem.NewDB()
+		imported, err := writeCollection(ctx, target, gotHeader, gotDocs, false)
+		if err != nil {
+			panic(err)
+		}
+
+		identical := reflect.DeepEqual(header, gotHeader) && reflect.DeepEqual(docs, gotDocs)
+		fmt.Printf("   %-9s → %d documents, identical to source: %v\n", format, imported.Count(), identical)
+
+		results, err := imported.QueryEmbedding(ctx, queryEmbedding, 2, nil, nil)
+		if err != nil {
+			panic(err)
+		}
+		for i, result := range results {
+			fmt.Printf("      %d. [%s] Similarity: %.4f\n", i+1, result.ID, result.Similarity)
+		}
+	}
+
+	// Checksums catch corruption before anything is imported
+	fmt.Println("\n🛡️  Corrupting a copy of each export...")
+	for _, format := range []string{"jsonl", "columnar"} {
+		path := filepath.Join(outDir, header.Name+formatExtension(format))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		// Flip a bit in the middle of the file
+		data[len(data)/2] ^= 0x01
+		corrupted := path + ".corrupted"
+		if err := os.WriteFile(corrupted, data, 0o644); err != nil {
+			panic(err)
+		}
+		_, _, err = importFromFile(corrupted)
+		fmt.Printf("   %-9s → rejected: %v\n", format, err)
+		os.Remove(corrupted)
+	}
+
+	fmt.Println("\n🎯 Key Benefits:")
+	fmt.Println("   ✅ JSONL exports can be diffed, grepped and committed")
+	fmt.Println("   ✅ Columnar exports keep vectors compact and fast to load")
+	fmt.Println("   ✅ Checksums reject truncated or edited files")
+	fmt.Println("   ✅ Same bytes for the same data - exports are reproducible")
+	fmt.Println("\n💡 Try: go run . export -db ../03_persist_reload/chromem-data -format columnar")
+}
+
+func knowledgeBase() []chromem.Document {
+	return []chromem.Document{
+		{
+			ID:        "doc-001",
+			Content:   "Docker containers provide lightweight, portable application packaging",
+			Embedding: []float32{0.1, 0.9, 0.3, 0.7, 0.5, 0.8, 0.2, 0.6, 0.4, 0.9, 0.1, 0.8, 0.3, 0.7, 0.5, 0.6},
+			Metadata:  map[string]string{"category": "containerization", "difficulty": "beginner"},
+		},
+		{
+			ID:        "doc-002",
+			Content:   "Kubernetes orchestrates containers across clusters with automated scaling",
+			Embedding: []float32{0.2, 0.8, 0.4, 0.6, 0.3, 0.9, 0.1, 0.7, 0.5, 0.8, 0.2, 0.6, 0.4, 0.9, 0.3, 0.7},
+			Metadata:  map[string]string{"category": "orchestration", "difficulty": "advanced"},
+		},
+		{
+			ID:        "doc-003",
+			Content:   "Microservice architecture breaks applications into independent, deployable services",
+			Embedding: []float32{0.3, 0.7, 0.5, 0.9, 0.1, 0.6, 0.2, 0.8, 0.4, 0.7, 0.3, 0.9, 0.5, 0.6, 0.1, 0.8},
+			Metadata:  map[string]string{"category": "architecture", "difficulty": "intermediate"},
+		},
+		{
+			ID:        "doc-004",
+			Content:   "REST APIs enable communication between services using HTTP protocols",
+			Embedding: []float32{0.4, 0.6, 0.2, 0.8, 0.3, 0.7, 0.5, 0.9, 0.1, 0.6, 0.4, 0.8, 0.2, 0.7, 0.3, 0.9},
+			Metadata:  map[string]string{"category": "api", "difficulty": "beginner"},
+		},
+		{
+			ID:        "doc-005",
+			Content:   "Database sharding distributes data across multiple database instances",
+			Embedding: []float32{0.5, 0.9, 0.1, 0.7, 0.3, 0.8, 0.4, 0.6, 0.2, 0.9, 0.5, 0.7, 0.1, 0.8, 0.3, 0.6},
+			Metadata:  map[string]string{"category": "database", "difficulty": "advanced"},
+		},
+	}
+}
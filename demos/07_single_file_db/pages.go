@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// A single-file DB is a file header followed by append-only pages:
+//
+//	file header  "SLDB" | version u16 | reserved u16
+//	page         type u8 | flags u8 | reserved u16 | length u32 | crc32c u32 | payload
+//
+// Document pages hold one document each. An index page lists, for every
+// collection, its metadata and the offset of the latest page of each live
+// document. A trailer page points at the index page and marks the end of a
+// commit. Nothing is ever overwritten: an update appends a new document page,
+// a delete simply leaves the ID out of the next index.
+const (
+	fileMagic      = "SLDB"
+	fileVersion    = 1
+	fileHeaderSize = 8
+	pageHeaderSize = 12
+	trailerSize    = pageHeaderSize + 16
+
+	pageDocument byte = 1
+	pageIndex    byte = 2
+	pageTrailer  byte = 3
+
+	flagCompressed byte = 1 << 0
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// indexEntry describes one collection in an index page.
+type indexEntry struct {
+	metadata map[string]string
+	docs     map[string]int64
+}
+
+func fileHeader() []byte {
+	buf := make([]byte, 0, fileHeaderSize)
+	buf = append(buf, fileMagic...)
+	buf = binary.LittleEndian.AppendUint16(buf, fileVersion)
+	return binary.LittleEndian.AppendUint16(buf, 0)
+}
+
+func checkFileHeader(buf []byte) error {
+	if len(buf) < fileHeaderSize || string(buf[:4]) != fileMagic {
+		return errors.New("not a single-file DB (bad magic)")
+	}
+	if v := binary.LittleEndian.Uint16(buf[4:]); v != fileVersion {
+		return fmt.Errorf("unsupported file version %d", v)
+	}
+	return nil
+}
+
+// encodePage frames a payload as a page, compressing it first if requested and
+// if compression actually makes it smaller.
+func encodePage(typ byte, payload []byte, compress bool) []byte {
+	var flags byte
+	if compress && len(payload) > 64 {
+		var buf bytes.Buffer
+		fw, _ := flate.NewWriter(&buf, flate.BestSpeed)
+		fw.Write(payload)
+		fw.Close()
+		if buf.Len() < len(payload) {
+			payload = buf.Bytes()
+			flags |= flagCompressed
+		}
+	}
+
+	page := make([]byte, 0, pageHeaderSize+len(payload))
+	page = append(page, typ, flags, 0, 0)
+	page = binary.LittleEndian.AppendUint32(page, uint32(len(payload)))
+	page = binary.LittleEndian.AppendUint32(page, crc32.Checksum(payload, castagnoli))
+	return append(page, payload...)
+}
+
+// pageHeader is the decoded fixed-size part of a page.
+type pageHeader struct {
+	typ    byte
+	flags  byte
+	length int
+	crc    uint32
+}
+
+func decodePageHeader(buf []byte) pageHeader {
+	return pageHeader{
+		typ:    buf[0],
+		flags:  buf[1],
+		length: int(binary.LittleEndian.Uint32(buf[4:])),
+		crc:    binary.LittleEndian.Uint32(buf[8:]),
+	}
+}
+
+// decodePayload verifies the checksum of a stored payload and decompresses it
+// if necessary.
+func decodePayload(h pageHeader, stored []byte) ([]byte, error) {
+	if crc32.Checksum(stored, castagnoli) != h.crc {
+		return nil, errors.New("page failed CRC check")
+	}
+	if h.flags&flagCompressed == 0 {
+		return stored, nil
+	}
+	payload, err := io.ReadAll(flate.NewReader(bytes.NewReader(stored)))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decompress page: %w", err)
+	}
+	return payload, nil
+}
+
+func encodeDocument(collection string, doc chromem.Document) []byte {
+	buf := appendString(nil, collection)
+	buf = appendString(buf, doc.ID)
+	buf = appendString(buf, doc.Content)
+	buf = appendStringMap(buf, doc.Metadata)
+	buf = binary.AppendUvarint(buf, uint64(len(doc.Embedding)))
+	for _, v := range doc.Embedding {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+	}
+	return buf
+}
+
+func decodeDocument(payload []byte) (string, chromem.Document, error) {
+	r := &payloadReader{buf: payload}
+	collection := r.string()
+	doc := chromem.Document{
+		ID:       r.string(),
+		Content:  r.string(),
+		Metadata: r.stringMap(),
+	}
+	doc.Embedding = r.float32s(r.uvarint())
+	if r.err != nil {
+		return "", chromem.Document{}, fmt.Errorf("malformed document page: %w", r.err)
+	}
+	return collection, doc, nil
+}
+
+func encodeIndex(collections map[string]*indexEntry) []byte {
+	names := make([]string, 0, len(collections))
+	for name := range collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := binary.AppendUvarint(nil, uint64(len(names)))
+	for _, name := range names {
+		entry := collections[name]
+		buf = appendString(buf, name)
+		buf = appendStringMap(buf, entry.metadata)
+
+		ids := make([]string, 0, len(entry.docs))
+		for id := range entry.docs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		buf = binary.AppendUvarint(buf, uint64(len(ids)))
+		for _, id := range ids {
+			buf = appendString(buf, id)
+			buf = binary.AppendUvarint(buf, uint64(entry.docs[id]))
+		}
+	}
+	return buf
+}
+
+func decodeIndex(payload []byte) (map[string]*indexEntry, error) {
+	r := &payloadReader{buf: payload}
+	n := r.uvarint()
+	collections := make(map[string]*indexEntry)
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		name := r.string()
+		entry := &indexEntry{metadata: r.stringMap(), docs: make(map[string]int64)}
+		docCount := r.uvarint()
+		for j := uint64(0); j < docCount && r.err == nil; j++ {
+			id := r.string()
+			entry.docs[id] = int64(r.uvarint())
+		}
+		collections[name] = entry
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed index page: %w", r.err)
+	}
+	return collections, nil
+}
+
+func encodeTrailer(indexOffset int64, seq uint64) []byte {
+	buf := binary.LittleEndian.AppendUint64(nil, uint64(indexOffset))
+	return binary.LittleEndian.AppendUint64(buf, seq)
+}
+
+func decodeTrailer(payload []byte) (indexOffset int64, seq uint64, err error) {
+	if len(payload) != 16 {
+		return 0, 0, errors.New("malformed trailer page")
+	}
+	return int64(binary.LittleEndian.Uint64(payload)), binary.LittleEndian.Uint64(payload[8:]), nil
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendStringMap(buf []byte, m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf = binary.AppendUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		buf = appendString(buf, k)
+		buf = appendString(buf, m[k])
+	}
+	return buf
+}
+
+// payloadReader decodes the primitives written by the append* helpers. The
+// first error sticks, so callers can check it once at the end.
+type payloadReader struct {
+	buf []byte
+	err error
+}
+
+var errShortPayload = errors.New("payload truncated")
+
+func (r *payloadReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = errShortPayload
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *payloadReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf) {
+		r.err = errShortPayload
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *payloadReader) string() string {
+	return string(r.bytes(int(r.uvarint())))
+}
+
+func (r *payloadReader) stringMap() map[string]string {
+	n := r.uvarint()
+	if n == 0 || r.err != nil {
+		return nil
+	}
+	m := make(map[string]string)
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		k := r.string()
+		m[k] = r.string()
+	}
+	return m
+}
+
+// float32s reads n little-endian floats. n comes from the page, so it's
+// checked against what's left before it's multiplied or allocated.
+func (r *payloadReader) float32s(n uint64) []float32 {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.buf))/4 {
+		r.err = errShortPayload
+		return nil
+	}
+	raw := r.bytes(int(n) * 4)
+	v := make([]float32, n)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return v
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Options configure how a single-file DB is opened.
+type Options struct {
+	// ReadOnly opens the file without write access and memory-maps it.
+	// Several read-only handles can be open while a writer appends.
+	ReadOnly bool
+
+	// Compress compresses newly written pages with flate. Existing pages are
+	// read regardless of how they were written.
+	Compress bool
+}
+
+// Store is a single-file DB. All collections and documents live in one file
+// of append-only pages; the last trailer page marks the committed state.
+type Store struct {
+	mu   sync.Mutex
+	path string
+	opts Options
+
+	f      *os.File
+	mapped []byte
+	unmap  func() error
+
+	// end is the offset just past the last committed trailer. Anything beyond
+	// it is an incomplete append and is ignored (read-only) or truncated.
+	end         int64
+	size        int64 // physical file size, used to bound page reads
+	seq         uint64
+	recovered   bool
+	collections map[string]*indexEntry
+}
+
+// OpenStore opens the single-file DB at path, creating it unless opts.ReadOnly
+// is set.
+func OpenStore(path string, opts Options) (*Store, error) {
+	flag := os.O_RDWR | os.O_CREATE
+	if opts.ReadOnly {
+		flag = os.O_RDONLY
+	}
+	f, err := os.OpenFile(path, flag, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open DB file: %w", err)
+	}
+
+	s := &Store{path: path, opts: opts, f: f, collections: make(map[string]*indexEntry)}
+	if err := s.init(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) init() error {
+	fi, err := s.f.Stat()
+	if err != nil {
+		return fmt.Errorf("couldn't stat DB file: %w", err)
+	}
+	size := fi.Size()
+	s.size = size
+
+	if size == 0 {
+		if s.opts.ReadOnly {
+			return errors.New("DB file is empty")
+		}
+		if _, err := s.f.WriteAt(fileHeader(), 0); err != nil {
+			return fmt.Errorf("couldn't write file header: %w", err)
+		}
+		s.end = fileHeaderSize
+		return s.commit(nil)
+	}
+
+	if s.opts.ReadOnly {
+		s.mapped, s.unmap, err = mapFile(s.f, size)
+		if err != nil {
+			return fmt.Errorf("couldn't map DB file: %w", err)
+		}
+	}
+
+	header, err := s.readAt(0, fileHeaderSize)
+	if err != nil {
+		return fmt.Errorf("couldn't read file header: %w", err)
+	}
+	if err := checkFileHeader(header); err != nil {
+		return err
+	}
+
+	// Fast path: the file ends with a valid trailer.
+	indexOffset, end, ok := s.readTrailer(size - trailerSize)
+	if !ok {
+		// A crash during an append left a torn tail. Scan forward for the last
+		// complete commit.
+		var stop int64
+		indexOffset, end, stop, ok = s.scanForLastCommit(size)
+		if !ok {
+			return errors.New("no committed state found in DB file")
+		}
+		s.recovered = true
+		// Truncating drops everything after the last commit the scan reached.
+		// That's a torn tail only if nothing valid follows the bad bytes;
+		// otherwise a page in the middle is corrupt and later commits would go
+		// with it.
+		if !s.opts.ReadOnly {
+			if off, found := s.nextValidPage(stop, size); found {
+				return fmt.Errorf("DB file is corrupt at offset %d but has valid pages from offset %d on; "+
+					"not truncating the commits after it - open it read-only to read the last commit before it, "+
+					"or verify and repair it (see 09_verify_repair)", stop, off)
+			}
+		}
+	}
+	s.end = end
+
+	_, payload, err := s.page(indexOffset)
+	if err != nil {
+		return fmt.Errorf("couldn't read index page: %w", err)
+	}
+	s.collections, err = decodeIndex(payload)
+	if err != nil {
+		return err
+	}
+
+	if s.recovered && !s.opts.ReadOnly {
+		if err := s.f.Truncate(end); err != nil {
+			return fmt.Errorf("couldn't truncate torn tail: %w", err)
+		}
+		s.size = end
+	}
+	return nil
+}
+
+// readTrailer checks whether a valid trailer page starts at off and returns
+// the index offset it points to and the end of the commit.
+func (s *Store) readTrailer(off int64) (indexOffset, end int64, ok bool) {
+	if off < fileHeaderSize {
+		return 0, 0, false
+	}
+	typ, payload, err := s.page(off)
+	if err != nil || typ != pageTrailer {
+		return 0, 0, false
+	}
+	indexOffset, seq, err := decodeTrailer(payload)
+	if err != nil || indexOffset >= off {
+		return 0, 0, false
+	}
+	s.seq = seq
+	return indexOffset, off + trailerSize, true
+}
+
+// scanForLastCommit walks the pages from the start of the file and returns
+// the last commit it finds and the offset of the first page it can't read,
+// or size if it read them all.
+func (s *Store) scanForLastCommit(size int64) (indexOffset, end, stop int64, ok bool) {
+	off := int64(fileHeaderSize)
+	for off+pageHeaderSize <= size {
+		raw, err := s.readAt(off, pageHeaderSize)
+		if err != nil {
+			break
+		}
+		h := decodePageHeader(raw)
+		if off+pageHeaderSize+int64(h.length) > size {
+			break
+		}
+		if h.typ == pageTrailer {
+			if idx, e, valid := s.readTrailer(off); valid {
+				indexOffset, end, ok = idx, e, true
+			}
+		} else if _, _, err := s.page(off); err != nil {
+			break
+		}
+		off += pageHeaderSize + int64(h.length)
+	}
+	return indexOffset, end, off, ok
+}
+
+// nextValidPage returns the offset of the first page after stop whose
+// checksum holds, trying every byte since a corrupt header's length can't be
+// trusted. A torn tail has none.
+func (s *Store) nextValidPage(stop, size int64) (int64, bool) {
+	for off := stop + 1; off+pageHeaderSize <= size; off++ {
+		raw, err := s.readAt(off, pageHeaderSize)
+		if err != nil {
+			break
+		}
+		h := decodePageHeader(raw)
+		if h.typ < pageDocument || h.typ > pageTrailer || h.length == 0 || off+pageHeaderSize+int64(h.length) > size {
+			continue
+		}
+		if _, _, err := s.page(off); err == nil {
+			return off, true
+		}
+	}
+	return 0, false
+}
+
+func (s *Store) readAt(off int64, n int) ([]byte, error) {
+	if s.mapped != nil {
+		if off < 0 || off+int64(n) > int64(len(s.mapped)) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return s.mapped[off : off+int64(n)], nil
+	}
+	buf := make([]byte, n)
+	if _, err := s.f.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// page reads, verifies and decompresses the page at off.
+func (s *Store) page(off int64) (byte, []byte, error) {
+	raw, err := s.readAt(off, pageHeaderSize)
+	if err != nil {
+		return 0, nil, err
+	}
+	h := decodePageHeader(raw)
+	if off+pageHeaderSize+int64(h.length) > s.size {
+		return 0, nil, fmt.Errorf("page at offset %d extends past end of file", off)
+	}
+	stored, err := s.readAt(off+pageHeaderSize, h.length)
+	if err != nil {
+		return 0, nil, err
+	}
+	payload, err := decodePayload(h, stored)
+	if err != nil {
+		return 0, nil, fmt.Errorf("page at offset %d: %w", off, err)
+	}
+	return h.typ, payload, nil
+}
+
+// commit appends pages followed by a new index and trailer. The data is
+// synced before the trailer is written and again after, so a trailer never
+// points at pages that haven't reached the disk.
+func (s *Store) commit(pages [][]byte) error {
+	if s.opts.ReadOnly {
+		return errors.New("DB is opened read-only")
+	}
+
+	var buf bytes.Buffer
+	for _, p := range pages {
+		buf.Write(p)
+	}
+	indexOffset := s.end + int64(buf.Len())
+	buf.Write(encodePage(pageIndex, encodeIndex(s.collections), s.opts.Compress))
+
+	if _, err := s.f.WriteAt(buf.Bytes(), s.end); err != nil {
+		return fmt.Errorf("couldn't append pages: %w", err)
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("couldn't sync pages: %w", err)
+	}
+
+	trailerOffset := s.end + int64(buf.Len())
+	trailer := encodePage(pageTrailer, encodeTrailer(indexOffset, s.seq+1), false)
+	if _, err := s.f.WriteAt(trailer, trailerOffset); err != nil {
+		return fmt.Errorf("couldn't append trailer: %w", err)
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("couldn't sync trailer: %w", err)
+	}
+
+	s.seq++
+	s.end = trailerOffset + trailerSize
+	s.size = s.end
+	return nil
+}
+
+// Put adds or replaces documents in a collection, creating the collection
+// with the given metadata if it doesn't exist yet. The whole batch is one
+// commit: after a crash either all documents are visible or none.
+func (s *Store) Put(collection string, metadata map[string]string, docs []chromem.Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.collections[collection]
+	if !ok {
+		entry = &indexEntry{metadata: maps.Clone(metadata), docs: make(map[string]int64)}
+	}
+
+	// Work on a copy of the doc offsets so a failed commit leaves the
+	// in-memory index untouched.
+	offsets := maps.Clone(entry.docs)
+	pages := make([][]byte, 0, len(docs))
+	off := s.end
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return errors.New("document ID is empty")
+		}
+		if len(doc.Embedding) == 0 {
+			return fmt.Errorf("document %q has no embedding", doc.ID)
+		}
+		p := encodePage(pageDocument, encodeDocument(collection, doc), s.opts.Compress)
+		offsets[doc.ID] = off
+		off += int64(len(p))
+		pages = append(pages, p)
+	}
+
+	previous := s.collections[collection]
+	s.collections[collection] = &indexEntry{metadata: entry.metadata, docs: offsets}
+	if err := s.commit(pages); err != nil {
+		s.restore(collection, previous)
+		return err
+	}
+	return nil
+}
+
+// Delete removes documents from a collection. No page is rewritten; the IDs
+// are just left out of the next index.
+func (s *Store) Delete(collection string, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.collections[collection]
+	if !ok {
+		return fmt.Errorf("collection %q not found", collection)
+	}
+	offsets := maps.Clone(entry.docs)
+	for _, id := range ids {
+		delete(offsets, id)
+	}
+
+	s.collections[collection] = &indexEntry{metadata: entry.metadata, docs: offsets}
+	if err := s.commit(nil); err != nil {
+		s.restore(collection, entry)
+		return err
+	}
+	return nil
+}
+
+func (s *Store) restore(collection string, previous *indexEntry) {
+	if previous == nil {
+		delete(s.collections, collection)
+	} else {
+		s.collections[collection] = previous
+	}
+}
+
+// Collections returns the names of all collections, sorted.
+func (s *Store) Collections() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.collections))
+	for name := range s.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get reads a single document without loading the rest of the collection.
+func (s *Store) Get(collection, id string) (chromem.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.collections[collection]
+	if !ok {
+		return chromem.Document{}, fmt.Errorf("collection %q not found", collection)
+	}
+	off, ok := entry.docs[id]
+	if !ok {
+		return chromem.Document{}, fmt.Errorf("document %q not found", id)
+	}
+	return s.document(off)
+}
+
+func (s *Store) document(off int64) (chromem.Document, error) {
+	typ, payload, err := s.page(off)
+	if err != nil {
+		return chromem.Document{}, err
+	}
+	if typ != pageDocument {
+		return chromem.Document{}, fmt.Errorf("page at offset %d is not a document page", off)
+	}
+	_, doc, err := decodeDocument(payload)
+	return doc, err
+}
+
+// Load copies every collection into db, typically a chromem.NewDB() in-memory
+// DB, so it can be queried with the regular chromem-go API.
+func (s *Store) Load(ctx context.Context, db *chromem.DB) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, entry := range s.collections {
+		collection, err := db.GetOrCreateCollection(name, entry.metadata, nil)
+		if err != nil {
+			return fmt.Errorf("couldn't create collection %q: %w", name, err)
+		}
+		if len(entry.docs) == 0 {
+			continue
+		}
+
+		docs := make([]chromem.Document, 0, len(entry.docs))
+		for _, off := range entry.docs {
+			doc, err := s.document(off)
+			if err != nil {
+				return fmt.Errorf("collection %q: %w", name, err)
+			}
+			docs = append(docs, doc)
+		}
+		if err := collection.AddDocuments(ctx, docs, runtime.NumCPU()); err != nil {
+			return fmt.Errorf("couldn't load collection %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// StoreStats summarizes how much of the file is still referenced.
+type StoreStats struct {
+	FileSize  int64
+	LiveBytes int64
+	Documents int
+	Commits   uint64
+	Recovered bool
+}
+
+// Stats reports file size versus the bytes referenced by the current index.
+func (s *Store) Stats() (StoreStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := StoreStats{FileSize: s.end, Commits: s.seq, Recovered: s.recovered}
+	for _, entry := range s.collections {
+		for _, off := range entry.docs {
+			raw, err := s.readAt(off, pageHeaderSize)
+			if err != nil {
+				return StoreStats{}, err
+			}
+			stats.LiveBytes += pageHeaderSize + int64(decodePageHeader(raw).length)
+			stats.Documents++
+		}
+	}
+	return stats, nil
+}
+
+// CopyTo writes a consistent copy of the committed state to path. The copy is
+// written to a temporary file, synced and renamed, so path either holds the
+// old file or a complete copy - never a partial one.
+func (s *Store) CopyTo(path string) error {
+	// Pages below end are never modified, but Compact replaces the file
+	// itself, so the copy holds the lock throughout.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errors.New("DB is closed")
+	}
+	end := s.end
+	var src io.Reader
+	if s.mapped != nil {
+		src = bytes.NewReader(s.mapped[:end])
+	} else {
+		src = io.NewSectionReader(s.f, 0, end)
+	}
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+}
+
+// Compact rewrites the file with only the live document pages, dropping
+// superseded versions and old indexes.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.opts.ReadOnly {
+		return errors.New("DB is opened read-only")
+	}
+
+	compacted := make(map[string]*indexEntry, len(s.collections))
+	err := writeFileAtomic(s.path, func(w io.Writer) error {
+		off := int64(fileHeaderSize)
+		if _, err := w.Write(fileHeader()); err != nil {
+			return err
+		}
+		for name, entry := range s.collections {
+			ne := &indexEntry{metadata: entry.metadata, docs: make(map[string]int64, len(entry.docs))}
+			for id, oldOff := range entry.docs {
+				// Copy the stored page as-is, including its compression.
+				raw, err := s.readAt(oldOff, pageHeaderSize)
+				if err != nil {
+					return err
+				}
+				p, err := s.readAt(oldOff, pageHeaderSize+decodePageHeader(raw).length)
+				if err != nil {
+					return err
+				}
+				if _, err := w.Write(p); err != nil {
+					return err
+				}
+				ne.docs[id] = off
+				off += int64(len(p))
+			}
+			compacted[name] = ne
+		}
+		index := encodePage(pageIndex, encodeIndex(compacted), s.opts.Compress)
+		trailer := encodePage(pageTrailer, encodeTrailer(off, s.seq+1), false)
+		if _, err := w.Write(index); err != nil {
+			return err
+		}
+		_, err := w.Write(trailer)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't compact DB: %w", err)
+	}
+
+	// The old handle still points at the replaced file. It stays open, and
+	// in use, until the new one is ready.
+	f, err := os.OpenFile(s.path, os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("couldn't reopen compacted DB: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("couldn't stat compacted DB: %w", err)
+	}
+	s.f.Close()
+	s.f = f
+	s.collections = compacted
+	s.end = fi.Size()
+	s.size = s.end
+	s.seq++
+	return nil
+}
+
+// Close releases the file and the memory mapping.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.unmap != nil {
+		err = s.unmap()
+		s.unmap, s.mapped = nil, nil
+	}
+	if s.f != nil {
+		if cerr := s.f.Close(); err == nil {
+			err = cerr
+		}
+		s.f = nil
+	}
+	return err
+}
+
+// writeFileAtomic writes to a temporary file next to path, syncs it, renames
+// it over path and syncs the directory.
+func writeFileAtomic(path string, write func(io.Writer) error) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("couldn't create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("couldn't sync temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("couldn't close temporary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("couldn't rename temporary file: %w", err)
+	}
+
+	// Make the rename itself durable.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// crashPoint names a spot in the write path where the crash-injection suite
+// can simulate the process dying.
+type crashPoint string
+
+const (
+	crashDuringWALWrite   crashPoint = "during WAL write"
+	crashAfterWALSync     crashPoint = "after WAL sync"
+	crashDuringApply      crashPoint = "during apply"
+	crashDuringCheckpoint crashPoint = "during checkpoint"
+
+	// crashAfterLastBatch is not a point in the write path: the process dies
+	// after the workload finished, without closing the DB.
+	crashAfterLastBatch crashPoint = "after last batch"
+)
+
+var errSimulatedCrash = errors.New("simulated crash")
+
+// crashInjector decides at each crash point whether the process dies there.
+// A nil injector never fires.
+type crashInjector func(crashPoint) bool
+
+func (c crashInjector) fires(p crashPoint) bool {
+	return c != nil && c(p)
+}
+
+func (c crashInjector) check(p crashPoint) {
+	if c.fires(p) {
+		panic(errSimulatedCrash)
+	}
+}
+
+// crashTrial is the outcome of one run of the suite.
+type crashTrial struct {
+	point   crashPoint
+	op      int
+	err     error
+	durable bool
+}
+
+// runCrashSuite runs random workloads against a DurableDB, kills them at a
+// random crash point, simulates losing every write that wasn't fsynced and
+// checks that recovery restores exactly the acknowledged batches - plus the
+// in-flight one if and only if its WAL record reached the disk.
+func runCrashSuite(trials int, seed uint64, verbose bool) []crashTrial {
+	results := make([]crashTrial, 0, trials)
+	for i := 0; i < trials; i++ {
+		rng := rand.New(rand.NewPCG(seed, uint64(i)))
+		res := runCrashTrial(rng)
+		results = append(results, res)
+		if verbose || res.err != nil {
+			status := "✅"
+			if res.err != nil {
+				status = "❌ " + res.err.Error()
+			}
+			fmt.Printf("   trial %3d: crash %-17s at batch %2d %s\n", i, res.point, res.op, status)
+		}
+	}
+	return results
+}
+
+// modelDB is the expected state: collection → ID → document.
+type modelDB map[string]map[string]chromem.Document
+
+func (m modelDB) apply(rec walRecord) {
+	docs, ok := m[rec.Collection]
+	if !ok {
+		docs = make(map[string]chromem.Document)
+		m[rec.Collection] = docs
+	}
+	if rec.Op == opDelete {
+		for _, id := range rec.IDs {
+			delete(docs, id)
+		}
+		return
+	}
+	for _, doc := range rec.Documents {
+		docs[doc.ID] = doc
+	}
+}
+
+func runCrashTrial(rng *rand.Rand) crashTrial {
+	dir, err := os.MkdirTemp("", "searchless-crash-*")
+	if err != nil {
+		return crashTrial{err: err}
+	}
+	defer os.RemoveAll(dir)
+
+	opts := DurableOptions{
+		Compress:        rng.IntN(2) == 0,
+		CheckpointEvery: 1 + rng.IntN(5),
+	}
+	workload := randomWorkload(rng, 5+rng.IntN(20))
+
+	// Count the crash points the workload passes, then pick one of them.
+	// Picking past the end means the process dies after the last batch.
+	var total int
+	counting := crashInjector(func(crashPoint) bool { total++; return false })
+	if err := runWorkload(dir+"-count", opts, counting, workload, nil); err != nil {
+		return crashTrial{err: fmt.Errorf("dry run failed: %w", err)}
+	}
+	os.RemoveAll(dir + "-count")
+	target := rng.IntN(total + 1)
+
+	var seen int
+	trial := crashTrial{point: crashAfterLastBatch}
+	injector := crashInjector(func(p crashPoint) bool {
+		seen++
+		if seen == target+1 {
+			trial.point = p
+			return true
+		}
+		return false
+	})
+
+	model := make(modelDB)
+	var crashed *DurableDB
+	err = runWorkload(dir, opts, injector, workload, func(i int, d *DurableDB, rec walRecord, ackErr error) {
+		crashed = d
+		trial.op = i
+		if ackErr == nil {
+			model.apply(rec)
+		}
+	})
+	if err != nil && !errors.Is(err, errSimulatedCrash) {
+		trial.err = fmt.Errorf("workload failed: %w", err)
+		return trial
+	}
+
+	// The in-flight batch must survive if and only if its WAL record was
+	// synced, even though it was never acknowledged.
+	if trial.point != crashAfterLastBatch {
+		trial.durable = trial.point != crashDuringWALWrite
+		if trial.durable {
+			model.apply(workload[trial.op])
+		}
+	}
+
+	if crashed != nil {
+		losePower(rng, crashed)
+	}
+
+	recovered, err := OpenDurableDB(dir, opts)
+	if err != nil {
+		trial.err = fmt.Errorf("recovery failed: %w", err)
+		return trial
+	}
+	defer recovered.Close()
+	trial.err = verifyAgainstModel(recovered, model)
+	return trial
+}
+
+// runWorkload applies batches until one of them crashes. onBatch is called
+// after each batch with its acknowledgement error.
+func runWorkload(dir string, opts DurableOptions, injector crashInjector, workload []walRecord, onBatch func(int, *DurableDB, walRecord, error)) (err error) {
+	d, err := openDurableDB(dir, opts, injector)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			if r != errSimulatedCrash {
+				panic(r)
+			}
+			err = errSimulatedCrash
+		}
+	}()
+
+	ctx := context.Background()
+	for i, rec := range workload {
+		if onBatch != nil {
+			// Register the DB before the batch, so a crash inside it still
+			// leaves the harness a handle to tear down.
+			onBatch(i, d, rec, errors.New("in flight"))
+		}
+		var batchErr error
+		switch rec.Op {
+		case opUpsert:
+			batchErr = d.Upsert(ctx, rec.Collection, rec.CollectionMetadata, rec.Documents)
+		case opDelete:
+			batchErr = d.Delete(ctx, rec.Collection, rec.IDs...)
+		}
+		if batchErr != nil {
+			return batchErr
+		}
+		if onBatch != nil {
+			onBatch(i, d, rec, nil)
+		}
+	}
+
+	if onBatch == nil {
+		return d.Close()
+	}
+	// Die without closing: whatever wasn't checkpointed stays in the WAL.
+	panic(errSimulatedCrash)
+}
+
+// losePower simulates a power loss after a crash: the unsynced tail of the
+// WAL is cut at a random point and every data file written since the last
+// checkpoint is kept, deleted or truncated at random.
+func losePower(rng *rand.Rand, d *DurableDB) {
+	if fi, err := d.wal.f.Stat(); err == nil && fi.Size() > d.wal.synced {
+		d.wal.f.Truncate(d.wal.synced + rng.Int64N(fi.Size()-d.wal.synced+1))
+	}
+	d.wal.close()
+
+	for path := range d.dirtyFiles {
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		switch rng.IntN(3) {
+		case 0:
+			// The write made it to disk.
+		case 1:
+			os.Remove(path)
+		case 2:
+			os.Truncate(path, rng.Int64N(fi.Size()+1))
+		}
+	}
+}
+
+func verifyAgainstModel(d *DurableDB, model modelDB) error {
+	ctx := context.Background()
+	for name, coll := range d.db.ListCollections() {
+		if _, ok := model[name]; !ok && coll.Count() > 0 {
+			return fmt.Errorf("unexpected collection %q after recovery", name)
+		}
+	}
+	for name, docs := range model {
+		coll := d.Collection(name)
+		if coll == nil {
+			if len(docs) == 0 {
+				continue
+			}
+			return fmt.Errorf("collection %q missing after recovery", name)
+		}
+		if coll.Count() != len(docs) {
+			return fmt.Errorf("collection %q has %d documents, expected %d", name, coll.Count(), len(docs))
+		}
+		for id, want := range docs {
+			got, err := coll.GetByID(ctx, id)
+			if err != nil {
+				return fmt.Errorf("collection %q: %w", name, err)
+			}
+			if got.Content != want.Content || !reflect.DeepEqual(got.Metadata, want.Metadata) ||
+				!sameDirection(got.Embedding, want.Embedding) {
+				return fmt.Errorf("collection %q: document %q differs after recovery", name, id)
+			}
+		}
+	}
+	return nil
+}
+
+// sameDirection compares embeddings up to normalization, which chromem-go
+// applies when documents are added.
+func sameDirection(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	return math.Abs(dot/math.Sqrt(na*nb)-1) < 1e-5
+}
+
+// randomWorkload creates upsert and delete batches over a small ID space, so
+// that batches overwrite and delete each other's documents.
+func randomWorkload(rng *rand.Rand, batches int) []walRecord {
+	collections := []string{"knowledge-base", "scratch"}
+	existing := make(map[string]map[string]bool)
+	workload := make([]walRecord, 0, batches)
+
+	for b := 0; b < batches; b++ {
+		name := collections[rng.IntN(len(collections))]
+		ids := existing[name]
+
+		if len(ids) > 0 && rng.IntN(4) == 0 {
+			var del []string
+			for id := range ids {
+				if rng.IntN(2) == 0 {
+					del = append(del, id)
+				}
+			}
+			if len(del) == 0 {
+				continue
+			}
+			sort.Strings(del)
+			for _, id := range del {
+				delete(ids, id)
+			}
+			workload = append(workload, walRecord{Op: opDelete, Collection: name, IDs: del})
+			continue
+		}
+
+		rec := walRecord{
+			Op:                 opUpsert,
+			Collection:         name,
+			CollectionMetadata: map[string]string{"owner": "crash-test"},
+		}
+		for n := 1 + rng.IntN(6); n > 0; n-- {
+			id := fmt.Sprintf("doc-%02d", rng.IntN(12))
+			embedding := make([]float32, 8)
+			for i := range embedding {
+				embedding[i] = rng.Float32() + 0.01
+			}
+			rec.Documents = append(rec.Documents, chromem.Document{
+				ID:        id,
+				Content:   fmt.Sprintf("%s version %d %s", id, b, strings.Repeat("·", rng.IntN(40))),
+				Metadata:  map[string]string{"batch": fmt.Sprint(b)},
+				Embedding: embedding,
+			})
+			if existing[name] == nil {
+				existing[name] = make(map[string]bool)
+			}
+			existing[name][id] = true
+		}
+		workload = append(workload, rec)
+	}
+	return workload
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// TestCrashRecovery runs the crash-injection suite with a fixed seed, so a
+// failure reproduces with go run . crashtest -seed 1.
+func TestCrashRecovery(t *testing.T) {
+	const seed = 1
+	trials := 200
+	if testing.Short() {
+		trials = 20
+	}
+	for i := range trials {
+		res := runCrashTrial(rand.New(rand.NewPCG(seed, uint64(i))))
+		if res.err != nil {
+			t.Fatalf("trial %d: crash %s at batch %d: %v", i, res.point, res.op, res.err)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/philippgille/chromem-go"
+)
+
+const walFileName = "wal.log"
+
+// DurableOptions configure a DurableDB.
+type DurableOptions struct {
+	// Compress is passed through to chromem.NewPersistentDB.
+	Compress bool
+
+	// CheckpointEvery is the number of logged batches after which the WAL is
+	// checkpointed into the chromem-go directory. Zero means 64.
+	CheckpointEvery int
+}
+
+// DurableDB wraps a chromem-go persistent DB with a write-ahead log.
+//
+// chromem-go writes one file per document and doesn't fsync, so a crash in the
+// middle of AddDocuments can leave some documents written, some missing and
+// one torn. DurableDB logs every batch first, fsyncs the log and only then
+// applies the batch. On open, batches that were logged but not yet
+// checkpointed are replayed, so every acknowledged write survives a crash.
+type DurableDB struct {
+	mu   sync.Mutex
+	dir  string
+	opts DurableOptions
+
+	db  *chromem.DB
+	wal *wal
+
+	// Files and directories written since the last checkpoint. They must be
+	// fsynced before the WAL can be emptied.
+	dirtyFiles map[string]struct{}
+	dirtyDirs  map[string]struct{}
+	pending    int
+
+	// Replayed is the number of batches recovered from the WAL on open.
+	Replayed int
+
+	crash crashInjector
+}
+
+// OpenDurableDB opens (or creates) the chromem-go persistent DB in dir and
+// recovers any batches left in its WAL.
+func OpenDurableDB(dir string, opts DurableOptions) (*DurableDB, error) {
+	return openDurableDB(dir, opts, nil)
+}
+
+func openDurableDB(dir string, opts DurableOptions, crash crashInjector) (*DurableDB, error) {
+	if opts.CheckpointEvery <= 0 {
+		opts.CheckpointEvery = 64
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("couldn't create DB directory: %w", err)
+	}
+
+	w, records, err := openWAL(filepath.Join(dir, walFileName))
+	if err != nil {
+		return nil, err
+	}
+	w.crash = crash
+
+	d := &DurableDB{
+		dir:        dir,
+		opts:       opts,
+		wal:        w,
+		dirtyFiles: make(map[string]struct{}),
+		dirtyDirs:  make(map[string]struct{}),
+		crash:      crash,
+	}
+
+	// Files touched by unacknowledged or un-checkpointed batches may be torn,
+	// and chromem-go refuses to open a directory with a torn document file.
+	// Remove them; replay writes them again.
+	if len(records) > 0 {
+		if err := d.removeUncheckpointed(records); err != nil {
+			w.close()
+			return nil, err
+		}
+	}
+
+	d.db, err = chromem.NewPersistentDB(dir, opts.Compress)
+	if err != nil {
+		w.close()
+		return nil, fmt.Errorf("couldn't open chromem-go DB: %w", err)
+	}
+
+	ctx := context.Background()
+	for _, rec := range records {
+		if err := d.apply(ctx, rec); err != nil {
+			w.close()
+			return nil, fmt.Errorf("couldn't replay WAL record %d: %w", rec.Seq, err)
+		}
+	}
+	d.Replayed = len(records)
+	if len(records) > 0 {
+		if err := d.checkpoint(); err != nil {
+			w.close()
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func (d *DurableDB) removeUncheckpointed(records []walRecord) error {
+	for _, rec := range records {
+		collDir := d.collectionDir(rec.Collection)
+		if rec.CreatesCollection {
+			// All documents of a collection created after the last checkpoint
+			// are in the WAL too, so rebuild the whole directory.
+			if err := os.RemoveAll(collDir); err != nil {
+				return fmt.Errorf("couldn't remove collection directory: %w", err)
+			}
+			continue
+		}
+		for _, doc := range rec.Documents {
+			err := os.Remove(d.docPath(rec.Collection, doc.ID))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("couldn't remove document file: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Add adds documents to a collection, creating it if necessary. It fails if
+// any of the IDs already exists. Documents must carry their embeddings, so
+// that replaying the WAL never depends on an embedding model.
+func (d *DurableDB) Add(ctx context.Context, collection string, metadata map[string]string, docs []chromem.Document) error {
+	return d.write(ctx, walRecord{Op: opAdd, Collection: collection, CollectionMetadata: metadata, Documents: docs})
+}
+
+// Upsert adds or replaces documents in a collection, creating it if
+// necessary.
+func (d *DurableDB) Upsert(ctx context.Context, collection string, metadata map[string]string, docs []chromem.Document) error {
+	return d.write(ctx, walRecord{Op: opUpsert, Collection: collection, CollectionMetadata: metadata, Documents: docs})
+}
+
+// Delete removes documents from a collection.
+func (d *DurableDB) Delete(ctx context.Context, collection string, ids ...string) error {
+	return d.write(ctx, walRecord{Op: opDelete, Collection: collection, IDs: ids})
+}
+
+func (d *DurableDB) write(ctx context.Context, rec walRecord) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.validate(ctx, rec); err != nil {
+		return err
+	}
+	rec.CreatesCollection = d.db.GetCollection(rec.Collection, nil) == nil
+
+	if err := d.wal.append(rec); err != nil {
+		return err
+	}
+	rec.Seq = d.wal.seq
+	d.crash.check(crashAfterWALSync)
+
+	if err := d.apply(ctx, rec); err != nil {
+		return fmt.Errorf("couldn't apply batch %d: %w", rec.Seq, err)
+	}
+
+	d.pending++
+	if d.pending >= d.opts.CheckpointEvery {
+		return d.checkpoint()
+	}
+	return nil
+}
+
+func (d *DurableDB) validate(ctx context.Context, rec walRecord) error {
+	if rec.Collection == "" {
+		return errors.New("collection name is empty")
+	}
+	switch rec.Op {
+	case opDelete:
+		if len(rec.IDs) == 0 {
+			return errors.New("no IDs to delete")
+		}
+		if d.db.GetCollection(rec.Collection, nil) == nil {
+			return fmt.Errorf("collection %q not found", rec.Collection)
+		}
+		return nil
+	case opAdd, opUpsert:
+		if len(rec.Documents) == 0 {
+			return errors.New("documents slice is nil or empty")
+		}
+		coll := d.db.GetCollection(rec.Collection, nil)
+		for _, doc := range rec.Documents {
+			if doc.ID == "" {
+				return errors.New("document ID is empty")
+			}
+			if len(doc.Embedding) == 0 {
+				return fmt.Errorf("document %q has no embedding", doc.ID)
+			}
+			if rec.Op == opAdd && coll != nil {
+				if _, err := coll.GetByID(ctx, doc.ID); err == nil {
+					return fmt.Errorf("document %q already exists", doc.ID)
+				}
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown operation %q", rec.Op)
+	}
+}
+
+// apply performs a logged batch on the chromem-go DB. It is idempotent, which
+// is what makes replaying a batch that was partially applied safe.
+func (d *DurableDB) apply(ctx context.Context, rec walRecord) error {
+	collDir := d.collectionDir(rec.Collection)
+	coll := d.db.GetCollection(rec.Collection, nil)
+
+	switch rec.Op {
+	case opDelete:
+		if coll == nil {
+			return nil
+		}
+		if err := coll.Delete(ctx, nil, nil, rec.IDs...); err != nil {
+			return err
+		}
+		d.dirtyDirs[collDir] = struct{}{}
+		return nil
+	default:
+		if coll == nil {
+			var err error
+			coll, err = d.db.CreateCollection(rec.Collection, rec.CollectionMetadata, nil)
+			if err != nil {
+				return err
+			}
+			d.dirtyFiles[d.metadataPath(rec.Collection)] = struct{}{}
+			d.dirtyDirs[d.dir] = struct{}{}
+		}
+		d.dirtyDirs[collDir] = struct{}{}
+
+		// Add one document at a time, so a crash can be injected between any
+		// two of them.
+		for _, doc := range rec.Documents {
+			d.crash.check(crashDuringApply)
+			if err := coll.AddDocument(ctx, doc); err != nil {
+				return fmt.Errorf("couldn't add document %q: %w", doc.ID, err)
+			}
+			d.dirtyFiles[d.docPath(rec.Collection, doc.ID)] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// Checkpoint makes everything written so far durable in the chromem-go
+// directory and empties the WAL.
+func (d *DurableDB) Checkpoint() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.checkpoint()
+}
+
+func (d *DurableDB) checkpoint() error {
+	for path := range d.dirtyFiles {
+		if err := syncPath(path); err != nil {
+			return fmt.Errorf("couldn't sync %s: %w", path, err)
+		}
+	}
+	for dir := range d.dirtyDirs {
+		if err := syncPath(dir); err != nil {
+			return fmt.Errorf("couldn't sync %s: %w", dir, err)
+		}
+	}
+	d.crash.check(crashDuringCheckpoint)
+
+	if err := d.wal.reset(); err != nil {
+		return err
+	}
+	clear(d.dirtyFiles)
+	clear(d.dirtyDirs)
+	d.pending = 0
+	return nil
+}
+
+// Collection returns a collection for querying. Writes must go through the
+// DurableDB, not through the returned collection.
+func (d *DurableDB) Collection(name string) *chromem.Collection {
+	return d.db.GetCollection(name, nil)
+}
+
+// Close checkpoints and closes the WAL.
+func (d *DurableDB) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.checkpoint(); err != nil {
+		d.wal.close()
+		return err
+	}
+	return d.wal.close()
+}
+
+// The helpers below mirror chromem-go's on-disk layout: one directory per
+// collection and one file per document, both named after the first four
+// bytes of the SHA-256 of the name or ID.
+
+func (d *DurableDB) collectionDir(collection string) string {
+	return filepath.Join(d.dir, hash2hex(collection))
+}
+
+func (d *DurableDB) docPath(collection, id string) string {
+	return filepath.Join(d.collectionDir(collection), hash2hex(id)+d.ext())
+}
+
+func (d *DurableDB) metadataPath(collection string) string {
+	return filepath.Join(d.collectionDir(collection), "00000000"+d.ext())
+}
+
+func (d *DurableDB) ext() string {
+	if d.opts.Compress {
+		return ".gob.gz"
+	}
+	return ".gob"
+}
+
+func hash2hex(name string) string {
+	hash := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(hash[:4])
+}
+
+// syncPath fsyncs a file or directory. Paths that no longer exist (deleted
+// documents) are skipped; their parent directory is synced instead.
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
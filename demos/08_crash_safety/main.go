@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "crashtest" {
+		os.Exit(runCrashTest(os.Args[2:]))
+	}
+
+	fmt.Println("🛡️  Crash Safety Demo - Write-Ahead Log for chromem-go")
+	fmt.Println("=====================================================")
+
+	ctx := context.Background()
+	dbPath := "./chromem-data"
+	os.RemoveAll(dbPath)
+
+	// 1. A crash in the middle of AddDocuments, without a WAL
+	fmt.Println("\n💥 1. Crashing halfway through AddDocuments (plain chromem-go)...")
+	crashMidBatch(ctx, dbPath, nil)
+	plain, err := chromem.NewPersistentDB(dbPath, false)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   Reopened: %d of %d documents on disk - and nothing says a batch is missing\n",
+		plain.GetCollection("knowledge-base", nil).Count(), len(knowledgeBase()))
+	os.RemoveAll(dbPath)
+
+	// 2. The same crash with the WAL
+	fmt.Println("\n💥 2. Crashing at the same point with the write-ahead log...")
+	crashMidBatch(ctx, dbPath, func(p crashPoint) bool { return p == crashDuringApply })
+	walInfo, err := os.Stat(filepath.Join(dbPath, walFileName))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   wal.log holds the batch (%d bytes), fsynced before the first document was written\n", walInfo.Size())
+
+	start := time.Now()
+	db, err := OpenDurableDB(dbPath, DurableOptions{})
+	if err != nil {
+		panic(err)
+	}
+	coll := db.Collection("knowledge-base")
+	fmt.Printf("   Reopened in %v: replayed %d batch(es), %d of %d documents present ✅\n",
+		time.Since(start), db.Replayed, coll.Count(), len(knowledgeBase()))
+
+	// 3. Normal operation: every batch is logged, applied and checkpointed
+	fmt.Println("\n📝 3. Regular writes go through the log...")
+	err = db.Delete(ctx, "knowledge-base", "doc-005")
+	if err != nil {
+		panic(err)
+	}
+	if err := db.Checkpoint(); err != nil {
+		panic(err)
+	}
+	walInfo, err = os.Stat(filepath.Join(dbPath, walFileName))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   Deleted doc-005 and checkpointed: wal.log is back to %d bytes\n", walInfo.Size())
+
+	queryEmbedding := []float32{0.15, 0.85, 0.35, 0.65, 0.45, 0.75, 0.25, 0.55, 0.4, 0.8, 0.15, 0.7, 0.35, 0.6, 0.45, 0.65}
+	results, err := coll.QueryEmbedding(ctx, queryEmbedding, 2, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	for i, result := range results {
+		fmt.Printf("   %d. [%s] Similarity: %.4f\n", i+1, result.ID, result.Similarity)
+		fmt.Printf("      %s\n", result.Content)
+	}
+	if err := db.Close(); err != nil {
+		panic(err)
+	}
+
+	// 4. Crash injection at random points
+	fmt.Println("\n🎲 4. Crash injection: random workloads killed at random points...")
+	results4 := runCrashSuite(50, uint64(time.Now().UnixNano()), false)
+	printCrashSummary(results4)
+
+	fmt.Println("\n🎯 Key Benefits:")
+	fmt.Println("   ✅ Acknowledged batches survive crashes and power loss")
+	fmt.Println("   ✅ Torn document files are detected and rewritten on open")
+	fmt.Println("   ✅ The data directory stays a regular chromem-go persistent DB")
+	fmt.Println("\n💡 Run the full suite: go run . crashtest -trials 1000")
+}
+
+// crashMidBatch adds the knowledge base in one batch and dies after two
+// documents. Without an injector it uses chromem-go directly.
+func crashMidBatch(ctx context.Context, dbPath string, injector crashInjector) {
+	docs := knowledgeBase()
+	metadata := map[string]string{"description": "Technical documentation snippets"}
+
+	if injector == nil {
+		db, err := chromem.NewPersistentDB(dbPath, false)
+		if err != nil {
+			panic(err)
+		}
+		coll, err := db.CreateCollection("knowledge-base", metadata, nil)
+		if err != nil {
+			panic(err)
+		}
+		// Simulate the process dying: only the first two documents get written
+		if err := coll.AddDocuments(ctx, docs[:2], 1); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	applied := 0
+	db, err := openDurableDB(dbPath, DurableOptions{}, func(p crashPoint) bool {
+		if p != crashDuringApply {
+			return false
+		}
+		applied++
+		return applied > 2
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if r := recover(); r != errSimulatedCrash {
+			panic(r)
+		}
+		db.wal.close()
+	}()
+	db.Add(ctx, "knowledge-base", metadata, docs)
+}
+
+// runCrashTest implements `go run . crashtest`.
+func runCrashTest(args []string) int {
+	fs := flag.NewFlagSet("crashtest", flag.ExitOnError)
+	trials := fs.Int("trials", 200, "number of random crash trials")
+	seed := fs.Uint64("seed", uint64(time.Now().UnixNano()), "random seed, to reproduce a failing run")
+	verbose := fs.Bool("v", false, "print every trial")
+	fs.Parse(args)
+
+	fmt.Printf("🎲 Running %d crash trials (seed %d)\n", *trials, *seed)
+	results := runCrashSuite(*trials, *seed, *verbose)
+	if printCrashSummary(results) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printCrashSummary prints pass/fail counts per crash point and returns the
+// number of failures.
+func printCrashSummary(results []crashTrial) int {
+	points := []crashPoint{crashDuringWALWrite, crashAfterWALSync, crashDuringApply, crashDuringCheckpoint, crashAfterLastBatch}
+	passed := make(map[crashPoint]int)
+	failed := make(map[crashPoint]int)
+	for _, r := range results {
+		if r.err != nil {
+			failed[r.point]++
+		} else {
+			passed[r.point]++
+		}
+	}
+
+	fmt.Println("   " + strings.Repeat("─", 45))
+	total := 0
+	for _, p := range points {
+		fmt.Printf("   crash %-18s %4d passed %4d failed\n", p, passed[p], failed[p])
+		total += failed[p]
+	}
+	fmt.Println("   " + strings.Repeat("─", 45))
+	if total == 0 {
+		fmt.Printf("   ✅ All %d trials recovered every acknowledged batch\n", len(results))
+	} else {
+		fmt.Printf("   ❌ %d of %d trials failed\n", total, len(results))
+	}
+	return total
+}
+
+func knowledgeBase() []chromem.Document {
+	return []chromem.Document{
+		{
+			ID:        "doc-001",
+			Content:   "Docker containers provide lightweight, portable application packaging",
+			Embedding: []float32{0.1, 0.9, 0.3, 0.7, 0.5, 0.8, 0.2, 0.6, 0.4, 0.9, 0.1, 0.8, 0.3, 0.7, 0.5, 0.6},
+			Metadata:  map[string]string{"category": "containerization", "difficulty": "beginner"},
+		},
+		{
+			ID:        "doc-002",
+			Content:   "Kubernetes orchestrates containers across clusters with automated scaling",
+			Embedding: []float32{0.2, 0.8, 0.4, 0.6, 0.3, 0.9, 0.1, 0.7, 0.5, 0.8, 0.2, 0.6, 0.4, 0.9, 0.3, 0.7},
+			Metadata:  map[string]string{"category": "orchestration", "difficulty": "advanced"},
+		},
+		{
+			ID:        "doc-003",
+			Content:   "Microservice architecture breaks applications into independent, deployable services",
+			Embedding: []float32{0.3, 0.7, 0.5, 0.9, 0.1, 0.6, 0.2, 0.8, 0.4, 0.7, 0.3, 0.9, 0.5, 0.6, 0.1, 0.8},
+			Metadata:  map[string]string{"category": "architecture", "difficulty": "intermediate"},
+		},
+		{
+			ID:        "doc-004",
+			Content:   "REST APIs enable communication between services using HTTP protocols",
+			Embedding: []float32{0.4, 0.6, 0.2, 0.8, 0.3, 0.7, 0.5, 0.9, 0.1, 0.6, 0.4, 0.8, 0.2, 0.7, 0.3, 0.9},
+			Metadata:  map[string]string{"category": "api", "difficulty": "beginner"},
+		},
+		{
+			ID:        "doc-005",
+			Content:   "Database sharding distributes data across multiple database instances",
+			Embedding: []float32{0.5, 0.9, 0.1, 0.7, 0.3, 0.8, 0.4, 0.6, 0.2, 0.9, 0.5, 0.7, 0.1, 0.8, 0.3, 0.6},
+			Metadata:  map[string]string{"category": "database", "difficulty": "advanced"},
+		},
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// The write-ahead log is a file header followed by framed records:
+//
+//	header  "SWAL" | version u16 | reserved u16
+//	record  length u32 | crc32c u32 | seq u64 | gob-encoded walRecord
+//
+// A record is appended and fsynced before the batch it describes touches the
+// chromem-go directory. Replay stops at the first record that is incomplete
+// or fails its checksum - that record was never acknowledged.
+const (
+	walMagic        = "SWAL"
+	walVersion      = 1
+	walHeaderSize   = 8
+	walRecordHeader = 16
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// walOp is the kind of batch a record describes.
+type walOp string
+
+const (
+	opAdd    walOp = "add"
+	opUpsert walOp = "upsert"
+	opDelete walOp = "delete"
+)
+
+// walRecord is one logged batch.
+type walRecord struct {
+	Seq uint64 `gob:"-"`
+
+	Op         walOp
+	Collection string
+
+	// CreatesCollection is set when the collection didn't exist when the
+	// batch was logged. Recovery then rebuilds the collection from scratch.
+	CreatesCollection  bool
+	CollectionMetadata map[string]string
+
+	Documents []chromem.Document
+	IDs       []string
+}
+
+// wal is an append-only log file.
+type wal struct {
+	f *os.File
+
+	// synced is the size of the log that is known to be on disk.
+	synced int64
+	seq    uint64
+
+	crash crashInjector
+}
+
+// openWAL opens or creates the log at path and returns all complete records.
+// A torn tail is truncated so new records are appended after the last good
+// one.
+func openWAL(path string) (*wal, []walRecord, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't open WAL: %w", err)
+	}
+	w := &wal{f: f}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("couldn't stat WAL: %w", err)
+	}
+	if fi.Size() < walHeaderSize {
+		// New log, or one that crashed while writing its header.
+		if err := w.reset(); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return w, nil, nil
+	}
+
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, fi.Size()))
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("couldn't read WAL: %w", err)
+	}
+	if string(data[:4]) != walMagic || binary.LittleEndian.Uint16(data[4:]) != walVersion {
+		f.Close()
+		return nil, nil, errors.New("not a WAL file (bad header)")
+	}
+
+	records, end := decodeWALRecords(data)
+	if end < int64(len(data)) {
+		if err := f.Truncate(end); err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("couldn't truncate torn WAL tail: %w", err)
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("couldn't sync WAL: %w", err)
+		}
+	}
+	w.synced = end
+	if len(records) > 0 {
+		w.seq = records[len(records)-1].Seq
+	}
+	return w, records, nil
+}
+
+// decodeWALRecords returns the complete records in data and the offset just
+// past the last one.
+func decodeWALRecords(data []byte) ([]walRecord, int64) {
+	var records []walRecord
+	off := int64(walHeaderSize)
+	for off+walRecordHeader <= int64(len(data)) {
+		length := int64(binary.LittleEndian.Uint32(data[off:]))
+		sum := binary.LittleEndian.Uint32(data[off+4:])
+		seq := binary.LittleEndian.Uint64(data[off+8:])
+		start := off + walRecordHeader
+		if start+length > int64(len(data)) {
+			break
+		}
+		payload := data[start : start+length]
+		if crc32.Checksum(payload, castagnoli) != sum {
+			break
+		}
+		var rec walRecord
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+			break
+		}
+		rec.Seq = seq
+		records = append(records, rec)
+		off = start + length
+	}
+	return records, off
+}
+
+// append writes rec to the log and fsyncs it. Only after append returns may
+// the batch be applied.
+func (w *wal) append(rec walRecord) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(rec); err != nil {
+		return fmt.Errorf("couldn't encode WAL record: %w", err)
+	}
+
+	buf := make([]byte, 0, walRecordHeader+payload.Len())
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(payload.Len()))
+	buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(payload.Bytes(), castagnoli))
+	buf = binary.LittleEndian.AppendUint64(buf, w.seq+1)
+	buf = append(buf, payload.Bytes()...)
+
+	if w.crash.fires(crashDuringWALWrite) {
+		// Die halfway through the write.
+		w.f.WriteAt(buf[:len(buf)/2], w.synced)
+		panic(errSimulatedCrash)
+	}
+
+	if _, err := w.f.WriteAt(buf, w.synced); err != nil {
+		return fmt.Errorf("couldn't write WAL record: %w", err)
+	}
+	if err := w.f.Sync(); err != nil {
+		return fmt.Errorf("couldn't sync WAL: %w", err)
+	}
+	w.synced += int64(len(buf))
+	w.seq++
+	return nil
+}
+
+// reset empties the log after a checkpoint.
+func (w *wal) reset() error {
+	header := make([]byte, 0, walHeaderSize)
+	header = append(header, walMagic...)
+	header = binary.LittleEndian.AppendUint16(header, walVersion)
+	header = binary.LittleEndian.AppendUint16(header, 0)
+
+	if err := w.f.Truncate(0); err != nil {
+		return fmt.Errorf("couldn't truncate WAL: %w", err)
+	}
+	if _, err := w.f.WriteAt(header, 0); err != nil {
+		return fmt.Errorf("couldn't write WAL header: %w", err)
+	}
+	if err := w.f.Sync(); err != nil {
+		return fmt.Errorf("couldn't sync WAL: %w", err)
+	}
+	w.synced = walHeaderSize
+	return nil
+}
+
+func (w *wal) close() error {
+	return w.f.Close()
+}
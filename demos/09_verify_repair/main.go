@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify":
+			os.Exit(runVerify(os.Args[2:]))
+		case "repair":
+			os.Exit(runRepair(os.Args[2:]))
+		}
+	}
+
+	fmt.Println("🩺 Verify & Repair Demo - fsck for chromem-go")
+	fmt.Println("=============================================")
+
+	ctx := context.Background()
+	dbPath := "./chromem-data"
+	for _, path := range []string{dbPath, dbPath + ".repaired", dbPath + ".quarantine"} {
+		os.RemoveAll(path)
+	}
+
+	// 1. Create a healthy database
+	fmt.Println("\n📦 1. Creating a healthy persistent database...")
+	createDB(ctx, dbPath)
+	report, err := Verify(dbPath)
+	if err != nil {
+		panic(err)
+	}
+	printReport(report)
+
+	// 2. Damage it the way real disks and scripts do
+	fmt.Println("\n🔨 2. Damaging it...")
+	damage(dbPath)
+	if _, err := chromem.NewPersistentDB(dbPath, false); err != nil {
+		fmt.Printf("   chromem.NewPersistentDB: %v\n", err)
+	}
+
+	// 3. Verify
+	fmt.Println("\n🔍 3. Verifying...")
+	report, err = Verify(dbPath)
+	if err != nil {
+		panic(err)
+	}
+	printReport(report)
+
+	// 4. Repair into a clean store
+	fmt.Println("\n🔧 4. Repairing...")
+	res, err := Repair(ctx, dbPath, RepairOptions{
+		Out:        dbPath + ".repaired",
+		Quarantine: dbPath + ".quarantine",
+	})
+	if err != nil {
+		panic(err)
+	}
+	printRepair(res, dbPath+".repaired", dbPath+".quarantine")
+
+	// 5. The clean store verifies and opens
+	fmt.Println("\n✅ 5. Opening the repaired store...")
+	report, err = Verify(dbPath + ".repaired")
+	if err != nil {
+		panic(err)
+	}
+	printReport(report)
+
+	db, err := chromem.NewPersistentDB(dbPath+".repaired", false)
+	if err != nil {
+		panic(err)
+	}
+	for name, coll := range db.ListCollections() {
+		fmt.Printf("   📚 %-28s %d documents\n", name, coll.Count())
+	}
+	queryEmbedding := []float32{0.15, 0.85, 0.35, 0.65, 0.45, 0.75, 0.25, 0.55, 0.4, 0.8, 0.15, 0.7, 0.35, 0.6, 0.45, 0.65}
+	results, err := db.GetCollection("knowledge-base", nil).QueryEmbedding(ctx, queryEmbedding, 2, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	for i, result := range results {
+		fmt.Printf("   %d. [%s] Similarity: %.4f\n", i+1, result.ID, result.Similarity)
+		fmt.Printf("      %s\n", result.Content)
+	}
+
+	fmt.Println("\n🎯 Key Benefits:")
+	fmt.Println("   ✅ Corruption is reported per file instead of a failed open or missing results")
+	fmt.Println("   ✅ Bad entries are quarantined, never deleted")
+	fmt.Println("   ✅ The repaired store is written by chromem-go itself")
+	fmt.Println("\n💡 Check your own data: go run . verify -db ../03_persist_reload/chromem-data")
+}
+
+// createDB writes the knowledge base with chromem-go, like 03_persist_reload.
+func createDB(ctx context.Context, dbPath string) {
+	db, err := chromem.NewPersistentDB(dbPath, false)
+	if err != nil {
+		panic(err)
+	}
+	coll, err := db.CreateCollection("knowledge-base", map[string]string{"description": "Technical documentation snippets"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	if err := coll.AddDocuments(ctx, knowledgeBase(), 1); err != nil {
+		panic(err)
+	}
+}
+
+// damage applies one of each kind of corruption Verify looks for.
+func damage(dbPath string) {
+	collDir := filepath.Join(dbPath, hash2hex("knowledge-base"))
+	docFile := func(id string) string { return filepath.Join(collDir, hash2hex(id)+".gob") }
+
+	// A torn write
+	data, err := os.ReadFile(docFile("doc-003"))
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(docFile("doc-003"), data[:len(data)/2], 0o600); err != nil {
+		panic(err)
+	}
+	fmt.Println("   ✂️  doc-003 truncated to half its size")
+
+	// A document embedded with a different model
+	writeGob(docFile("doc-006"), chromem.Document{
+		ID:        "doc-006",
+		Content:   "Service meshes add mTLS and retries between services",
+		Embedding: []float32{0.35355339, 0.35355339, 0.35355339, 0.35355339, 0.35355339, 0.35355339, 0.35355339, 0.35355339},
+	})
+	fmt.Println("   📐 doc-006 written with 8 dimensions instead of 16")
+
+	// A backup copy left behind by a script
+	data, err = os.ReadFile(docFile("doc-001"))
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(filepath.Join(collDir, "deadbeef.gob"), data, 0o600); err != nil {
+		panic(err)
+	}
+	fmt.Println("   👯 doc-001 copied to deadbeef.gob")
+
+	// A collection whose metadata file was lost
+	orphanDir := filepath.Join(dbPath, "cafebabe")
+	if err := os.MkdirAll(orphanDir, 0o700); err != nil {
+		panic(err)
+	}
+	data, err = os.ReadFile(docFile("doc-002"))
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(filepath.Join(orphanDir, hash2hex("doc-002")+".gob"), data, 0o600); err != nil {
+		panic(err)
+	}
+	fmt.Println("   🧭 collection directory cafebabe has no metadata file")
+
+	// Something chromem-go will ignore
+	if err := os.WriteFile(filepath.Join(collDir, "NOTES.txt"), []byte("don't touch\n"), 0o600); err != nil {
+		panic(err)
+	}
+	fmt.Println("   📝 NOTES.txt dropped into the collection")
+}
+
+func writeGob(path string, obj any) {
+	f, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if err := gob.NewEncoder(f).Encode(obj); err != nil {
+		panic(err)
+	}
+}
+
+// runVerify implements `go run . verify`.
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	dbPath := fs.String("db", "./chromem-data", "chromem-go persistent DB directory")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	report, err := Verify(*dbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printReport(report)
+	}
+	if report.Count(SeverityError) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runRepair implements `go run . repair`.
+func runRepair(args []string) int {
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+	dbPath := fs.String("db", "./chromem-data", "chromem-go persistent DB directory")
+	out := fs.String("out", "", "directory for the clean store (default <db>.repaired)")
+	quarantine := fs.String("quarantine", "", "directory for bad files and the report (default <db>.quarantine)")
+	replace := fs.Bool("replace", false, "swap the clean store into place and move the original into the quarantine")
+	fs.Parse(args)
+
+	opts := RepairOptions{Out: *out, Quarantine: *quarantine, Replace: *replace}
+	if opts.Out == "" {
+		opts.Out = filepath.Clean(*dbPath) + ".repaired"
+	}
+	if opts.Quarantine == "" {
+		opts.Quarantine = filepath.Clean(*dbPath) + ".quarantine"
+	}
+
+	res, err := Repair(context.Background(), *dbPath, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return 1
+	}
+	printReport(res.Report)
+	dest := opts.Out
+	if opts.Replace {
+		dest = *dbPath
+	}
+	printRepair(res, dest, opts.Quarantine)
+	return 0
+}
+
+func printReport(report *Report) {
+	for _, c := range report.Collections {
+		name := c.Name
+		if name == "" {
+			name = "(unknown)"
+		}
+		fmt.Printf("   📁 %s  %-20s %d documents, %d dimensions\n", c.Dir, name, c.Documents, c.Dimension)
+		for _, f := range c.Findings {
+			icon := "❌"
+			if f.Severity == SeverityWarning {
+				icon = "⚠️ "
+			}
+			fmt.Printf("      %s %-22s %s: %s\n", icon, f.Check, filepath.Base(f.Path), f.Message)
+		}
+	}
+	errs, warns := report.Count(SeverityError), report.Count(SeverityWarning)
+	fmt.Println("   " + strings.Repeat("─", 45))
+	if errs == 0 {
+		fmt.Printf("   ✅ No errors (%d warnings)\n", warns)
+	} else {
+		fmt.Printf("   ❌ %d errors, %d warnings\n", errs, warns)
+	}
+}
+
+func printRepair(res *RepairResult, out, quarantine string) {
+	fmt.Printf("   Kept %d documents in %d collections (%d salvaged into %s*)\n",
+		res.Kept, res.Collections, res.Salvaged, lostAndFoundPrefix)
+	fmt.Printf("   Clean store:  %s\n", out)
+	fmt.Printf("   Quarantined:  %d files and report.json in %s\n", res.Quarantined, quarantine)
+}
+
+func knowledgeBase() []chromem.Document {
+	return []chromem.Document{
+		{
+			ID:        "doc-001",
+			Content:   "Docker containers provide lightweight, portable application packaging",
+			Embedding: []float32{0.1, 0.9, 0.3, 0.7, 0.5, 0.8, 0.2, 0.6, 0.4, 0.9, 0.1, 0.8, 0.3, 0.7, 0.5, 0.6},
+			Metadata:  map[string]string{"category": "containerization", "difficulty": "beginner"},
+		},
+		{
+			ID:        "doc-002",
+			Content:   "Kubernetes orchestrates containers across clusters with automated scaling",
+			Embedding: []float32{0.2, 0.8, 0.4, 0.6, 0.3, 0.9, 0.1, 0.7, 0.5, 0.8, 0.2, 0.6, 0.4, 0.9, 0.3, 0.7},
+			Metadata:  map[string]string{"category": "orchestration", "difficulty": "advanced"},
+		},
+		{
+			ID:        "doc-003",
+			Content:   "Microservice architecture breaks applications into independent, deployable services",
+			Embedding: []float32{0.3, 0.7, 0.5, 0.9, 0.1, 0.6, 0.2, 0.8, 0.4, 0.7, 0.3, 0.9, 0.5, 0.6, 0.1, 0.8},
+			Metadata:  map[string]string{"category": "architecture", "difficulty": "intermediate"},
+		},
+		{
+			ID:        "doc-004",
+			Content:   "REST APIs enable communication between services using HTTP protocols",
+			Embedding: []float32{0.4, 0.6, 0.2, 0.8, 0.3, 0.7, 0.5, 0.9, 0.1, 0.6, 0.4, 0.8, 0.2, 0.7, 0.3, 0.9},
+			Metadata:  map[string]string{"category": "api", "difficulty": "beginner"},
+		},
+		{
+			ID:        "doc-005",
+			Content:   "Database sharding distributes data across multiple database instances",
+			Embedding: []float32{0.5, 0.9, 0.1, 0.7, 0.3, 0.8, 0.4, 0.6, 0.2, 0.9, 0.5, 0.7, 0.1, 0.8, 0.3, 0.6},
+			Metadata:  map[string]string{"category": "database", "difficulty": "advanced"},
+		},
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// lostAndFoundPrefix names the collections that documents of a collection
+// without metadata are salvaged into.
+const lostAndFoundPrefix = "lost+found-"
+
+// RepairOptions configure Repair.
+type RepairOptions struct {
+	// Out is the directory the clean store is written to. It must not exist.
+	Out string
+
+	// Quarantine is the directory bad files are copied to, along with the
+	// verify report. It must not exist.
+	Quarantine string
+
+	// Replace swaps the clean store into place once it's written. The
+	// original directory is moved into the quarantine as "original".
+	Replace bool
+}
+
+// RepairResult summarizes what Repair did.
+type RepairResult struct {
+	Report      *Report
+	Collections int
+	Kept        int
+	Salvaged    int
+	Quarantined int
+}
+
+// Repair rewrites the DB at dbPath into a clean store that contains every
+// document that passed verification. Bad files are copied into the
+// quarantine; the source directory is only touched when opts.Replace is set.
+func Repair(ctx context.Context, dbPath string, opts RepairOptions) (*RepairResult, error) {
+	if opts.Out == "" || opts.Quarantine == "" {
+		return nil, errors.New("out and quarantine directories are required")
+	}
+	for _, path := range []string{opts.Out, opts.Quarantine} {
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("%s already exists", path)
+		}
+	}
+
+	report, collections, err := scan(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	res := &RepairResult{Report: report}
+
+	// Quarantine first, so nothing is lost if writing the clean store fails
+	if err := os.MkdirAll(opts.Quarantine, 0o700); err != nil {
+		return nil, fmt.Errorf("couldn't create quarantine directory: %w", err)
+	}
+	for _, sc := range collections {
+		for _, path := range sc.quarantineFiles() {
+			if err := copyIntoQuarantine(dbPath, path, opts.Quarantine); err != nil {
+				return nil, err
+			}
+			res.Quarantined++
+		}
+	}
+	if err := writeReport(report, filepath.Join(opts.Quarantine, "report.json")); err != nil {
+		return nil, err
+	}
+
+	// Write the clean store through chromem-go, so every file gets its
+	// canonical name
+	tmp := opts.Out + ".tmp"
+	os.RemoveAll(tmp)
+	db, err := chromem.NewPersistentDB(tmp, report.Compressed)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create clean store: %w", err)
+	}
+	for _, sc := range collections {
+		if sc.duplicate {
+			continue
+		}
+		name, metadata := sc.name, sc.metadata
+		if sc.orphaned {
+			name = lostAndFoundPrefix + filepath.Base(sc.dir)
+			metadata = map[string]string{"salvaged-from": filepath.Base(sc.dir)}
+		}
+
+		docs := sc.goodDocs()
+		coll, err := db.CreateCollection(name, metadata, nil)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create collection %q: %w", name, err)
+		}
+		if len(docs) > 0 {
+			if err := coll.AddDocuments(ctx, docs, runtime.NumCPU()); err != nil {
+				return nil, fmt.Errorf("couldn't write collection %q: %w", name, err)
+			}
+		}
+		res.Collections++
+		res.Kept += len(docs)
+		if sc.orphaned {
+			res.Salvaged += len(docs)
+		}
+	}
+	if err := os.Rename(tmp, opts.Out); err != nil {
+		return nil, fmt.Errorf("couldn't move clean store into place: %w", err)
+	}
+
+	if opts.Replace {
+		if err := os.Rename(dbPath, filepath.Join(opts.Quarantine, "original")); err != nil {
+			return nil, fmt.Errorf("couldn't move original store into quarantine: %w", err)
+		}
+		if err := os.Rename(opts.Out, dbPath); err != nil {
+			return nil, fmt.Errorf("couldn't replace original store: %w", err)
+		}
+	}
+	return res, nil
+}
+
+// quarantineFiles returns the files of the collection that didn't make it
+// into the clean store as they are.
+func (sc *scannedCollection) quarantineFiles() []string {
+	files := append([]string(nil), sc.badFiles...)
+	for _, d := range sc.docs {
+		if d.bad {
+			files = append(files, d.path)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (sc *scannedCollection) goodDocs() []chromem.Document {
+	var docs []chromem.Document
+	for _, d := range sc.docs {
+		if !d.bad {
+			docs = append(docs, d.doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	return docs
+}
+
+// copyIntoQuarantine copies path into the quarantine, keeping its path
+// relative to the DB directory.
+func copyIntoQuarantine(dbPath, path, quarantine string) error {
+	rel, err := filepath.Rel(dbPath, path)
+	if err != nil {
+		return fmt.Errorf("couldn't quarantine %s: %w", path, err)
+	}
+	dst := filepath.Join(quarantine, rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return fmt.Errorf("couldn't create quarantine directory: %w", err)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("couldn't quarantine %s: %w", path, err)
+	}
+	defer src.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("couldn't quarantine %s: %w", path, err)
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return fmt.Errorf("couldn't quarantine %s: %w", path, err)
+	}
+	return out.Close()
+}
+
+func writeReport(report *Report, path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't encode report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("couldn't write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// metadataFileName is the file chromem-go stores a collection's name and
+// metadata in, next to one file per document.
+const metadataFileName = "00000000"
+
+// Severity of a finding. Errors make chromem-go fail to open the DB or
+// silently serve wrong data; warnings are worth a look but harmless.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a single problem found by Verify.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Check    string   `json:"check"`
+	Path     string   `json:"path"`
+	Message  string   `json:"message"`
+}
+
+// CollectionReport summarizes one collection directory.
+type CollectionReport struct {
+	Dir       string    `json:"dir"`
+	Name      string    `json:"name"`
+	Documents int       `json:"documents"`
+	Dimension int       `json:"dimension"`
+	Findings  []Finding `json:"findings,omitempty"`
+}
+
+// Report is the result of verifying a persistent DB directory.
+type Report struct {
+	Path        string              `json:"path"`
+	Compressed  bool                `json:"compressed"`
+	Collections []*CollectionReport `json:"collections"`
+}
+
+// Count returns the number of findings with the given severity.
+func (r *Report) Count(s Severity) int {
+	n := 0
+	for _, f := range r.allFindings() {
+		if f.Severity == s {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) allFindings() []Finding {
+	var all []Finding
+	for _, c := range r.Collections {
+		all = append(all, c.Findings...)
+	}
+	return all
+}
+
+// scannedDoc is a document file that decoded successfully.
+type scannedDoc struct {
+	path string
+	doc  chromem.Document
+	bad  bool
+}
+
+// scannedCollection is everything read from one collection directory.
+type scannedCollection struct {
+	report    *CollectionReport
+	dir       string
+	name      string
+	metadata  map[string]string
+	orphaned  bool
+	duplicate bool
+	docs      []*scannedDoc
+	badFiles  []string
+	dimension int
+}
+
+// Verify checks a chromem-go persistent DB directory without modifying it.
+func Verify(dbPath string) (*Report, error) {
+	report, _, err := scan(dbPath)
+	return report, err
+}
+
+func scan(dbPath string) (*Report, []*scannedCollection, error) {
+	entries, err := os.ReadDir(dbPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't read DB directory: %w", err)
+	}
+
+	report := &Report{Path: dbPath}
+	report.Compressed = detectCompression(dbPath, entries)
+	ext := ".gob"
+	if report.Compressed {
+		ext += ".gz"
+	}
+
+	var collections []*scannedCollection
+	names := make(map[string]string)
+	for _, entry := range entries {
+		path := filepath.Join(dbPath, entry.Name())
+		if !entry.IsDir() {
+			// chromem-go ignores files at the top level, and the WAL from
+			// 08_crash_safety lives here.
+			continue
+		}
+		sc, err := scanCollection(path, ext)
+		if err != nil {
+			return nil, nil, err
+		}
+		if sc == nil {
+			continue
+		}
+		if sc.name != "" {
+			if other, ok := names[sc.name]; ok {
+				sc.addFinding(SeverityError, "duplicate-collection", path,
+					fmt.Sprintf("collection %q is also stored in %s; chromem-go keeps only one of them", sc.name, other))
+				sc.duplicate = true
+				sc.markAllBad()
+			}
+			names[sc.name] = path
+		}
+		report.Collections = append(report.Collections, sc.report)
+		collections = append(collections, sc)
+	}
+
+	return report, collections, nil
+}
+
+// detectCompression decides whether the DB uses .gob or .gob.gz files, based
+// on the metadata files of its collections.
+func detectCompression(dbPath string, entries []os.DirEntry) bool {
+	plain, compressed := 0, 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(dbPath, entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, metadataFileName+".gob")); err == nil {
+			plain++
+		}
+		if _, err := os.Stat(filepath.Join(dir, metadataFileName+".gob.gz")); err == nil {
+			compressed++
+		}
+	}
+	return compressed > plain
+}
+
+func scanCollection(dir, ext string) (*scannedCollection, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read collection directory: %w", err)
+	}
+
+	sc := &scannedCollection{dir: dir, report: &CollectionReport{Dir: filepath.Base(dir)}}
+	hasFiles := false
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			sc.addFinding(SeverityWarning, "stray-directory", path, "directory inside a collection is ignored by chromem-go")
+			continue
+		}
+		hasFiles = true
+
+		if !strings.HasSuffix(entry.Name(), ext) {
+			sc.addFinding(SeverityWarning, "foreign-file", path,
+				fmt.Sprintf("file without %s extension is ignored by chromem-go", ext))
+			continue
+		}
+
+		if entry.Name() == metadataFileName+ext {
+			sc.scanMetadata(path)
+			continue
+		}
+		sc.scanDocument(path, ext)
+	}
+	if !hasFiles {
+		return nil, nil
+	}
+
+	if sc.name == "" && len(sc.docs)+len(sc.badFiles) > 0 {
+		sc.orphaned = true
+		sc.addFinding(SeverityError, "missing-metadata", dir,
+			"collection has documents but no metadata file; chromem-go refuses to open the DB")
+	}
+
+	sc.checkDimensions()
+	sc.checkDuplicates(ext)
+
+	for _, d := range sc.docs {
+		if !d.bad {
+			sc.report.Documents++
+		}
+	}
+	sc.report.Dimension = sc.dimension
+	return sc, nil
+}
+
+func (sc *scannedCollection) scanMetadata(path string) {
+	pc := struct {
+		Name     string
+		Metadata map[string]string
+	}{}
+	if err := readGobFile(path, &pc); err != nil {
+		sc.badFiles = append(sc.badFiles, path)
+		sc.addFinding(SeverityError, "undecodable-metadata", path, err.Error())
+		return
+	}
+	if pc.Name == "" {
+		sc.badFiles = append(sc.badFiles, path)
+		sc.addFinding(SeverityError, "undecodable-metadata", path, "metadata file has no collection name")
+		return
+	}
+	sc.name = pc.Name
+	sc.metadata = pc.Metadata
+	sc.report.Name = pc.Name
+	if hash2hex(pc.Name) != filepath.Base(sc.dir) {
+		sc.addFinding(SeverityWarning, "misplaced-collection", sc.dir,
+			fmt.Sprintf("directory name doesn't match collection %q (expected %s)", pc.Name, hash2hex(pc.Name)))
+	}
+}
+
+func (sc *scannedCollection) scanDocument(path, ext string) {
+	var doc chromem.Document
+	if err := readGobFile(path, &doc); err != nil {
+		sc.badFiles = append(sc.badFiles, path)
+		sc.addFinding(SeverityError, "undecodable-document", path, err.Error())
+		return
+	}
+
+	d := &scannedDoc{path: path, doc: doc}
+	sc.docs = append(sc.docs, d)
+
+	switch {
+	case doc.ID == "":
+		d.bad = true
+		sc.addFinding(SeverityError, "empty-id", path, "document has no ID")
+	case len(doc.Embedding) == 0:
+		d.bad = true
+		sc.addFinding(SeverityError, "missing-embedding", path, fmt.Sprintf("document %q has no embedding", doc.ID))
+	case !finite(doc.Embedding):
+		d.bad = true
+		sc.addFinding(SeverityError, "non-finite-embedding", path, fmt.Sprintf("document %q has NaN or Inf values", doc.ID))
+	case !normalized(doc.Embedding):
+		sc.addFinding(SeverityWarning, "unnormalized-embedding", path,
+			fmt.Sprintf("document %q is not normalized; chromem-go normalizes on add, so it was written by something else", doc.ID))
+	}
+
+	if doc.ID != "" && hash2hex(doc.ID)+ext != filepath.Base(path) {
+		sc.addFinding(SeverityError, "orphaned-file", path,
+			fmt.Sprintf("file name doesn't match document %q (expected %s%s); deleting the document won't remove this file",
+				doc.ID, hash2hex(doc.ID), ext))
+	}
+}
+
+// checkDimensions flags documents whose dimension differs from the most
+// common one in the collection.
+func (sc *scannedCollection) checkDimensions() {
+	counts := make(map[int]int)
+	for _, d := range sc.docs {
+		if !d.bad {
+			counts[len(d.doc.Embedding)]++
+		}
+	}
+	for dim, n := range counts {
+		if n > counts[sc.dimension] || (n == counts[sc.dimension] && dim < sc.dimension) {
+			sc.dimension = dim
+		}
+	}
+	for _, d := range sc.docs {
+		if !d.bad && len(d.doc.Embedding) != sc.dimension {
+			d.bad = true
+			sc.addFinding(SeverityError, "dimension-mismatch", d.path,
+				fmt.Sprintf("document %q has %d dimensions, the collection has %d; queries will fail",
+					d.doc.ID, len(d.doc.Embedding), sc.dimension))
+		}
+	}
+}
+
+// checkDuplicates flags files that decode to the same document ID. The file
+// at the canonical path wins; chromem-go would keep whichever it read last.
+func (sc *scannedCollection) checkDuplicates(ext string) {
+	byID := make(map[string][]*scannedDoc)
+	for _, d := range sc.docs {
+		if !d.bad {
+			byID[d.doc.ID] = append(byID[d.doc.ID], d)
+		}
+	}
+	for id, docs := range byID {
+		if len(docs) < 2 {
+			continue
+		}
+		canonical := hash2hex(id) + ext
+		sort.Slice(docs, func(i, j int) bool {
+			return filepath.Base(docs[i].path) == canonical && filepath.Base(docs[j].path) != canonical
+		})
+		for _, d := range docs[1:] {
+			d.bad = true
+			sc.addFinding(SeverityError, "duplicate-id", d.path,
+				fmt.Sprintf("document %q is also stored in %s", id, filepath.Base(docs[0].path)))
+		}
+	}
+}
+
+func (sc *scannedCollection) markAllBad() {
+	for _, d := range sc.docs {
+		d.bad = true
+	}
+	sc.report.Documents = 0
+}
+
+func (sc *scannedCollection) addFinding(s Severity, check, path, msg string) {
+	sc.report.Findings = append(sc.report.Findings, Finding{Severity: s, Check: check, Path: path, Message: msg})
+}
+
+// readGobFile decodes a chromem-go file, which is gob, optionally gzipped.
+// Encrypted files are only used by DB.Export and never appear in a
+// persistent DB directory.
+func readGobFile(path string, obj any) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gzr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("couldn't open gzip stream: %w", err)
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+	if err := gob.NewDecoder(r).Decode(obj); err != nil {
+		return fmt.Errorf("couldn't decode: %w", err)
+	}
+	return nil
+}
+
+// hash2hex mirrors how chromem-go names collection directories and document
+// files: the first four bytes of the SHA-256, hex-encoded.
+func hash2hex(name string) string {
+	hash := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(hash[:4])
+}
+
+func finite(v []float32) bool {
+	for _, x := range v {
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalized(v []float32) bool {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Abs(math.Sqrt(sum)-1) < 1e-5
+}
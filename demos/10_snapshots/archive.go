@@ -0,0 +1,176 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// A snapshot archive is a gzipped tar file:
+//
+//	manifest.json      the snapshot's manifest
+//	data/<path>        every file of the DB directory
+//
+// Extracting data/ yields a regular chromem-go persistent DB directory, so an
+// archive is useful even without this tool.
+const (
+	archiveManifest = "manifest.json"
+	archiveDataDir  = "data/"
+
+	// maxManifestSize bounds how much of an untrusted archive is read into
+	// memory as a manifest.
+	maxManifestSize = 64 << 20
+)
+
+// Export writes a snapshot as a compressed archive.
+func (s *SnapshotStore) Export(id string, w io.Writer) error {
+	m, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't encode manifest: %w", err)
+	}
+
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+	hdr := &tar.Header{Name: archiveManifest, Mode: 0o600, Size: int64(len(manifest)), ModTime: m.Created}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("couldn't write archive: %w", err)
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return fmt.Errorf("couldn't write archive: %w", err)
+	}
+
+	for _, f := range m.Files {
+		r, err := s.openObject(f)
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: archiveDataDir + f.Path, Mode: 0o600, Size: f.Size, ModTime: m.Created}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("couldn't write archive: %w", err)
+		}
+		if _, err := io.Copy(tw, r); err != nil {
+			return fmt.Errorf("couldn't write archive: %w", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("couldn't write archive: %w", err)
+	}
+	return gzw.Close()
+}
+
+// Import adds the snapshot in an archive to the store. Every file is checked
+// against the hash in the manifest before the snapshot becomes visible.
+func (s *SnapshotStore) Import(r io.Reader) (*Manifest, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a snapshot archive: %w", err)
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != archiveManifest {
+		return nil, errors.New("not a snapshot archive: manifest.json must come first")
+	}
+	data, err := io.ReadAll(io.LimitReader(tr, maxManifestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read manifest: %w", err)
+	}
+	if len(data) > maxManifestSize {
+		return nil, errors.New("manifest is too large")
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("couldn't decode manifest: %w", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(s.manifestPath(m.ID)); !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("snapshot %q already exists", m.ID)
+	}
+
+	want := make(map[string]SnapshotFile, len(m.Files))
+	for _, f := range m.Files {
+		want[f.Path] = f
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read archive: %w", err)
+		}
+		rel, ok := strings.CutPrefix(hdr.Name, archiveDataDir)
+		if !ok || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		expected, ok := want[rel]
+		if !ok {
+			return nil, fmt.Errorf("archive contains %s, which isn't in the manifest", rel)
+		}
+		got, _, err := s.storeObject(tr)
+		if err != nil {
+			return nil, err
+		}
+		if got.SHA256 != expected.SHA256 || got.Size != expected.Size {
+			return nil, fmt.Errorf("%s doesn't match the manifest (checksum mismatch)", rel)
+		}
+		delete(want, rel)
+	}
+	for rel := range want {
+		return nil, fmt.Errorf("archive is missing %s", rel)
+	}
+	if err := s.syncObjects(m.Files); err != nil {
+		return nil, err
+	}
+
+	if err := s.writeManifest(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// validate rejects manifests that don't come from Create - in particular
+// paths that would escape the directory on restore.
+func (m *Manifest) validate() error {
+	if m.ID == "" || !labelPattern.MatchString(m.ID) || strings.Trim(m.ID, ".") == "" {
+		return fmt.Errorf("invalid snapshot ID %q", m.ID)
+	}
+	seen := make(map[string]bool, len(m.Files))
+	for _, f := range m.Files {
+		if f.Path == "" || path.IsAbs(f.Path) || path.Clean(f.Path) != f.Path ||
+			f.Path == ".." || strings.HasPrefix(f.Path, "../") || strings.Contains(f.Path, `\`) {
+			return fmt.Errorf("invalid path %q in manifest", f.Path)
+		}
+		if seen[f.Path] {
+			return fmt.Errorf("duplicate path %q in manifest", f.Path)
+		}
+		seen[f.Path] = true
+		if len(f.SHA256) != 64 || strings.Trim(f.SHA256, "0123456789abcdef") != "" {
+			return fmt.Errorf("invalid hash for %q in manifest", f.Path)
+		}
+		if f.Size < 0 {
+			return fmt.Errorf("invalid size for %q in manifest", f.Path)
+		}
+	}
+	return nil
+}
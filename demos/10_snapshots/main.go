@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string) error{
+			"create":  runCreate,
+			"list":    runList,
+			"restore": runRestore,
+			"delete":  runDelete,
+			"export":  runExport,
+			"import":  runImport,
+		}
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Println("📸 Snapshots Demo - Point-in-Time Copies of a Live DB")
+	fmt.Println("=====================================================")
+
+	ctx := context.Background()
+	dbPath := "./chromem-data"
+	for _, path := range []string{dbPath, dbPath + ".snapshots", "./exports"} {
+		os.RemoveAll(path)
+	}
+
+	// 1. Create the live database
+	fmt.Println("\n📦 1. Creating the live database...")
+	db, err := chromem.NewPersistentDB(dbPath, false)
+	if err != nil {
+		panic(err)
+	}
+	coll, err := db.CreateCollection("knowledge-base", map[string]string{"description": "Technical documentation snippets"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	if err := coll.AddDocuments(ctx, knowledgeBase(), 1); err != nil {
+		panic(err)
+	}
+	fmt.Printf("   %d documents in knowledge-base\n", coll.Count())
+
+	store, err := OpenSnapshotStore(dbPath)
+	if err != nil {
+		panic(err)
+	}
+
+	// 2. Snapshot it while a writer keeps going
+	fmt.Println("\n📸 2. Snapshotting while another goroutine writes...")
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		docs := knowledgeBase()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			// Rewrite a document with its own content: same data, new file
+			if err := coll.AddDocument(ctx, docs[i%len(docs)]); err != nil {
+				panic(err)
+			}
+			time.Sleep(2 * time.Millisecond)
+		}
+	}()
+	before, info, err := store.Create("before-reindex")
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		panic(err)
+	}
+	printCreated(before, info)
+
+	// 3. Re-index: change one document, add two
+	fmt.Println("\n🔄 3. Re-indexing...")
+	if err := coll.AddDocuments(ctx, reindexedDocuments(), 1); err != nil {
+		panic(err)
+	}
+	fmt.Printf("   Updated doc-004, added doc-006 and doc-007 → %d documents\n", coll.Count())
+	after, info, err := store.Create("after-reindex")
+	if err != nil {
+		panic(err)
+	}
+	printCreated(after, info)
+
+	// 4. Open both snapshots read-only and compare
+	fmt.Println("\n🆚 4. A/B comparison of search results...")
+	queryEmbedding := []float32{0.4, 0.6, 0.25, 0.8, 0.3, 0.7, 0.45, 0.85, 0.15, 0.6, 0.4, 0.8, 0.2, 0.7, 0.3, 0.85}
+	for _, m := range []*Manifest{before, after} {
+		snap, err := store.Open(ctx, m.ID)
+		if err != nil {
+			panic(err)
+		}
+		results, err := snap.GetCollection("knowledge-base", nil).QueryEmbedding(ctx, queryEmbedding, 3, nil, nil)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("   %s:\n", m.ID)
+		for i, result := range results {
+			fmt.Printf("      %d. [%s] %.4f  %s\n", i+1, result.ID, result.Similarity, result.Content)
+		}
+	}
+
+	// 5. Roll back
+	fmt.Println("\n⏪ 5. Restoring the snapshot from before the re-index...")
+	if err := store.Restore(before.ID); err != nil {
+		panic(err)
+	}
+	db, err = chromem.NewPersistentDB(dbPath, false)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   Reopened live DB: %d documents in knowledge-base\n", db.GetCollection("knowledge-base", nil).Count())
+
+	// 6. Ship a snapshot as a single file
+	fmt.Println("\n📦 6. Exporting, deleting and importing a snapshot...")
+	archive := filepath.Join("./exports", after.ID+".tar.gz")
+	if err := exportToFile(store, after.ID, archive); err != nil {
+		panic(err)
+	}
+	fi, err := os.Stat(archive)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   Exported %s (%s)\n", archive, formatBytes(fi.Size()))
+	freed, err := store.Delete(after.ID)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   Deleted %s, freed %s of objects only it used\n", after.ID, formatBytes(freed))
+	imported, err := importFromFile(store, archive)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   Imported %s back, every file checked against its hash\n", imported.ID)
+	printList(store)
+
+	fmt.Println("\n🎯 Key Benefits:")
+	fmt.Println("   ✅ Snapshots share unchanged files - they cost only what changed")
+	fmt.Println("   ✅ Consistent even while writes are going on")
+	fmt.Println("   ✅ Any snapshot opens read-only next to the live DB")
+	fmt.Println("   ✅ One .tar.gz per snapshot, extractable as a plain chromem-go directory")
+	fmt.Println("\n💡 Try: go run . list")
+}
+
+// reindexedDocuments is what a re-index produced: one changed document and
+// two new ones.
+func reindexedDocuments() []chromem.Document {
+	return []chromem.Document{
+		{
+			ID:        "doc-004",
+			Content:   "REST and gRPC APIs connect services over HTTP",
+			Embedding: []float32{0.4, 0.6, 0.3, 0.8, 0.3, 0.7, 0.4, 0.9, 0.2, 0.6, 0.4, 0.8, 0.2, 0.7, 0.3, 0.8},
+			Metadata:  map[string]string{"category": "api", "difficulty": "beginner"},
+		},
+		{
+			ID:        "doc-006",
+			Content:   "API gateways route, authenticate and rate-limit requests to services",
+			Embedding: []float32{0.4, 0.6, 0.2, 0.8, 0.3, 0.7, 0.5, 0.8, 0.1, 0.6, 0.4, 0.8, 0.2, 0.7, 0.3, 0.9},
+			Metadata:  map[string]string{"category": "api", "difficulty": "intermediate"},
+		},
+		{
+			ID:        "doc-007",
+			Content:   "Message queues decouple producers from consumers",
+			Embedding: []float32{0.6, 0.2, 0.8, 0.3, 0.7, 0.1, 0.9, 0.2, 0.6, 0.3, 0.8, 0.1, 0.7, 0.2, 0.9, 0.3},
+			Metadata:  map[string]string{"category": "messaging", "difficulty": "intermediate"},
+		},
+	}
+}
+
+func runCreate(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	dbPath := fs.String("db", "./chromem-data", "chromem-go persistent DB directory")
+	label := fs.String("label", "", "label to include in the snapshot ID")
+	fs.Parse(args)
+
+	store, err := OpenSnapshotStore(*dbPath)
+	if err != nil {
+		return err
+	}
+	m, info, err := store.Create(*label)
+	if err != nil {
+		return err
+	}
+	printCreated(m, info)
+	return nil
+}
+
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	dbPath := fs.String("db", "./chromem-data", "chromem-go persistent DB directory")
+	fs.Parse(args)
+
+	store, err := OpenSnapshotStore(*dbPath)
+	if err != nil {
+		return err
+	}
+	printList(store)
+	return nil
+}
+
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dbPath := fs.String("db", "./chromem-data", "chromem-go persistent DB directory")
+	id := fs.String("id", "", "snapshot to restore")
+	to := fs.String("to", "", "directory to restore into (default: replace the DB)")
+	fs.Parse(args)
+
+	store, err := OpenSnapshotStore(*dbPath)
+	if err != nil {
+		return err
+	}
+	dest := *dbPath
+	if *to != "" {
+		dest = *to
+	}
+	if err := store.RestoreTo(*id, dest); err != nil {
+		return err
+	}
+	fmt.Printf("⏪ Restored %s into %s\n", *id, dest)
+	return nil
+}
+
+func runDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	dbPath := fs.String("db", "./chromem-data", "chromem-go persistent DB directory")
+	id := fs.String("id", "", "snapshot to delete")
+	fs.Parse(args)
+
+	store, err := OpenSnapshotStore(*dbPath)
+	if err != nil {
+		return err
+	}
+	freed, err := store.Delete(*id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🗑️  Deleted %s, freed %s\n", *id, formatBytes(freed))
+	return nil
+}
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPath := fs.String("db", "./chromem-data", "chromem-go persistent DB directory")
+	id := fs.String("id", "", "snapshot to export")
+	out := fs.String("out", "", "archive to write (default ./exports/<id>.tar.gz)")
+	fs.Parse(args)
+
+	store, err := OpenSnapshotStore(*dbPath)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = filepath.Join("./exports", *id+".tar.gz")
+	}
+	if err := exportToFile(store, *id, *out); err != nil {
+		return err
+	}
+	fmt.Printf("📦 Exported %s to %s\n", *id, *out)
+	return nil
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dbPath := fs.String("db", "./chromem-data", "chromem-go persistent DB directory")
+	in := fs.String("in", "", "archive to import")
+	fs.Parse(args)
+
+	store, err := OpenSnapshotStore(*dbPath)
+	if err != nil {
+		return err
+	}
+	m, err := importFromFile(store, *in)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📥 Imported %s\n", m.ID)
+	return nil
+}
+
+// exportToFile writes the archive to a temporary file and renames it into
+// place, so a failed export never leaves a truncated archive behind.
+func exportToFile(store *SnapshotStore, id, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("couldn't create output directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".export-*")
+	if err != nil {
+		return fmt.Errorf("couldn't create archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := store.Export(id, tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("couldn't sync archive: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("couldn't write archive: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func importFromFile(store *SnapshotStore, path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open archive: %w", err)
+	}
+	defer f.Close()
+	return store.Import(f)
+}
+
+func printCreated(m *Manifest, info *SnapshotCreateInfo) {
+	fmt.Printf("   📸 %s: %d files, %s\n", m.ID, len(m.Files), formatBytes(m.Size()))
+	fmt.Printf("      stored %d new objects (%s), reused %d, consistent after %d attempt(s)\n",
+		info.NewObjects, formatBytes(info.NewBytes), info.Reused, info.Attempts)
+}
+
+func printList(store *SnapshotStore) {
+	manifests, err := store.List()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   %-36s %-20s %s\n", "ID", "CREATED", "COLLECTIONS")
+	fmt.Println("   " + strings.Repeat("─", 80))
+	for _, m := range manifests {
+		var colls []string
+		for _, c := range m.Collections {
+			colls = append(colls, fmt.Sprintf("%s (%d)", c.Name, c.Documents))
+		}
+		fmt.Printf("   %-36s %-20s %s\n", m.ID, m.Created.Format("2006-01-02 15:04:05"), strings.Join(colls, ", "))
+	}
+}
+
+func formatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.1f KB", float64(n)/1024)
+}
+
+func knowledgeBase() []chromem.Document {
+	return []chromem.Document{
+		{
+			ID:        "doc-001",
+			Content:   "Docker containers provide lightweight, portable application packaging",
+			Embedding: []float32{0.1, 0.9, 0.3, 0.7, 0.5, 0.8, 0.2, 0.6, 0.4, 0.9, 0.1, 0.8, 0.3, 0.7, 0.5, 0.6},
+			Metadata:  map[string]string{"category": "containerization", "difficulty": "beginner"},
+		},
+		{
+			ID:        "doc-002",
+			Content:   "Kubernetes orchestrates containers across clusters with automated scaling",
+			Embedding: []float32{0.2, 0.8, 0.4, 0.6, 0.3, 0.9, 0.1, 0.7, 0.5, 0.8, 0.2, 0.6, 0.4, 0.9, 0.3, 0.7},
+			Metadata:  map[string]string{"category": "orchestration", "difficulty": "advanced"},
+		},
+		{
+			ID:        "doc-003",
+			Content:   "Microservice architecture breaks applications into independent, deployable services",
+			Embedding: []float32{0.3, 0.7, 0.5, 0.9, 0.1, 0.6, 0.2, 0.8, 0.4, 0.7, 0.3, 0.9, 0.5, 0.6, 0.1, 0.8},
+			Metadata:  map[string]string{"category": "architecture", "difficulty": "intermediate"},
+		},
+		{
+			ID:        "doc-004",
+			Content:   "REST APIs enable communication between services using HTTP protocols",
+			Embedding: []float32{0.4, 0.6, 0.2, 0.8, 0.3, 0.7, 0.5, 0.9, 0.1, 0.6, 0.4, 0.8, 0.2, 0.7, 0.3, 0.9},
+			Metadata:  map[string]string{"category": "api", "difficulty": "beginner"},
+		},
+		{
+			ID:        "doc-005",
+			Content:   "Database sharding distributes data across multiple database instances",
+			Embedding: []float32{0.5, 0.9, 0.1, 0.7, 0.3, 0.8, 0.4, 0.6, 0.2, 0.9, 0.5, 0.7, 0.1, 0.8, 0.3, 0.6},
+			Metadata:  map[string]string{"category": "database", "difficulty": "advanced"},
+		},
+	}
+}
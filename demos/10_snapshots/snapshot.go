@@ -0,0 +1,676 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// A snapshot store lives next to the DB directory:
+//
+//	<db>.snapshots/
+//	  objects/ab/cdef…   file contents, named by their SHA-256
+//	  manifests/<id>.json
+//
+// A manifest lists every file of the DB directory with its hash. chromem-go
+// writes one file per document, so two snapshots of a DB that changed a few
+// documents share all other objects - a snapshot costs only what changed.
+
+// ErrSnapshotNotFound is returned for unknown snapshot IDs.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// ErrSnapshotBusy is returned when the DB kept changing while a snapshot was
+// being taken.
+var ErrSnapshotBusy = errors.New("DB kept changing during snapshot")
+
+// snapshotAttempts is how often Create retries before giving up on a DB that
+// is being written to.
+const snapshotAttempts = 20
+
+// lockTimeout is how long Create and Delete wait for each other.
+const lockTimeout = 30 * time.Second
+
+var labelPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]*$`)
+
+// Manifest describes one snapshot.
+type Manifest struct {
+	ID          string         `json:"id"`
+	Label       string         `json:"label,omitempty"`
+	Created     time.Time      `json:"created"`
+	Compressed  bool           `json:"compressed"`
+	Collections []SnapshotColl `json:"collections"`
+	Files       []SnapshotFile `json:"files"`
+}
+
+// SnapshotColl is a collection contained in a snapshot.
+type SnapshotColl struct {
+	Name      string `json:"name"`
+	Documents int    `json:"documents"`
+}
+
+// SnapshotFile is a file of the DB directory.
+type SnapshotFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// SnapshotCreateInfo reports what Create had to do.
+type SnapshotCreateInfo struct {
+	Attempts   int
+	NewObjects int
+	NewBytes   int64
+	Reused     int
+}
+
+// Size returns the logical size of the snapshot.
+func (m *Manifest) Size() int64 {
+	var n int64
+	for _, f := range m.Files {
+		n += f.Size
+	}
+	return n
+}
+
+// SnapshotStore manages the snapshots of one persistent DB directory.
+type SnapshotStore struct {
+	dbPath string
+	dir    string
+}
+
+// OpenSnapshotStore opens (or creates) the snapshot store for the DB in
+// dbPath.
+func OpenSnapshotStore(dbPath string) (*SnapshotStore, error) {
+	s := &SnapshotStore{
+		dbPath: filepath.Clean(dbPath),
+		dir:    filepath.Clean(dbPath) + ".snapshots",
+	}
+	for _, dir := range []string{s.objectsDir(), s.manifestsDir()} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("couldn't create snapshot store: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Create takes a snapshot of the DB directory.
+//
+// chromem-go has no way to pause writers, so Create is optimistic: it lists
+// the directory, copies every file and lists it again. If any file was added,
+// removed or modified in the meantime, it starts over.
+func (s *SnapshotStore) Create(label string) (*Manifest, *SnapshotCreateInfo, error) {
+	if !labelPattern.MatchString(label) {
+		return nil, nil, fmt.Errorf("invalid label %q: only letters, digits, '.', '_' and '-' are allowed", label)
+	}
+
+	// gc must not see objects stored here before the manifest refers to them
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	info := &SnapshotCreateInfo{}
+attempt:
+	for info.Attempts < snapshotAttempts {
+		info.Attempts++
+		info.NewObjects, info.NewBytes, info.Reused = 0, 0, 0
+		before, err := listFiles(s.dbPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		files := make([]SnapshotFile, 0, len(before))
+		for _, rel := range sortedKeys(before) {
+			f, created, err := s.storeFile(filepath.Join(s.dbPath, filepath.FromSlash(rel)))
+			if errors.Is(err, fs.ErrNotExist) {
+				// Deleted since the listing
+				continue attempt
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			f.Path = rel
+			files = append(files, f)
+			if created {
+				info.NewObjects++
+				info.NewBytes += f.Size
+			} else {
+				info.Reused++
+			}
+		}
+
+		after, err := listFiles(s.dbPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !sameFiles(before, after) {
+			continue
+		}
+
+		if err := s.syncObjects(files); err != nil {
+			return nil, nil, err
+		}
+		m := &Manifest{
+			ID:      s.newID(label),
+			Label:   label,
+			Created: time.Now().UTC(),
+			Files:   files,
+		}
+		if err := s.describe(m); err != nil {
+			return nil, nil, err
+		}
+		if err := s.writeManifest(m); err != nil {
+			return nil, nil, err
+		}
+		return m, info, nil
+	}
+	return nil, info, ErrSnapshotBusy
+}
+
+// List returns all snapshots, oldest first.
+func (s *SnapshotStore) List() ([]*Manifest, error) {
+	entries, err := os.ReadDir(s.manifestsDir())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list snapshots: %w", err)
+	}
+	var manifests []*Manifest
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		m, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		if !manifests[i].Created.Equal(manifests[j].Created) {
+			return manifests[i].Created.Before(manifests[j].Created)
+		}
+		return manifests[i].ID < manifests[j].ID
+	})
+	return manifests, nil
+}
+
+// Get returns the manifest of a snapshot.
+func (s *SnapshotStore) Get(id string) (*Manifest, error) {
+	if !labelPattern.MatchString(id) || id == "" {
+		return nil, fmt.Errorf("%w: %q", ErrSnapshotNotFound, id)
+	}
+	data, err := os.ReadFile(s.manifestPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %q", ErrSnapshotNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read manifest: %w", err)
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("couldn't decode manifest %q: %w", id, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("manifest %q: %w", id, err)
+	}
+	return m, nil
+}
+
+// Restore replaces the DB directory with a snapshot. Any open chromem.DB on
+// the directory must be discarded and reopened afterwards.
+//
+// The snapshot is written to a sibling directory first and swapped in with
+// two renames, so a failure never leaves a half-restored DB behind.
+func (s *SnapshotStore) Restore(id string) error {
+	return s.RestoreTo(id, s.dbPath)
+}
+
+// RestoreTo writes a snapshot into dir, replacing it if it exists.
+func (s *SnapshotStore) RestoreTo(id, dir string) error {
+	m, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	dir = filepath.Clean(dir)
+	tmp := dir + ".restore-tmp"
+	old := dir + ".restore-old"
+	os.RemoveAll(tmp)
+	os.RemoveAll(old)
+
+	if err := os.MkdirAll(tmp, 0o700); err != nil {
+		return fmt.Errorf("couldn't create restore directory: %w", err)
+	}
+	for _, f := range m.Files {
+		if err := s.materialize(f, filepath.Join(tmp, filepath.FromSlash(f.Path))); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		if err := os.Rename(dir, old); err != nil {
+			os.RemoveAll(tmp)
+			return fmt.Errorf("couldn't move DB aside: %w", err)
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.Rename(old, dir)
+		return fmt.Errorf("couldn't move restored DB into place: %w", err)
+	}
+	return os.RemoveAll(old)
+}
+
+// Open loads a snapshot into an in-memory DB. Changes to it are never written
+// anywhere, which makes it safe for comparing search quality between
+// snapshots while the live DB keeps running.
+func (s *SnapshotStore) Open(ctx context.Context, id string) (*chromem.DB, error) {
+	m, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	type collection struct {
+		name     string
+		metadata map[string]string
+		docs     []chromem.Document
+	}
+	byDir := make(map[string]*collection)
+	for _, f := range m.Files {
+		dir, file := path.Split(f.Path)
+		if dir == "" {
+			continue // top-level files aren't part of chromem-go's layout
+		}
+		c := byDir[dir]
+		if c == nil {
+			c = &collection{}
+			byDir[dir] = c
+		}
+		if strings.HasPrefix(file, "00000000.") {
+			pc := struct {
+				Name     string
+				Metadata map[string]string
+			}{}
+			if err := s.decodeObject(f, &pc); err != nil {
+				return nil, err
+			}
+			c.name, c.metadata = pc.Name, pc.Metadata
+			continue
+		}
+		var doc chromem.Document
+		if err := s.decodeObject(f, &doc); err != nil {
+			return nil, err
+		}
+		c.docs = append(c.docs, doc)
+	}
+
+	db := chromem.NewDB()
+	for dir, c := range byDir {
+		if c.name == "" {
+			return nil, fmt.Errorf("snapshot %q: collection directory %s has no metadata file", id, dir)
+		}
+		coll, err := db.CreateCollection(c.name, c.metadata, nil)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create collection %q: %w", c.name, err)
+		}
+		if len(c.docs) > 0 {
+			if err := coll.AddDocuments(ctx, c.docs, runtime.NumCPU()); err != nil {
+				return nil, fmt.Errorf("couldn't load collection %q: %w", c.name, err)
+			}
+		}
+	}
+	return db, nil
+}
+
+// Delete removes a snapshot and every object no other snapshot refers to. It
+// returns the number of bytes freed.
+func (s *SnapshotStore) Delete(id string) (int64, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	if _, err := s.Get(id); err != nil {
+		return 0, err
+	}
+	if err := os.Remove(s.manifestPath(id)); err != nil {
+		return 0, fmt.Errorf("couldn't delete manifest: %w", err)
+	}
+	return s.gc()
+}
+
+// lock takes the store's lock file, which Create holds from its first object
+// to its manifest and Delete while it collects garbage - in this process or
+// another. It waits up to lockTimeout for a holder to finish.
+func (s *SnapshotStore) lock() (unlock func(), err error) {
+	path := filepath.Join(s.dir, "lock")
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("couldn't lock snapshot store: %w", err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("snapshot store is locked; if no snapshot is being taken or deleted, remove %s", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// gc removes objects that no manifest refers to. The caller holds the lock.
+func (s *SnapshotStore) gc() (int64, error) {
+	manifests, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+	live := make(map[string]bool)
+	for _, m := range manifests {
+		for _, f := range m.Files {
+			live[f.SHA256] = true
+		}
+	}
+
+	var freed int64
+	err = filepath.WalkDir(s.objectsDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Dir(path) == s.objectsDir() {
+			// Objects live in subdirectories; the top level only holds
+			// incoming files of a running Create.
+			return err
+		}
+		hash := filepath.Base(filepath.Dir(path)) + d.Name()
+		if live[hash] {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		freed += fi.Size()
+		return nil
+	})
+	if err != nil {
+		return freed, fmt.Errorf("couldn't collect unused objects: %w", err)
+	}
+	return freed, nil
+}
+
+// storeFile copies a file into the object store and reports whether the
+// object is new.
+func (s *SnapshotStore) storeFile(path string) (SnapshotFile, bool, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return SnapshotFile{}, false, fmt.Errorf("couldn't read %s: %w", path, err)
+	}
+	defer src.Close()
+	return s.storeObject(src)
+}
+
+// storeObject writes r into the object store, named by its hash. New objects
+// aren't synced; callers sync them once before writing a manifest that refers
+// to them.
+func (s *SnapshotStore) storeObject(r io.Reader) (SnapshotFile, bool, error) {
+	tmp, err := os.CreateTemp(s.objectsDir(), "incoming-*")
+	if err != nil {
+		return SnapshotFile{}, false, fmt.Errorf("couldn't create object: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		tmp.Close()
+		return SnapshotFile{}, false, fmt.Errorf("couldn't write object: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return SnapshotFile{}, false, fmt.Errorf("couldn't write object: %w", err)
+	}
+
+	f := SnapshotFile{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}
+	dst := s.objectPath(f.SHA256)
+	if _, err := os.Stat(dst); err == nil {
+		return f, false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return SnapshotFile{}, false, fmt.Errorf("couldn't create object directory: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return SnapshotFile{}, false, fmt.Errorf("couldn't store object: %w", err)
+	}
+	return f, true, nil
+}
+
+// syncObjects makes the objects of files durable. Syncing objects that were
+// already durable is cheap.
+func (s *SnapshotStore) syncObjects(files []SnapshotFile) error {
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		path := s.objectPath(f.SHA256)
+		if err := syncPath(path); err != nil {
+			return fmt.Errorf("couldn't sync object: %w", err)
+		}
+		dirs[filepath.Dir(path)] = true
+	}
+	for dir := range dirs {
+		if err := syncPath(dir); err != nil {
+			return fmt.Errorf("couldn't sync object directory: %w", err)
+		}
+	}
+	return nil
+}
+
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// openObject opens the object of f and checks that it is still intact.
+func (s *SnapshotStore) openObject(f SnapshotFile) (io.Reader, error) {
+	data, err := os.ReadFile(s.objectPath(f.SHA256))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read object for %s: %w", f.Path, err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != f.SHA256 {
+		return nil, fmt.Errorf("object for %s is corrupted (checksum mismatch)", f.Path)
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (s *SnapshotStore) materialize(f SnapshotFile, dst string) error {
+	r, err := s.openObject(f)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return fmt.Errorf("couldn't create directory: %w", err)
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("couldn't restore %s: %w", f.Path, err)
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return fmt.Errorf("couldn't restore %s: %w", f.Path, err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("couldn't sync %s: %w", f.Path, err)
+	}
+	return out.Close()
+}
+
+// decodeObject decodes a chromem-go gob file from the object store.
+func (s *SnapshotStore) decodeObject(f SnapshotFile, obj any) error {
+	r, err := s.openObject(f)
+	if err != nil {
+		return err
+	}
+
+	var dec io.Reader = r
+	if strings.HasSuffix(f.Path, ".gz") {
+		gzr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("couldn't decompress %s: %w", f.Path, err)
+		}
+		defer gzr.Close()
+		dec = gzr
+	}
+	if err := gob.NewDecoder(dec).Decode(obj); err != nil {
+		return fmt.Errorf("couldn't decode %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// describe fills in the collections of a manifest from its metadata files.
+func (s *SnapshotStore) describe(m *Manifest) error {
+	counts := make(map[string]int)
+	names := make(map[string]string)
+	for _, f := range m.Files {
+		dir, file := path.Split(f.Path)
+		if dir == "" {
+			continue
+		}
+		if !strings.HasPrefix(file, "00000000.") {
+			counts[dir]++
+			continue
+		}
+		m.Compressed = strings.HasSuffix(file, ".gz")
+		pc := struct{ Name string }{}
+		if err := s.decodeObject(f, &pc); err != nil {
+			return err
+		}
+		names[dir] = pc.Name
+	}
+	for dir, name := range names {
+		m.Collections = append(m.Collections, SnapshotColl{Name: name, Documents: counts[dir]})
+	}
+	sort.Slice(m.Collections, func(i, j int) bool { return m.Collections[i].Name < m.Collections[j].Name })
+	return nil
+}
+
+func (s *SnapshotStore) writeManifest(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't encode manifest: %w", err)
+	}
+	tmp := s.manifestPath(m.ID) + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("couldn't write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, s.manifestPath(m.ID)); err != nil {
+		return fmt.Errorf("couldn't write manifest: %w", err)
+	}
+	return nil
+}
+
+// newID returns a sortable, unique snapshot ID.
+func (s *SnapshotStore) newID(label string) string {
+	base := time.Now().UTC().Format("20060102-150405")
+	if label != "" {
+		base += "-" + label
+	}
+	id := base
+	for n := 2; ; n++ {
+		if _, err := os.Stat(s.manifestPath(id)); errors.Is(err, fs.ErrNotExist) {
+			return id
+		}
+		id = fmt.Sprintf("%s.%d", base, n)
+	}
+}
+
+func (s *SnapshotStore) objectsDir() string   { return filepath.Join(s.dir, "objects") }
+func (s *SnapshotStore) manifestsDir() string { return filepath.Join(s.dir, "manifests") }
+
+func (s *SnapshotStore) manifestPath(id string) string {
+	return filepath.Join(s.manifestsDir(), id+".json")
+}
+
+func (s *SnapshotStore) objectPath(hash string) string {
+	return filepath.Join(s.objectsDir(), hash[:2], hash[2:])
+}
+
+// fileState is what listFiles compares between two listings.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// listFiles returns every regular file below dir, keyed by relative path.
+func listFiles(dir string) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = fileState{size: fi.Size(), modTime: fi.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list DB directory: %w", err)
+	}
+	return files, nil
+}
+
+func sameFiles(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, fa := range a {
+		fb, ok := b[path]
+		if !ok || fa.size != fb.size || !fa.modTime.Equal(fb.modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]fileState) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
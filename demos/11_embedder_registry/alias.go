@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// aliasFile maps collection names to the chromem-go collections that serve
+// them. It lives at the top of the DB directory, where chromem-go doesn't
+// look: it only loads subdirectories as collections.
+const aliasFile = "aliases.json"
+
+// aliasMu serializes read-modify-write cycles of alias files within the
+// process.
+var aliasMu sync.Mutex
+
+// readAliases returns the aliases of the DB at dbPath. Names without an alias
+// are served by the collection of the same name.
+func readAliases(dbPath string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(dbPath, aliasFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return aliases, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("couldn't read aliases: %w", err)
+	}
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("couldn't decode aliases: %w", err)
+	}
+	return aliases, nil
+}
+
+// resolveCollection returns the chromem-go collection that serves name.
+func resolveCollection(dbPath, name string) (string, error) {
+	aliases, err := readAliases(dbPath)
+	if err != nil {
+		return "", err
+	}
+	if target, ok := aliases[name]; ok {
+		return target, nil
+	}
+	return name, nil
+}
+
+// setAlias points name at target. The alias file is replaced with a rename,
+// so a crash leaves either the old or the new alias in place.
+func setAlias(dbPath, name, target string) error {
+	aliasMu.Lock()
+	defer aliasMu.Unlock()
+
+	aliases, err := readAliases(dbPath)
+	if err != nil {
+		return err
+	}
+	if target == name {
+		delete(aliases, name)
+	} else {
+		aliases[name] = target
+	}
+	data, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't encode aliases: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dbPath, aliasFile), append(data, '\n')); err != nil {
+		return fmt.Errorf("couldn't write aliases: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data: it writes and syncs a temporary
+// file next to it, renames it over path and syncs the directory.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// hash2hex returns the first 4 bytes of the SHA-256 of name as hex, the way
+// chromem-go names collection directories and document files.
+func hash2hex(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:4])
+}
+
+// LiveCollection is a collection opened by its name rather than by the
+// chromem-go collection that currently serves it. Queries and writes follow
+// a migration when it swaps collections.
+type LiveCollection struct {
+	registry *Registry
+	db       *chromem.DB
+	dbPath   string
+	name     string
+
+	// mu is held for reading by queries and writes and for writing by a
+	// migration while it catches up and swaps coll.
+	mu   sync.RWMutex
+	coll *Collection
+}
+
+// OpenLive opens the collection that serves name in the persistent DB at
+// dbPath, resolving aliases left by earlier migrations.
+func (r *Registry) OpenLive(ctx context.Context, db *chromem.DB, dbPath, name string) (*LiveCollection, error) {
+	target, err := resolveCollection(dbPath, name)
+	if err != nil {
+		return nil, err
+	}
+	coll, err := r.OpenCollection(ctx, db, target)
+	if err != nil {
+		return nil, err
+	}
+	return &LiveCollection{registry: r, db: db, dbPath: dbPath, name: name, coll: coll}, nil
+}
+
+// Name returns the name the collection was opened by.
+func (l *LiveCollection) Name() string {
+	return l.name
+}
+
+// Current returns the collection that serves queries right now.
+func (l *LiveCollection) Current() *Collection {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.coll
+}
+
+// Query embeds text with the current collection's embedder and searches for
+// it.
+func (l *LiveCollection) Query(ctx context.Context, text string, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.coll.Query(ctx, text, nResults, where, whereDocument)
+}
+
+// AddDocuments adds documents to the current collection. Documents added
+// while a migration runs are picked up by the migration before it swaps.
+func (l *LiveCollection) AddDocuments(ctx context.Context, docs []chromem.Document, concurrency int) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.coll.AddDocuments(ctx, docs, concurrency)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Collection metadata keys that identify the embedder a collection was built
+// with. chromem-go persists collection metadata but not embedding functions,
+// so these keys are what lets a reloaded collection embed text queries again.
+const (
+	metaEmbedderName       = "embedder.name"
+	metaEmbedderVersion    = "embedder.version"
+	metaEmbedderDimension  = "embedder.dimension"
+	metaEmbedderNormalized = "embedder.normalized"
+)
+
+// EmbedderIdentity describes an embedding model precisely enough that two
+// embedders with the same identity produce comparable vectors.
+type EmbedderIdentity struct {
+	Name       string
+	Version    string
+	Dimension  int
+	Normalized bool
+}
+
+// Key returns the registry key, "name@version".
+func (id EmbedderIdentity) Key() string {
+	return id.Name + "@" + id.Version
+}
+
+func (id EmbedderIdentity) String() string {
+	if id.Dimension == 0 {
+		return id.Key()
+	}
+	return fmt.Sprintf("%s (%d dims)", id.Key(), id.Dimension)
+}
+
+// metadata returns the collection metadata keys for the identity, merged into
+// a copy of base. The dimension isn't among them: it is recorded with the
+// first document.
+func (id EmbedderIdentity) metadata(base map[string]string) map[string]string {
+	m := make(map[string]string, len(base)+3)
+	for k, v := range base {
+		m[k] = v
+	}
+	m[metaEmbedderName] = id.Name
+	m[metaEmbedderVersion] = id.Version
+	m[metaEmbedderNormalized] = strconv.FormatBool(id.Normalized)
+	return m
+}
+
+// identityFromMetadata reads the identity stored by metadata. The boolean is
+// false if the collection has no identity at all. The dimension is 0 if no
+// document has recorded it yet.
+func identityFromMetadata(m map[string]string) (EmbedderIdentity, bool, error) {
+	name, ok := m[metaEmbedderName]
+	if !ok {
+		return EmbedderIdentity{}, false, nil
+	}
+	id := EmbedderIdentity{Name: name, Version: m[metaEmbedderVersion]}
+
+	var err error
+	if d, ok := m[metaEmbedderDimension]; ok {
+		if id.Dimension, err = strconv.Atoi(d); err != nil || id.Dimension <= 0 {
+			return EmbedderIdentity{}, true, fmt.Errorf("invalid %s %q", metaEmbedderDimension, d)
+		}
+	}
+	if id.Normalized, err = strconv.ParseBool(m[metaEmbedderNormalized]); err != nil {
+		return EmbedderIdentity{}, true, fmt.Errorf("invalid %s %q", metaEmbedderNormalized, m[metaEmbedderNormalized])
+	}
+	return id, true, nil
+}
+
+// Embedder is an embedding function together with its identity.
+type Embedder struct {
+	EmbedderIdentity
+	Embed chromem.EmbeddingFunc
+}
+
+// NewHashingEmbedder returns a local embedder that hashes features of the text
+// into a fixed number of dimensions. It needs no model and no network, which
+// makes it a stand-in for a real model in this demo:
+//
+//   - version "1" hashes words into 256 dimensions
+//   - version "2" adds character trigrams, so "container" also matches
+//     "containers", and uses 512 dimensions
+func NewHashingEmbedder(version string) (Embedder, error) {
+	var dim int
+	var trigrams bool
+	switch version {
+	case "1":
+		dim = 256
+	case "2":
+		dim, trigrams = 512, true
+	default:
+		return Embedder{}, fmt.Errorf("unknown hashing embedder version %q", version)
+	}
+
+	embed := func(_ context.Context, text string) ([]float32, error) {
+		vec := make([]float32, dim)
+		for _, word := range tokenize(text) {
+			addFeature(vec, "w:"+word, 1)
+			if trigrams {
+				padded := "^" + word + "$"
+				for i := 0; i+3 <= len(padded); i++ {
+					addFeature(vec, "t:"+padded[i:i+3], 0.5)
+				}
+			}
+		}
+		if !normalize(vec) {
+			return nil, fmt.Errorf("text %q has no features to embed", text)
+		}
+		return vec, nil
+	}
+
+	return Embedder{
+		EmbedderIdentity: EmbedderIdentity{Name: "hashing", Version: version, Dimension: dim, Normalized: true},
+		Embed:            embed,
+	}, nil
+}
+
+// addFeature adds weight to the dimension the feature hashes to. A second bit
+// of the hash picks the sign, so collisions cancel out instead of piling up.
+func addFeature(vec []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum&1 == 1 {
+		weight = -weight
+	}
+	vec[(sum>>1)%uint64(len(vec))] += weight
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// normalize scales vec to unit length and reports whether it was non-zero.
+func normalize(vec []float32) bool {
+	var sum float64
+	for _, v := range vec {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return false
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vec {
+		vec[i] /= norm
+	}
+	return true
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Println("🧬 Embedder Registry Demo - Text Queries on Reloaded Collections")
+	fmt.Println("================================================================")
+
+	ctx := context.Background()
+	dbPath := "./chromem-data"
+	os.RemoveAll(dbPath)
+
+	registry := defaultRegistry()
+	hashingV1, _ := registry.Lookup("hashing@1")
+	hashingV2, _ := registry.Lookup("hashing@2")
+
+	// 1. Create a collection that records its embedder
+	fmt.Println("\n📝 1. Creating knowledge-base with hashing@1...")
+	createAndSave(ctx, dbPath, registry)
+
+	// 2. Restart: the embedding function is gone, the identity isn't
+	fmt.Println("\n🔄 2. Reloading from disk...")
+	db, err := chromem.NewPersistentDB(dbPath, false)
+	if err != nil {
+		panic(err)
+	}
+	coll, err := registry.OpenCollection(ctx, db, "knowledge-base")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   knowledge-base: %d documents, re-bound to %s\n", coll.Count(), coll.Embedder.EmbedderIdentity)
+
+	// 3. Text queries, no hand-made vectors
+	fmt.Println("\n🔍 3. Querying with text...")
+	for _, query := range []string{"kubernetes clusters", "how does database sharding work", "REST APIs over HTTP"} {
+		results, err := coll.Query(ctx, query, 2, nil, nil)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("   %q\n", query)
+		for i, result := range results {
+			fmt.Printf("      %d. [%s] %.4f  %s\n", i+1, result.ID, result.Similarity, result.Content)
+		}
+	}
+
+	// 4. Refusing the wrong model
+	fmt.Println("\n🛑 4. Refusing to query with the wrong model...")
+	onlyV2, err := NewRegistry(hashingV2)
+	if err != nil {
+		panic(err)
+	}
+	tryOpen(ctx, dbPath, onlyV2, "knowledge-base", "registry without hashing@1")
+
+	broken := hashingV1
+	broken.Dimension = 128
+	mismatched, err := NewRegistry(broken)
+	if err != nil {
+		panic(err)
+	}
+	tryOpen(ctx, dbPath, mismatched, "knowledge-base", "hashing@1 registered with 128 dimensions")
+
+	// Same name and version, different vectors - like a model tag that was
+	// re-pulled with new weights
+	drifted := hashingV1
+	drifted.Embed = func(ctx context.Context, text string) ([]float32, error) {
+		return hashingV1.Embed(ctx, "the "+text)
+	}
+	driftedRegistry, err := NewRegistry(drifted)
+	if err != nil {
+		panic(err)
+	}
+	tryOpen(ctx, dbPath, driftedRegistry, "knowledge-base", "hashing@1 whose output changed")
+	tryOpen(ctx, dbPath, registry, "legacy", "collection created by plain chromem-go")
+
+	// 5. Validation on writes and queries
+	fmt.Println("\n🚧 5. Rejecting mismatched vectors...")
+	// The 16-dim vectors of 03_persist_reload and 04_semantic_snippets
+	_, err = coll.QueryEmbedding(ctx, make([]float32, 16), 3, nil, nil)
+	printRejected("16-dim query vector", err)
+
+	err = coll.AddDocuments(ctx, []chromem.Document{{ID: "doc-006", Content: "Hand-made vector", Embedding: make([]float32, 16)}}, 1)
+	printRejected("16-dim document", err)
+
+	v2Docs := []chromem.Document{{ID: "doc-006", Content: "Service meshes add mTLS and retries between services"}}
+	if err := embedAll(ctx, hashingV2, "knowledge-base", v2Docs, 1); err != nil {
+		panic(err)
+	}
+	err = coll.AddEmbeddedDocuments(ctx, hashingV2.EmbedderIdentity, v2Docs, 1)
+	printRejected("document embedded by hashing@2", err)
+
+	// 6. Migrate to a better model while serving
+	fmt.Println("\n🚚 6. Migrating knowledge-base to hashing@2 while serving...")
+	// hashing@2 with the latency of a model server, so the migration takes a
+	// while
+	remote, err := NewRegistry(hashingV1, withLatency(hashingV2, 2*time.Millisecond))
+	if err != nil {
+		panic(err)
+	}
+	live, err := remote.OpenLive(ctx, db, dbPath, "knowledge-base")
+	if err != nil {
+		panic(err)
+	}
+	if err := live.AddDocuments(ctx, incidents(120), runtime.NumCPU()); err != nil {
+		panic(err)
+	}
+	fmt.Printf("   knowledge-base grew to %d documents\n", live.Current().Count())
+
+	// The first run is interrupted after two checkpoints
+	query := "orchestrating containers"
+	interrupted, cancel := context.WithCancel(ctx)
+	checkpointed := make(chan struct{})
+	opts := MigrationOptions{Concurrency: 4, BatchSize: 25}
+	opts.OnProgress = func(p MigrationProgress) {
+		if p.Done == 25 && p.CaughtUp == 0 {
+			close(checkpointed)
+		}
+		if p.Done >= 50 {
+			cancel()
+		}
+	}
+	migration, err := remote.StartMigration(interrupted, live, "hashing@2", opts)
+	if err != nil {
+		panic(err)
+	}
+	<-checkpointed
+	printTop(ctx, live, query)
+	err = live.AddDocuments(ctx, []chromem.Document{{
+		ID:       "doc-006",
+		Content:  "Service meshes add mTLS and retries between services",
+		Metadata: map[string]string{"category": "networking", "difficulty": "advanced"},
+	}}, 1)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("   Added doc-006 while migrating")
+	if err := migration.Wait(); !errors.Is(err, context.Canceled) {
+		panic(fmt.Sprintf("expected the migration to be interrupted, got %v", err))
+	}
+	p := migration.Progress()
+	fmt.Printf("   ⏸️  Interrupted at %d/%d, %s still serves\n", p.Done, p.Total, live.Current().Embedder.Key())
+	printTop(ctx, live, query)
+
+	// The second run resumes from the checkpoint
+	opts.OnProgress = func(p MigrationProgress) {
+		switch {
+		case p.Swapped:
+			fmt.Printf("   🔀 Swapped: knowledge-base is served by %q after catching up %d write(s)\n", p.Shadow, p.CaughtUp)
+		case p.CaughtUp > 0:
+		case p.Done < p.Total:
+			fmt.Printf("   ⏳ %d/%d\n", p.Done, p.Total)
+		}
+	}
+	migration, err = remote.StartMigration(ctx, live, "hashing@2", opts)
+	if err != nil {
+		panic(err)
+	}
+	if err := migration.Wait(); err != nil {
+		panic(err)
+	}
+	printTop(ctx, live, query)
+
+	// The swap survives a restart, and doc-006 kept its metadata
+	db, err = chromem.NewPersistentDB(dbPath, false)
+	if err != nil {
+		panic(err)
+	}
+	live, err = registry.OpenLive(ctx, db, dbPath, "knowledge-base")
+	if err != nil {
+		panic(err)
+	}
+	doc, err := live.Current().GetByID(ctx, "doc-006")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   After reload: %d documents with %s, doc-006 %v\n", live.Current().Count(), live.Current().Embedder.EmbedderIdentity, doc.Metadata)
+
+	fmt.Println("\n🎯 Key Benefits:")
+	fmt.Println("   ✅ Reloaded collections embed text queries with the model they were built with")
+	fmt.Println("   ✅ No dummy embedding function, no silent fallback to a hosted default")
+	fmt.Println("   ✅ A missing or mismatched model is an error, not bad results")
+	fmt.Println("   ✅ Vectors of the wrong dimension or model never reach a collection")
+	fmt.Println("   ✅ Switching models is a background job, not downtime")
+	fmt.Println("\n💡 Try: go run . migrate -collection knowledge-base -to hashing@1")
+}
+
+// defaultRegistry returns the embedders this demo knows about.
+func defaultRegistry() *Registry {
+	hashingV1, err := NewHashingEmbedder("1")
+	if err != nil {
+		panic(err)
+	}
+	hashingV2, err := NewHashingEmbedder("2")
+	if err != nil {
+		panic(err)
+	}
+	registry, err := NewRegistry(hashingV1, hashingV2)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// withLatency returns e with a delay added to every call.
+func withLatency(e Embedder, d time.Duration) Embedder {
+	embed := e.Embed
+	e.Embed = func(ctx context.Context, text string) ([]float32, error) {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return embed(ctx, text)
+	}
+	return e
+}
+
+// runMigrate implements `go run . migrate`. Interrupting it with Ctrl-C
+// leaves a checkpoint; running it again resumes.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbPath := fs.String("db", "./chromem-data", "chromem-go persistent DB directory")
+	name := fs.String("collection", "knowledge-base", "collection to re-embed")
+	to := fs.String("to", "", "embedder to migrate to, as name@version")
+	concurrency := fs.Int("concurrency", runtime.NumCPU(), "number of documents embedded in parallel")
+	batch := fs.Int("batch", 100, "number of documents embedded between checkpoints")
+	fs.Parse(args)
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		return fmt.Errorf("couldn't open DB: %w", err)
+	}
+	db, err := chromem.NewPersistentDB(*dbPath, false)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	registry := defaultRegistry()
+	live, err := registry.OpenLive(ctx, db, *dbPath, *name)
+	if err != nil {
+		return err
+	}
+	migration, err := registry.StartMigration(ctx, live, *to, MigrationOptions{
+		Concurrency: *concurrency,
+		BatchSize:   *batch,
+		OnProgress: func(p MigrationProgress) {
+			if !p.Swapped {
+				fmt.Printf("⏳ %s: %d/%d documents, %d caught up\n", p.Collection, p.Done, p.Total, p.CaughtUp)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := migration.Wait(); errors.Is(err, context.Canceled) {
+		p := migration.Progress()
+		return fmt.Errorf("interrupted at %d/%d documents; run again to resume", p.Done, p.Total)
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("🔀 %s is served by %q with %s\n", *name, live.Current().Name, live.Current().Embedder.EmbedderIdentity)
+	return nil
+}
+
+func printRejected(what string, err error) {
+	var dimErr *DimensionMismatchError
+	var modelErr *ModelMismatchError
+	switch {
+	case errors.As(err, &dimErr):
+		fmt.Printf("   ❌ %s: DimensionMismatchError\n      %v\n", what, err)
+	case errors.As(err, &modelErr):
+		fmt.Printf("   ❌ %s: ModelMismatchError\n      %v\n", what, err)
+	case err != nil:
+		panic(err)
+	default:
+		panic("expected " + what + " to be rejected")
+	}
+}
+
+func printTop(ctx context.Context, live *LiveCollection, query string) {
+	results, err := live.Query(ctx, query, 1, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   %s: %q → [%s] %.4f  %s\n", live.Current().Embedder.Key(), query, results[0].ID, results[0].Similarity, results[0].Content)
+}
+
+func createAndSave(ctx context.Context, dbPath string, registry *Registry) {
+	db, err := chromem.NewPersistentDB(dbPath, false)
+	if err != nil {
+		panic(err)
+	}
+	coll, err := registry.CreateCollection(ctx, db, "knowledge-base",
+		map[string]string{"description": "Technical documentation snippets"}, "hashing@1")
+	if err != nil {
+		panic(err)
+	}
+	if err := coll.AddDocuments(ctx, knowledgeBase(), 1); err != nil {
+		panic(err)
+	}
+	fmt.Printf("   Added %d documents, embedded by %s\n", coll.Count(), coll.Embedder.EmbedderIdentity)
+
+	// A collection without identity, like the one 03_persist_reload creates
+	legacy, err := db.CreateCollection("legacy", nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	err = legacy.AddDocument(ctx, chromem.Document{
+		ID:        "doc-001",
+		Content:   "Docker containers provide lightweight, portable application packaging",
+		Embedding: []float32{0.1, 0.9, 0.3, 0.7, 0.5, 0.8, 0.2, 0.6, 0.4, 0.9, 0.1, 0.8, 0.3, 0.7, 0.5, 0.6},
+	})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("   Added legacy collection with hand-made vectors and no identity")
+}
+
+// tryOpen reloads the DB and opens a collection, expecting the registry to
+// refuse it.
+func tryOpen(ctx context.Context, dbPath string, registry *Registry, name, scenario string) {
+	db, err := chromem.NewPersistentDB(dbPath, false)
+	if err != nil {
+		panic(err)
+	}
+	_, err = registry.OpenCollection(ctx, db, name)
+	switch {
+	case errors.Is(err, ErrUnknownEmbedder), errors.Is(err, ErrEmbedderMismatch), errors.Is(err, ErrNoEmbedder):
+		fmt.Printf("   ❌ %s:\n      %v\n", scenario, err)
+	case err != nil:
+		panic(err)
+	default:
+		panic("expected " + scenario + " to be refused")
+	}
+}
+
+func knowledgeBase() []chromem.Document {
+	return []chromem.Document{
+		{
+			ID:       "doc-001",
+			Content:  "Docker containers provide lightweight, portable application packaging",
+			Metadata: map[string]string{"category": "containerization", "difficulty": "beginner"},
+		},
+		{
+			ID:       "doc-002",
+			Content:  "Kubernetes orchestrates containers across clusters with automated scaling",
+			Metadata: map[string]string{"category": "orchestration", "difficulty": "advanced"},
+		},
+		{
+			ID:       "doc-003",
+			Content:  "Microservice architecture breaks applications into independent, deployable services",
+			Metadata: map[string]string{"category": "architecture", "difficulty": "intermediate"},
+		},
+		{
+			ID:       "doc-004",
+			Content:  "REST APIs enable communication between services using HTTP protocols",
+			Metadata: map[string]string{"category": "api", "difficulty": "beginner"},
+		},
+		{
+			ID:       "doc-005",
+			Content:  "Database sharding distributes data across multiple database instances",
+			Metadata: map[string]string{"category": "database", "difficulty": "advanced"},
+		},
+	}
+}
+
+// incidents returns n generated incident reports, so the migration has
+// enough documents to take a while.
+func incidents(n int) []chromem.Document {
+	services := []string{"payments", "search", "auth", "billing", "checkout"}
+	causes := []string{
+		"returned 5xx errors after a deploy",
+		"latency spiked during peak traffic",
+		"ran out of disk space on its primary volume",
+		"failed health checks after a certificate expired",
+	}
+	docs := make([]chromem.Document, n)
+	for i := range docs {
+		service := services[i%len(services)]
+		docs[i] = chromem.Document{
+			ID:       fmt.Sprintf("inc-%03d", i+1),
+			Content:  fmt.Sprintf("Incident %d: the %s service %s", i+1, service, causes[i%len(causes)]),
+			Metadata: map[string]string{"category": "incident", "service": service},
+		}
+	}
+	return docs
+}
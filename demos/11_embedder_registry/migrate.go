@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// MigrationOptions configures a migration.
+type MigrationOptions struct {
+	// Concurrency is the number of documents embedded in parallel.
+	Concurrency int
+	// BatchSize is the number of documents embedded between checkpoints.
+	BatchSize int
+	// OnProgress is called from the migration's goroutine after every
+	// checkpoint and once more after the swap.
+	OnProgress func(MigrationProgress)
+}
+
+// MigrationProgress describes how far a migration got.
+type MigrationProgress struct {
+	Collection string // name the collection is opened by
+	From       string // chromem-go collection serving it before the swap
+	Shadow     string // chromem-go collection being filled, serving it after
+	Done       int    // documents copied in ID order, including earlier runs
+	Total      int    // documents in From when the migration (re)started
+	CaughtUp   int    // documents written to From while copying
+	Swapped    bool
+}
+
+// migrationCheckpoint is persisted after every batch. Documents up to LastID
+// are in the shadow collection, so a resumed migration continues after it.
+type migrationCheckpoint struct {
+	Collection string    `json:"collection"`
+	From       string    `json:"from"`
+	Shadow     string    `json:"shadow"`
+	To         string    `json:"to"`
+	LastID     string    `json:"last_id"`
+	Updated    time.Time `json:"updated"`
+}
+
+// Migration re-embeds a collection with another model in the background.
+// Documents are copied into a shadow collection in ID order while the old
+// collection keeps serving queries and writes; then the documents written in
+// the meantime are caught up and the shadow is swapped in.
+type Migration struct {
+	registry *Registry
+	live     *LiveCollection
+	to       Embedder
+	cp       migrationCheckpoint
+	opts     MigrationOptions
+
+	mu       sync.Mutex
+	progress MigrationProgress
+
+	done chan struct{}
+	err  error
+}
+
+// StartMigration starts re-embedding live with the embedder registered as
+// toKey. If an earlier migration of the same collection to the same model was
+// interrupted, it resumes from its last checkpoint.
+//
+// Cancelling ctx stops the migration at the next batch; the old collection
+// keeps serving and the checkpoint stays in place.
+func (r *Registry) StartMigration(ctx context.Context, live *LiveCollection, toKey string, opts MigrationOptions) (*Migration, error) {
+	to, ok := r.Lookup(toKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEmbedder, toKey)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+
+	from := live.Current()
+	if from.Embedder.EmbedderIdentity == to.EmbedderIdentity {
+		return nil, fmt.Errorf("collection %q already uses %s", live.Name(), to.EmbedderIdentity)
+	}
+	cp, ok, err := readCheckpoint(live.db, live.dbPath, live.Name())
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !ok:
+		cp = migrationCheckpoint{Collection: live.Name(), From: from.Name, Shadow: live.Name() + "~" + toKey, To: toKey}
+	case cp.From != from.Name || cp.To != toKey:
+		return nil, fmt.Errorf("collection %q has an unfinished migration from %q to %s; resume it or delete %s",
+			live.Name(), cp.From, cp.To, checkpointPath(live.dbPath, live.Name()))
+	}
+
+	m := &Migration{
+		registry: r,
+		live:     live,
+		to:       to,
+		cp:       cp,
+		opts:     opts,
+		progress: MigrationProgress{Collection: cp.Collection, From: cp.From, Shadow: cp.Shadow},
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(m.done)
+		m.err = m.run(ctx)
+	}()
+	return m, nil
+}
+
+// Wait blocks until the migration has swapped or failed.
+func (m *Migration) Wait() error {
+	<-m.done
+	return m.err
+}
+
+// Progress returns the latest progress.
+func (m *Migration) Progress() MigrationProgress {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.progress
+}
+
+func (m *Migration) report(update func(*MigrationProgress)) {
+	m.mu.Lock()
+	update(&m.progress)
+	p := m.progress
+	m.mu.Unlock()
+	if m.opts.OnProgress != nil {
+		m.opts.OnProgress(p)
+	}
+}
+
+func (m *Migration) run(ctx context.Context) error {
+	db, dbPath := m.live.db, m.live.dbPath
+	from := m.live.Current()
+
+	// The checkpoint goes first: a crash after creating the shadow collection
+	// then resumes into it instead of starting a second one.
+	if err := writeCheckpoint(dbPath, &m.cp); err != nil {
+		return err
+	}
+	shadow, err := m.openShadow(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	m.live.mu.Lock()
+	docs, err := readDocuments(db, from.Name)
+	m.live.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	done := sort.Search(len(docs), func(i int) bool { return m.cp.LastID == "" || docs[i].ID > m.cp.LastID })
+	m.report(func(p *MigrationProgress) { p.Done, p.Total = done, len(docs) })
+
+	for start := done; start < len(docs); start += m.opts.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch := docs[start:min(start+m.opts.BatchSize, len(docs))]
+		if err := m.copy(ctx, shadow, batch); err != nil {
+			return err
+		}
+		m.cp.LastID = batch[len(batch)-1].ID
+		if err := writeCheckpoint(dbPath, &m.cp); err != nil {
+			return err
+		}
+		m.report(func(p *MigrationProgress) { p.Done = start + len(batch) })
+	}
+
+	// Catch up once while the old collection still serves, then again with
+	// queries and writes held, which only has to cover the last moments.
+	m.live.mu.Lock()
+	old, copied, err := m.snapshot(from, shadow)
+	m.live.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := m.catchUp(ctx, shadow, old, copied); err != nil {
+		return err
+	}
+	m.live.mu.Lock()
+	old, copied, err = m.snapshot(from, shadow)
+	if err == nil {
+		err = m.catchUp(ctx, shadow, old, copied)
+	}
+	if err == nil {
+		err = setAlias(dbPath, m.live.Name(), shadow.Name)
+	}
+	if err == nil {
+		m.live.coll = shadow
+	}
+	m.live.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// The alias is the commit point. What follows is cleanup, which
+	// readCheckpoint finishes if it is interrupted.
+	if err := db.DeleteCollection(from.Name); err != nil {
+		return fmt.Errorf("couldn't delete collection %q: %w", from.Name, err)
+	}
+	if err := os.Remove(checkpointPath(dbPath, m.live.Name())); err != nil {
+		return fmt.Errorf("couldn't remove checkpoint: %w", err)
+	}
+	m.report(func(p *MigrationProgress) { p.Swapped = true })
+	return nil
+}
+
+// openShadow opens the shadow collection left by an interrupted run or
+// creates it with the old collection's metadata.
+func (m *Migration) openShadow(ctx context.Context, from *Collection) (*Collection, error) {
+	db := m.live.db
+	if _, ok := db.ListCollections()[m.cp.Shadow]; ok {
+		return m.registry.OpenCollection(ctx, db, m.cp.Shadow)
+	}
+	// The export behind collectionMetadata races writers like readDocuments
+	m.live.mu.Lock()
+	metadata, err := collectionMetadata(db, from.Name)
+	m.live.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return m.registry.CreateCollection(ctx, db, m.cp.Shadow, userMetadata(metadata), m.cp.To)
+}
+
+// copy embeds docs with the new model and adds them to the shadow collection.
+func (m *Migration) copy(ctx context.Context, shadow *Collection, docs []chromem.Document) error {
+	if err := embedAll(ctx, m.to, shadow.Name, docs, m.opts.Concurrency); err != nil {
+		return err
+	}
+	return shadow.AddEmbeddedDocuments(ctx, m.to.EmbedderIdentity, docs, m.opts.Concurrency)
+}
+
+// snapshot returns the documents of the old and the shadow collection. The
+// caller holds m.live.mu for writing: chromem-go exports a collection without
+// taking its lock, and writers through the LiveCollection hold only the read
+// lock, so an export next to a write races on the document map.
+func (m *Migration) snapshot(from, shadow *Collection) (old, copied []chromem.Document, err error) {
+	if old, err = readDocuments(m.live.db, from.Name); err != nil {
+		return nil, nil, err
+	}
+	if copied, err = readDocuments(m.live.db, shadow.Name); err != nil {
+		return nil, nil, err
+	}
+	return old, copied, nil
+}
+
+// catchUp copies documents that were added to or changed in the old
+// collection after they were copied, and removes documents from the shadow
+// collection that were deleted from the old one.
+func (m *Migration) catchUp(ctx context.Context, shadow *Collection, old, copied []chromem.Document) error {
+	byID := make(map[string]chromem.Document, len(copied))
+	for _, doc := range copied {
+		byID[doc.ID] = doc
+	}
+	var stale []chromem.Document
+	for _, doc := range old {
+		c, ok := byID[doc.ID]
+		if !ok || c.Content != doc.Content || !maps.Equal(c.Metadata, doc.Metadata) {
+			stale = append(stale, doc)
+		}
+		delete(byID, doc.ID)
+	}
+	if len(stale) > 0 {
+		if err := m.copy(ctx, shadow, stale); err != nil {
+			return err
+		}
+		m.report(func(p *MigrationProgress) { p.CaughtUp += len(stale) })
+	}
+	if len(byID) > 0 {
+		if err := shadow.Delete(ctx, nil, nil, sortedKeys(byID)...); err != nil {
+			return fmt.Errorf("couldn't delete documents from %q: %w", shadow.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkpointPath returns the checkpoint file of a migration of name. Like
+// the alias file, it sits at the top of the DB directory.
+func checkpointPath(dbPath, name string) string {
+	return filepath.Join(dbPath, "migration-"+hash2hex(name)+".json")
+}
+
+// readCheckpoint returns the checkpoint of an unfinished migration of name.
+// A checkpoint whose shadow collection already serves name belongs to a
+// migration that swapped but didn't clean up; that cleanup is finished here,
+// through db so it doesn't keep serving the deleted collection.
+func readCheckpoint(db *chromem.DB, dbPath, name string) (migrationCheckpoint, bool, error) {
+	var cp migrationCheckpoint
+	data, err := os.ReadFile(checkpointPath(dbPath, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, false, nil
+	} else if err != nil {
+		return cp, false, fmt.Errorf("couldn't read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, false, fmt.Errorf("couldn't decode checkpoint: %w", err)
+	}
+
+	target, err := resolveCollection(dbPath, name)
+	if err != nil {
+		return cp, false, err
+	}
+	if target != cp.Shadow {
+		return cp, true, nil
+	}
+	if err := db.DeleteCollection(cp.From); err != nil {
+		return cp, false, fmt.Errorf("couldn't remove collection %q: %w", cp.From, err)
+	}
+	if err := os.Remove(checkpointPath(dbPath, name)); err != nil {
+		return cp, false, fmt.Errorf("couldn't remove checkpoint: %w", err)
+	}
+	return migrationCheckpoint{}, false, nil
+}
+
+func writeCheckpoint(dbPath string, cp *migrationCheckpoint) error {
+	cp.Updated = time.Now().UTC()
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't encode checkpoint: %w", err)
+	}
+	if err := writeFileAtomic(checkpointPath(dbPath, cp.Collection), append(data, '\n')); err != nil {
+		return fmt.Errorf("couldn't write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// userMetadata returns a copy of collection metadata without the embedder
+// keys.
+func userMetadata(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		switch k {
+		case metaEmbedderName, metaEmbedderVersion, metaEmbedderDimension, metaEmbedderNormalized, metaEmbedderFingerprint:
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// readDocuments returns all documents of a collection, sorted by ID.
+// chromem-go doesn't expose a way to list documents, but DB.ExportToWriter
+// writes a plain gob stream that we can decode.
+func readDocuments(db *chromem.DB, name string) ([]chromem.Document, error) {
+	var buf bytes.Buffer
+	if err := db.ExportToWriter(&buf, false, "", name); err != nil {
+		return nil, fmt.Errorf("couldn't export collection %q: %w", name, err)
+	}
+	persisted := struct {
+		Collections map[string]*struct {
+			Documents map[string]*chromem.Document
+		}
+	}{}
+	if err := gob.NewDecoder(&buf).Decode(&persisted); err != nil {
+		return nil, fmt.Errorf("couldn't decode collection %q: %w", name, err)
+	}
+	pc, ok := persisted.Collections[name]
+	if !ok {
+		return nil, fmt.Errorf("collection %q not found", name)
+	}
+
+	docs := make([]chromem.Document, 0, len(pc.Documents))
+	for _, doc := range pc.Documents {
+		docs = append(docs, *doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	return docs, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/philippgille/chromem-go"
+)
+
+var (
+	// ErrNoEmbedder is returned for collections that were created without an
+	// embedder identity, e.g. by plain chromem-go.
+	ErrNoEmbedder = errors.New("collection has no embedder identity")
+
+	// ErrUnknownEmbedder is returned when a collection's embedder isn't
+	// registered.
+	ErrUnknownEmbedder = errors.New("embedder not registered")
+
+	// ErrEmbedderMismatch is returned when the registered embedder doesn't
+	// match the identity stored with the collection.
+	ErrEmbedderMismatch = errors.New("embedder doesn't match collection")
+)
+
+// Registry maps embedder identities to embedding functions.
+type Registry struct {
+	mu           sync.RWMutex
+	embedders    map[string]Embedder
+	fingerprints map[string]string
+}
+
+// NewRegistry returns a registry with the given embedders.
+func NewRegistry(embedders ...Embedder) (*Registry, error) {
+	r := &Registry{embedders: make(map[string]Embedder), fingerprints: make(map[string]string)}
+	for _, e := range embedders {
+		if err := r.Register(e); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register adds an embedder. Registering two embedders with the same name and
+// version is an error: they would be indistinguishable on disk.
+func (r *Registry) Register(e Embedder) error {
+	if e.Name == "" || e.Version == "" || e.Dimension <= 0 || e.Embed == nil {
+		return fmt.Errorf("embedder %q needs a name, version, dimension and function", e.Key())
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.embedders[e.Key()]; ok {
+		return fmt.Errorf("embedder %s is already registered", e.Key())
+	}
+	r.embedders[e.Key()] = e
+	return nil
+}
+
+// Lookup returns the embedder registered as "name@version".
+func (r *Registry) Lookup(key string) (Embedder, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.embedders[key]
+	return e, ok
+}
+
+// Keys returns the keys of all registered embedders.
+func (r *Registry) Keys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]string, 0, len(r.embedders))
+	for k := range r.embedders {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Collection is a chromem-go collection bound to the embedder it was built
+// with. The chromem-go collection isn't exposed, so every document and query
+// goes through the validating methods.
+type Collection struct {
+	Name     string
+	Embedder Embedder
+
+	db          *chromem.DB
+	fingerprint string
+
+	// mu guards coll and metadata, which change once: when the first
+	// document records the collection's dimension and fingerprint.
+	mu       sync.Mutex
+	coll     *chromem.Collection
+	metadata map[string]string
+}
+
+// fingerprint returns the fingerprint of a registered embedder, computing it
+// once.
+func (r *Registry) fingerprint(ctx context.Context, e Embedder) (string, error) {
+	r.mu.RLock()
+	fp, ok := r.fingerprints[e.Key()]
+	r.mu.RUnlock()
+	if ok {
+		return fp, nil
+	}
+
+	fp, err := Fingerprint(ctx, e)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.fingerprints[e.Key()] = fp
+	r.mu.Unlock()
+	return fp, nil
+}
+
+// CreateCollection creates a collection that records the identity of the
+// embedder registered as key in its metadata. The dimension and the model's
+// fingerprint are recorded by the first document added. The embedder is
+// called on the probe texts first, so a model that doesn't produce its
+// declared dimension is caught before any document is stored.
+func (r *Registry) CreateCollection(ctx context.Context, db *chromem.DB, name string, metadata map[string]string, key string) (*Collection, error) {
+	e, ok := r.Lookup(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEmbedder, key)
+	}
+	if _, err := checkedEmbed(e, name)(ctx, fingerprintProbes[0]); err != nil {
+		return nil, err
+	}
+	fp, err := r.fingerprint(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata = e.metadata(metadata)
+	coll, err := db.CreateCollection(name, metadata, checkedEmbed(e, name))
+	if err != nil {
+		return nil, err
+	}
+	return &Collection{Name: name, Embedder: e, db: db, fingerprint: fp, coll: coll, metadata: metadata}, nil
+}
+
+// OpenCollection returns a collection of db bound to the embedder recorded in
+// its metadata. It refuses collections whose embedder isn't registered or
+// doesn't match the recorded identity, dimension or fingerprint.
+//
+// chromem-go binds an embedding function the first time a reloaded
+// collection is fetched and ignores it afterwards, so collections must be
+// opened through the registry before anything calls db.GetCollection.
+func (r *Registry) OpenCollection(ctx context.Context, db *chromem.DB, name string) (*Collection, error) {
+	metadata, err := collectionMetadata(db, name)
+	if err != nil {
+		return nil, err
+	}
+	id, ok, err := identityFromMetadata(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("collection %q: %w", name, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("collection %q: %w", name, ErrNoEmbedder)
+	}
+
+	e, ok := r.Lookup(id.Key())
+	if !ok {
+		return nil, fmt.Errorf("collection %q needs %s: %w (have %v)", name, id, ErrUnknownEmbedder, r.Keys())
+	}
+	if e.Key() != id.Key() || e.Normalized != id.Normalized || (id.Dimension != 0 && e.Dimension != id.Dimension) {
+		return nil, &ModelMismatchError{Collection: name, Want: id.String(), Got: "registered " + e.EmbedderIdentity.String()}
+	}
+	got, err := r.fingerprint(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	if want, ok := metadata[metaEmbedderFingerprint]; ok {
+		if got != want {
+			return nil, &ModelMismatchError{
+				Collection: name,
+				Want:       fmt.Sprintf("%s fingerprint %s", id.Key(), want),
+				Got:        fmt.Sprintf("registered %s fingerprint %s", e.Key(), got),
+			}
+		}
+	}
+
+	coll := db.GetCollection(name, checkedEmbed(e, name))
+	return &Collection{Name: name, Embedder: e, db: db, fingerprint: got, coll: coll, metadata: metadata}, nil
+}
+
+// current returns the chromem-go collection.
+func (c *Collection) current() *chromem.Collection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.coll
+}
+
+// Count returns the number of documents in the collection.
+func (c *Collection) Count() int {
+	return c.current().Count()
+}
+
+// GetByID returns the document with the given ID.
+func (c *Collection) GetByID(ctx context.Context, id string) (chromem.Document, error) {
+	return c.current().GetByID(ctx, id)
+}
+
+// Delete removes documents, as chromem.Collection.Delete does.
+func (c *Collection) Delete(ctx context.Context, where, whereDocument map[string]string, ids ...string) error {
+	return c.current().Delete(ctx, where, whereDocument, ids...)
+}
+
+// Query embeds text with the collection's embedder and searches for it. It
+// doesn't rely on the embedding function chromem-go has bound, so it works
+// even if the collection was fetched elsewhere first.
+func (c *Collection) Query(ctx context.Context, text string, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error) {
+	vec, err := checkedEmbed(c.Embedder, c.Name)(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return c.current().QueryEmbedding(ctx, vec, nResults, where, whereDocument)
+}
+
+// checkedEmbed wraps the embedder's function so that a model that silently
+// changed its output dimension is caught before any vector is stored or
+// compared.
+func checkedEmbed(e Embedder, collection string) chromem.EmbeddingFunc {
+	return func(ctx context.Context, text string) ([]float32, error) {
+		vec, err := e.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		if len(vec) != e.Dimension {
+			return nil, &DimensionMismatchError{Collection: collection, Want: e.Dimension, Got: len(vec)}
+		}
+		return vec, nil
+	}
+}
+
+// collectionMetadata returns the metadata of a collection. chromem-go keeps
+// it unexported, but DB.ExportToWriter writes it as part of a plain gob
+// stream.
+func collectionMetadata(db *chromem.DB, name string) (map[string]string, error) {
+	var buf bytes.Buffer
+	if err := db.ExportToWriter(&buf, false, "", name); err != nil {
+		return nil, fmt.Errorf("couldn't export collection %q: %w", name, err)
+	}
+	persisted := struct {
+		Collections map[string]*struct {
+			Name     string
+			Metadata map[string]string
+		}
+	}{}
+	if err := gob.NewDecoder(&buf).Decode(&persisted); err != nil {
+		return nil, fmt.Errorf("couldn't decode collection %q: %w", name, err)
+	}
+	pc, ok := persisted.Collections[name]
+	if !ok {
+		return nil, fmt.Errorf("collection %q not found", name)
+	}
+	return pc.Metadata, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"math"
+	"runtime"
+	"strconv"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// metaEmbedderFingerprint stores the fingerprint of the model a collection
+// was built with. It is recorded with the first document, so empty
+// collections and those created before fingerprints existed don't have it
+// and are only checked by identity.
+const metaEmbedderFingerprint = "embedder.fingerprint"
+
+// fingerprintProbes are embedded to fingerprint a model. They cover short and
+// long texts, so models that differ only in some cases still differ here.
+var fingerprintProbes = []string{
+	"searchless",
+	"The quick brown fox jumps over the lazy dog",
+	"Containers, clusters and databases: what runs where?",
+}
+
+// DimensionMismatchError is returned when a vector doesn't have the
+// collection's dimension.
+type DimensionMismatchError struct {
+	Collection string
+	DocumentID string // empty for queries
+	Want, Got  int
+}
+
+func (e *DimensionMismatchError) Error() string {
+	what := "query embedding"
+	if e.DocumentID != "" {
+		what = fmt.Sprintf("document %q", e.DocumentID)
+	}
+	return fmt.Sprintf("collection %q: %s has %d dimensions, collection has %d", e.Collection, what, e.Got, e.Want)
+}
+
+// Unwrap makes errors.Is(err, ErrEmbedderMismatch) work.
+func (e *DimensionMismatchError) Unwrap() error { return ErrEmbedderMismatch }
+
+// ModelMismatchError is returned when vectors from one model meet a
+// collection built with another, including a model that changed behind an
+// unchanged name and version.
+type ModelMismatchError struct {
+	Collection string
+	Want, Got  string
+}
+
+func (e *ModelMismatchError) Error() string {
+	return fmt.Sprintf("collection %q was built with %s, got %s", e.Collection, e.Want, e.Got)
+}
+
+// Unwrap makes errors.Is(err, ErrEmbedderMismatch) work.
+func (e *ModelMismatchError) Unwrap() error { return ErrEmbedderMismatch }
+
+// Fingerprint embeds a fixed set of probe texts and hashes the result. Two
+// models with the same fingerprint produce the same vectors for the probes;
+// values are rounded first, so harmless float noise between runs doesn't
+// change it.
+func Fingerprint(ctx context.Context, e Embedder) (string, error) {
+	h := sha256.New()
+	for _, probe := range fingerprintProbes {
+		vec, err := e.Embed(ctx, probe)
+		if err != nil {
+			return "", fmt.Errorf("couldn't fingerprint %s: %w", e.Key(), err)
+		}
+		binary.Write(h, binary.LittleEndian, uint32(len(vec)))
+		for _, v := range vec {
+			binary.Write(h, binary.LittleEndian, int32(math.Round(float64(v)*1e4)))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8]), nil
+}
+
+// AddDocuments adds documents to the collection. Documents without an
+// embedding are embedded with the collection's embedder; documents with one
+// must have the collection's dimension.
+func (c *Collection) AddDocuments(ctx context.Context, docs []chromem.Document, concurrency int) error {
+	for _, doc := range docs {
+		if len(doc.Embedding) > 0 && len(doc.Embedding) != c.Embedder.Dimension {
+			return &DimensionMismatchError{Collection: c.Name, DocumentID: doc.ID, Want: c.Embedder.Dimension, Got: len(doc.Embedding)}
+		}
+	}
+	coll, err := c.record()
+	if err != nil {
+		return err
+	}
+	return coll.AddDocuments(ctx, docs, concurrency)
+}
+
+// record stores the collection's dimension and fingerprint in its metadata
+// before the first document is added, and returns the chromem-go collection
+// to add it to. chromem-go can't change a collection's metadata, but an
+// empty collection can be created again with more.
+func (c *Collection) record() (*chromem.Collection, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.metadata[metaEmbedderFingerprint]; ok || c.coll.Count() > 0 {
+		return c.coll, nil
+	}
+	metadata := maps.Clone(c.metadata)
+	metadata[metaEmbedderDimension] = strconv.Itoa(c.Embedder.Dimension)
+	metadata[metaEmbedderFingerprint] = c.fingerprint
+	coll, err := c.db.CreateCollection(c.Name, metadata, checkedEmbed(c.Embedder, c.Name))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't record the dimension of collection %q: %w", c.Name, err)
+	}
+	c.coll, c.metadata = coll, metadata
+	return coll, nil
+}
+
+// AddEmbeddedDocuments adds documents whose embeddings were computed elsewhere
+// by the model identified by from. It refuses vectors from any model other
+// than the collection's.
+func (c *Collection) AddEmbeddedDocuments(ctx context.Context, from EmbedderIdentity, docs []chromem.Document, concurrency int) error {
+	if from != c.Embedder.EmbedderIdentity {
+		return &ModelMismatchError{Collection: c.Name, Want: c.Embedder.EmbedderIdentity.String(), Got: from.String()}
+	}
+	for _, doc := range docs {
+		if len(doc.Embedding) == 0 {
+			return fmt.Errorf("document %q has no embedding", doc.ID)
+		}
+	}
+	return c.AddDocuments(ctx, docs, concurrency)
+}
+
+// QueryEmbedding searches for a vector, which must have the collection's
+// dimension.
+func (c *Collection) QueryEmbedding(ctx context.Context, vec []float32, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error) {
+	if len(vec) != c.Embedder.Dimension {
+		return nil, &DimensionMismatchError{Collection: c.Name, Want: c.Embedder.Dimension, Got: len(vec)}
+	}
+	return c.current().QueryEmbedding(ctx, vec, nResults, where, whereDocument)
+}
+
+// embedAll embeds the content of every document of collection with e, using
+// up to concurrency goroutines.
+func embedAll(ctx context.Context, e Embedder, collection string, docs []chromem.Document, concurrency int) error {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	embed := checkedEmbed(e, collection)
+	sem := make(chan struct{}, concurrency)
+	errs := make(chan error, len(docs))
+	for i := range docs {
+		sem <- struct{}{}
+		go func(doc *chromem.Document) {
+			defer func() { <-sem }()
+			vec, err := embed(ctx, doc.Content)
+			if err != nil {
+				errs <- fmt.Errorf("couldn't embed document %q: %w", doc.ID, err)
+				return
+			}
+			doc.Embedding = vec
+		}(&docs[i])
+	}
+	for i := 0; i < cap(sem); i++ {
+		sem <- struct{}{}
+	}
+	close(errs)
+	return <-errs
+}
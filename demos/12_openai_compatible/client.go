@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// ClientConfig configures a client for an OpenAI-compatible embeddings API:
+// OpenAI itself, LocalAI, vLLM, llama.cpp's server, Ollama's /v1 and others.
+type ClientConfig struct {
+	// BaseURL is the API root, e.g. "http://localhost:8080/v1". The client
+	// posts to BaseURL + "/embeddings".
+	BaseURL string
+	// APIKey is sent as a bearer token if set. Local servers usually don't
+	// need one.
+	APIKey string
+	// Model is the model name the server expects.
+	Model string
+	// Dimension, if set, is the dimension every returned vector must have.
+	Dimension int
+
+	// BatchSize is the maximum number of texts per request. Servers limit
+	// it; OpenAI allows 2048, many local servers far fewer.
+	BatchSize int
+	// MaxRetries is the number of retries after a failed request; zero means
+	// 3 and a negative value disables retries. Only network errors, timeouts,
+	// 408, 429 and 5xx responses are retried.
+	MaxRetries int
+	// Timeout limits each request, including reading the response.
+	Timeout time.Duration
+	// RequestsPerSecond limits the request rate. Zero means no limit.
+	RequestsPerSecond float64
+
+	// HTTPClient defaults to a client without a timeout of its own; Timeout
+	// applies per request instead.
+	HTTPClient *http.Client
+}
+
+// Client embeds texts with an OpenAI-compatible embeddings API.
+type Client struct {
+	cfg     ClientConfig
+	url     string
+	limiter *limiter
+
+	mu    sync.Mutex
+	stats ClientStats
+}
+
+// ClientStats counts what a client did.
+type ClientStats struct {
+	Texts    int // texts embedded
+	Requests int // HTTP requests sent, including retries
+	Retries  int
+	Tokens   int // as reported by the server
+}
+
+// APIError is a non-2xx response from the server.
+type APIError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // from the Retry-After header, if any
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("embeddings API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// retryable reports whether the request may succeed if sent again.
+func (e *APIError) retryable() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// NewClient returns a client for cfg, filling in defaults: 64 texts per
+// batch, 3 retries and a 30 second timeout.
+func NewClient(cfg ClientConfig) (*Client, error) {
+	if cfg.BaseURL == "" || cfg.Model == "" {
+		return nil, errors.New("base URL and model are required")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 64
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+	return &Client{
+		cfg:     cfg,
+		url:     strings.TrimSuffix(cfg.BaseURL, "/") + "/embeddings",
+		limiter: newLimiter(cfg.RequestsPerSecond),
+	}, nil
+}
+
+// Stats returns what the client did so far.
+func (c *Client) Stats() ClientStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// EmbeddingFunc returns a chromem.EmbeddingFunc that embeds one text per call.
+// Use it for queries; for documents, Embed and EmbedDocuments batch.
+func (c *Client) EmbeddingFunc() chromem.EmbeddingFunc {
+	return func(ctx context.Context, text string) ([]float32, error) {
+		vecs, err := c.Embed(ctx, []string{text})
+		if err != nil {
+			return nil, err
+		}
+		return vecs[0], nil
+	}
+}
+
+// EmbedDocuments sets the embedding of every document that doesn't have one,
+// in batches. The documents can then be added to a collection without
+// chromem-go calling the API once per document.
+func (c *Client) EmbedDocuments(ctx context.Context, docs []chromem.Document) error {
+	var texts []string
+	var idx []int
+	for i, doc := range docs {
+		if len(doc.Embedding) == 0 {
+			texts = append(texts, doc.Content)
+			idx = append(idx, i)
+		}
+	}
+	vecs, err := c.Embed(ctx, texts)
+	if err != nil {
+		return err
+	}
+	for i, vec := range vecs {
+		docs[idx[i]].Embedding = vec
+	}
+	return nil
+}
+
+// Embed returns the embeddings of texts, in order. Texts are sent in batches
+// of at most BatchSize, one batch at a time.
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vecs := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += c.cfg.BatchSize {
+		batch := texts[start:min(start+c.cfg.BatchSize, len(texts))]
+		batchVecs, err := c.embedBatch(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't embed texts %d-%d: %w", start, start+len(batch)-1, err)
+		}
+		vecs = append(vecs, batchVecs...)
+	}
+	return vecs, nil
+}
+
+// embedBatch sends one batch, retrying with exponential backoff.
+func (c *Client) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingRequest{Model: c.cfg.Model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't encode request: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		vecs, err := c.post(ctx, body, len(texts))
+		if err == nil {
+			c.mu.Lock()
+			c.stats.Texts += len(texts)
+			c.mu.Unlock()
+			return vecs, nil
+		}
+
+		var apiErr *APIError
+		switch {
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		case errors.As(err, &apiErr) && !apiErr.retryable():
+			return nil, err
+		case errors.Is(err, errInvalidResponse):
+			return nil, err
+		case attempt == c.cfg.MaxRetries:
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+		}
+
+		delay := backoff(attempt)
+		if apiErr != nil && apiErr.RetryAfter > 0 {
+			// A server that wants a break longer than a request may take
+			// won't be ready within this call either.
+			if apiErr.RetryAfter > c.cfg.Timeout {
+				return nil, fmt.Errorf("giving up, server asked to retry after %v: %w", apiErr.RetryAfter, err)
+			}
+			delay = apiErr.RetryAfter
+		}
+		c.mu.Lock()
+		c.stats.Retries++
+		c.mu.Unlock()
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// errInvalidResponse marks responses that won't get better by retrying.
+var errInvalidResponse = errors.New("invalid embeddings response")
+
+// post sends one request with the configured timeout.
+func (c *Client) post(ctx context.Context, body []byte, n int) ([][]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+
+	c.mu.Lock()
+	c.stats.Requests++
+	c.mu.Unlock()
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, newAPIError(resp, data)
+	}
+
+	var parsed embeddingResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidResponse, err)
+	}
+	if len(parsed.Data) != n {
+		return nil, fmt.Errorf("%w: got %d embeddings for %d texts", errInvalidResponse, len(parsed.Data), n)
+	}
+	// The spec doesn't promise that data is in input order, only that each
+	// item carries the index of its input.
+	vecs := make([][]float32, n)
+	for _, d := range parsed.Data {
+		if d.Index < 0 || d.Index >= n || vecs[d.Index] != nil {
+			return nil, fmt.Errorf("%w: unexpected index %d", errInvalidResponse, d.Index)
+		}
+		if len(d.Embedding) == 0 || (c.cfg.Dimension > 0 && len(d.Embedding) != c.cfg.Dimension) {
+			return nil, fmt.Errorf("%w: embedding %d has %d dimensions, want %d", errInvalidResponse, d.Index, len(d.Embedding), c.cfg.Dimension)
+		}
+		vecs[d.Index] = d.Embedding
+	}
+
+	c.mu.Lock()
+	c.stats.Tokens += parsed.Usage.TotalTokens
+	c.mu.Unlock()
+	return vecs, nil
+}
+
+// newAPIError builds an APIError from a response, using the OpenAI error
+// message if the body has one.
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	var parsed struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &parsed) == nil && parsed.Error.Message != "" {
+		apiErr.Message = parsed.Error.Message
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+		apiErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return apiErr
+}
+
+// backoff returns the delay before retry attempt+1: 100ms doubling up to 5s,
+// with up to 50% jitter so clients that failed together don't retry together.
+// The shift is clamped, since 100ms<<37 overflows to a negative delay.
+func backoff(attempt int) time.Duration {
+	d := min(100*time.Millisecond<<min(attempt, 6), 5*time.Second)
+	return d/2 + rand.N(d/2+1)
+}
+
+// limiter spaces requests evenly at a fixed rate.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newLimiter(perSecond float64) *limiter {
+	if perSecond <= 0 {
+		return &limiter{}
+	}
+	return &limiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the next request may be sent.
+func (l *limiter) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// embeddingRequest and embeddingResponse are the subset of the OpenAI
+// embeddings API that compatible servers implement.
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []embeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  embeddingUsage  `json:"usage"`
+}
+
+type embeddingData struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float32 `json:"embedding"`
+}
+
+type embeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// FakeServerConfig configures a FakeServer.
+type FakeServerConfig struct {
+	// Dimension of the returned vectors. Defaults to 256.
+	Dimension int
+	// MaxBatch is the largest number of inputs per request. Larger requests
+	// get a 400, like from a real server. Defaults to 2048.
+	MaxBatch int
+	// APIKey, if set, must be sent as a bearer token.
+	APIKey string
+}
+
+// Fault makes the fake server misbehave for one request.
+type Fault struct {
+	Status     int           // respond with this status instead of embeddings
+	RetryAfter int           // Retry-After header in seconds, with Status
+	Delay      time.Duration // wait this long before responding
+}
+
+// FakeServer is an OpenAI-compatible embeddings server for tests and offline
+// runs. Its vectors are deterministic: each word of the input maps to a fixed
+// pseudo-random vector, seeded by the model name and the word, and a text's
+// vector is the normalized sum of its words' vectors. Texts that share words
+// are similar, and the same text always gets the same vector.
+type FakeServer struct {
+	cfg FakeServerConfig
+
+	mu      sync.Mutex
+	faults  []Fault
+	batches []int
+
+	srv *httptest.Server
+}
+
+// NewFakeServer returns an unstarted fake server. Start it with Start, or
+// serve its Handler yourself.
+func NewFakeServer(cfg FakeServerConfig) *FakeServer {
+	if cfg.Dimension <= 0 {
+		cfg.Dimension = 256
+	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 2048
+	}
+	return &FakeServer{cfg: cfg}
+}
+
+// Start serves on a random local port and returns the base URL to configure
+// a client with.
+func (s *FakeServer) Start() string {
+	s.srv = httptest.NewServer(s.Handler())
+	return s.srv.URL + "/v1"
+}
+
+// Close stops a started server.
+func (s *FakeServer) Close() {
+	s.srv.Close()
+}
+
+// Fail queues faults; each of the next requests consumes one.
+func (s *FakeServer) Fail(faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, faults...)
+}
+
+// Batches returns the number of inputs of every successful request so far.
+func (s *FakeServer) Batches() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.batches...)
+}
+
+// Handler returns the server's HTTP handler, which serves POST /v1/embeddings.
+func (s *FakeServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/embeddings", s.embeddings)
+	return mux
+}
+
+func (s *FakeServer) embeddings(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var fault Fault
+	if len(s.faults) > 0 {
+		fault, s.faults = s.faults[0], s.faults[1:]
+	}
+	s.mu.Unlock()
+
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fault.Status != 0 {
+		if fault.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+		}
+		writeAPIError(w, fault.Status, "injected fault")
+		return
+	}
+	if s.cfg.APIKey != "" && r.Header.Get("Authorization") != "Bearer "+s.cfg.APIKey {
+		writeAPIError(w, http.StatusUnauthorized, "invalid API key")
+		return
+	}
+
+	var req struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	inputs, err := parseInput(req.Input)
+	switch {
+	case err != nil:
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	case req.Model == "":
+		writeAPIError(w, http.StatusBadRequest, "model is required")
+		return
+	case len(inputs) > s.cfg.MaxBatch:
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("%d inputs, at most %d allowed", len(inputs), s.cfg.MaxBatch))
+		return
+	}
+
+	resp := embeddingResponse{Object: "list", Model: req.Model}
+	for i, input := range inputs {
+		words := words(input)
+		resp.Data = append(resp.Data, embeddingData{Object: "embedding", Index: i, Embedding: s.vector(req.Model, words)})
+		resp.Usage.PromptTokens += len(words)
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens
+
+	s.mu.Lock()
+	s.batches = append(s.batches, len(inputs))
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseInput accepts a string or an array of strings, like the OpenAI API.
+func parseInput(raw json.RawMessage) ([]string, error) {
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		raw, _ = json.Marshal([]string{one})
+	}
+	var inputs []string
+	if err := json.Unmarshal(raw, &inputs); err != nil {
+		return nil, fmt.Errorf("input must be a string or an array of strings")
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("input is empty")
+	}
+	for i, input := range inputs {
+		if len(words(input)) == 0 {
+			return nil, fmt.Errorf("input %d has no words", i)
+		}
+	}
+	return inputs, nil
+}
+
+// vector returns the normalized sum of the words' vectors.
+func (s *FakeServer) vector(model string, words []string) []float32 {
+	sum := make([]float64, s.cfg.Dimension)
+	for _, word := range words {
+		seed := sha256.Sum256([]byte(model + "\x00" + word))
+		rng := rand.New(rand.NewPCG(binary.LittleEndian.Uint64(seed[:8]), binary.LittleEndian.Uint64(seed[8:16])))
+		for i := range sum {
+			sum[i] += rng.NormFloat64()
+		}
+	}
+
+	var norm float64
+	for _, v := range sum {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	vec := make([]float32, len(sum))
+	for i, v := range sum {
+		if norm > 0 {
+			vec[i] = float32(v / norm)
+		}
+	}
+	return vec
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]string{"message": message, "type": http.StatusText(status)},
+	})
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string) error{
+			"serve": runServe,
+			"query": runQuery,
+		}
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Println("🔌 OpenAI-Compatible Embeddings Demo - Any /v1/embeddings, Tested Offline")
+	fmt.Println("=========================================================================")
+
+	ctx := context.Background()
+
+	// 1. A stand-in for LocalAI, vLLM or llama.cpp's server
+	fmt.Println("\n🧪 1. Starting the fake embeddings server...")
+	server := NewFakeServer(FakeServerConfig{Dimension: 256, MaxBatch: 16, APIKey: "sk-local"})
+	baseURL := server.Start()
+	defer server.Close()
+	fmt.Printf("   Serving %s/embeddings (256 dims, at most 16 inputs per request)\n", baseURL)
+
+	cfg := ClientConfig{
+		BaseURL:           baseURL,
+		APIKey:            "sk-local",
+		Model:             "fake-embed-v1",
+		Dimension:         256,
+		BatchSize:         16,
+		Timeout:           2 * time.Second,
+		RequestsPerSecond: 20,
+	}
+	client, err := NewClient(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	// 2. Batching and rate limiting
+	fmt.Println("\n📦 2. Embedding 40 documents in batches...")
+	docs := append(knowledgeBase(), incidents(35)...)
+	start := time.Now()
+	if err := client.EmbedDocuments(ctx, docs); err != nil {
+		panic(err)
+	}
+	stats := client.Stats()
+	fmt.Printf("   %d texts in %d requests, batches of %v\n", stats.Texts, stats.Requests, server.Batches())
+	fmt.Printf("   Took %v at 20 requests/s - the limiter spaces requests 50ms apart\n", time.Since(start).Round(10*time.Millisecond))
+
+	// 3. Query through chromem-go
+	fmt.Println("\n🔍 3. Querying...")
+	db := chromem.NewDB()
+	coll, err := db.CreateCollection("knowledge-base", nil, client.EmbeddingFunc())
+	if err != nil {
+		panic(err)
+	}
+	if err := coll.AddDocuments(ctx, docs, 4); err != nil {
+		panic(err)
+	}
+	for _, query := range []string{"kubernetes clusters", "database sharding", "latency of the payments service"} {
+		results, err := coll.Query(ctx, query, 1, nil, nil)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("   %q → [%s] %.4f  %s\n", query, results[0].ID, results[0].Similarity, results[0].Content)
+	}
+
+	// 4. Failures worth retrying
+	fmt.Println("\n🔁 4. Retrying transient failures...")
+	server.Fail(Fault{Status: http.StatusTooManyRequests}, Fault{Status: http.StatusServiceUnavailable})
+	before := client.Stats()
+	if _, err := client.Embed(ctx, []string{"retry me"}); err != nil {
+		panic(err)
+	}
+	after := client.Stats()
+	fmt.Printf("   429, then 503, then success: %d requests, %d retries\n", after.Requests-before.Requests, after.Retries-before.Retries)
+
+	// 5. Failures not worth retrying
+	fmt.Println("\n🚫 5. Failing fast...")
+	badKey := cfg
+	badKey.APIKey = "sk-wrong"
+	tryEmbed(ctx, badKey, "wrong API key")
+
+	tooBig := cfg
+	tooBig.BatchSize = 32
+	tryEmbed(ctx, tooBig, "batch larger than the server allows")
+
+	wrongDim := cfg
+	wrongDim.Dimension = 384
+	tryEmbed(ctx, wrongDim, "server returns a different dimension")
+
+	// 6. Timeouts
+	fmt.Println("\n⏱️  6. Timing out a hanging server...")
+	server.Fail(Fault{Delay: time.Second}, Fault{Delay: time.Second})
+	slow := cfg
+	slow.Timeout = 100 * time.Millisecond
+	slow.MaxRetries = 1
+	tryEmbed(ctx, slow, "two requests that hang for 1s, 100ms timeout")
+
+	fmt.Println("\n🎯 Key Benefits:")
+	fmt.Println("   ✅ One client for OpenAI, LocalAI, vLLM, llama.cpp and Ollama's /v1")
+	fmt.Println("   ✅ Documents are embedded in batches, not one request each")
+	fmt.Println("   ✅ Transient failures are retried, permanent ones fail fast")
+	fmt.Println("   ✅ The whole pipeline runs offline against a deterministic fake server")
+	fmt.Println("\n💡 Try: go run . serve -addr :8080, then go run . query -url http://localhost:8080/v1 kubernetes")
+}
+
+// tryEmbed embeds a text with a client for cfg, expecting it to fail.
+func tryEmbed(ctx context.Context, cfg ClientConfig, scenario string) {
+	client, err := NewClient(cfg)
+	if err != nil {
+		panic(err)
+	}
+	var texts []string
+	for i := range cfg.BatchSize {
+		texts = append(texts, fmt.Sprintf("text %d", i))
+	}
+	start := time.Now()
+	_, err = client.Embed(ctx, texts)
+	if err == nil {
+		panic("expected " + scenario + " to fail")
+	}
+	stats := client.Stats()
+	fmt.Printf("   ❌ %s: %d request(s), %v\n      %v\n", scenario, stats.Requests, time.Since(start).Round(10*time.Millisecond), err)
+}
+
+// runServe implements `go run . serve`.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	dim := fs.Int("dim", 256, "dimension of the returned vectors")
+	maxBatch := fs.Int("max-batch", 2048, "maximum number of inputs per request")
+	key := fs.String("key", "", "API key clients must send, if any")
+	fs.Parse(args)
+
+	server := NewFakeServer(FakeServerConfig{Dimension: *dim, MaxBatch: *maxBatch, APIKey: *key})
+	fmt.Printf("🧪 Fake embeddings server on http://%s/v1/embeddings (%d dims)\n", *addr, *dim)
+	return http.ListenAndServe(*addr, server.Handler())
+}
+
+// runQuery implements `go run . query`: it indexes the demo documents with
+// any OpenAI-compatible server and runs one query.
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	url := fs.String("url", "http://localhost:8080/v1", "base URL of the API")
+	model := fs.String("model", "fake-embed-v1", "embedding model")
+	key := fs.String("key", os.Getenv("OPENAI_API_KEY"), "API key, defaults to $OPENAI_API_KEY")
+	batch := fs.Int("batch", 64, "maximum number of texts per request")
+	rps := fs.Float64("rps", 0, "maximum requests per second, 0 for no limit")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout per request")
+	n := fs.Int("n", 3, "number of results")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: query [flags] <text>")
+	}
+
+	client, err := NewClient(ClientConfig{
+		BaseURL:           *url,
+		APIKey:            *key,
+		Model:             *model,
+		BatchSize:         *batch,
+		Timeout:           *timeout,
+		RequestsPerSecond: *rps,
+	})
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	docs := knowledgeBase()
+	if err := client.EmbedDocuments(ctx, docs); err != nil {
+		return err
+	}
+	coll, err := chromem.NewDB().CreateCollection("knowledge-base", nil, client.EmbeddingFunc())
+	if err != nil {
+		return err
+	}
+	if err := coll.AddDocuments(ctx, docs, 1); err != nil {
+		return err
+	}
+	results, err := coll.Query(ctx, strings.Join(fs.Args(), " "), min(*n, coll.Count()), nil, nil)
+	if err != nil {
+		return err
+	}
+	for i, result := range results {
+		fmt.Printf("%d. [%s] %.4f  %s\n", i+1, result.ID, result.Similarity, result.Content)
+	}
+	stats := client.Stats()
+	fmt.Printf("🔌 %d requests, %d retries, %d tokens\n", stats.Requests, stats.Retries, stats.Tokens)
+	return nil
+}
+
+func knowledgeBase() []chromem.Document {
+	return []chromem.Document{
+		{
+			ID:       "doc-001",
+			Content:  "Docker containers provide lightweight, portable application packaging",
+			Metadata: map[string]string{"category": "containerization", "difficulty": "beginner"},
+		},
+		{
+			ID:       "doc-002",
+			Content:  "Kubernetes orchestrates containers across clusters with automated scaling",
+			Metadata: map[string]string{"category": "orchestration", "difficulty": "advanced"},
+		},
+		{
+			ID:       "doc-003",
+			Content:  "Microservice architecture breaks applications into independent, deployable services",
+			Metadata: map[string]string{"category": "architecture", "difficulty": "intermediate"},
+		},
+		{
+			ID:       "doc-004",
+			Content:  "REST APIs enable communication between services using HTTP protocols",
+			Metadata: map[string]string{"category": "api", "difficulty": "beginner"},
+		},
+		{
+			ID:       "doc-005",
+			Content:  "Database sharding distributes data across multiple database instances",
+			Metadata: map[string]string{"category": "database", "difficulty": "advanced"},
+		},
+	}
+}
+
+// incidents returns n generated incident reports, so there is more to embed
+// than one batch.
+func incidents(n int) []chromem.Document {
+	services := []string{"payments", "search", "auth", "billing", "checkout"}
+	causes := []string{
+		"returned 5xx errors after a deploy",
+		"latency spiked during peak traffic",
+		"ran out of disk space on its primary volume",
+		"failed health checks after a certificate expired",
+	}
+	docs := make([]chromem.Document, n)
+	for i := range docs {
+		service := services[i%len(services)]
+		docs[i] = chromem.Document{
+			ID:       fmt.Sprintf("inc-%03d", i+1),
+			Content:  fmt.Sprintf("Incident %d: the %s service %s", i+1, service, causes[i%len(causes)]),
+			Metadata: map[string]string{"category": "incident", "service": service},
+		}
+	}
+	return docs
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// BertConfig is the part of a Hugging Face config.json a BERT encoder needs.
+type BertConfig struct {
+	VocabSize        int     `json:"vocab_size"`
+	HiddenSize       int     `json:"hidden_size"`
+	NumLayers        int     `json:"num_hidden_layers"`
+	NumHeads         int     `json:"num_attention_heads"`
+	IntermediateSize int     `json:"intermediate_size"`
+	MaxPositions     int     `json:"max_position_embeddings"`
+	TypeVocabSize    int     `json:"type_vocab_size"`
+	LayerNormEps     float64 `json:"layer_norm_eps"`
+	HiddenAct        string  `json:"hidden_act"`
+}
+
+// Bert is a BERT encoder that turns text into sentence embeddings by mean
+// pooling its last hidden states, the way sentence-transformers models such
+// as all-MiniLM-L6-v2 are used. It runs on the CPU in plain Go and is safe
+// for concurrent use.
+type Bert struct {
+	Config    BertConfig
+	Tokenizer *WordPiece
+
+	maxLen int // tokens per text, including [CLS] and [SEP]
+
+	wordEmb, posEmb, typeEmb []float32
+	embNorm                  layerNorm
+	layers                   []bertLayer
+}
+
+type bertLayer struct {
+	query, key, value, attnOut linear
+	attnNorm                   layerNorm
+	up, down                   linear
+	outNorm                    layerNorm
+}
+
+// linear is a dense layer with PyTorch's weight layout: out rows of in
+// values each.
+type linear struct {
+	w       []float32
+	b       []float32
+	in, out int
+}
+
+type layerNorm struct {
+	w, b []float32
+	eps  float32
+}
+
+// LoadBert loads a model from path, which is either a directory in Hugging
+// Face layout (config.json, vocab.txt and model.safetensors) or a GGUF file
+// converted by llama.cpp, which carries config and vocabulary itself.
+func LoadBert(path string) (*Bert, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".gguf") {
+		return loadBertGGUF(path)
+	}
+	return loadBertDir(path)
+}
+
+func loadBertDir(dir string) (*Bert, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read model config: %w", err)
+	}
+	cfg := BertConfig{TypeVocabSize: 2, LayerNormEps: 1e-12, HiddenAct: "gelu"}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("couldn't decode model config: %w", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "vocab.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read vocabulary: %w", err)
+	}
+	tokens, err := readVocab(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	var tokCfg struct {
+		Lower *bool `json:"do_lower_case"`
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "tokenizer_config.json")); err == nil {
+		json.Unmarshal(data, &tokCfg)
+	}
+	tokenizer, err := NewWordPiece(tokens, tokCfg.Lower == nil || *tokCfg.Lower)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := openSafetensors(filepath.Join(dir, "model.safetensors"))
+	if err != nil {
+		return nil, err
+	}
+	// BertModel checkpoints name tensors "embeddings...", checkpoints saved
+	// from a task model prefix them with "bert."
+	var src tensorSource = st
+	if _, ok := st.tensors["bert.embeddings.word_embeddings.weight"]; ok {
+		src = prefixed{st, "bert."}
+	}
+
+	maxLen := cfg.MaxPositions
+	var stCfg struct {
+		MaxSeqLength int `json:"max_seq_length"`
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "sentence_bert_config.json")); err == nil {
+		if json.Unmarshal(data, &stCfg) == nil && stCfg.MaxSeqLength > 0 {
+			maxLen = min(maxLen, stCfg.MaxSeqLength)
+		}
+	}
+	return newBert(cfg, tokenizer, maxLen, src)
+}
+
+func loadBertGGUF(path string) (*Bert, error) {
+	g, err := openGGUF(path)
+	if err != nil {
+		return nil, err
+	}
+	if arch, _ := g.metadata["general.architecture"].(string); arch != "bert" {
+		return nil, fmt.Errorf("%s holds a %q model, not bert", path, arch)
+	}
+
+	var cfg BertConfig
+	for key, dst := range map[string]*int{
+		"bert.embedding_length":     &cfg.HiddenSize,
+		"bert.block_count":          &cfg.NumLayers,
+		"bert.attention.head_count": &cfg.NumHeads,
+		"bert.feed_forward_length":  &cfg.IntermediateSize,
+		"bert.context_length":       &cfg.MaxPositions,
+	} {
+		v, ok := g.metaUint(key)
+		if !ok {
+			return nil, fmt.Errorf("%s has no %s", path, key)
+		}
+		*dst = v
+	}
+	cfg.LayerNormEps = 1e-12
+	if eps, ok := g.metadata["bert.attention.layer_norm_epsilon"].(float32); ok {
+		cfg.LayerNormEps = float64(eps)
+	}
+	cfg.HiddenAct = "gelu"
+	if t, ok := g.tensors["token_types.weight"]; ok && len(t.dims) == 2 {
+		cfg.TypeVocabSize = int(t.dims[1])
+	}
+
+	list, _ := g.metadata["tokenizer.ggml.tokens"].([]any)
+	tokens := make([]string, len(list))
+	for i, v := range list {
+		tok, _ := v.(string)
+		// llama.cpp marks word starts with "▁" instead of marking
+		// continuations with "##"; turn that back into WordPiece
+		switch {
+		case strings.HasPrefix(tok, "▁"):
+			tok = strings.TrimPrefix(tok, "▁")
+		case strings.HasPrefix(tok, "[") && strings.HasSuffix(tok, "]"):
+		default:
+			tok = "##" + tok
+		}
+		tokens[i] = tok
+	}
+	cfg.VocabSize = len(tokens)
+	tokenizer, err := NewWordPiece(tokens, true)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return newBert(cfg, tokenizer, cfg.MaxPositions, ggufBert{g})
+}
+
+// prefixed reads tensors whose names start with a prefix.
+type prefixed struct {
+	src    tensorSource
+	prefix string
+}
+
+func (p prefixed) tensor(name string) ([]float32, []int, error) {
+	return p.src.tensor(p.prefix + name)
+}
+
+// ggufBert reads tensors by their Hugging Face names from a GGUF file, which
+// uses llama.cpp's names.
+type ggufBert struct{ g *gguf }
+
+func (s ggufBert) tensor(name string) ([]float32, []int, error) {
+	ggufName, ok := strings.CutPrefix(name, "encoder.layer.")
+	if ok {
+		n, rest, _ := strings.Cut(ggufName, ".")
+		for hf, gg := range map[string]string{
+			"attention.self.query.":       "attn_q.",
+			"attention.self.key.":         "attn_k.",
+			"attention.self.value.":       "attn_v.",
+			"attention.output.dense.":     "attn_output.",
+			"attention.output.LayerNorm.": "attn_output_norm.",
+			"intermediate.dense.":         "ffn_up.",
+			"output.dense.":               "ffn_down.",
+			"output.LayerNorm.":           "layer_output_norm.",
+		} {
+			if param, ok := strings.CutPrefix(rest, hf); ok {
+				return s.g.tensor("blk." + n + "." + gg + param)
+			}
+		}
+		return nil, nil, fmt.Errorf("no GGUF name for tensor %s", name)
+	}
+	ggufName = strings.NewReplacer(
+		"embeddings.word_embeddings.", "token_embd.",
+		"embeddings.position_embeddings.", "position_embd.",
+		"embeddings.token_type_embeddings.", "token_types.",
+		"embeddings.LayerNorm.", "token_embd_norm.",
+	).Replace(name)
+	return s.g.tensor(ggufName)
+}
+
+// newBert reads every weight from src and checks its shape against cfg.
+func newBert(cfg BertConfig, tokenizer *WordPiece, maxLen int, src tensorSource) (*Bert, error) {
+	if cfg.HiddenSize <= 0 || cfg.NumHeads <= 0 || cfg.HiddenSize%cfg.NumHeads != 0 {
+		return nil, fmt.Errorf("hidden size %d isn't divisible into %d heads", cfg.HiddenSize, cfg.NumHeads)
+	}
+	if cfg.HiddenAct != "gelu" && cfg.HiddenAct != "gelu_new" {
+		return nil, fmt.Errorf("unsupported activation %q", cfg.HiddenAct)
+	}
+	if cfg.VocabSize != len(tokenizer.tokens) {
+		return nil, fmt.Errorf("config has %d tokens, vocabulary has %d", cfg.VocabSize, len(tokenizer.tokens))
+	}
+
+	var err error
+	load := func(name string, shape ...int) []float32 {
+		if err != nil {
+			return nil
+		}
+		var values []float32
+		var got []int
+		values, got, err = src.tensor(name)
+		if err == nil && !equalShapes(got, shape) {
+			err = fmt.Errorf("tensor %s has shape %v, want %v", name, got, shape)
+		}
+		return values
+	}
+	h, eps := cfg.HiddenSize, float32(cfg.LayerNormEps)
+	dense := func(prefix string, in, out int) linear {
+		return linear{w: load(prefix+".weight", out, in), b: load(prefix+".bias", out), in: in, out: out}
+	}
+	norm := func(prefix string) layerNorm {
+		return layerNorm{w: load(prefix+".weight", h), b: load(prefix+".bias", h), eps: eps}
+	}
+
+	m := &Bert{
+		Config:    cfg,
+		Tokenizer: tokenizer,
+		maxLen:    min(maxLen, cfg.MaxPositions),
+		wordEmb:   load("embeddings.word_embeddings.weight", cfg.VocabSize, h),
+		posEmb:    load("embeddings.position_embeddings.weight", cfg.MaxPositions, h),
+		typeEmb:   load("embeddings.token_type_embeddings.weight", cfg.TypeVocabSize, h),
+		embNorm:   norm("embeddings.LayerNorm"),
+	}
+	for i := range cfg.NumLayers {
+		p := fmt.Sprintf("encoder.layer.%d.", i)
+		m.layers = append(m.layers, bertLayer{
+			query:    dense(p+"attention.self.query", h, h),
+			key:      dense(p+"attention.self.key", h, h),
+			value:    dense(p+"attention.self.value", h, h),
+			attnOut:  dense(p+"attention.output.dense", h, h),
+			attnNorm: norm(p + "attention.output.LayerNorm"),
+			up:       dense(p+"intermediate.dense", h, cfg.IntermediateSize),
+			down:     dense(p+"output.dense", cfg.IntermediateSize, h),
+			outNorm:  norm(p + "output.LayerNorm"),
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load weights: %w", err)
+	}
+	return m, nil
+}
+
+func equalShapes(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Embed returns the normalized mean of the last hidden states of text.
+func (m *Bert) Embed(ctx context.Context, text string) ([]float32, error) {
+	ids := m.Tokenizer.Encode(text, m.maxLen)
+	h := m.Config.HiddenSize
+	n := len(ids)
+
+	// Embeddings: token + position + segment 0, then LayerNorm
+	x := make([]float32, n*h)
+	for i, id := range ids {
+		row := x[i*h : (i+1)*h]
+		for j := range row {
+			row[j] = m.wordEmb[id*h+j] + m.posEmb[i*h+j] + m.typeEmb[j]
+		}
+		m.embNorm.apply(row)
+	}
+
+	for _, layer := range m.layers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		layer.forward(x, n, m.Config)
+	}
+
+	// Mean pooling over all tokens; a single text has no padding to mask
+	vec := make([]float32, h)
+	for i := range n {
+		for j, v := range x[i*h : (i+1)*h] {
+			vec[j] += v
+		}
+	}
+	var norm float64
+	for j := range vec {
+		vec[j] /= float32(n)
+		norm += float64(vec[j]) * float64(vec[j])
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return nil, fmt.Errorf("text %q has a zero embedding", text)
+	}
+	for j := range vec {
+		vec[j] = float32(float64(vec[j]) / norm)
+	}
+	return vec, nil
+}
+
+// EmbeddingFunc returns m as a chromem.EmbeddingFunc.
+func (m *Bert) EmbeddingFunc() chromem.EmbeddingFunc {
+	return m.Embed
+}
+
+// forward runs one post-LayerNorm encoder layer in place on n hidden states.
+func (l *bertLayer) forward(x []float32, n int, cfg BertConfig) {
+	h, heads := cfg.HiddenSize, cfg.NumHeads
+	dh := h / heads
+	q, k, v := l.query.apply(x, n), l.key.apply(x, n), l.value.apply(x, n)
+
+	ctx := make([]float32, n*h)
+	scores := make([]float32, n)
+	scale := float32(1 / math.Sqrt(float64(dh)))
+	for hd := range heads {
+		off := hd * dh
+		for i := range n {
+			qi := q[i*h+off : i*h+off+dh]
+			for j := range n {
+				scores[j] = dot(qi, k[j*h+off:j*h+off+dh]) * scale
+			}
+			softmax(scores)
+			out := ctx[i*h+off : i*h+off+dh]
+			for j, s := range scores {
+				for d, vv := range v[j*h+off : j*h+off+dh] {
+					out[d] += s * vv
+				}
+			}
+		}
+	}
+
+	attn := l.attnOut.apply(ctx, n)
+	for i := range n {
+		row := x[i*h : (i+1)*h]
+		for j := range row {
+			row[j] += attn[i*h+j]
+		}
+		l.attnNorm.apply(row)
+	}
+
+	inter := l.up.apply(x, n)
+	for i, u := range inter {
+		inter[i] = gelu(u, cfg.HiddenAct)
+	}
+	out := l.down.apply(inter, n)
+	for i := range n {
+		row := x[i*h : (i+1)*h]
+		for j := range row {
+			row[j] += out[i*h+j]
+		}
+		l.outNorm.apply(row)
+	}
+}
+
+// apply returns W·x + b for each of the n rows of x.
+func (l linear) apply(x []float32, n int) []float32 {
+	y := make([]float32, n*l.out)
+	for i := range n {
+		in := x[i*l.in : (i+1)*l.in]
+		out := y[i*l.out : (i+1)*l.out]
+		for o := range out {
+			out[o] = dot(l.w[o*l.in:(o+1)*l.in], in) + l.b[o]
+		}
+	}
+	return y
+}
+
+// apply normalizes row in place.
+func (ln layerNorm) apply(row []float32) {
+	var mean, variance float32
+	for _, v := range row {
+		mean += v
+	}
+	mean /= float32(len(row))
+	for _, v := range row {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float32(len(row))
+	inv := 1 / float32(math.Sqrt(float64(variance+ln.eps)))
+	for i, v := range row {
+		row[i] = (v-mean)*inv*ln.w[i] + ln.b[i]
+	}
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func softmax(s []float32) {
+	maxScore := s[0]
+	for _, v := range s[1:] {
+		maxScore = max(maxScore, v)
+	}
+	var sum float32
+	for i, v := range s {
+		s[i] = float32(math.Exp(float64(v - maxScore)))
+		sum += s[i]
+	}
+	for i := range s {
+		s[i] /= sum
+	}
+}
+
+// gelu is BERT's activation: the exact erf form for "gelu", the tanh
+// approximation for "gelu_new".
+func gelu(x float32, act string) float32 {
+	v := float64(x)
+	if act == "gelu_new" {
+		return float32(0.5 * v * (1 + math.Tanh(math.Sqrt(2/math.Pi)*(v+0.044715*v*v*v))))
+	}
+	return float32(0.5 * v * (1 + math.Erf(v/math.Sqrt2)))
+}
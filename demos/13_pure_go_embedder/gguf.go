@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+)
+
+// GGML tensor types this embedder reads. Quantized types aren't supported:
+// small embedding models are usually shipped as F32 or F16 anyway.
+const (
+	ggmlF32  = 0
+	ggmlF16  = 1
+	ggmlBF16 = 30
+)
+
+// GGUF metadata value types.
+const (
+	ggufUint8 = iota
+	ggufInt8
+	ggufUint16
+	ggufInt16
+	ggufUint32
+	ggufInt32
+	ggufFloat32
+	ggufBool
+	ggufString
+	ggufArray
+	ggufUint64
+	ggufInt64
+	ggufFloat64
+)
+
+const ggufMagic = "GGUF"
+
+// gguf is a file in llama.cpp's GGUF format: a header with typed metadata and
+// tensor descriptions, followed by aligned tensor data.
+type gguf struct {
+	path     string
+	metadata map[string]any
+	tensors  map[string]ggufTensor
+	data     []byte
+}
+
+type ggufTensor struct {
+	dims   []uint64 // innermost first, the reverse of PyTorch
+	dtype  uint32
+	offset uint64
+}
+
+func openGGUF(path string) (*gguf, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read weights: %w", err)
+	}
+	r := &ggufReader{r: bytes.NewReader(raw)}
+
+	magic := make([]byte, 4)
+	r.read(magic)
+	version := r.u32()
+	if r.err == nil && (string(magic) != ggufMagic || version < 2 || version > 3) {
+		return nil, fmt.Errorf("%s isn't a GGUF v2 or v3 file", path)
+	}
+	nTensors, nKV := r.u64(), r.u64()
+
+	g := &gguf{path: path, metadata: make(map[string]any), tensors: make(map[string]ggufTensor)}
+	for i := uint64(0); i < nKV && r.err == nil; i++ {
+		key := r.str()
+		g.metadata[key] = r.value(r.u32())
+	}
+	for i := uint64(0); i < nTensors && r.err == nil; i++ {
+		name := r.str()
+		// Each dimension takes 8 bytes, so a count the rest of the file
+		// can't hold is corrupt, not a reason to allocate gigabytes
+		nDims := r.u32()
+		if r.err == nil && uint64(nDims) > uint64(r.r.Len())/8 {
+			r.err = io.ErrUnexpectedEOF
+		}
+		if r.err != nil {
+			break
+		}
+		t := ggufTensor{dims: make([]uint64, nDims)}
+		for d := range t.dims {
+			t.dims[d] = r.u64()
+		}
+		t.dtype, t.offset = r.u32(), r.u64()
+		g.tensors[name] = t
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("couldn't parse GGUF header of %s: %w", path, r.err)
+	}
+
+	alignment := uint64(32)
+	if a, ok := g.metadata["general.alignment"].(uint32); ok && a > 0 {
+		alignment = uint64(a)
+	}
+	start := (uint64(len(raw)) - uint64(r.r.Len()) + alignment - 1) / alignment * alignment
+	if start > uint64(len(raw)) {
+		return nil, fmt.Errorf("%s: tensor data starts past the end of the file", path)
+	}
+	g.data = raw[start:]
+	return g, nil
+}
+
+func (g *gguf) tensor(name string) ([]float32, []int, error) {
+	t, ok := g.tensors[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s has no tensor %s", g.path, name)
+	}
+	size := map[uint32]uint64{ggmlF32: 4, ggmlF16: 2, ggmlBF16: 2}[t.dtype]
+	if size == 0 {
+		return nil, nil, fmt.Errorf("tensor %s has unsupported type %d", name, t.dtype)
+	}
+	// The dimensions are checked against the data one at a time, so their
+	// product can't overflow on the way
+	pastEnd := fmt.Errorf("tensor %s extends past the end of %s", name, g.path)
+	if t.offset > uint64(len(g.data)) {
+		return nil, nil, pastEnd
+	}
+	limit := (uint64(len(g.data)) - t.offset) / size
+	shape := make([]int, len(t.dims))
+	n := uint64(1)
+	for i, d := range t.dims {
+		if d != 0 && n > limit/d {
+			return nil, nil, pastEnd
+		}
+		n *= d
+		shape[len(shape)-1-i] = int(d)
+	}
+	end := t.offset + n*size
+	values, err := decodeFloats(g.data[t.offset:end], t.dtype, numElements(shape))
+	if err != nil {
+		return nil, nil, fmt.Errorf("tensor %s: %w", name, err)
+	}
+	return values, shape, nil
+}
+
+// metaUint returns an integer metadata value of any width.
+func (g *gguf) metaUint(key string) (int, bool) {
+	switch v := g.metadata[key].(type) {
+	case uint8:
+		return int(v), true
+	case uint16:
+		return int(v), true
+	case uint32:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// ggufReader reads little-endian GGUF values and remembers the first error.
+type ggufReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *ggufReader) read(v any) {
+	if r.err == nil {
+		r.err = binary.Read(r.r, binary.LittleEndian, v)
+	}
+}
+
+func (r *ggufReader) u32() uint32 {
+	var v uint32
+	r.read(&v)
+	return v
+}
+
+func (r *ggufReader) u64() uint64 {
+	var v uint64
+	r.read(&v)
+	return v
+}
+
+func (r *ggufReader) str() string {
+	n := r.u64()
+	if r.err != nil {
+		return ""
+	}
+	if n > uint64(r.r.Len()) {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	b := make([]byte, n)
+	r.read(b)
+	return string(b)
+}
+
+func (r *ggufReader) value(typ uint32) any {
+	switch typ {
+	case ggufUint8:
+		var v uint8
+		r.read(&v)
+		return v
+	case ggufInt8:
+		var v int8
+		r.read(&v)
+		return v
+	case ggufUint16:
+		var v uint16
+		r.read(&v)
+		return v
+	case ggufInt16:
+		var v int16
+		r.read(&v)
+		return v
+	case ggufUint32:
+		return r.u32()
+	case ggufInt32:
+		var v int32
+		r.read(&v)
+		return v
+	case ggufFloat32:
+		var v float32
+		r.read(&v)
+		return v
+	case ggufBool:
+		var v uint8
+		r.read(&v)
+		return v != 0
+	case ggufString:
+		return r.str()
+	case ggufUint64:
+		return r.u64()
+	case ggufInt64:
+		var v int64
+		r.read(&v)
+		return v
+	case ggufFloat64:
+		var v float64
+		r.read(&v)
+		return v
+	case ggufArray:
+		elem, n := r.u32(), r.u64()
+		if r.err == nil && n > uint64(r.r.Len()) {
+			r.err = io.ErrUnexpectedEOF
+		}
+		values := make([]any, 0, min(n, 1<<20))
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			values = append(values, r.value(elem))
+		}
+		return values
+	}
+	if r.err == nil {
+		r.err = fmt.Errorf("unknown metadata type %d", typ)
+	}
+	return nil
+}
+
+// namedTensor is a tensor to write with writeGGUF or writeSafetensors, shaped
+// like in PyTorch.
+type namedTensor struct {
+	name   string
+	shape  []int
+	values []float32
+}
+
+// writeGGUF writes a GGUF v3 file with string, uint32, float32 and
+// string-array metadata and F16 tensors. It exists to produce the test model.
+func writeGGUF(path string, metadata map[string]any, tensors []namedTensor) error {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	le := func(v any) { binary.Write(w, binary.LittleEndian, v) }
+	str := func(s string) { le(uint64(len(s))); w.WriteString(s) }
+
+	w.WriteString(ggufMagic)
+	le(uint32(3))
+	le(uint64(len(tensors)))
+	le(uint64(len(metadata)))
+	for _, key := range sortedKeys(metadata) {
+		str(key)
+		switch v := metadata[key].(type) {
+		case string:
+			le(uint32(ggufString))
+			str(v)
+		case uint32:
+			le(uint32(ggufUint32))
+			le(v)
+		case float32:
+			le(uint32(ggufFloat32))
+			le(v)
+		case []string:
+			le(uint32(ggufArray))
+			le(uint32(ggufString))
+			le(uint64(len(v)))
+			for _, s := range v {
+				str(s)
+			}
+		default:
+			return fmt.Errorf("unsupported metadata type %T for %s", v, key)
+		}
+	}
+
+	const alignment = 32
+	var offset uint64
+	for _, t := range tensors {
+		str(t.name)
+		le(uint32(len(t.shape)))
+		for _, d := range slices.Backward(t.shape) {
+			le(uint64(d))
+		}
+		le(uint32(ggmlF16))
+		le(offset)
+		offset += (uint64(2*len(t.values)) + alignment - 1) / alignment * alignment
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	buf.Write(make([]byte, (alignment-buf.Len()%alignment)%alignment))
+	for _, t := range tensors {
+		for _, v := range t.values {
+			binary.Write(&buf, binary.LittleEndian, toFloat16(v))
+		}
+		buf.Write(make([]byte, (alignment-buf.Len()%alignment)%alignment))
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// toFloat16 rounds a float32 to the nearest half-precision value. Values out
+// of range become infinity; the test model's weights are far from that.
+func toFloat16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23&0xff) - 127 + 15
+	frac := bits & 0x7fffff
+	switch {
+	case exp >= 0x1f:
+		return sign | 0x7c00
+	case exp <= 0:
+		if exp < -10 {
+			return sign
+		}
+		frac |= 0x800000
+		shift := uint32(14 - exp)
+		half := uint16(frac >> shift)
+		if frac>>(shift-1)&1 == 1 {
+			half++
+		}
+		return sign | half
+	}
+	half := sign | uint16(exp)<<10 | uint16(frac>>13)
+	if frac&0x1000 != 0 {
+		half++ // round half up; carries into the exponent correctly
+	}
+	return half
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// tensorSource reads named tensors as float32, with shapes in PyTorch's
+// order: outermost dimension first.
+type tensorSource interface {
+	tensor(name string) ([]float32, []int, error)
+}
+
+// safetensors is a file in Hugging Face's safetensors format: an 8-byte
+// little-endian header length, a JSON header describing every tensor, and the
+// raw tensor data.
+type safetensors struct {
+	path    string
+	data    []byte
+	tensors map[string]safetensorInfo
+}
+
+type safetensorInfo struct {
+	DType   string   `json:"dtype"`
+	Shape   []int    `json:"shape"`
+	Offsets [2]int64 `json:"data_offsets"`
+}
+
+func openSafetensors(path string) (*safetensors, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read weights: %w", err)
+	}
+	if len(data) < 8 {
+		return nil, fmt.Errorf("%s is too short for safetensors", path)
+	}
+	n := binary.LittleEndian.Uint64(data)
+	if n > uint64(len(data)-8) {
+		return nil, fmt.Errorf("%s: header length %d exceeds file size", path, n)
+	}
+
+	var header map[string]json.RawMessage
+	if err := json.Unmarshal(data[8:8+n], &header); err != nil {
+		return nil, fmt.Errorf("couldn't decode safetensors header of %s: %w", path, err)
+	}
+	st := &safetensors{path: path, data: data[8+n:], tensors: make(map[string]safetensorInfo, len(header))}
+	for name, raw := range header {
+		if name == "__metadata__" {
+			continue
+		}
+		var info safetensorInfo
+		if err := json.Unmarshal(raw, &info); err != nil {
+			return nil, fmt.Errorf("couldn't decode safetensors entry %s: %w", name, err)
+		}
+		if info.Offsets[0] < 0 || info.Offsets[1] < info.Offsets[0] || info.Offsets[1] > int64(len(st.data)) {
+			return nil, fmt.Errorf("%s: tensor %s has invalid offsets %v", path, name, info.Offsets)
+		}
+		st.tensors[name] = info
+	}
+	return st, nil
+}
+
+func (st *safetensors) tensor(name string) ([]float32, []int, error) {
+	info, ok := st.tensors[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s has no tensor %s", st.path, name)
+	}
+	raw := st.data[info.Offsets[0]:info.Offsets[1]]
+	var dtype uint32
+	switch info.DType {
+	case "F32":
+		dtype = ggmlF32
+	case "F16":
+		dtype = ggmlF16
+	case "BF16":
+		dtype = ggmlBF16
+	default:
+		return nil, nil, fmt.Errorf("tensor %s has unsupported dtype %s", name, info.DType)
+	}
+	values, err := decodeFloats(raw, dtype, numElements(info.Shape))
+	if err != nil {
+		return nil, nil, fmt.Errorf("tensor %s: %w", name, err)
+	}
+	return values, info.Shape, nil
+}
+
+func numElements(shape []int) int {
+	n := 1
+	for _, d := range shape {
+		n *= d
+	}
+	return n
+}
+
+// decodeFloats converts n little-endian values of a GGML type to float32.
+func decodeFloats(raw []byte, dtype uint32, n int) ([]float32, error) {
+	size := map[uint32]int{ggmlF32: 4, ggmlF16: 2, ggmlBF16: 2}[dtype]
+	if size == 0 {
+		return nil, fmt.Errorf("unsupported tensor type %d", dtype)
+	}
+	if len(raw) != n*size {
+		return nil, fmt.Errorf("%d bytes of data for %d values", len(raw), n)
+	}
+	out := make([]float32, n)
+	for i := range out {
+		switch dtype {
+		case ggmlF32:
+			out[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:]))
+		case ggmlF16:
+			out[i] = float16(binary.LittleEndian.Uint16(raw[2*i:]))
+		case ggmlBF16:
+			out[i] = math.Float32frombits(uint32(binary.LittleEndian.Uint16(raw[2*i:])) << 16)
+		}
+	}
+	return out, nil
+}
+
+// float16 converts an IEEE 754 half-precision value.
+func float16(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := int32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch {
+	case exp == 0 && frac == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// Subnormal: shift the fraction until it has a leading one
+		exp = 1
+		for frac&0x400 == 0 {
+			frac <<= 1
+			exp--
+		}
+		frac &= 0x3ff
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	}
+	return math.Float32frombits(sign | uint32(exp+127-15)<<23 | frac<<13)
+}
@@ -0,0 +1,196 @@
+"""Reference outputs for the tiny test model.
+
+A second, independent implementation of BERT's tokenizer and forward pass in
+plain Python (no numpy, no torch), written from Hugging Face's BertTokenizer
+and BertModel. It reads config.json, vocab.txt and model.safetensors next to
+it and writes expected.json, which the Go demo checks itself against.
+
+    python3 testdata/tiny-bert/reference.py
+"""
+
+import json
+import math
+import os
+import struct
+import unicodedata
+
+HERE = os.path.dirname(os.path.abspath(__file__))
+
+TEXTS = [
+    "Docker containers provide lightweight, portable application packaging",
+    "Kubernetes orchestrates containers across clusters with automated scaling",
+    "REST APIs enable communication between services using HTTP protocols",
+    "How do I shard a database? (Asking for 2 friends.)",
+    "Café déjà vu: naïve façades",
+    "zzzz qwerty",
+    "",
+]
+
+
+def load_safetensors(path):
+    with open(path, "rb") as f:
+        raw = f.read()
+    (n,) = struct.unpack("<Q", raw[:8])
+    header = json.loads(raw[8 : 8 + n])
+    data = raw[8 + n :]
+    tensors = {}
+    for name, info in header.items():
+        if name == "__metadata__":
+            continue
+        assert info["dtype"] == "F32", info["dtype"]
+        start, end = info["data_offsets"]
+        values = struct.unpack("<%df" % ((end - start) // 4), data[start:end])
+        tensors[name] = (list(values), info["shape"])
+    return tensors
+
+
+def basic_tokenize(text):
+    out = []
+    for ch in text:
+        cp = ord(ch)
+        if cp == 0 or cp == 0xFFFD or (unicodedata.category(ch).startswith("C") and ch not in "\t\n\r"):
+            continue
+        if ch in " \t\n\r" or unicodedata.category(ch) == "Zs":
+            out.append(" ")
+        elif is_cjk(cp):
+            out.append(" %s " % ch)
+        else:
+            out.append(ch)
+    words = []
+    for token in "".join(out).split():
+        token = token.lower()
+        token = "".join(c for c in unicodedata.normalize("NFD", token) if unicodedata.category(c) != "Mn")
+        current = ""
+        for ch in token:
+            if is_punct(ch):
+                if current:
+                    words.append(current)
+                words.append(ch)
+                current = ""
+            else:
+                current += ch
+        if current:
+            words.append(current)
+    return words
+
+
+def is_punct(ch):
+    cp = ord(ch)
+    if 33 <= cp <= 47 or 58 <= cp <= 64 or 91 <= cp <= 96 or 123 <= cp <= 126:
+        return True
+    return unicodedata.category(ch).startswith("P")
+
+
+def is_cjk(cp):
+    return any(
+        lo <= cp <= hi
+        for lo, hi in [
+            (0x4E00, 0x9FFF), (0x3400, 0x4DBF), (0x20000, 0x2A6DF), (0x2A700, 0x2B73F),
+            (0x2B740, 0x2B81F), (0x2B820, 0x2CEAF), (0xF900, 0xFAFF), (0x2F800, 0x2FA1F),
+        ]
+    )
+
+
+def wordpiece(word, vocab):
+    if len(word) > 100:
+        return ["[UNK]"]
+    pieces, start = [], 0
+    while start < len(word):
+        end, found = len(word), None
+        while start < end:
+            piece = word[start:end] if start == 0 else "##" + word[start:end]
+            if piece in vocab:
+                found = piece
+                break
+            end -= 1
+        if found is None:
+            return ["[UNK]"]
+        pieces.append(found)
+        start = end
+    return pieces
+
+
+def tokenize(text, vocab):
+    return [p for w in basic_tokenize(text) for p in wordpiece(w, vocab)]
+
+
+def layer_norm(x, w, b, eps):
+    mean = sum(x) / len(x)
+    var = sum((v - mean) ** 2 for v in x) / len(x)
+    inv = 1 / math.sqrt(var + eps)
+    return [(v - mean) * inv * w[i] + b[i] for i, v in enumerate(x)]
+
+
+def linear(x, w, b, n_in, n_out):
+    return [sum(w[o * n_in + i] * x[i] for i in range(n_in)) + b[o] for o in range(n_out)]
+
+
+def gelu(x):
+    return 0.5 * x * (1 + math.erf(x / math.sqrt(2)))
+
+
+def embed(text, cfg, vocab_ids, t):
+    h, heads = cfg["hidden_size"], cfg["num_attention_heads"]
+    dh, eps, inter = h // heads, cfg["layer_norm_eps"], cfg["intermediate_size"]
+    ids = [vocab_ids["[CLS]"]] + [vocab_ids[p] for p in tokenize(text, vocab_ids)]
+    ids = ids[: cfg["max_position_embeddings"] - 1] + [vocab_ids["[SEP]"]]
+    word, pos, typ = (t["embeddings.%s.weight" % k][0] for k in ("word_embeddings", "position_embeddings", "token_type_embeddings"))
+    x = [
+        layer_norm(
+            [word[tok * h + j] + pos[i * h + j] + typ[j] for j in range(h)],
+            t["embeddings.LayerNorm.weight"][0], t["embeddings.LayerNorm.bias"][0], eps,
+        )
+        for i, tok in enumerate(ids)
+    ]
+    for layer in range(cfg["num_hidden_layers"]):
+        p = "encoder.layer.%d." % layer
+        dense = lambda name, rows, n_in, n_out: [linear(r, t[p + name + ".weight"][0], t[p + name + ".bias"][0], n_in, n_out) for r in rows]
+        q, k, v = (dense("attention.self." + n, x, h, h) for n in ("query", "key", "value"))
+        ctx = [[0.0] * h for _ in x]
+        for hd in range(heads):
+            s = slice(hd * dh, (hd + 1) * dh)
+            for i in range(len(x)):
+                scores = [sum(a * b for a, b in zip(q[i][s], k[j][s])) / math.sqrt(dh) for j in range(len(x))]
+                m = max(scores)
+                e = [math.exp(sc - m) for sc in scores]
+                z = sum(e)
+                for j in range(len(x)):
+                    for d in range(dh):
+                        ctx[i][hd * dh + d] += e[j] / z * v[j][hd * dh + d]
+        attn = dense("attention.output.dense", ctx, h, h)
+        x = [
+            layer_norm([a + b for a, b in zip(x[i], attn[i])], t[p + "attention.output.LayerNorm.weight"][0], t[p + "attention.output.LayerNorm.bias"][0], eps)
+            for i in range(len(x))
+        ]
+        up = [[gelu(u) for u in row] for row in dense("intermediate.dense", x, h, inter)]
+        down = dense("output.dense", up, inter, h)
+        x = [
+            layer_norm([a + b for a, b in zip(x[i], down[i])], t[p + "output.LayerNorm.weight"][0], t[p + "output.LayerNorm.bias"][0], eps)
+            for i in range(len(x))
+        ]
+    mean = [sum(row[j] for row in x) / len(x) for j in range(h)]
+    norm = math.sqrt(sum(v * v for v in mean))
+    return [v / norm for v in mean]
+
+
+def main():
+    with open(os.path.join(HERE, "config.json")) as f:
+        cfg = json.load(f)
+    with open(os.path.join(HERE, "vocab.txt"), encoding="utf-8") as f:
+        vocab = [line.rstrip("\n") for line in f]
+    vocab_ids = {}
+    for i, tok in enumerate(vocab):
+        vocab_ids.setdefault(tok, i)
+    tensors = load_safetensors(os.path.join(HERE, "model.safetensors"))
+
+    cases = [
+        {"text": text, "tokens": tokenize(text, vocab_ids), "embedding": [round(v, 7) for v in embed(text, cfg, vocab_ids, tensors)]}
+        for text in TEXTS
+    ]
+    with open(os.path.join(HERE, "expected.json"), "w") as f:
+        json.dump({"reference": "reference.py", "cases": cases}, f, indent=1, ensure_ascii=False)
+        f.write("\n")
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tinyConfig is the test model in testdata/tiny-bert: BERT's architecture at
+// a size that's fast to check by hand, with seeded random weights. It is for
+// verifying the implementation, not for finding anything.
+var tinyConfig = BertConfig{
+	HiddenSize:       32,
+	NumLayers:        2,
+	NumHeads:         2,
+	IntermediateSize: 64,
+	MaxPositions:     64,
+	TypeVocabSize:    2,
+	LayerNormEps:     1e-12,
+	HiddenAct:        "gelu",
+}
+
+// tinyVocab is a small WordPiece vocabulary: the special tokens, ASCII
+// punctuation and digits, every letter as a word start and a continuation,
+// common suffixes and the words of the demo documents, so most words split
+// into a few meaningful pieces.
+func tinyVocab() []string {
+	tokens := []string{"[PAD]", "[UNK]", "[CLS]", "[SEP]", "[MASK]"}
+	for r := '!'; r <= '~'; r++ {
+		if isPunct(r) || (r >= '0' && r <= '9') {
+			tokens = append(tokens, string(r))
+		}
+	}
+	for r := 'a'; r <= 'z'; r++ {
+		tokens = append(tokens, string(r))
+	}
+	for r := 'a'; r <= 'z'; r++ {
+		tokens = append(tokens, "##"+string(r))
+	}
+	for _, suffix := range strings.Fields("es ed ing er ers ion ions ation ly al able ment ize ized ity weight service services") {
+		tokens = append(tokens, "##"+suffix)
+	}
+	words := `the an of and to in for is are with into between across using how do what
+		docker container provide light port application pack packaging kubernetes orchestrate
+		cluster automate scale scaling micro service architecture break independent deploy
+		rest api enable communication http protocol database shard sharding distribute data
+		multiple instance search vector embed query model text word go`
+	return append(tokens, strings.Fields(words)...)
+}
+
+// genTiny writes the test model to dir: config.json, vocab.txt and
+// model.safetensors in F32 for the Hugging Face layout, and model.gguf in F16
+// for llama.cpp's.
+func genTiny(dir string) error {
+	cfg := tinyConfig
+	vocab := tinyVocab()
+	cfg.VocabSize = len(vocab)
+	h, inter := cfg.HiddenSize, cfg.IntermediateSize
+
+	rng := rand.New(rand.NewPCG(36, 2024))
+	normal := func(std float64, shape ...int) []float32 {
+		values := make([]float32, numElements(shape))
+		for i := range values {
+			values[i] = float32(rng.NormFloat64() * std)
+		}
+		return values
+	}
+	var tensors []namedTensor
+	add := func(name string, values []float32, shape ...int) {
+		tensors = append(tensors, namedTensor{name: name, shape: shape, values: values})
+	}
+	addNorm := func(prefix string) {
+		w := normal(0.1, h)
+		for i := range w {
+			w[i] += 1
+		}
+		add(prefix+".weight", w, h)
+		add(prefix+".bias", normal(0.1, h), h)
+	}
+	addDense := func(prefix string, in, out int) {
+		add(prefix+".weight", normal(0.2, out, in), out, in)
+		add(prefix+".bias", normal(0.05, out), out)
+	}
+
+	// The dense layers are large enough that a mistake in any of them shows
+	// in the embeddings.
+	add("embeddings.word_embeddings.weight", normal(1, cfg.VocabSize, h), cfg.VocabSize, h)
+	add("embeddings.position_embeddings.weight", normal(0.1, cfg.MaxPositions, h), cfg.MaxPositions, h)
+	add("embeddings.token_type_embeddings.weight", normal(0.1, cfg.TypeVocabSize, h), cfg.TypeVocabSize, h)
+	addNorm("embeddings.LayerNorm")
+	for i := range cfg.NumLayers {
+		p := fmt.Sprintf("encoder.layer.%d.", i)
+		addDense(p+"attention.self.query", h, h)
+		addDense(p+"attention.self.key", h, h)
+		addDense(p+"attention.self.value", h, h)
+		addDense(p+"attention.output.dense", h, h)
+		addNorm(p + "attention.output.LayerNorm")
+		addDense(p+"intermediate.dense", h, inter)
+		addDense(p+"output.dense", inter, h)
+		addNorm(p + "output.LayerNorm")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("couldn't create %s: %w", dir, err)
+	}
+	config, err := json.MarshalIndent(map[string]any{
+		"architectures":           []string{"BertModel"},
+		"model_type":              "bert",
+		"vocab_size":              cfg.VocabSize,
+		"hidden_size":             cfg.HiddenSize,
+		"num_hidden_layers":       cfg.NumLayers,
+		"num_attention_heads":     cfg.NumHeads,
+		"intermediate_size":       cfg.IntermediateSize,
+		"max_position_embeddings": cfg.MaxPositions,
+		"type_vocab_size":         cfg.TypeVocabSize,
+		"layer_norm_eps":          cfg.LayerNormEps,
+		"hidden_act":              cfg.HiddenAct,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), append(config, '\n'), 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vocab.txt"), []byte(strings.Join(vocab, "\n")+"\n"), 0o644); err != nil {
+		return err
+	}
+	if err := writeSafetensors(filepath.Join(dir, "model.safetensors"), tensors); err != nil {
+		return err
+	}
+
+	// llama.cpp's converter renames tensors and marks word starts with "▁"
+	// instead of marking continuations with "##"
+	ggufTensors := make([]namedTensor, len(tensors))
+	for i, t := range tensors {
+		name := t.name
+		if strings.HasPrefix(name, "encoder.layer.") {
+			n, rest, _ := strings.Cut(strings.TrimPrefix(name, "encoder.layer."), ".")
+			name = "blk." + n + "." + strings.NewReplacer(
+				"attention.self.query", "attn_q",
+				"attention.self.key", "attn_k",
+				"attention.self.value", "attn_v",
+				"attention.output.dense", "attn_output",
+				"attention.output.LayerNorm", "attn_output_norm",
+				"intermediate.dense", "ffn_up",
+				"output.dense", "ffn_down",
+				"output.LayerNorm", "layer_output_norm",
+			).Replace(rest)
+		} else {
+			name = strings.NewReplacer(
+				"embeddings.word_embeddings", "token_embd",
+				"embeddings.position_embeddings", "position_embd",
+				"embeddings.token_type_embeddings", "token_types",
+				"embeddings.LayerNorm", "token_embd_norm",
+			).Replace(name)
+		}
+		ggufTensors[i] = namedTensor{name: name, shape: t.shape, values: t.values}
+	}
+	ggufVocab := make([]string, len(vocab))
+	for i, tok := range vocab {
+		switch {
+		case strings.HasPrefix(tok, "##"):
+			tok = strings.TrimPrefix(tok, "##")
+		case strings.HasPrefix(tok, "[") && strings.HasSuffix(tok, "]"):
+		default:
+			tok = "▁" + tok
+		}
+		ggufVocab[i] = tok
+	}
+	return writeGGUF(filepath.Join(dir, "model.gguf"), map[string]any{
+		"general.architecture":              "bert",
+		"general.name":                      "tiny-bert",
+		"general.alignment":                 uint32(32),
+		"bert.embedding_length":             uint32(cfg.HiddenSize),
+		"bert.block_count":                  uint32(cfg.NumLayers),
+		"bert.attention.head_count":         uint32(cfg.NumHeads),
+		"bert.feed_forward_length":          uint32(cfg.IntermediateSize),
+		"bert.context_length":               uint32(cfg.MaxPositions),
+		"bert.attention.layer_norm_epsilon": float32(cfg.LayerNormEps),
+		"tokenizer.ggml.model":              "bert",
+		"tokenizer.ggml.tokens":             ggufVocab,
+	}, ggufTensors)
+}
+
+// writeSafetensors writes F32 tensors in the safetensors format.
+func writeSafetensors(path string, tensors []namedTensor) error {
+	header := make(map[string]safetensorInfo, len(tensors))
+	var data bytes.Buffer
+	for _, t := range tensors {
+		start := int64(data.Len())
+		binary.Write(&data, binary.LittleEndian, t.values)
+		header[t.name] = safetensorInfo{DType: "F32", Shape: t.shape, Offsets: [2]int64{start, int64(data.Len())}}
+	}
+	raw, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// The header is padded with spaces so the data is 8-byte aligned
+	raw = append(raw, bytes.Repeat([]byte(" "), (8-len(raw)%8)%8)...)
+
+	var out bytes.Buffer
+	binary.Write(&out, binary.LittleEndian, uint64(len(raw)))
+	out.Write(raw)
+	out.Write(data.Bytes())
+	return os.WriteFile(path, out.Bytes(), 0o644)
+}
+
+// runGenTiny implements `go run . gen-tiny`.
+func runGenTiny(args []string) error {
+	fs := flag.NewFlagSet("gen-tiny", flag.ExitOnError)
+	dir := fs.String("dir", filepath.Join("testdata", "tiny-bert"), "directory to write the model to")
+	fs.Parse(args)
+
+	if err := genTiny(*dir); err != nil {
+		return fmt.Errorf("couldn't write test model: %w", err)
+	}
+	fmt.Printf("🧬 Wrote the test model to %s\n", *dir)
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// WordPiece is BERT's tokenizer: text is cleaned, lowercased, stripped of
+// accents and split on whitespace and punctuation, then each word is split
+// greedily into the longest pieces found in the vocabulary. Pieces that
+// continue a word are prefixed with "##".
+type WordPiece struct {
+	vocab  map[string]int
+	tokens []string
+
+	lower   bool
+	maxWord int // longer words become [UNK], like in HF's tokenizer
+
+	unk, cls, sep int
+}
+
+// NewWordPiece builds a tokenizer from a vocabulary, one token per entry in
+// ID order. It needs the special tokens [UNK], [CLS] and [SEP].
+func NewWordPiece(tokens []string, lower bool) (*WordPiece, error) {
+	t := &WordPiece{vocab: make(map[string]int, len(tokens)), tokens: tokens, lower: lower, maxWord: 100}
+	for id, tok := range tokens {
+		if _, ok := t.vocab[tok]; !ok {
+			t.vocab[tok] = id
+		}
+	}
+	for _, special := range []struct {
+		tok string
+		id  *int
+	}{{"[UNK]", &t.unk}, {"[CLS]", &t.cls}, {"[SEP]", &t.sep}} {
+		id, ok := t.vocab[special.tok]
+		if !ok {
+			return nil, fmt.Errorf("vocabulary has no %s token", special.tok)
+		}
+		*special.id = id
+	}
+	return t, nil
+}
+
+// readVocab reads a vocab.txt: one token per line, the line number is the ID.
+func readVocab(r io.Reader) ([]string, error) {
+	var tokens []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		tokens = append(tokens, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read vocabulary: %w", err)
+	}
+	return tokens, nil
+}
+
+// Tokenize returns the word pieces of text.
+func (t *WordPiece) Tokenize(text string) []string {
+	var pieces []string
+	for _, word := range t.words(text) {
+		for _, id := range t.wordPieces(word) {
+			pieces = append(pieces, t.tokens[id])
+		}
+	}
+	return pieces
+}
+
+// Encode returns the token IDs of text between [CLS] and [SEP], truncated to
+// maxLen IDs in total.
+func (t *WordPiece) Encode(text string, maxLen int) []int {
+	ids := []int{t.cls}
+	for _, word := range t.words(text) {
+		ids = append(ids, t.wordPieces(word)...)
+	}
+	if len(ids) > maxLen-1 {
+		ids = ids[:maxLen-1]
+	}
+	return append(ids, t.sep)
+}
+
+// words splits text the way HF's BasicTokenizer does.
+func (t *WordPiece) words(text string) []string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == 0 || r == unicode.ReplacementChar || (unicode.IsControl(r) && !unicode.IsSpace(r)):
+			continue
+		case unicode.IsSpace(r):
+			b.WriteByte(' ')
+		case isCJK(r):
+			b.WriteByte(' ')
+			b.WriteRune(r)
+			b.WriteByte(' ')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	var words []string
+	for _, field := range strings.Fields(b.String()) {
+		if t.lower {
+			field = stripAccents(strings.ToLower(field))
+		}
+		start := 0
+		for i, r := range field {
+			if isPunct(r) {
+				if start < i {
+					words = append(words, field[start:i])
+				}
+				words = append(words, string(r))
+				start = i + len(string(r))
+			}
+		}
+		if start < len(field) {
+			words = append(words, field[start:])
+		}
+	}
+	return words
+}
+
+// wordPieces splits one word greedily, longest match first.
+func (t *WordPiece) wordPieces(word string) []int {
+	runes := []rune(word)
+	if len(runes) > t.maxWord {
+		return []int{t.unk}
+	}
+	var ids []int
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		id := -1
+		for ; end > start; end-- {
+			piece := string(runes[start:end])
+			if start > 0 {
+				piece = "##" + piece
+			}
+			if i, ok := t.vocab[piece]; ok {
+				id = i
+				break
+			}
+		}
+		if id < 0 {
+			return []int{t.unk}
+		}
+		ids = append(ids, id)
+		start = end
+	}
+	return ids
+}
+
+// isPunct matches HF's definition: all non-alphanumeric ASCII that isn't
+// whitespace, and everything Unicode calls punctuation.
+func isPunct(r rune) bool {
+	if (r >= 33 && r <= 47) || (r >= 58 && r <= 64) || (r >= 91 && r <= 96) || (r >= 123 && r <= 126) {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+// isCJK reports whether r is in the CJK Unified Ideographs blocks, whose
+// characters BERT treats as words of their own.
+func isCJK(r rune) bool {
+	return (r >= 0x4E00 && r <= 0x9FFF) || (r >= 0x3400 && r <= 0x4DBF) || (r >= 0x20000 && r <= 0x2A6DF) ||
+		(r >= 0x2A700 && r <= 0x2B73F) || (r >= 0x2B740 && r <= 0x2B81F) || (r >= 0x2B820 && r <= 0x2CEAF) ||
+		(r >= 0xF900 && r <= 0xFAFF) || (r >= 0x2F800 && r <= 0x2FA1F)
+}
+
+// accents maps precomposed Latin letters to their base letter. HF strips
+// accents with NFD normalization; without golang.org/x/text, this table
+// covers Latin-1 and Latin Extended-A, which is what English text meets.
+var accents = func() map[rune]rune {
+	m := make(map[rune]rune)
+	for base, variants := range map[rune]string{
+		'a': "àáâãäåāăą", 'c': "çćĉċč", 'd': "ď", 'e': "èéêëēĕėęě", 'g': "ĝğġģ",
+		'h': "ĥ", 'i': "ìíîïĩīĭįı", 'j': "ĵ", 'k': "ķ", 'l': "ĺļľ", 'n': "ñńņňŉ",
+		'o': "òóôõöōŏő", 'r': "ŕŗř", 's': "śŝşš", 't': "ţť", 'u': "ùúûüũūŭůűų",
+		'w': "ŵ", 'y': "ýÿŷ", 'z': "źżž",
+	} {
+		for _, v := range variants {
+			m[v] = base
+		}
+	}
+	return m
+}()
+
+func stripAccents(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		if base, ok := accents[r]; ok {
+			return base
+		}
+		return r
+	}, s)
+}
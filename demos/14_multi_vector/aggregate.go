@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Aggregation rolls the similarities of a parent's chunks up into the
+// parent's score.
+type Aggregation interface {
+	Name() string
+	// Score is given the similarities of every chunk of one parent, best
+	// first.
+	Score(similarities []float32) float32
+}
+
+// Max scores a parent by its best chunk: one paragraph that answers the query
+// is enough, however long the rest of the document is.
+type Max struct{}
+
+func (Max) Name() string { return "max" }
+
+func (Max) Score(similarities []float32) float32 { return similarities[0] }
+
+// Mean scores a parent by all of its chunks, so a document about the query
+// throughout beats one that mentions it once. Long documents pay for every
+// chunk that's about something else.
+type Mean struct{}
+
+func (Mean) Name() string { return "mean" }
+
+func (Mean) Score(similarities []float32) float32 {
+	var sum float32
+	for _, s := range similarities {
+		sum += s
+	}
+	return sum / float32(len(similarities))
+}
+
+// TopNSum scores a parent by the sum of its best N chunks: between Max and
+// Mean, it rewards a document for answering in several places without
+// punishing it for the chunks that don't.
+type TopNSum struct{ N int }
+
+func (a TopNSum) Name() string { return fmt.Sprintf("top-%d sum", a.N) }
+
+func (a TopNSum) Score(similarities []float32) float32 {
+	var sum float32
+	for _, s := range similarities[:min(a.N, len(similarities))] {
+		sum += s
+	}
+	return sum
+}
+
+// ParseAggregation parses "max", "mean" or "topN", such as "top2".
+func ParseAggregation(s string) (Aggregation, error) {
+	switch s {
+	case "max":
+		return Max{}, nil
+	case "mean":
+		return Mean{}, nil
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(s, "top")); err == nil && n > 0 && strings.HasPrefix(s, "top") {
+		return TopNSum{N: n}, nil
+	}
+	return nil, fmt.Errorf("unknown aggregation %q, want max, mean or topN", s)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Chunk is a byte range of a parent document. The chunks of a document cover
+// it exactly, whitespace included, so their contents joined in order are the
+// document again.
+type Chunk struct {
+	Index      int
+	Start, End int
+}
+
+// ChunkText splits text into chunks of about size bytes, which must be
+// positive. A chunk ends at a paragraph break, or else after the last
+// sentence that fits, or the last word if no sentence does; the whitespace
+// after it belongs to it. A chunk of only punctuation and whitespace has
+// nothing to embed, so it joins the chunk before it, or the one after it if
+// it comes first.
+func ChunkText(text string, size int) []Chunk {
+	var chunks []Chunk
+	for start := 0; start < len(text); {
+		end := start + chunkEnd(text[start:], size)
+		for len(chunks) == 0 && end < len(text) && !hasWord(text[start:end]) {
+			end += chunkEnd(text[end:], size)
+		}
+		if len(chunks) > 0 && !hasWord(text[start:end]) {
+			chunks[len(chunks)-1].End = end
+		} else {
+			chunks = append(chunks, Chunk{Index: len(chunks), Start: start, End: end})
+		}
+		start = end
+	}
+	return chunks
+}
+
+// hasWord reports whether text has a letter or a digit, which the embedders
+// need to embed it.
+func hasWord(text string) bool {
+	return strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0
+}
+
+// chunkEnd returns where the first chunk of text ends.
+func chunkEnd(text string, size int) int {
+	limit := min(size, len(text))
+	if i := strings.Index(text[:limit], "\n\n"); i > 0 {
+		return skipSpace(text, i)
+	}
+	if len(text) <= size {
+		return len(text)
+	}
+
+	sentence, word := 0, 0
+	for i := 1; i <= limit; i++ {
+		if !unicode.IsSpace(rune(text[i])) || unicode.IsSpace(rune(text[i-1])) {
+			continue
+		}
+		word = i
+		if strings.ContainsRune(".!?", rune(text[i-1])) {
+			sentence = i
+		}
+	}
+	switch {
+	case sentence > 0:
+		return skipSpace(text, sentence)
+	case word > 0:
+		return skipSpace(text, word)
+	}
+	// One word longer than a chunk: cut it, but not inside a character
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	if limit == 0 {
+		_, limit = utf8.DecodeRuneInString(text)
+	}
+	return limit
+}
+
+// skipSpace returns the index of the first non-space byte of text from i.
+func skipSpace(text string, i int) int {
+	for i < len(text) && unicode.IsSpace(rune(text[i])) {
+		i++
+	}
+	return i
+}
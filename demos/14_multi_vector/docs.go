@@ -0,0 +1,56 @@
+package main
+
+import "strings"
+
+// handbook returns long documents, several paragraphs each on different
+// things, the kind a single embedding can't do justice.
+func handbook() []ParentDocument {
+	return []ParentDocument{
+		doc("runbook", "Operations Runbook", "ops",
+			"This runbook is the first stop for anyone on call. It covers deploying, rolling back and scaling the platform, and who to call when none of that works.",
+			"Deploys go out through the release pipeline only. Merge to main, wait for the canary to report healthy for ten minutes, then promote the release to every region. Never deploy on a Friday afternoon.",
+			"To roll back, promote the previous release from the pipeline's history. Rollbacks skip the canary. Database migrations are not rolled back automatically, so check the release notes for a down migration first.",
+			"The platform scales on CPU. If queues grow while CPU stays low, the workers are waiting on the database, and adding more of them makes it worse: raise the connection pool limit instead.",
+			"Escalation: page the secondary on-call after fifteen minutes without progress, and the engineering manager after thirty. Customer-facing outages are announced on the status page within ten minutes.",
+			"Database credentials are rotated every ninety days by the secrets job. After a rotation, restart the API servers so their connection pools pick up the new password; stale pools fail with authentication errors.",
+		),
+		doc("onboarding", "Engineering Onboarding", "people",
+			"Welcome! Your first week is about getting set up, meeting the team and shipping something small.",
+			"Your laptop arrives with disk encryption enabled. Install the development tools with the bootstrap script, and ask in the help channel if anything fails rather than working around it.",
+			"Request access to the source repositories and the staging environment through the access portal. Production access comes after your first month, with the on-call training.",
+			"Every change is reviewed by one teammate. Keep pull requests small, describe why as well as what, and don't merge your own change without an approval.",
+			"In your third week you shadow the on-call engineer for a day. You won't be paged, but you'll see how incidents are handled and where the runbook lives.",
+			"Expenses for books, courses and conferences are covered up to the yearly learning budget. Submit receipts through the finance tool within a month.",
+		),
+		doc("payments-api", "Payments API Guide", "api",
+			"The Payments API creates charges, refunds them and reports their status. All requests and responses are JSON over HTTPS.",
+			"Authenticate with a secret API key in the Authorization header. Keys are scoped to one account and can be rolled from the dashboard; a rolled key keeps working for twenty-four hours.",
+			"Send an Idempotency-Key header with every POST. Retrying a request with the same key returns the original response instead of charging the customer twice.",
+			"Webhooks notify you when a charge succeeds, fails or is refunded. Verify the signature header on every webhook before trusting its contents, and answer within five seconds.",
+			"List endpoints are paginated with cursors. Pass the next_cursor from one page to get the next; cursors expire after an hour.",
+			"Requests over the rate limit of one hundred per second get a 429 response with a Retry-After header. Back off and retry; other 4xx errors should not be retried.",
+		),
+		doc("postmortem", "Postmortem: Checkout Outage", "incident",
+			"On March 3rd checkout failed for forty minutes. About twelve percent of orders in that window were lost.",
+			"At 14:02 the secrets job rotated the database credentials as scheduled. The API servers kept their old connection pools, and as connections were recycled they failed to authenticate.",
+			"Alerts fired at 14:06 for elevated checkout errors. The on-call engineer suspected the morning's deploy and rolled it back, which changed nothing.",
+			"The secondary on-call was paged at 14:31, later than the runbook's fifteen minutes, and recognized the authentication errors. Restarting the API servers restored checkout at 14:42.",
+			"Action items: restart API servers automatically after every credential rotation, alert on database authentication failures, and practice escalating earlier in on-call training.",
+		),
+		doc("security", "Security Policy", "policy",
+			"This policy applies to every employee and contractor with access to company systems.",
+			"Use a password manager and a unique password for every service. Multi-factor authentication is required everywhere it is offered, and hardware keys are required for production access.",
+			"Secrets never go into source code or chat. Store them in the secrets manager, where they are rotated on a schedule: database credentials every ninety days, API keys every year.",
+			"Laptops must have disk encryption and automatic updates enabled, and lock after five minutes idle. Report a lost or stolen laptop to security immediately.",
+			"Report suspected security incidents to the security team's pager, day or night. Don't investigate on your own: preserving evidence matters more than a quick answer.",
+		),
+	}
+}
+
+func doc(id, title, category string, paragraphs ...string) ParentDocument {
+	return ParentDocument{
+		ID:       id,
+		Content:  strings.Join(paragraphs, "\n\n"),
+		Metadata: map[string]string{"title": title, "category": category},
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// embeddingDimension is the dimension of the hashing embedder.
+const embeddingDimension = 512
+
+// newHashingEmbedder returns a local stand-in for an embedding model: words
+// and their character trigrams are feature-hashed into 512 dimensions. Like a
+// real model it reads at most contextLimit bytes of a text and ignores the
+// rest, which is what makes long documents a problem; 0 means no limit.
+func newHashingEmbedder(contextLimit int) chromem.EmbeddingFunc {
+	return func(_ context.Context, text string) ([]float32, error) {
+		if contextLimit > 0 && len(text) > contextLimit {
+			text = text[:contextLimit]
+		}
+		vec := make([]float32, embeddingDimension)
+		for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			addFeature(vec, "w:"+word, 1)
+			padded := "^" + word + "$"
+			for i := 0; i+3 <= len(padded); i++ {
+				addFeature(vec, "t:"+padded[i:i+3], 0.5)
+			}
+		}
+
+		var sum float64
+		for _, v := range vec {
+			sum += float64(v) * float64(v)
+		}
+		if sum == 0 {
+			return nil, fmt.Errorf("text %q has no words to embed", text)
+		}
+		norm := float32(math.Sqrt(sum))
+		for i := range vec {
+			vec[i] /= norm
+		}
+		return vec, nil
+	}
+}
+
+// addFeature adds weight to the dimension the feature hashes to. A second bit
+// of the hash picks the sign, so collisions cancel out instead of piling up.
+func addFeature(vec []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum&1 == 1 {
+		weight = -weight
+	}
+	vec[(sum>>1)%uint64(len(vec))] += weight
+}
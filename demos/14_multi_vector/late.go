@@ -0,0 +1,93 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// MaxSim is ColBERT's late-interaction score: every query token finds its
+// most similar document token, and their similarities are summed. It returns
+// the score and, for each query token, the index of the document token it
+// matched.
+func MaxSim(query, doc TokenBag) (float32, []int) {
+	var score float32
+	matches := make([]int, query.Len())
+	for i := range query.Len() {
+		q := query.Vector(i)
+		best := float32(-1)
+		for j := range doc.Len() {
+			d := doc.Vector(j)
+			var dot float32
+			for k := range q {
+				dot += q[k] * d[k]
+			}
+			if dot > best {
+				best, matches[i] = dot, j
+			}
+		}
+		score += best
+	}
+	return score, matches
+}
+
+// LateInteraction searches in two stages: chromem-go finds the Candidates
+// best documents by their single vectors, then MaxSim over their token
+// vectors re-ranks them. Only the candidates' token vectors are compared, so
+// the second stage costs the same however large the collection is - but a
+// document the first stage misses can't be found.
+type LateInteraction struct {
+	Embed       chromem.EmbeddingFunc // the collection's embedding function
+	EmbedTokens TokenEmbeddingFunc    // the function the store's bags came from
+	Tokens      *TokenStore
+	Candidates  int
+}
+
+// LateResult is a re-ranked result. The Result's Similarity is still its
+// single-vector score.
+type LateResult struct {
+	chromem.Result
+	Rank   int     // in the single-vector results, from 1
+	MaxSim float32 // divided by the number of query tokens, so 1 is a perfect match
+	// Matches pairs each query token with the document token it matched.
+	Matches [][2]string
+}
+
+// Search returns the n best documents for query by MaxSim, out of the
+// Candidates best by cosine similarity that pass where.
+func (l *LateInteraction) Search(ctx context.Context, collection *chromem.Collection, query string, n int, where map[string]string) ([]LateResult, error) {
+	vec, err := l.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't embed query: %w", err)
+	}
+	q, err := l.EmbedTokens(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't embed query tokens: %w", err)
+	}
+	if collection.Count() == 0 {
+		return nil, nil
+	}
+	candidates, err := collection.QueryEmbedding(ctx, vec, min(max(l.Candidates, n), collection.Count()), where, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]LateResult, len(candidates))
+	for i, c := range candidates {
+		bag, ok := l.Tokens.Get(c.ID)
+		if !ok {
+			return nil, fmt.Errorf("no token vectors for %s: add them whenever the document is added", c.ID)
+		}
+		score, matches := MaxSim(q, bag)
+		r := LateResult{Result: c, Rank: i + 1, MaxSim: score / float32(q.Len())}
+		for qi, di := range matches {
+			r.Matches = append(r.Matches, [2]string{q.Tokens[qi], bag.Tokens[di]})
+		}
+		results[i] = r
+	}
+	slices.SortStableFunc(results, func(a, b LateResult) int { return cmp.Compare(b.MaxSim, a.MaxSim) })
+	return results[:min(n, len(results))], nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func main() {
+	chunkSize := flag.Int("chunk", 240, "chunk size in bytes")
+	contextLimit := flag.Int("context", 256, "bytes of a text the embedder reads, like a model's context window")
+	best := flag.Int("best", 2, "best chunks to show per parent")
+	candidates := flag.Int("candidates", 10, "single-vector results late interaction re-ranks")
+	aggName := flag.String("agg", "max", "how chunk scores roll up to the parent: max, mean or topN")
+	flag.Parse()
+	if *chunkSize <= 0 || *contextLimit <= 0 || *best <= 0 || *candidates <= 0 {
+		fmt.Fprintln(os.Stderr, "error: -chunk, -context, -best and -candidates must be positive")
+		os.Exit(2)
+	}
+	agg, err := ParseAggregation(*aggName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+
+	fmt.Println("🧩 Multi-Vector Documents Demo - One Document, Many Embeddings")
+	fmt.Println("=============================================================")
+
+	ctx := context.Background()
+	embed := newHashingEmbedder(*contextLimit)
+	docs := handbook()
+
+	// 1. Split every document into chunks that fit the embedder
+	fmt.Printf("\n✂️  1. Chunking %d documents into ~%d-byte chunks (the embedder reads %d bytes)...\n", len(docs), *chunkSize, *contextLimit)
+	if *chunkSize > *contextLimit {
+		fmt.Println("   ⚠️  Chunks are longer than the embedder reads, so their ends won't be embedded")
+	}
+	for _, d := range docs {
+		fmt.Printf("   %-13s %5d bytes → %d chunks\n", d.ID, len(d.Content), len(ChunkText(d.Content, *chunkSize)))
+	}
+
+	db := chromem.NewDB()
+	whole, err := db.CreateCollection("whole", nil, embed)
+	if err != nil {
+		panic(err)
+	}
+	for _, d := range docs {
+		if err := whole.AddDocument(ctx, chromem.Document{ID: d.ID, Content: d.Content, Metadata: d.Metadata}); err != nil {
+			panic(err)
+		}
+	}
+	chunks, err := db.CreateCollection("chunks", nil, embed)
+	if err != nil {
+		panic(err)
+	}
+	parents := NewParentCollection(chunks, embed, *chunkSize)
+	if err := parents.AddDocuments(ctx, docs, runtime.NumCPU()); err != nil {
+		panic(err)
+	}
+
+	query := "restart servers after rotating database credentials"
+
+	// 2. One embedding per document sees only its beginning
+	fmt.Printf("\n📄 2. One vector per document: %q\n", query)
+	results, err := whole.Query(ctx, query, 3, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	for i, r := range results {
+		fmt.Printf("   %d. [%s] %.4f  %s\n", i+1, r.ID, r.Similarity, r.Metadata["title"])
+	}
+	fmt.Printf("   The runbook's answer is in its last paragraph, past byte %d: its vector never saw it\n", *contextLimit)
+
+	// 3. Chunks on their own are fragments
+	fmt.Printf("\n🧱 3. One vector per chunk, searched as documents: %q\n", query)
+	results, err = chunks.Query(ctx, query, 5, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	for i, r := range results {
+		fmt.Printf("   %d. [%s] %.4f  %s\n", i+1, r.ID, r.Similarity, preview(r.Content, 60))
+	}
+	fmt.Println("   The right paragraphs, but the same document twice and no idea what the rest says")
+
+	// 4. Chunks rolled up into their parents
+	fmt.Printf("\n🧩 4. Chunks rolled up to parents by %s: %q\n", agg.Name(), query)
+	printParents(ctx, parents, query, SearchOptions{Aggregation: agg, BestChunks: *best})
+
+	// 5. The aggregation decides what "relevant" means
+	query = "database connection"
+	aggs := []Aggregation{Max{}, Mean{}, TopNSum{N: 2}}
+	fmt.Printf("\n⚖️  5. Ranking parents for %q by each aggregation\n", query)
+	fmt.Printf("   %-6s", "")
+	for _, a := range aggs {
+		fmt.Printf(" %-24s", a.Name())
+	}
+	fmt.Println()
+	ranked := make([][]ParentResult, len(aggs))
+	for i, a := range aggs {
+		if ranked[i], err = parents.Search(ctx, query, 3, SearchOptions{Aggregation: a}); err != nil {
+			panic(err)
+		}
+	}
+	for rank := range 3 {
+		fmt.Printf("   %-6d", rank+1)
+		for i := range aggs {
+			r := ranked[i][rank]
+			fmt.Printf(" %-24s", fmt.Sprintf("%s %.4f", r.ID, r.Score))
+		}
+		fmt.Println()
+	}
+	fmt.Println("   Max picks the postmortem for its one paragraph on connection pools; mean and top-2")
+	fmt.Println("   pick the runbook, which comes back to the database in several")
+
+	// 6. Token vectors re-rank the best chunks
+	fmt.Println("\n🎯 6. Late interaction: MaxSim over token vectors re-ranks the top chunks")
+	tokens := NewTokenStore(tokenDimension)
+	embedTokens := newHashingTokenEmbedder()
+	for _, d := range docs {
+		if err := tokens.AddDocuments(ctx, embedTokens, parents.Chunks(d), runtime.NumCPU()); err != nil {
+			panic(err)
+		}
+	}
+	late := &LateInteraction{Embed: embed, EmbedTokens: embedTokens, Tokens: tokens, Candidates: *candidates}
+	query = "restart api servers"
+	fmt.Printf("   %q, MaxSim over the %d best by cosine similarity:\n", query, *candidates)
+	reranked, err := late.Search(ctx, chunks, query, 3, nil)
+	if err != nil {
+		panic(err)
+	}
+	for i, r := range reranked {
+		fmt.Printf("   %d. [%s] MaxSim %.4f (cosine %.4f, was #%d)  %s\n", i+1, r.ID, r.MaxSim, r.Similarity, r.Rank, preview(r.Content, 50))
+		var pairs []string
+		for _, m := range r.Matches {
+			pairs = append(pairs, m[0]+"→"+m[1])
+		}
+		fmt.Printf("      %s\n", strings.Join(pairs, " "))
+	}
+	fmt.Println("   Every query token found itself in the runbook, so its long paragraph no longer dilutes the match")
+	nDocs, nTokens, tokenBytes := tokens.Stats()
+	fmt.Printf("   Memory: %d chunk vectors take %.1f KB; their %d token vectors take %.1f KB, %.0f× as much\n",
+		chunks.Count(), float64(chunks.Count()*embeddingDimension*4)/1024, nTokens, float64(tokenBytes)/1024,
+		float64(tokenBytes)/float64(nDocs*embeddingDimension*4))
+	tokenPath := filepath.Join(os.TempDir(), "multi-vector-tokens.gob")
+	if err := tokens.Save(tokenPath); err != nil {
+		panic(err)
+	}
+	defer os.Remove(tokenPath)
+	reloaded, err := LoadTokenStore(tokenPath)
+	if err != nil {
+		panic(err)
+	}
+	_, reloadedTokens, _ := reloaded.Stats()
+	fmt.Printf("   Saved to %s and reloaded: %d token vectors\n", filepath.Base(tokenPath), reloadedTokens)
+
+	// 7. Updating a parent replaces all of its chunks
+	fmt.Println("\n✏️  7. Updating the postmortem as a unit...")
+	before := chunks.Count()
+	updated := handbook()[3]
+	updated.Content = "On March 3rd checkout failed for forty minutes after a credential rotation. All action items are done: API servers restart after every rotation."
+	if err := parents.AddDocuments(ctx, []ParentDocument{updated}, runtime.NumCPU()); err != nil {
+		panic(err)
+	}
+	got, err := parents.Get(ctx, "postmortem")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   Chunks in the collection: %d → %d, none left over from the old version\n", before, chunks.Count())
+	fmt.Printf("   Get puts it back together from its chunks, byte for byte: %v\n", got.Content == updated.Content)
+	fmt.Printf("   postmortem now reads: %s\n", preview(got.Content, 80))
+
+	// 8. Deleting a parent deletes all of its chunks
+	fmt.Println("\n🗑️  8. Deleting the onboarding guide...")
+	before = chunks.Count()
+	if err := parents.Delete(ctx, "onboarding"); err != nil {
+		panic(err)
+	}
+	fmt.Printf("   Chunks in the collection: %d → %d\n", before, chunks.Count())
+	if _, err := parents.Get(ctx, "onboarding"); err != nil {
+		fmt.Printf("   Get: %v\n", err)
+	}
+	query = "shadow the on-call engineer in your first weeks"
+	fmt.Printf("   %q now finds:\n", query)
+	printParents(ctx, parents, query, SearchOptions{Aggregation: agg, BestChunks: 1})
+
+	fmt.Println("\n🎯 Key Insights:")
+	fmt.Println("   • A model reads a few hundred tokens; one vector per long document ignores the rest")
+	fmt.Println("   • Chunks find the right paragraph, but a result should be the document it came from")
+	fmt.Println("   • Max finds documents that answer somewhere, mean ones that are about the query throughout")
+	fmt.Println("   • Late interaction matches token by token, for several times the memory of one vector")
+	fmt.Println("   • Parents are added, replaced and deleted whole, so no search sees half of one")
+	fmt.Println("\n💡 Try: go run . -agg mean -chunk 120")
+}
+
+// printParents searches parents and prints each result with its best chunks.
+func printParents(ctx context.Context, parents *ParentCollection, query string, opts SearchOptions) {
+	results, err := parents.Search(ctx, query, 3, opts)
+	if err != nil {
+		panic(err)
+	}
+	for i, r := range results {
+		fmt.Printf("   %d. [%s] %.4f  %s (%d chunks, %d bytes)\n", i+1, r.ID, r.Score, r.Metadata["title"], r.Chunks, len(r.Content))
+		for _, c := range r.Best {
+			fmt.Printf("      #%d %.4f at %d-%d: %s\n", c.Index, c.Similarity, c.Start, c.End, preview(c.Content, 60))
+		}
+	}
+}
+
+// preview returns the first n bytes of text on one line.
+func preview(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) <= n {
+		return text
+	}
+	for n > 0 && text[n] >= 0x80 && text[n] < 0xC0 {
+		n--
+	}
+	return text[:n] + "..."
+}
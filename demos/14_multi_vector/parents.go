@@ -0,0 +1,301 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Metadata keys ParentCollection sets on every chunk. The parent's own
+// metadata is copied next to them, so where filters on it work on chunks.
+const (
+	metaParent = "parent"
+	metaChunk  = "chunk"  // the chunk's index in the parent
+	metaChunks = "chunks" // how many chunks the parent has
+	metaStart  = "start"  // byte offsets of the chunk in the parent
+	metaEnd    = "end"
+)
+
+var reservedKeys = []string{metaParent, metaChunk, metaChunks, metaStart, metaEnd}
+
+// ErrNotFound is returned for a parent the collection doesn't have.
+var ErrNotFound = errors.New("parent not found")
+
+// ParentDocument is a logical document, as long as it needs to be.
+type ParentDocument struct {
+	ID       string
+	Content  string
+	Metadata map[string]string
+}
+
+// ParentCollection stores long documents in a chromem-go collection as one
+// document per chunk, each with its own embedding, and searches, adds and
+// deletes them as whole parents. Every chunk's ID is the parent's ID, "#" and
+// the chunk's index.
+//
+// chromem-go locks per chunk, so the collection holds its own lock around
+// whole parents: a search never sees half of an update.
+type ParentCollection struct {
+	Collection *chromem.Collection
+	Embed      chromem.EmbeddingFunc // the collection's embedding function
+	ChunkSize  int                   // in bytes
+
+	mu sync.RWMutex
+}
+
+// NewParentCollection stores parents in collection, which embeds with embed,
+// in chunks of about chunkSize bytes.
+func NewParentCollection(collection *chromem.Collection, embed chromem.EmbeddingFunc, chunkSize int) *ParentCollection {
+	return &ParentCollection{Collection: collection, Embed: embed, ChunkSize: chunkSize}
+}
+
+func chunkID(parent string, index int) string {
+	return parent + "#" + strconv.Itoa(index)
+}
+
+// AddDocuments adds parents, replacing any with the same ID as a whole:
+// their old chunks are deleted, however many there were. The chunks are
+// embedded before anything changes, so a failing embedder leaves every
+// parent as it was.
+func (p *ParentCollection) AddDocuments(ctx context.Context, docs []ParentDocument, concurrency int) error {
+	var chunks []chromem.Document
+	counts := make(map[string]int, len(docs))
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return errors.New("parent ID is empty")
+		}
+		if _, ok := counts[doc.ID]; ok {
+			return fmt.Errorf("parent %s is added twice", doc.ID)
+		}
+		for _, key := range reservedKeys {
+			if _, ok := doc.Metadata[key]; ok {
+				return fmt.Errorf("parent %s: metadata key %q is reserved for chunks", doc.ID, key)
+			}
+		}
+		if strings.TrimSpace(doc.Content) == "" {
+			return fmt.Errorf("parent %s has no content", doc.ID)
+		}
+		docChunks := p.Chunks(doc)
+		counts[doc.ID] = len(docChunks)
+		chunks = append(chunks, docChunks...)
+	}
+	if err := p.embedChunks(ctx, chunks, concurrency); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var stale []string
+	for id, n := range counts {
+		old, err := p.chunkCount(ctx, id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		for i := n; i < old; i++ {
+			stale = append(stale, chunkID(id, i))
+		}
+	}
+	if err := p.Collection.AddDocuments(ctx, chunks, concurrency); err != nil {
+		return err
+	}
+	if len(stale) > 0 {
+		return p.Collection.Delete(ctx, nil, nil, stale...)
+	}
+	return nil
+}
+
+// Chunks returns the chunks a parent is stored as, without embeddings.
+func (p *ParentCollection) Chunks(doc ParentDocument) []chromem.Document {
+	parts := ChunkText(doc.Content, p.ChunkSize)
+	chunks := make([]chromem.Document, len(parts))
+	for i, c := range parts {
+		m := maps.Clone(doc.Metadata)
+		if m == nil {
+			m = make(map[string]string)
+		}
+		m[metaParent] = doc.ID
+		m[metaChunk] = strconv.Itoa(c.Index)
+		m[metaChunks] = strconv.Itoa(len(parts))
+		m[metaStart] = strconv.Itoa(c.Start)
+		m[metaEnd] = strconv.Itoa(c.End)
+		chunks[i] = chromem.Document{ID: chunkID(doc.ID, c.Index), Metadata: m, Content: doc.Content[c.Start:c.End]}
+	}
+	return chunks
+}
+
+// embedChunks fills in the embeddings of chunks, concurrency at a time.
+func (p *ParentCollection) embedChunks(ctx context.Context, chunks []chromem.Document, concurrency int) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i := range chunks {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if ctx.Err() != nil {
+				return
+			}
+			v, err := p.Embed(ctx, chunks[i].Content)
+			if err != nil {
+				cancel(fmt.Errorf("couldn't embed %s: %w", chunks[i].ID, err))
+				return
+			}
+			chunks[i].Embedding = v
+		}()
+	}
+	wg.Wait()
+	return context.Cause(ctx)
+}
+
+// chunkCount returns how many chunks a parent has, from its first chunk.
+func (p *ParentCollection) chunkCount(ctx context.Context, id string) (int, error) {
+	first, err := p.Collection.GetByID(ctx, chunkID(id, 0))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return strconv.Atoi(first.Metadata[metaChunks])
+}
+
+// Delete deletes parents with all of their chunks.
+func (p *ParentCollection) Delete(ctx context.Context, ids ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, id := range ids {
+		if err := p.Collection.Delete(ctx, map[string]string{metaParent: id}, nil); err != nil {
+			return fmt.Errorf("couldn't delete %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// Get returns a parent put back together from its chunks.
+func (p *ParentCollection) Get(ctx context.Context, id string) (ParentDocument, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	n, err := p.chunkCount(ctx, id)
+	if err != nil {
+		return ParentDocument{}, err
+	}
+	doc := ParentDocument{ID: id}
+	var sb strings.Builder
+	for i := range n {
+		c, err := p.Collection.GetByID(ctx, chunkID(id, i))
+		if err != nil {
+			return ParentDocument{}, fmt.Errorf("parent %s is missing chunk %d: %w", id, i, err)
+		}
+		sb.WriteString(c.Content)
+		doc.Metadata = parentMetadata(c.Metadata)
+	}
+	doc.Content = sb.String()
+	return doc, nil
+}
+
+// parentMetadata returns a chunk's metadata without the keys it has as a
+// chunk.
+func parentMetadata(m map[string]string) map[string]string {
+	out := maps.Clone(m)
+	for _, key := range reservedKeys {
+		delete(out, key)
+	}
+	return out
+}
+
+// SearchOptions configure ParentCollection.Search.
+type SearchOptions struct {
+	Aggregation Aggregation       // Max if nil
+	BestChunks  int               // chunks to return per parent, 1 if 0
+	Where       map[string]string // on the parents' metadata
+}
+
+// ParentResult is a parent that matched a query.
+type ParentResult struct {
+	ID       string
+	Content  string
+	Metadata map[string]string
+	Score    float32 // the chunk similarities, aggregated
+	Chunks   int
+	Best     []ChunkMatch // best first
+}
+
+// ChunkMatch is one chunk of a result and its similarity to the query.
+type ChunkMatch struct {
+	Index      int
+	Start, End int // in the parent's content
+	Content    string
+	Similarity float32
+}
+
+// Search returns the n parents that score best for query. Every chunk is
+// compared with the query - chromem-go always scans exhaustively - so each
+// parent is scored by all of its chunks, not just the ones that made some
+// cut-off.
+func (p *ParentCollection) Search(ctx context.Context, query string, n int, opts SearchOptions) ([]ParentResult, error) {
+	agg := opts.Aggregation
+	if agg == nil {
+		agg = Max{}
+	}
+	best := cmp.Or(opts.BestChunks, 1)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.Collection.Count() == 0 {
+		return nil, nil
+	}
+	chunks, err := p.Collection.Query(ctx, query, p.Collection.Count(), opts.Where, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Results come best first, so each parent's matches do too
+	byParent := make(map[string][]chromem.Result)
+	var order []string
+	for _, c := range chunks {
+		id := c.Metadata[metaParent]
+		if _, ok := byParent[id]; !ok {
+			order = append(order, id)
+		}
+		byParent[id] = append(byParent[id], c)
+	}
+
+	results := make([]ParentResult, 0, len(order))
+	for _, id := range order {
+		matches := byParent[id]
+		similarities := make([]float32, len(matches))
+		for i, m := range matches {
+			similarities[i] = m.Similarity
+		}
+		r := ParentResult{ID: id, Metadata: parentMetadata(matches[0].Metadata), Score: agg.Score(similarities), Chunks: len(matches)}
+		for _, m := range matches[:min(best, len(matches))] {
+			r.Best = append(r.Best, chunkMatch(m))
+		}
+		// Put the whole parent back together, in chunk order
+		slices.SortFunc(matches, func(a, b chromem.Result) int {
+			return cmp.Compare(chunkMatch(a).Index, chunkMatch(b).Index)
+		})
+		var sb strings.Builder
+		for _, m := range matches {
+			sb.WriteString(m.Content)
+		}
+		r.Content = sb.String()
+		results = append(results, r)
+	}
+	slices.SortStableFunc(results, func(a, b ParentResult) int { return cmp.Compare(b.Score, a.Score) })
+	return results[:min(n, len(results))], nil
+}
+
+func chunkMatch(r chromem.Result) ChunkMatch {
+	index, _ := strconv.Atoi(r.Metadata[metaChunk])
+	start, _ := strconv.Atoi(r.Metadata[metaStart])
+	end, _ := strconv.Atoi(r.Metadata[metaEnd])
+	return ChunkMatch{Index: index, Start: start, End: end, Content: r.Content, Similarity: r.Similarity}
+}
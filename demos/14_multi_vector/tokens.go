@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// tokenDimension is the dimension of the hashing token embedder, ColBERT's.
+const tokenDimension = 128
+
+// TokenBag is a text's token vectors, one row of Dimension floats per token,
+// in one slice so a bag is one allocation however many tokens it has.
+type TokenBag struct {
+	Tokens  []string
+	Vectors []float32
+}
+
+// Len returns the number of tokens.
+func (b TokenBag) Len() int { return len(b.Tokens) }
+
+// Vector returns the i-th token's vector.
+func (b TokenBag) Vector(i int) []float32 {
+	dim := len(b.Vectors) / len(b.Tokens)
+	return b.Vectors[i*dim : (i+1)*dim]
+}
+
+// TokenEmbeddingFunc embeds each token of a text, ColBERT style: every
+// vector is normalized and depends on the token's neighbours as well as the
+// token.
+type TokenEmbeddingFunc func(ctx context.Context, text string) (TokenBag, error)
+
+// TokenStore keeps a bag of token vectors per document, next to a chromem-go
+// collection that holds one vector per document under the same IDs.
+// chromem-go has nowhere to put them: Document has one Embedding.
+type TokenStore struct {
+	Dimension int
+
+	mu   sync.RWMutex
+	bags map[string]TokenBag
+}
+
+// NewTokenStore returns an empty store for vectors of dim dimensions.
+func NewTokenStore(dim int) *TokenStore {
+	return &TokenStore{Dimension: dim, bags: make(map[string]TokenBag)}
+}
+
+// Set stores the token vectors of a document, replacing any it had.
+func (s *TokenStore) Set(id string, bag TokenBag) error {
+	if bag.Len() == 0 {
+		return fmt.Errorf("token vectors of %s: no tokens", id)
+	}
+	if len(bag.Vectors) != bag.Len()*s.Dimension {
+		return fmt.Errorf("token vectors of %s: %d floats for %d tokens of %d dimensions", id, len(bag.Vectors), bag.Len(), s.Dimension)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bags[id] = bag
+	return nil
+}
+
+// Get returns the token vectors of a document.
+func (s *TokenStore) Get(id string) (TokenBag, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	bag, ok := s.bags[id]
+	return bag, ok
+}
+
+// Delete deletes the token vectors of documents.
+func (s *TokenStore) Delete(ids ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.bags, id)
+	}
+}
+
+// AddDocuments embeds the tokens of docs, concurrency at a time, and stores
+// them under the documents' IDs. Add the same documents to the collection.
+func (s *TokenStore) AddDocuments(ctx context.Context, embed TokenEmbeddingFunc, docs []chromem.Document, concurrency int) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for _, doc := range docs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if ctx.Err() != nil {
+				return
+			}
+			bag, err := embed(ctx, doc.Content)
+			if err == nil {
+				err = s.Set(doc.ID, bag)
+			}
+			if err != nil {
+				cancel(fmt.Errorf("couldn't embed the tokens of %s: %w", doc.ID, err))
+			}
+		}()
+	}
+	wg.Wait()
+	return context.Cause(ctx)
+}
+
+// Stats returns how many documents and token vectors the store holds, and
+// the bytes their floats take.
+func (s *TokenStore) Stats() (docs, tokens, bytes int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, bag := range s.bags {
+		tokens += bag.Len()
+		bytes += 4 * len(bag.Vectors)
+	}
+	return len(s.bags), tokens, bytes
+}
+
+// tokenFile is what Save writes.
+type tokenFile struct {
+	Dimension int
+	Bags      map[string]TokenBag
+}
+
+// Save writes the store to path, through a temporary file and a rename so a
+// crash leaves the old file or the new one.
+func (s *TokenStore) Save(path string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("couldn't save token vectors: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := gob.NewEncoder(tmp).Encode(tokenFile{s.Dimension, s.bags}); err != nil {
+		tmp.Close()
+		return fmt.Errorf("couldn't encode token vectors: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("couldn't save token vectors: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadTokenStore reads a store written by Save.
+func LoadTokenStore(path string) (*TokenStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load token vectors: %w", err)
+	}
+	defer f.Close()
+	var tf tokenFile
+	if err := gob.NewDecoder(f).Decode(&tf); err != nil {
+		return nil, fmt.Errorf("couldn't decode token vectors: %w", err)
+	}
+	return &TokenStore{Dimension: tf.Dimension, bags: tf.Bags}, nil
+}
+
+// tokenStopWords get no vectors: they'd match a stop word anywhere. A text
+// of nothing else, like a chunk "the ", keeps them rather than have none.
+var tokenStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "in": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+	"so": true, "the": true, "their": true, "to": true, "with": true,
+}
+
+// newHashingTokenEmbedder returns a stand-in for a ColBERT model: each word
+// gets the hashed features of itself and its trigrams, as the document
+// embedder does, plus half-weight features of the words either side of it.
+// That's its context, so "api keys" and "database keys" give "keys"
+// different vectors.
+func newHashingTokenEmbedder() TokenEmbeddingFunc {
+	return func(_ context.Context, text string) (TokenBag, error) {
+		all := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		var words []string
+		for _, w := range all {
+			if !tokenStopWords[w] {
+				words = append(words, w)
+			}
+		}
+		if len(words) == 0 {
+			words = all
+		}
+		if len(words) == 0 {
+			return TokenBag{}, fmt.Errorf("text %q has no tokens to embed", text)
+		}
+
+		bag := TokenBag{Tokens: words, Vectors: make([]float32, len(words)*tokenDimension)}
+		for i, word := range words {
+			vec := bag.Vector(i)
+			addFeature(vec, "w:"+word, 1)
+			padded := "^" + word + "$"
+			for j := 0; j+3 <= len(padded); j++ {
+				addFeature(vec, "t:"+padded[j:j+3], 0.5)
+			}
+			if i > 0 {
+				addFeature(vec, "n:"+words[i-1], 0.5)
+			}
+			if i+1 < len(words) {
+				addFeature(vec, "n:"+words[i+1], 0.5)
+			}
+			var sum float64
+			for _, v := range vec {
+				sum += float64(v) * float64(v)
+			}
+			norm := float32(math.Sqrt(sum))
+			for j := range vec {
+				vec[j] /= norm
+			}
+		}
+		return bag, nil
+	}
+}
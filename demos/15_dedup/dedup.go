@@ -0,0 +1,436 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Policy is what a Deduper does with a document that duplicates one the
+// collection already has.
+type Policy int
+
+const (
+	Skip          Policy = iota // keep the document there, drop the new one
+	MergeMetadata               // keep the document there, with the new one's metadata added
+	KeepNewest                  // keep whichever is newer by DedupConfig.TimeKey
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Skip:
+		return "skip"
+	case MergeMetadata:
+		return "merge"
+	case KeepNewest:
+		return "newest"
+	}
+	return fmt.Sprintf("Policy(%d)", int(p))
+}
+
+// ParsePolicy parses "skip", "merge" or "newest".
+func ParsePolicy(s string) (Policy, error) {
+	for _, p := range []Policy{Skip, MergeMetadata, KeepNewest} {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown policy %q, want skip, merge or newest", s)
+}
+
+// DuplicatesKey is the metadata key MergeMetadata lists the IDs of merged
+// documents under, comma separated.
+const DuplicatesKey = "duplicates"
+
+// DedupConfig configures a Deduper. Two documents are duplicates if any of
+// the three tests says so; each catches what the others miss.
+type DedupConfig struct {
+	Policy Policy
+	// TimeKey is the metadata key KeepNewest compares, an RFC 3339 time or
+	// a date. A document without one is older than any with one.
+	TimeKey string
+
+	// ShingleSize is the number of words per shingle. Defaults to 3.
+	ShingleSize int
+	// Bands and Rows cut the MinHash signature, Bands×Rows hashes long, for
+	// the LSH index. Defaults to 32 bands of 4, which makes pairs with a
+	// Jaccard similarity from about 0.4 candidates.
+	Bands, Rows int
+	// Jaccard is the estimated Jaccard similarity of the shingles at which
+	// two documents are duplicates: the same text with a few edits. Defaults
+	// to 0.7.
+	Jaccard float64
+	// Hamming is the SimHash distance in bits up to which two documents are
+	// duplicates: nearly the same words, in any order. Defaults to 3; a
+	// negative value turns the test off.
+	Hamming int
+	// Cosine is the similarity of the embeddings at which two documents are
+	// duplicates: the same content in other words, as far as the embedder
+	// can tell. Defaults to 0.95; a negative value turns the test off.
+	Cosine float32
+}
+
+func (c DedupConfig) withDefaults() DedupConfig {
+	if c.TimeKey == "" {
+		c.TimeKey = "updated"
+	}
+	if c.ShingleSize <= 0 {
+		c.ShingleSize = 3
+	}
+	if c.Bands <= 0 || c.Rows <= 0 {
+		c.Bands, c.Rows = 32, 4
+	}
+	if c.Jaccard <= 0 {
+		c.Jaccard = 0.7
+	}
+	if c.Hamming == 0 {
+		c.Hamming = 3
+	}
+	if c.Cosine == 0 {
+		c.Cosine = 0.95
+	}
+	return c
+}
+
+// Match is how similar a document is to another, and which tests say they
+// are duplicates.
+type Match struct {
+	ID, Of  string
+	Jaccard float64 // estimated from MinHash signatures
+	Hamming int     // between SimHash fingerprints
+	Cosine  float32 // between embeddings
+	Signals []string
+}
+
+// Action is what AddDocuments did with a document.
+type Action string
+
+const (
+	Added    Action = "added"    // not a duplicate
+	Skipped  Action = "skipped"  // a duplicate, dropped
+	Merged   Action = "merged"   // a duplicate, its metadata added to the one kept
+	Replaced Action = "replaced" // a newer duplicate, which took the old one's place
+)
+
+// Decision is what AddDocuments did with one document, and why.
+type Decision struct {
+	ID     string
+	Action Action
+	Match  *Match // the duplicate it was compared with, nil if Added
+}
+
+// Deduper adds documents to a collection unless they duplicate one it has.
+// It keeps each document's MinHash signature and SimHash fingerprint in
+// memory, in indexes that find the likely duplicates without comparing
+// every pair; the embeddings are compared by the collection itself.
+type Deduper struct {
+	Collection *chromem.Collection
+	Embed      chromem.EmbeddingFunc
+
+	cfg    DedupConfig
+	hasher *MinHasher
+
+	mu      sync.Mutex
+	entries map[string]fingerprint
+	lsh     *lshIndex
+	sim     *simIndex
+}
+
+type fingerprint struct {
+	minhash []uint64
+	simhash uint64
+}
+
+// NewDeduper returns a deduper for collection, which embeds with embed. Use
+// Index to tell it about the documents the collection already has.
+func NewDeduper(collection *chromem.Collection, embed chromem.EmbeddingFunc, cfg DedupConfig) *Deduper {
+	cfg = cfg.withDefaults()
+	return &Deduper{
+		Collection: collection,
+		Embed:      embed,
+		cfg:        cfg,
+		hasher:     NewMinHasher(cfg.Bands * cfg.Rows),
+		entries:    make(map[string]fingerprint),
+		lsh:        newLSHIndex(cfg.Bands, cfg.Rows),
+		sim:        newSimIndex(max(cfg.Hamming, 0)),
+	}
+}
+
+// Config returns the configuration with its defaults filled in.
+func (d *Deduper) Config() DedupConfig {
+	return d.cfg
+}
+
+// Index fingerprints documents already in the collection, so new documents
+// are checked against them too. It doesn't check them against each other;
+// FindDuplicates does.
+func (d *Deduper) Index(docs []chromem.Document) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, doc := range docs {
+		d.index(doc.ID, d.fingerprint(doc.Content))
+	}
+}
+
+func (d *Deduper) fingerprint(content string) fingerprint {
+	return fingerprint{
+		minhash: d.hasher.Signature(Shingles(content, d.cfg.ShingleSize)),
+		simhash: SimHash(content),
+	}
+}
+
+// index adds a fingerprint under id, replacing the one it had. The buckets
+// of the old one still list id; matches checks candidates against entries,
+// so that's harmless.
+func (d *Deduper) index(id string, fp fingerprint) {
+	d.entries[id] = fp
+	d.lsh.add(id, fp.minhash)
+	d.sim.add(id, fp.simhash)
+}
+
+// AddDocuments adds docs to the collection one at a time, in order, each
+// checked against the collection and the documents before it, and returns
+// what it did with each. A document with the ID of one already there
+// replaces it, like in chromem-go, and isn't its duplicate.
+//
+// The documents are embedded first, concurrency at a time, so a failing
+// embedder changes nothing. Each change is a chromem-go call of its own; on
+// a persistent DB, a crash can leave the changes up to any document made.
+func (d *Deduper) AddDocuments(ctx context.Context, docs []chromem.Document, concurrency int) ([]Decision, error) {
+	seen := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		if doc.ID == "" || doc.Content == "" {
+			return nil, errors.New("documents need an ID and content")
+		}
+		if seen[doc.ID] {
+			return nil, fmt.Errorf("document %s is in the batch twice", doc.ID)
+		}
+		seen[doc.ID] = true
+	}
+	docs = slices.Clone(docs)
+	if err := d.embed(ctx, docs, concurrency); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	decisions := make([]Decision, 0, len(docs))
+	for _, doc := range docs {
+		decision, err := d.add(ctx, doc)
+		if err != nil {
+			return decisions, err
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions, nil
+}
+
+// add decides what to do with one document and does it.
+func (d *Deduper) add(ctx context.Context, doc chromem.Document) (Decision, error) {
+	fp := d.fingerprint(doc.Content)
+	matches, err := d.matches(ctx, doc.ID, fp, doc.Embedding)
+	if err != nil {
+		return Decision{}, err
+	}
+	if len(matches) == 0 {
+		if err := d.Collection.AddDocument(ctx, doc); err != nil {
+			return Decision{}, fmt.Errorf("couldn't add %s: %w", doc.ID, err)
+		}
+		d.index(doc.ID, fp)
+		return Decision{ID: doc.ID, Action: Added}, nil
+	}
+
+	match := &matches[0]
+	kept, err := d.Collection.GetByID(ctx, match.Of)
+	if err != nil {
+		return Decision{}, fmt.Errorf("couldn't get %s: %w", match.Of, err)
+	}
+	switch d.cfg.Policy {
+	case MergeMetadata:
+		merged := mergeMetadata(kept.Metadata, doc.Metadata, doc.ID)
+		kept.Metadata = merged
+		if err := d.Collection.AddDocument(ctx, kept); err != nil {
+			return Decision{}, fmt.Errorf("couldn't update %s: %w", kept.ID, err)
+		}
+		return Decision{ID: doc.ID, Action: Merged, Match: match}, nil
+	case KeepNewest:
+		if !d.newer(doc, kept) {
+			return Decision{ID: doc.ID, Action: Skipped, Match: match}, nil
+		}
+		if err := d.Collection.AddDocument(ctx, doc); err != nil {
+			return Decision{}, fmt.Errorf("couldn't add %s: %w", doc.ID, err)
+		}
+		if err := d.Collection.Delete(ctx, nil, nil, kept.ID); err != nil {
+			return Decision{}, fmt.Errorf("couldn't delete %s: %w", kept.ID, err)
+		}
+		delete(d.entries, kept.ID)
+		d.index(doc.ID, fp)
+		return Decision{ID: doc.ID, Action: Replaced, Match: match}, nil
+	}
+	return Decision{ID: doc.ID, Action: Skipped, Match: match}, nil
+}
+
+// matches returns the indexed documents other than id that are duplicates
+// of a document with fingerprint fp and embedding vec, the most certain
+// first: the one most tests agree on, then the most similar.
+func (d *Deduper) matches(ctx context.Context, id string, fp fingerprint, vec []float32) ([]Match, error) {
+	found := make(map[string]*Match)
+	candidate := func(other string) *Match {
+		if m, ok := found[other]; ok {
+			return m
+		}
+		ofp, ok := d.entries[other]
+		if !ok || other == id {
+			return nil
+		}
+		m := &Match{ID: id, Of: other, Jaccard: EstimateJaccard(fp.minhash, ofp.minhash), Hamming: Hamming(fp.simhash, ofp.simhash), Cosine: -1}
+		found[other] = m
+		return m
+	}
+	for _, other := range d.lsh.candidates(fp.minhash) {
+		candidate(other)
+	}
+	if d.cfg.Hamming >= 0 {
+		for _, other := range d.sim.candidates(fp.simhash) {
+			candidate(other)
+		}
+	}
+	// chromem-go compares the embedding with every document's anyway, so ask
+	// for all of them: the nearest may be duplicates, and those the indexes
+	// found get their similarity.
+	if n := d.Collection.Count(); n > 0 && vec != nil {
+		results, err := d.Collection.QueryEmbedding(ctx, vec, n, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't compare embeddings: %w", err)
+		}
+		for _, r := range results {
+			if m, ok := found[r.ID]; ok {
+				m.Cosine = r.Similarity
+			} else if d.cfg.Cosine >= 0 && r.Similarity >= d.cfg.Cosine {
+				if m := candidate(r.ID); m != nil {
+					m.Cosine = r.Similarity
+				}
+			}
+		}
+	}
+
+	var matches []Match
+	for _, m := range found {
+		if d.judge(m); len(m.Signals) > 0 {
+			matches = append(matches, *m)
+		}
+	}
+	slices.SortFunc(matches, func(a, b Match) int {
+		return cmp.Or(cmp.Compare(len(b.Signals), len(a.Signals)), cmp.Compare(b.Jaccard, a.Jaccard),
+			cmp.Compare(b.Cosine, a.Cosine), strings.Compare(a.Of, b.Of))
+	})
+	return matches, nil
+}
+
+// judge sets the signals of the tests that say m is a duplicate.
+func (d *Deduper) judge(m *Match) {
+	m.Signals = nil
+	if m.Jaccard >= d.cfg.Jaccard {
+		m.Signals = append(m.Signals, "minhash")
+	}
+	if d.cfg.Hamming >= 0 && m.Hamming <= d.cfg.Hamming {
+		m.Signals = append(m.Signals, "simhash")
+	}
+	if d.cfg.Cosine >= 0 && m.Cosine >= d.cfg.Cosine {
+		m.Signals = append(m.Signals, "cosine")
+	}
+}
+
+// Compare returns how similar two embedded documents are, and which tests
+// say they're duplicates, if any.
+func (d *Deduper) Compare(a, b chromem.Document) Match {
+	fa, fb := d.fingerprint(a.Content), d.fingerprint(b.Content)
+	m := Match{ID: a.ID, Of: b.ID, Jaccard: EstimateJaccard(fa.minhash, fb.minhash), Hamming: Hamming(fa.simhash, fb.simhash)}
+	for i := range a.Embedding {
+		m.Cosine += a.Embedding[i] * b.Embedding[i]
+	}
+	d.judge(&m)
+	return m
+}
+
+// newer reports whether doc is newer than kept by TimeKey.
+func (d *Deduper) newer(doc, kept chromem.Document) bool {
+	t, ok := parseTime(doc.Metadata[d.cfg.TimeKey])
+	if !ok {
+		return false
+	}
+	k, ok := parseTime(kept.Metadata[d.cfg.TimeKey])
+	return !ok || t.After(k)
+}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// mergeMetadata returns kept's metadata with the keys of dup's that kept
+// doesn't have, and dupID added to DuplicatesKey. Where both have a key,
+// kept's value wins.
+func mergeMetadata(kept, dup map[string]string, dupID string) map[string]string {
+	merged := maps.Clone(kept)
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	for k, v := range dup {
+		if _, ok := merged[k]; !ok && k != DuplicatesKey {
+			merged[k] = v
+		}
+	}
+	ids := strings.Split(merged[DuplicatesKey], ",")
+	if merged[DuplicatesKey] == "" {
+		ids = nil
+	}
+	for _, id := range append([]string{dupID}, strings.Split(dup[DuplicatesKey], ",")...) {
+		if id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	merged[DuplicatesKey] = strings.Join(ids, ",")
+	return merged
+}
+
+// embed embeds the documents that have no embedding, concurrency at a time.
+func (d *Deduper) embed(ctx context.Context, docs []chromem.Document, concurrency int) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i := range docs {
+		if docs[i].Embedding != nil {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if ctx.Err() != nil {
+				return
+			}
+			v, err := d.Embed(ctx, docs[i].Content)
+			if err != nil {
+				cancel(fmt.Errorf("couldn't embed %s: %w", docs[i].ID, err))
+				return
+			}
+			docs[i].Embedding = v
+		}()
+	}
+	wg.Wait()
+	return context.Cause(ctx)
+}
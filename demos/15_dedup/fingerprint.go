@@ -0,0 +1,205 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// words returns the lowercased words of text.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix64 is splitmix64's finalizer: it scrambles x so that inputs differing
+// in one bit give unrelated outputs.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Shingles returns the distinct hashes of every run of k consecutive words
+// of text, sorted. Case and punctuation don't count; word order does. A text
+// of fewer than k words is one shingle.
+func Shingles(text string, k int) []uint64 {
+	w := words(text)
+	if len(w) == 0 {
+		return nil
+	}
+	var shingles []uint64
+	for i := 0; i+k <= len(w) || i == 0; i++ {
+		shingles = append(shingles, hashString(strings.Join(w[i:min(i+k, len(w))], " ")))
+	}
+	slices.Sort(shingles)
+	return slices.Compact(shingles)
+}
+
+// MinHasher computes MinHash signatures: for each of its hash functions, the
+// smallest hash of any of a text's shingles. Two texts' signatures agree in a
+// position with probability equal to the Jaccard similarity of their shingle
+// sets, so the share of agreeing positions estimates it without comparing
+// the sets.
+type MinHasher struct {
+	seeds []uint64
+}
+
+// NewMinHasher returns a hasher with n hash functions. Their seeds are fixed,
+// so signatures computed in different runs can be compared.
+func NewMinHasher(n int) *MinHasher {
+	seeds := make([]uint64, n)
+	for i := range seeds {
+		seeds[i] = mix64(uint64(i+1) * 0x9e3779b97f4a7c15)
+	}
+	return &MinHasher{seeds: seeds}
+}
+
+// Signature returns the MinHash signature of a set of shingles.
+func (m *MinHasher) Signature(shingles []uint64) []uint64 {
+	sig := make([]uint64, len(m.seeds))
+	for i, seed := range m.seeds {
+		lowest := ^uint64(0)
+		for _, s := range shingles {
+			lowest = min(lowest, mix64(s^seed))
+		}
+		sig[i] = lowest
+	}
+	return sig
+}
+
+// EstimateJaccard returns the share of positions where two signatures agree.
+func EstimateJaccard(a, b []uint64) float64 {
+	var same int
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// lshIndex finds signatures that are probably similar without comparing
+// every pair: each signature is cut into bands of rows, and two documents
+// are candidates if every row of any one band agrees. That happens with
+// probability 1-(1-j^rows)^bands for Jaccard similarity j - an S-curve that
+// is steepest near (1/bands)^(1/rows).
+type lshIndex struct {
+	rows    int
+	buckets []map[uint64][]string // per band
+}
+
+func newLSHIndex(bands, rows int) *lshIndex {
+	x := &lshIndex{rows: rows, buckets: make([]map[uint64][]string, bands)}
+	for i := range x.buckets {
+		x.buckets[i] = make(map[uint64][]string)
+	}
+	return x
+}
+
+func (x *lshIndex) bandKey(sig []uint64, band int) uint64 {
+	key := uint64(band)
+	for _, v := range sig[band*x.rows : (band+1)*x.rows] {
+		key = mix64(key ^ v)
+	}
+	return key
+}
+
+func (x *lshIndex) add(id string, sig []uint64) {
+	for band, bucket := range x.buckets {
+		key := x.bandKey(sig, band)
+		bucket[key] = append(bucket[key], id)
+	}
+}
+
+// candidates returns the IDs that share a band with sig.
+func (x *lshIndex) candidates(sig []uint64) []string {
+	var ids []string
+	for band, bucket := range x.buckets {
+		ids = append(ids, bucket[x.bandKey(sig, band)]...)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// SimHash returns Charikar's 64-bit fingerprint of text: every word votes on
+// every bit, for it if the word's hash has the bit set and against it if not,
+// as often as the word occurs. Texts with nearly the same words get
+// fingerprints that differ in few bits. Word order doesn't count.
+func SimHash(text string) uint64 {
+	var votes [64]int
+	for _, word := range words(text) {
+		h := mix64(hashString(word))
+		for b := range votes {
+			if h&(1<<b) != 0 {
+				votes[b]++
+			} else {
+				votes[b]--
+			}
+		}
+	}
+	var fp uint64
+	for b, v := range votes {
+		if v > 0 {
+			fp |= 1 << b
+		}
+	}
+	return fp
+}
+
+// Hamming returns the number of bits in which two fingerprints differ.
+func Hamming(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// simIndex finds fingerprints within a Hamming distance of k without
+// comparing every pair. Fingerprints are cut into k+1 blocks of bits; k
+// differing bits can touch at most k of them, so a fingerprint within k
+// agrees exactly with the query in at least one block.
+type simIndex struct {
+	blocks []map[uint64][]string
+}
+
+func newSimIndex(k int) *simIndex {
+	x := &simIndex{blocks: make([]map[uint64][]string, k+1)}
+	for i := range x.blocks {
+		x.blocks[i] = make(map[uint64][]string)
+	}
+	return x
+}
+
+// block returns the bits of fp in block i.
+func (x *simIndex) block(fp uint64, i int) uint64 {
+	lo, hi := i*64/len(x.blocks), (i+1)*64/len(x.blocks)
+	return fp >> lo & (^uint64(0) >> (64 - (hi - lo)))
+}
+
+func (x *simIndex) add(id string, fp uint64) {
+	for i, block := range x.blocks {
+		key := x.block(fp, i)
+		block[key] = append(block[key], id)
+	}
+}
+
+// candidates returns the IDs that agree with fp in some block, a superset of
+// those within k bits.
+func (x *simIndex) candidates(fp uint64) []string {
+	var ids []string
+	for i, block := range x.blocks {
+		ids = append(ids, block[x.block(fp, i)]...)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string) error{
+			"report": runReport,
+		}
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	var flags dedupFlags
+	flags.register(flag.CommandLine)
+	policyName := flag.String("policy", "skip", "what to do with a duplicate at ingest: skip, merge or newest")
+	flag.Parse()
+	policy, err := ParsePolicy(*policyName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+	cfg, err := flags.config(policy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+	cfg = cfg.withDefaults()
+
+	fmt.Println("🧹 Near-Duplicate Detection Demo - One Page, Many URLs")
+	fmt.Println("=====================================================")
+
+	ctx := context.Background()
+	embed := newHashingEmbedder()
+	dbPath := "./chromem-data"
+	os.RemoveAll(dbPath)
+
+	// 1. Ingest a crawl as it comes, duplicates and all
+	crawl := firstCrawl()
+	fmt.Printf("\n🕷️  1. Ingesting a crawl of %d pages as it comes...\n", len(crawl))
+	db, err := chromem.NewPersistentDB(dbPath, false)
+	if err != nil {
+		panic(err)
+	}
+	raw, err := db.CreateCollection("raw", nil, embed)
+	if err != nil {
+		panic(err)
+	}
+	if err := raw.AddDocuments(ctx, crawl, runtime.NumCPU()); err != nil {
+		panic(err)
+	}
+	query := "install the server from a release archive"
+	results, err := raw.Query(ctx, query, 3, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   %d documents in %s/raw. %q finds:\n", raw.Count(), dbPath, query)
+	for i, r := range results {
+		fmt.Printf("   %d. [%s] %.4f  %s\n", i+1, r.ID, r.Similarity, r.Metadata["url"])
+	}
+	fmt.Println("   Three results, one page under three URLs")
+
+	// 2. Three tests, each for another kind of duplicate
+	fmt.Println("\n🔬 2. Comparing pairs of pages three ways")
+	fmt.Printf("   minhash: Jaccard of %d-word shingles ≥ %.2f · simhash: ≤ %d bits apart · cosine: embeddings ≥ %.2f\n",
+		cfg.ShingleSize, cfg.Jaccard, cfg.Hamming, cfg.Cosine)
+	docs, err := readDocuments(db, "raw")
+	if err != nil {
+		panic(err)
+	}
+	byID := make(map[string]chromem.Document, len(docs))
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+	}
+	d := NewDeduper(raw, embed, cfg)
+	fmt.Printf("   %-31s %-18s %8s %8s %7s  %s\n", "pair", "", "jaccard", "hamming", "cosine", "duplicate by")
+	for _, p := range []struct{ a, b, kind string }{
+		{"install", "install-print", "exact copy"},
+		{"install", "install-mirror", "boilerplate"},
+		{"backup", "backup-mirror", "boilerplate"},
+		{"upgrade", "upgrade-mirror", "reordered"},
+		{"config", "config-old", "older version"},
+		{"tls", "logging", "same template"},
+	} {
+		m := d.Compare(byID[p.a], byID[p.b])
+		fmt.Printf("   %-31s %-18s %8.2f %8d %7.4f  %s\n", p.a+" ~ "+p.b, p.kind, m.Jaccard, m.Hamming, m.Cosine, signals(m))
+	}
+
+	// 3. The report finds them without being told which pairs to compare
+	fmt.Println("\n📋 3. Duplicate clusters in the raw collection")
+	clusters, err := NewDeduper(raw, embed, cfg).FindDuplicates(ctx, docs)
+	if err != nil {
+		panic(err)
+	}
+	printClusters(clusters)
+
+	// 4. Deduplicate at ingest instead
+	fmt.Printf("\n🚪 4. Ingesting the same crawl through a deduper, policy %s\n", cfg.Policy)
+	clean, err := db.CreateCollection("pages", nil, embed)
+	if err != nil {
+		panic(err)
+	}
+	d = NewDeduper(clean, embed, cfg)
+	decisions, err := d.AddDocuments(ctx, crawl, runtime.NumCPU())
+	if err != nil {
+		panic(err)
+	}
+	printDecisions(decisions)
+	fmt.Printf("   %d pages in, %d documents kept\n", len(crawl), clean.Count())
+
+	// 5. What happens to a duplicate is a policy
+	fmt.Println("\n⚖️  5. The same two crawls under each policy")
+	for _, policy := range []Policy{Skip, MergeMetadata, KeepNewest} {
+		c := cfg
+		c.Policy = policy
+		mem := chromem.NewDB()
+		collection, err := mem.CreateCollection("pages", nil, embed)
+		if err != nil {
+			panic(err)
+		}
+		d := NewDeduper(collection, embed, c)
+		if _, err := d.AddDocuments(ctx, firstCrawl(), runtime.NumCPU()); err != nil {
+			panic(err)
+		}
+		decisions, err := d.AddDocuments(ctx, secondCrawl(), runtime.NumCPU())
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("\n   %s: second crawl", policy)
+		for _, dec := range decisions {
+			fmt.Printf(" · %s %s", dec.ID, dec.Action)
+			if dec.Match != nil {
+				fmt.Printf(" (%s)", dec.Match.Of)
+			}
+		}
+		kept, err := readDocuments(mem, "pages")
+		if err != nil {
+			panic(err)
+		}
+		var ids []string
+		for _, doc := range kept {
+			ids = append(ids, doc.ID)
+		}
+		fmt.Printf("\n   %d kept: %s\n", len(ids), strings.Join(ids, ", "))
+		// Show what the policy changed: the lists of merged duplicates, the
+		// replacements with their dates
+		for _, doc := range kept {
+			if policy == MergeMetadata && doc.Metadata[DuplicatesKey] != "" ||
+				policy == KeepNewest && slices.ContainsFunc(decisions, func(dec Decision) bool { return dec.ID == doc.ID && dec.Action == Replaced }) {
+				fmt.Printf("     %s: %s\n", doc.ID, formatMetadata(doc.Metadata))
+			}
+		}
+	}
+
+	fmt.Println("\n🎯 Key Insights:")
+	fmt.Println("   • MinHash catches copies with edits and boilerplate: shingles keep word order, so a reordered page escapes it")
+	fmt.Println("   • SimHash catches the same words in any order, in 64 bits per document - but boilerplate moves it")
+	fmt.Println("   • Embeddings catch what the words alone don't - as far as the embedder understands the page")
+	fmt.Println("   • Pages from one template share much of their wording; thresholds decide whether that's a duplicate")
+	fmt.Println("   • Skip keeps what came first, merge lists the copies by ID but keeps the first URL, newest keeps what's current")
+	fmt.Println("\n💡 Try: go run . report, or go run . -policy newest -jaccard 0.5")
+}
+
+// dedupFlags are the thresholds of a DedupConfig, shared by the demo and
+// the report.
+type dedupFlags struct {
+	jaccard, cosine  float64
+	hamming, shingle int
+	timeKey          string
+}
+
+func (f *dedupFlags) register(fs *flag.FlagSet) {
+	fs.Float64Var(&f.jaccard, "jaccard", 0.7, "estimated Jaccard similarity of shingles at which pages are duplicates")
+	fs.IntVar(&f.hamming, "hamming", 3, "SimHash distance in bits up to which pages are duplicates, -1 for no SimHash test")
+	fs.Float64Var(&f.cosine, "cosine", 0.95, "embedding similarity at which pages are duplicates, -1 for no cosine test")
+	fs.IntVar(&f.shingle, "shingle", 3, "words per shingle")
+	fs.StringVar(&f.timeKey, "time-key", "updated", "metadata key with each page's date")
+}
+
+func (f *dedupFlags) config(policy Policy) (DedupConfig, error) {
+	if f.jaccard <= 0 || f.jaccard > 1 || f.shingle <= 0 {
+		return DedupConfig{}, fmt.Errorf("-jaccard must be in (0, 1] and -shingle positive")
+	}
+	return DedupConfig{
+		Policy:      policy,
+		TimeKey:     f.timeKey,
+		ShingleSize: f.shingle,
+		Jaccard:     f.jaccard,
+		Hamming:     f.hamming,
+		Cosine:      float32(f.cosine),
+	}, nil
+}
+
+// printDecisions prints what a deduper did with each document.
+func printDecisions(decisions []Decision) {
+	for _, dec := range decisions {
+		if dec.Match == nil {
+			fmt.Printf("   ✅ %-15s %s\n", dec.ID, dec.Action)
+			continue
+		}
+		fmt.Printf("   ♻️  %-15s %-8s duplicate of %s by %s\n", dec.ID, dec.Action, dec.Match.Of, signals(*dec.Match))
+	}
+}
+
+// signals returns the tests that call a match a duplicate, or "-".
+func signals(m Match) string {
+	if len(m.Signals) == 0 {
+		return "-"
+	}
+	return strings.Join(m.Signals, " + ")
+}
+
+// formatMetadata returns metadata as sorted key=value pairs.
+func formatMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for _, k := range slices.Sorted(maps.Keys(metadata)) {
+		pairs = append(pairs, k+"="+metadata[k])
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,89 @@
+package main
+
+import "github.com/philippgille/chromem-go"
+
+const (
+	install = "Install the server with the package manager of your platform or download a release archive. " +
+		"The archive contains a single binary and a sample configuration file. Copy the binary to a directory on your PATH, " +
+		"copy the sample configuration to /etc/server/config.yaml and start the service. " +
+		"The server listens on port 8080 by default and writes its data to /var/lib/server."
+	config = "The configuration file is YAML. The listen key sets the address and port the server binds to. " +
+		"The storage key sets the data directory and the cache size in megabytes. " +
+		"The auth section turns on token authentication; tokens are read from the file named by tokens_file. " +
+		"Environment variables override the file: SERVER_LISTEN, SERVER_STORAGE_DIR and SERVER_CACHE_MB. " +
+		"Reload the configuration without a restart by sending the process SIGHUP."
+	upgrade = "Before an upgrade, take a snapshot of the data directory. Stop the server, replace the binary and start it again. " +
+		"On start the server migrates its data to the new format; the migration can't be undone, which is why the snapshot matters. " +
+		"Read the release notes for settings that were renamed. Downgrades are not supported: restore the snapshot instead."
+	tlsPage = "This page explains how to turn on TLS. Open the configuration file and add a tls section. " +
+		"Set cert_file and key_file to the paths of a certificate and its private key in PEM format. " +
+		"Restart the server and connect with https. To renew a certificate, replace the files and send the process SIGHUP."
+	loggingPage = "This page explains how to turn on request logging. Open the configuration file and add a logging section. " +
+		"Set level to debug, info or warn and format to json or text. " +
+		"Restart the server and watch the output. To rotate logs, move the file away and send the process SIGHUP."
+	backup = "Back up the server by copying the data directory while the server is stopped, or take an online snapshot with the snapshot command, " +
+		"which copies a consistent view of the data without stopping writes. Store snapshots on another machine. " +
+		"Test a restore now and then: a backup that has never been restored is a hope, not a backup."
+)
+
+// Boilerplate a site wraps every page in, which makes copies on another site
+// look different to an exact comparison.
+const (
+	mirrorHeader = "Home › Docs › "
+	mirrorFooter = " Was this page helpful? Edit this page on GitHub. © Example Mirror."
+)
+
+// firstCrawl is a crawl of a documentation site and a mirror of it: the same
+// pages under several URLs, printer-friendly copies, an older version that
+// was never taken down, and distinct pages written from the same template.
+func firstCrawl() []chromem.Document {
+	return []chromem.Document{
+		page("install", "docs.example.com/install", "2024-01-10", "Installation", install),
+		page("install-print", "docs.example.com/install?print=1", "2024-01-10", "Installation", install),
+		page("install-mirror", "mirror.example.org/docs/install", "2023-12-01", "Installation",
+			mirrorHeader+"Installation. "+install+mirrorFooter),
+		page("config", "docs.example.com/config", "2024-03-02", "Configuration", config),
+		page("config-old", "docs.example.com/v1/config", "2023-06-20", "Configuration (v1)",
+			"The configuration file is YAML. The listen key sets the address and port the server binds to. "+
+				"The data_dir key sets the data directory and cache_mb the cache size in megabytes. "+
+				"The auth section turns on password authentication; passwords are read from the file named by passwords_file. "+
+				"Environment variables override the file: SERVER_LISTEN, SERVER_STORAGE_DIR and SERVER_CACHE_MB. "+
+				"Restart the server to reload the configuration."),
+		page("upgrade", "docs.example.com/upgrade", "2024-02-14", "Upgrading", upgrade),
+		page("upgrade-mirror", "mirror.example.org/docs/upgrade", "2024-02-20", "Upgrading",
+			// The same words, in another order
+			"Take a snapshot of the data directory before an upgrade. Replace the binary: stop the server and start it again. "+
+				"The migration can't be undone, which is why the snapshot matters: on start the server migrates its data to the new format. "+
+				"For settings that were renamed, read the release notes. Instead, restore the snapshot: downgrades are not supported."),
+		page("tls", "docs.example.com/tls", "2024-01-22", "TLS", tlsPage),
+		page("logging", "docs.example.com/logging", "2024-01-22", "Logging", loggingPage),
+		page("backup", "docs.example.com/backup", "2024-02-01", "Backups", backup),
+		page("backup-mirror", "mirror.example.org/docs/backup", "2024-02-03", "Backups",
+			mirrorHeader+"Backups. "+backup+mirrorFooter),
+	}
+}
+
+// secondCrawl is the same site a month later: one page was edited, one
+// is unchanged and one is new.
+func secondCrawl() []chromem.Document {
+	return []chromem.Document{
+		page("config-2024-04", "docs.example.com/config", "2024-04-05", "Configuration",
+			config+" Unknown keys are reported at start instead of being ignored."),
+		page("install-2024-04", "docs.example.com/install", "2024-04-05", "Installation", install),
+		page("metrics", "docs.example.com/metrics", "2024-04-01", "Metrics",
+			"The server exposes Prometheus metrics on /metrics: request counts and latencies by route, cache hits and misses, "+
+				"and the size of the data directory. Scrape it every 15 seconds and alert on the error rate, not on single errors."),
+	}
+}
+
+func page(id, url, updated, title, content string) chromem.Document {
+	return chromem.Document{
+		ID:      id,
+		Content: content,
+		Metadata: map[string]string{
+			"url":     url,
+			"updated": updated,
+			"title":   title,
+		},
+	}
+}
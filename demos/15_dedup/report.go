@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Cluster is a group of documents that are duplicates of each other, each
+// directly or through another member.
+type Cluster struct {
+	IDs     []string // sorted
+	Matches []Match  // the pairs that link them, each once
+	Keep    string   // the one KeepNewest would keep: the newest, or the first ID
+}
+
+// FindDuplicates returns the clusters of duplicates among docs, the
+// documents of the deduper's collection as readDocuments returns them, the
+// largest first. Each document is compared with the others the way
+// AddDocuments compares a new one, and every match links two clusters.
+func (d *Deduper) FindDuplicates(ctx context.Context, docs []chromem.Document) ([]Cluster, error) {
+	d.Index(docs)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	parent := make(map[string]string, len(docs))
+	var find func(string) string
+	find = func(id string) string {
+		if p, ok := parent[id]; ok && p != id {
+			parent[id] = find(p)
+			return parent[id]
+		}
+		return id
+	}
+	byID := make(map[string]chromem.Document, len(docs))
+	var matches []Match
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+		found, err := d.matches(ctx, doc.ID, d.entries[doc.ID], doc.Embedding)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range found {
+			if m.ID < m.Of { // both directions are found; keep one
+				matches = append(matches, m)
+				parent[find(m.Of)] = find(m.ID)
+			}
+		}
+	}
+
+	groups := make(map[string]*Cluster)
+	for _, m := range matches {
+		root := find(m.ID)
+		c, ok := groups[root]
+		if !ok {
+			c = &Cluster{}
+			groups[root] = c
+		}
+		c.Matches = append(c.Matches, m)
+		for _, id := range []string{m.ID, m.Of} {
+			if !slices.Contains(c.IDs, id) {
+				c.IDs = append(c.IDs, id)
+			}
+		}
+	}
+	clusters := make([]Cluster, 0, len(groups))
+	for _, c := range groups {
+		slices.Sort(c.IDs)
+		c.Keep = c.IDs[0]
+		for _, id := range c.IDs[1:] {
+			if d.newer(byID[id], byID[c.Keep]) {
+				c.Keep = id
+			}
+		}
+		clusters = append(clusters, *c)
+	}
+	slices.SortFunc(clusters, func(a, b Cluster) int {
+		return cmp.Or(cmp.Compare(len(b.IDs), len(a.IDs)), strings.Compare(a.IDs[0], b.IDs[0]))
+	})
+	return clusters, nil
+}
+
+// readDocuments returns all documents of a collection, sorted by ID.
+// chromem-go has no API to list them, but its export is a gob of the same
+// structs.
+func readDocuments(db *chromem.DB, name string) ([]chromem.Document, error) {
+	var buf bytes.Buffer
+	if err := db.ExportToWriter(&buf, false, "", name); err != nil {
+		return nil, fmt.Errorf("couldn't export collection %q: %w", name, err)
+	}
+	persisted := struct {
+		Collections map[string]*struct {
+			Documents map[string]*chromem.Document
+		}
+	}{}
+	if err := gob.NewDecoder(&buf).Decode(&persisted); err != nil {
+		return nil, fmt.Errorf("couldn't decode collection %q: %w", name, err)
+	}
+	pc, ok := persisted.Collections[name]
+	if !ok {
+		return nil, fmt.Errorf("collection %q not found", name)
+	}
+	docs := make([]chromem.Document, 0, len(pc.Documents))
+	for _, doc := range pc.Documents {
+		docs = append(docs, *doc)
+	}
+	slices.SortFunc(docs, func(a, b chromem.Document) int { return strings.Compare(a.ID, b.ID) })
+	return docs, nil
+}
+
+// runReport implements `go run . report`: it reads a collection the demo
+// saved, or any other, and lists its clusters of duplicates.
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	dbPath := fs.String("db", "./chromem-data", "persistent DB directory")
+	name := fs.String("collection", "raw", "collection to check")
+	var flags dedupFlags
+	flags.register(fs)
+	fs.Parse(args)
+	cfg, err := flags.config(KeepNewest)
+	if err != nil {
+		return err
+	}
+
+	db, err := chromem.NewPersistentDB(*dbPath, false)
+	if err != nil {
+		return fmt.Errorf("couldn't open %s: %w", *dbPath, err)
+	}
+	docs, err := readDocuments(db, *name)
+	if err != nil {
+		return err
+	}
+	embed := newHashingEmbedder()
+	d := NewDeduper(db.GetCollection(*name, embed), embed, cfg)
+	clusters, err := d.FindDuplicates(context.Background(), docs)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📋 %s/%s: %d documents\n", *dbPath, *name, len(docs))
+	printClusters(clusters)
+	return nil
+}
+
+// printClusters prints each cluster with the matches that link it and the
+// document KeepNewest would keep.
+func printClusters(clusters []Cluster) {
+	if len(clusters) == 0 {
+		fmt.Println("   No duplicates")
+		return
+	}
+	var dups int
+	for i, c := range clusters {
+		dups += len(c.IDs) - 1
+		fmt.Printf("   Cluster %d: %s - keep %s\n", i+1, strings.Join(c.IDs, ", "), c.Keep)
+		for _, m := range c.Matches {
+			fmt.Printf("      %-15s ~ %-15s jaccard %.2f  hamming %2d  cosine %.4f  by %s\n",
+				m.ID, m.Of, m.Jaccard, m.Hamming, m.Cosine, signals(m))
+		}
+	}
+	fmt.Printf("   %d clusters, %d documents that could go\n", len(clusters), dups)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// embeddingDimension is the dimension of the hashing embedder.
+const embeddingDimension = 512
+
+// stopWords say nothing about what a text is about: the embedder skips them,
+// and they're never a topic's terms.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"do": true, "for": true, "from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+	"of": true, "on": true, "or": true, "the": true, "to": true, "what": true, "with": true,
+	"use": true, "using": true, "implement": true, "set": true, "up": true, "proper": true, "like": true,
+}
+
+// words returns the lowercased words of text that aren't stop words.
+func words(text string) []string {
+	var ws []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !stopWords[word] {
+			ws = append(ws, word)
+		}
+	}
+	return ws
+}
+
+// newHashingEmbedder returns a local stand-in for an embedding model: words
+// and their character trigrams are feature-hashed into 512 dimensions, so
+// "cache" and "caching" land near each other. It groups texts that share
+// words; a real model also groups those that share meaning.
+func newHashingEmbedder() chromem.EmbeddingFunc {
+	return func(_ context.Context, text string) ([]float32, error) {
+		vec := make([]float32, embeddingDimension)
+		for _, word := range words(text) {
+			addFeature(vec, "w:"+word, 1)
+			padded := "^" + word + "$"
+			for i := 0; i+3 <= len(padded); i++ {
+				addFeature(vec, "t:"+padded[i:i+3], 0.5)
+			}
+		}
+
+		var sum float64
+		for _, v := range vec {
+			sum += float64(v) * float64(v)
+		}
+		if sum == 0 {
+			return nil, fmt.Errorf("text %q has no words to embed", text)
+		}
+		norm := float32(math.Sqrt(sum))
+		for i := range vec {
+			vec[i] /= norm
+		}
+		return vec, nil
+	}
+}
+
+// addFeature is 11_embedder_registry's signed feature hashing.
+func addFeature(vec []float32, feature string, weight float32) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum&1 == 1 {
+		weight = -weight
+	}
+	vec[(sum>>1)%uint64(len(vec))] += weight
+}
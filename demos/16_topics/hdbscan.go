@@ -0,0 +1,268 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// HDBSCAN finds clusters as regions that stay dense over a range of
+// densities, and leaves what is in none of them as noise - it needs no k.
+//
+// Each vector's core distance is the distance to its MinSamples-th nearest
+// neighbour, itself included: how sparse its neighbourhood is. The mutual
+// reachability distance of two vectors is the largest of their distance and
+// both core distances, which pushes sparse vectors away from everything. Its
+// minimum spanning tree, cut at ever shorter edges, splits the vectors into
+// ever smaller groups; groups of fewer than MinClusterSize fall out as
+// noise. Each cluster's stability sums, over its vectors, how long they stay
+// in it, in 1/distance; the clusters chosen are the most stable that don't
+// contain each other.
+type HDBSCAN struct {
+	MinClusterSize int
+	MinSamples     int // defaults to MinClusterSize
+}
+
+func (h HDBSCAN) Name() string {
+	return fmt.Sprintf("hdbscan min=%d", h.MinClusterSize)
+}
+
+func (h HDBSCAN) Cluster(vectors [][]float32) (Clustering, error) {
+	n := len(vectors)
+	minSamples := cmp.Or(h.MinSamples, h.MinClusterSize)
+	if h.MinClusterSize < 2 || minSamples > n {
+		return Clustering{}, fmt.Errorf("hdbscan needs a minimum cluster size from 2 and at most %d samples", n)
+	}
+
+	dist := make([][]float64, n)
+	core := make([]float64, n)
+	for i := range dist {
+		dist[i] = make([]float64, n)
+		for j := range dist[i] {
+			dist[i][j] = distance(vectors[i], vectors[j])
+		}
+		sorted := slices.Clone(dist[i])
+		slices.Sort(sorted)
+		core[i] = sorted[minSamples-1]
+	}
+	reach := func(i, j int) float64 { return max(dist[i][j], core[i], core[j]) }
+
+	tree := singleLinkage(n, primMST(n, reach))
+	condensed := condense(tree, n, h.MinClusterSize)
+	selected := condensed.selectClusters()
+
+	c := Clustering{Algorithm: h.Name(), Labels: make([]int, n)}
+	label := make(map[int]int, len(selected))
+	for _, id := range selected {
+		label[id] = c.K
+		c.K++
+	}
+	for p := range c.Labels {
+		c.Labels[p] = Noise
+		// The point's last cluster, or the selected one above it
+		for id := condensed.pointCluster[p]; id >= 0; id = condensed.clusters[id].parent {
+			if l, ok := label[id]; ok {
+				c.Labels[p] = l
+				break
+			}
+		}
+	}
+	return c, nil
+}
+
+// mstEdge is an edge of the minimum spanning tree.
+type mstEdge struct {
+	a, b   int
+	weight float64
+}
+
+// primMST returns the minimum spanning tree of the complete graph on n
+// vertices weighted by weight, in O(n²), its edges sorted by weight.
+func primMST(n int, weight func(i, j int) float64) []mstEdge {
+	inTree := make([]bool, n)
+	best := make([]float64, n)
+	from := make([]int, n)
+	for i := range best {
+		best[i] = math.Inf(1)
+	}
+	edges := make([]mstEdge, 0, n-1)
+	current := 0
+	for range n - 1 {
+		inTree[current] = true
+		next := -1
+		for j := range n {
+			if inTree[j] {
+				continue
+			}
+			if w := weight(current, j); w < best[j] {
+				best[j], from[j] = w, current
+			}
+			if next < 0 || best[j] < best[next] {
+				next = j
+			}
+		}
+		edges = append(edges, mstEdge{from[next], next, best[next]})
+		current = next
+	}
+	slices.SortStableFunc(edges, func(x, y mstEdge) int { return cmp.Compare(x.weight, y.weight) })
+	return edges
+}
+
+// linkage is a node of the single-linkage tree: nodes 0 to n-1 are the
+// points, each later node merges two earlier ones at a distance.
+type linkage struct {
+	left, right int
+	distance    float64
+	size        int
+}
+
+// singleLinkage merges the points along the sorted MST edges.
+func singleLinkage(n int, edges []mstEdge) []linkage {
+	nodes := make([]linkage, n, 2*n-1)
+	parent := make([]int, 2*n-1)
+	for i := range nodes {
+		nodes[i] = linkage{left: -1, right: -1, size: 1}
+	}
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(x int) int {
+		if parent[x] != x {
+			parent[x] = find(parent[x])
+		}
+		return parent[x]
+	}
+	for _, e := range edges {
+		a, b := find(e.a), find(e.b)
+		id := len(nodes)
+		nodes = append(nodes, linkage{left: a, right: b, distance: e.weight, size: nodes[a].size + nodes[b].size})
+		parent[a], parent[b] = id, id
+	}
+	return nodes
+}
+
+// condensedCluster is a cluster of the condensed tree. Densities are in
+// lambda, 1/distance: a cluster is born at the lambda where it split off its
+// parent, and each point leaves it at the lambda where it fell out or the
+// cluster split.
+type condensedCluster struct {
+	parent    int // -1 for the root
+	children  []int
+	birth     float64
+	stability float64
+}
+
+// condensedTree is the single-linkage tree with every split that cuts off
+// fewer than the minimum cluster size read as points falling out instead.
+type condensedTree struct {
+	clusters     []condensedCluster
+	pointCluster []int // the last cluster each point was in
+}
+
+// lambda is 1/distance. Identical points are at distance 0; a large finite
+// lambda keeps their stability a number.
+func lambda(distance float64) float64 {
+	return 1 / max(distance, 1e-9)
+}
+
+func condense(nodes []linkage, n, minClusterSize int) *condensedTree {
+	t := &condensedTree{pointCluster: make([]int, n)}
+	t.clusters = append(t.clusters, condensedCluster{parent: -1})
+
+	// leave records the points under node falling out of cluster at l.
+	var leave func(node, cluster int, l float64)
+	leave = func(node, cluster int, l float64) {
+		if node < n {
+			t.pointCluster[node] = cluster
+			t.clusters[cluster].stability += l - t.clusters[cluster].birth
+			return
+		}
+		leave(nodes[node].left, cluster, l)
+		leave(nodes[node].right, cluster, l)
+	}
+	// walk follows node, which is still all of cluster, down the tree.
+	var walk func(node, cluster int)
+	walk = func(node, cluster int) {
+		if node < n {
+			leave(node, cluster, t.clusters[cluster].birth) // a cluster of one point
+			return
+		}
+		nd := nodes[node]
+		l := lambda(nd.distance)
+		left, right := nodes[nd.left].size >= minClusterSize, nodes[nd.right].size >= minClusterSize
+		switch {
+		case left && right:
+			// A true split: cluster ends here, its points leave it for two new ones
+			t.clusters[cluster].stability += float64(nd.size) * (l - t.clusters[cluster].birth)
+			for _, child := range []int{nd.left, nd.right} {
+				id := len(t.clusters)
+				t.clusters = append(t.clusters, condensedCluster{parent: cluster, birth: l})
+				t.clusters[cluster].children = append(t.clusters[cluster].children, id)
+				walk(child, id)
+			}
+		case left:
+			leave(nd.right, cluster, l)
+			walk(nd.left, cluster)
+		case right:
+			leave(nd.left, cluster, l)
+			walk(nd.right, cluster)
+		default:
+			leave(nd.left, cluster, l)
+			leave(nd.right, cluster, l)
+		}
+	}
+	walk(len(nodes)-1, 0)
+	return t
+}
+
+// selectClusters returns the IDs of the clusters to keep: bottom up, a
+// cluster is kept over its descendants if it's more stable than the best of
+// them together. The root, all of the points, is never kept.
+func (t *condensedTree) selectClusters() []int {
+	var best func(id int) (float64, []int)
+	best = func(id int) (float64, []int) {
+		var sum float64
+		var below []int
+		for _, child := range t.clusters[id].children {
+			s, ids := best(child)
+			sum += s
+			below = append(below, ids...)
+		}
+		if id != 0 && (len(t.clusters[id].children) == 0 || t.clusters[id].stability >= sum) {
+			return t.clusters[id].stability, []int{id}
+		}
+		return sum, below
+	}
+	_, ids := best(0)
+	slices.Sort(ids)
+	return ids
+}
+
+func dot(a, b []float32) float32 {
+	var s float32
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+// distance is the cosine distance of two unit vectors, from 0 to 2.
+func distance(a, b []float32) float64 {
+	return max(0, 1-float64(dot(a, b)))
+}
+
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
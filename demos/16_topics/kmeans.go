@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// Noise is the label of a point that belongs to no cluster.
+const Noise = -1
+
+// Clustering is a partition of a set of vectors.
+type Clustering struct {
+	Algorithm string // the clusterer's name
+	K         int    // the number of clusters, labelled 0 to K-1
+	Labels    []int  // per vector: its cluster, or Noise
+}
+
+// Clusterer groups unit vectors by cosine similarity.
+type Clusterer interface {
+	Name() string
+	Cluster(vectors [][]float32) (Clustering, error)
+}
+
+// KMeans is spherical k-means: each vector goes to the centroid it's most
+// similar to, and each centroid is the normalized mean of its vectors, until
+// nothing moves. Centroids start from k-means++ seeding - each next one
+// picked with probability growing with its distance from those picked - and
+// of Restarts runs with different seeds the most cohesive wins.
+type KMeans struct {
+	K        int
+	Restarts int    // defaults to 10
+	MaxIter  int    // per run, defaults to 100
+	Seed     uint64 // the same seed, the same clusters
+}
+
+func (km KMeans) Name() string {
+	return fmt.Sprintf("k-means k=%d", km.K)
+}
+
+func (km KMeans) Cluster(vectors [][]float32) (Clustering, error) {
+	if km.K <= 0 || km.K > len(vectors) {
+		return Clustering{}, fmt.Errorf("k-means needs 1 to %d clusters, got %d", len(vectors), km.K)
+	}
+	restarts, maxIter := km.Restarts, km.MaxIter
+	if restarts <= 0 {
+		restarts = 10
+	}
+	if maxIter <= 0 {
+		maxIter = 100
+	}
+
+	var best []int
+	bestCohesion := -1.0
+	for run := range restarts {
+		rng := rand.New(rand.NewPCG(km.Seed, uint64(run)))
+		labels, cohesion := kmeansRun(vectors, kmeansPlusPlus(vectors, km.K, rng), maxIter)
+		if cohesion > bestCohesion {
+			best, bestCohesion = labels, cohesion
+		}
+	}
+	return Clustering{Algorithm: km.Name(), K: km.K, Labels: best}, nil
+}
+
+// kmeansPlusPlus picks k of the vectors as starting centroids: the first at
+// random, each next with probability proportional to its squared distance
+// from the nearest already picked.
+func kmeansPlusPlus(vectors [][]float32, k int, rng *rand.Rand) [][]float32 {
+	centroids := [][]float32{vectors[rng.IntN(len(vectors))]}
+	nearest := make([]float64, len(vectors))
+	for i, v := range vectors {
+		nearest[i] = distance(v, centroids[0])
+	}
+	for len(centroids) < k {
+		var total float64
+		for _, d := range nearest {
+			total += d * d
+		}
+		pick := 0
+		if total > 0 {
+			r := rng.Float64() * total
+			for pick = range nearest {
+				if r -= nearest[pick] * nearest[pick]; r <= 0 {
+					break
+				}
+			}
+		} else {
+			pick = rng.IntN(len(vectors)) // every vector is a centroid already
+		}
+		centroids = append(centroids, vectors[pick])
+		for i, v := range vectors {
+			nearest[i] = min(nearest[i], distance(v, vectors[pick]))
+		}
+	}
+	return centroids
+}
+
+// kmeansRun iterates from the given centroids and returns the labels and the
+// mean similarity of each vector to its centroid.
+func kmeansRun(vectors, centroids [][]float32, maxIter int) ([]int, float64) {
+	labels := make([]int, len(vectors))
+	for i := range labels {
+		labels[i] = -1
+	}
+	var cohesion float64
+	for range maxIter {
+		moved := false
+		cohesion = 0
+		for i, v := range vectors {
+			label, sim := 0, float32(-2)
+			for c, centroid := range centroids {
+				if s := dot(v, centroid); s > sim {
+					label, sim = c, s
+				}
+			}
+			if labels[i] != label {
+				labels[i], moved = label, true
+			}
+			cohesion += float64(sim)
+		}
+		if !moved {
+			break
+		}
+		centroids = centroidsOf(vectors, labels, len(centroids))
+	}
+	return labels, cohesion / float64(len(vectors))
+}
+
+// centroidsOf returns the normalized mean of each cluster's vectors. An empty
+// cluster keeps a zero centroid, which nothing is more similar to than to a
+// real one.
+func centroidsOf(vectors [][]float32, labels []int, k int) [][]float32 {
+	centroids := make([][]float32, k)
+	for c := range centroids {
+		centroids[c] = make([]float32, len(vectors[0]))
+	}
+	for i, v := range vectors {
+		if labels[i] == Noise {
+			continue
+		}
+		for j, x := range v {
+			centroids[labels[i]][j] += x
+		}
+	}
+	for _, c := range centroids {
+		normalize(c)
+	}
+	return centroids
+}
+
+// Silhouette returns the mean silhouette of the clustered vectors: for each,
+// how much nearer it is to its own cluster than to the next nearest, from -1
+// to 1. Noise doesn't count, and neither do clusters of one.
+func Silhouette(vectors [][]float32, c Clustering) float64 {
+	var sum float64
+	var n int
+	for i, v := range vectors {
+		if c.Labels[i] == Noise {
+			continue
+		}
+		dists := make([]float64, c.K)
+		sizes := make([]int, c.K)
+		for j, w := range vectors {
+			if j != i && c.Labels[j] != Noise {
+				dists[c.Labels[j]] += distance(v, w)
+				sizes[c.Labels[j]]++
+			}
+		}
+		own := c.Labels[i]
+		if sizes[own] == 0 {
+			continue
+		}
+		a, b := dists[own]/float64(sizes[own]), -1.0
+		for l := range dists {
+			if l != own && sizes[l] > 0 && (b < 0 || dists[l]/float64(sizes[l]) < b) {
+				b = dists[l] / float64(sizes[l])
+			}
+		}
+		if b < 0 {
+			continue // one cluster: nothing to be nearer to
+		}
+		sum += (b - a) / max(a, b)
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// ChooseK runs k-means for every k from lo to hi and returns the clustering
+// with the best silhouette, and every k's silhouette.
+func ChooseK(vectors [][]float32, lo, hi int, seed uint64) (Clustering, []float64, error) {
+	hi = min(hi, len(vectors)-1)
+	if lo < 2 || lo > hi {
+		return Clustering{}, nil, fmt.Errorf("can't choose k from %d to %d for %d vectors", lo, hi, len(vectors))
+	}
+	var best Clustering
+	scores := make([]float64, 0, hi-lo+1)
+	for k := lo; k <= hi; k++ {
+		c, err := KMeans{K: k, Seed: seed}.Cluster(vectors)
+		if err != nil {
+			return Clustering{}, nil, err
+		}
+		scores = append(scores, Silhouette(vectors, c))
+		if k == lo || scores[len(scores)-1] > scores[best.K-lo] {
+			best = c
+		}
+	}
+	return best, scores, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// runLabel implements `go run . label`: it clusters the documents of a
+// persistent collection, the demo's or any other, by their stored
+// embeddings, writes each one's cluster into its metadata under -key and
+// prints the topics.
+func runLabel(args []string) error {
+	fs := flag.NewFlagSet("label", flag.ExitOnError)
+	dbPath := fs.String("db", "./chromem-data", "persistent DB directory")
+	name := fs.String("collection", "tickets", "collection to cluster")
+	algo := fs.String("algo", "kmeans", "kmeans or hdbscan")
+	key := fs.String("key", "cluster", "metadata key to write each document's cluster to")
+	var flags clusterFlags
+	flags.register(fs)
+	fs.Parse(args)
+	if err := flags.validate(); err != nil {
+		return err
+	}
+
+	db, err := chromem.NewPersistentDB(*dbPath, false)
+	if err != nil {
+		return fmt.Errorf("couldn't open %s: %w", *dbPath, err)
+	}
+	docs, err := readDocuments(db, *name)
+	if err != nil {
+		return err
+	}
+	if len(docs) < 3 {
+		return fmt.Errorf("collection %q has %d documents, too few to cluster", *name, len(docs))
+	}
+	vectors := make([][]float32, len(docs))
+	for i, doc := range docs {
+		vectors[i] = doc.Embedding
+	}
+
+	var c Clustering
+	switch *algo {
+	case "kmeans":
+		if flags.k > 0 {
+			c, err = KMeans{K: flags.k, Seed: flags.seed}.Cluster(vectors)
+		} else {
+			c, _, err = ChooseK(vectors, 2, flags.maxK, flags.seed)
+		}
+	case "hdbscan":
+		c, err = HDBSCAN{MinClusterSize: flags.minCluster, MinSamples: flags.minSamples}.Cluster(vectors)
+	default:
+		return fmt.Errorf("unknown algorithm %q, want kmeans or hdbscan", *algo)
+	}
+	if err != nil {
+		return err
+	}
+	// The embedding function is never called: every document has its embedding
+	collection := db.GetCollection(*name, newHashingEmbedder())
+	if err := LabelDocuments(context.Background(), collection, docs, c, *key); err != nil {
+		return err
+	}
+
+	fmt.Printf("🗂️  %s/%s: %d documents, %s\n", *dbPath, *name, len(docs), c.Algorithm)
+	for _, t := range Describe(docs, c, flags.reps, flags.terms) {
+		if t.Cluster == Noise {
+			fmt.Printf("   %s=noise (%d): %v\n", *key, len(t.IDs), t.IDs)
+			continue
+		}
+		fmt.Printf("   %s=%d %s (%d, cohesion %.2f): %v\n", *key, t.Cluster, t.Label(), len(t.IDs), t.Cohesion, t.IDs)
+	}
+	fmt.Printf("   %d clusters, silhouette %.3f; filter with where {%q: \"<n>\"}\n", c.K, Silhouette(vectors, c), *key)
+	return nil
+}
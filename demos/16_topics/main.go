@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string) error{
+			"label": runLabel,
+		}
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	var flags clusterFlags
+	flags.register(flag.CommandLine)
+	flag.Parse()
+	if err := flags.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+
+	fmt.Println("🗂️  Topic Discovery Demo - Categories Nobody Wrote Down")
+	fmt.Println("======================================================")
+
+	ctx := context.Background()
+	embed := newHashingEmbedder()
+	dbPath := "./chromem-data"
+	os.RemoveAll(dbPath)
+
+	// 1. Embed an inbox nobody sorted; the queues are only for checking
+	fmt.Println("\n📥 1. Embedding a support inbox nobody has sorted...")
+	db, err := chromem.NewPersistentDB(dbPath, false)
+	if err != nil {
+		panic(err)
+	}
+	collection, err := db.CreateCollection("tickets", nil, embed)
+	if err != nil {
+		panic(err)
+	}
+	if err := collection.AddDocuments(ctx, supportTickets(), runtime.NumCPU()); err != nil {
+		panic(err)
+	}
+	docs, err := readDocuments(db, "tickets")
+	if err != nil {
+		panic(err)
+	}
+	c := newCorpus(docs, "queue")
+	fmt.Printf("   %d tickets in %s/tickets. Support ended up routing them to %d queues: %s\n",
+		len(docs), dbPath, len(c.distinct), strings.Join(c.distinct, ", "))
+	fmt.Println("   The clusterers see only the embeddings")
+
+	// 2. k-means, with k picked by silhouette unless it's given
+	fmt.Println("\n🎯 2. k-means")
+	kmeans, err := c.kmeans(flags, true)
+	if err != nil {
+		panic(err)
+	}
+	c.print(kmeans, flags)
+
+	// 3. HDBSCAN: no k, and what fits nowhere is noise
+	fmt.Printf("\n🌳 3. HDBSCAN, clusters of at least %d\n", flags.minCluster)
+	hdbscan, err := HDBSCAN{MinClusterSize: flags.minCluster, MinSamples: flags.minSamples}.Cluster(c.vectors)
+	if err != nil {
+		panic(err)
+	}
+	c.print(hdbscan, flags)
+
+	// 4. Write both back so queries can filter on them
+	fmt.Println("\n🏷️  4. Writing the clusters into each ticket's metadata")
+	if err := LabelDocuments(ctx, collection, docs, kmeans, "kmeans"); err != nil {
+		panic(err)
+	}
+	if docs, err = readDocuments(db, "tickets"); err != nil {
+		panic(err)
+	}
+	if err := LabelDocuments(ctx, collection, docs, hdbscan, "hdbscan"); err != nil {
+		panic(err)
+	}
+	doc, err := collection.GetByID(ctx, docs[0].ID)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   %s: %s\n", doc.ID, formatMetadata(doc.Metadata))
+
+	query := "refund the order that never arrived"
+	results, err := collection.Query(ctx, query, 5, nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("\n   %q, all tickets:\n", query)
+	printResults(results)
+	// Only in the topic of a ticket about being charged twice
+	charged, err := collection.GetByID(ctx, "t02")
+	if err != nil {
+		panic(err)
+	}
+	where := map[string]string{"kmeans": charged.Metadata["kmeans"]}
+	size := 0
+	for _, l := range kmeans.Labels {
+		if ClusterValue(l) == where["kmeans"] {
+			size++
+		}
+	}
+	results, err = collection.Query(ctx, query, min(5, size), where, nil)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("   the same, where kmeans=%s, the topic of t02 %q:\n", where["kmeans"], charged.Content)
+	printResults(results)
+
+	// 5. The same on the documentation snippets, where categories share few words
+	fmt.Println("\n📖 5. The documentation snippets of 04_semantic_snippets")
+	snippets, err := db.CreateCollection("snippets", nil, embed)
+	if err != nil {
+		panic(err)
+	}
+	if err := snippets.AddDocuments(ctx, createDocumentationSnippets(), runtime.NumCPU()); err != nil {
+		panic(err)
+	}
+	if docs, err = readDocuments(db, "snippets"); err != nil {
+		panic(err)
+	}
+	s := newCorpus(docs, "category")
+	fmt.Printf("   %d snippets, %d categories written by hand: %s\n", len(docs), len(s.distinct), strings.Join(s.distinct, ", "))
+	kmeans, err = s.kmeans(flags, false)
+	if err != nil {
+		panic(err)
+	}
+	hdbscan, err = HDBSCAN{MinClusterSize: flags.minCluster, MinSamples: flags.minSamples}.Cluster(s.vectors)
+	if err != nil {
+		panic(err)
+	}
+	s.summary(kmeans)
+	s.summary(hdbscan)
+	fmt.Println("   Each snippet shares more words with other categories than with its own:")
+	fmt.Println("   the hashing embedder finds no topics here, a language model would")
+
+	fmt.Println("\n🎯 Key Insights:")
+	fmt.Println("   • k-means puts every document somewhere; you pick k, or let the silhouette pick it")
+	fmt.Println("   • HDBSCAN finds k itself and calls what fits nowhere noise, instead of forcing it in")
+	fmt.Println("   • Class-based TF-IDF names a cluster by the words it uses and the others don't")
+	fmt.Println("   • A cluster label in metadata is a where filter, like a category someone typed in")
+	fmt.Println("   • Clusters follow the embedder: this one groups shared words, a model groups shared meaning")
+	fmt.Println("\n💡 Try: go run . -k 5, go run . -min-cluster 2, or go run . label -algo hdbscan")
+}
+
+// clusterFlags are the flags that configure the clusterers, shared by the
+// demo and the label command.
+type clusterFlags struct {
+	k, maxK                int
+	minCluster, minSamples int
+	seed                   uint64
+	terms, reps            int
+}
+
+func (f *clusterFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&f.k, "k", 0, "k-means clusters, 0 to pick k by silhouette")
+	fs.IntVar(&f.maxK, "max-k", 12, "the largest k to try when picking k")
+	fs.IntVar(&f.minCluster, "min-cluster", 3, "HDBSCAN's smallest cluster")
+	fs.IntVar(&f.minSamples, "min-samples", 0, "HDBSCAN's neighbours for a core distance, defaults to -min-cluster")
+	fs.Uint64Var(&f.seed, "seed", 1, "k-means seed")
+	fs.IntVar(&f.terms, "terms", 5, "distinctive terms per topic")
+	fs.IntVar(&f.reps, "reps", 2, "representative documents per topic")
+}
+
+func (f *clusterFlags) validate() error {
+	if f.k < 0 || f.maxK < 2 || f.minCluster < 2 || f.minSamples < 0 || f.terms <= 0 || f.reps <= 0 {
+		return fmt.Errorf("-k and -min-samples can't be negative, -max-k and -min-cluster must be at least 2, -terms and -reps positive")
+	}
+	return nil
+}
+
+// corpus is a set of embedded documents and the labels a person gave them,
+// which the clusterings are checked against.
+type corpus struct {
+	docs     []chromem.Document
+	vectors  [][]float32
+	labels   []string // per document
+	distinct []string // sorted
+}
+
+func newCorpus(docs []chromem.Document, key string) *corpus {
+	c := &corpus{docs: docs, vectors: make([][]float32, len(docs)), labels: make([]string, len(docs))}
+	for i, doc := range docs {
+		c.vectors[i], c.labels[i] = doc.Embedding, doc.Metadata[key]
+	}
+	c.distinct = slices.Compact(slices.Sorted(slices.Values(c.labels)))
+	return c
+}
+
+// kmeans runs k-means with -k clusters, or picks k by silhouette if -k is 0,
+// printing every k's silhouette if verbose.
+func (c *corpus) kmeans(flags clusterFlags, verbose bool) (Clustering, error) {
+	if flags.k > 0 {
+		return KMeans{K: flags.k, Seed: flags.seed}.Cluster(c.vectors)
+	}
+	best, scores, err := ChooseK(c.vectors, 2, flags.maxK, flags.seed)
+	if err != nil || !verbose {
+		return best, err
+	}
+	fmt.Print("   silhouette by k:")
+	for i, s := range scores {
+		fmt.Printf(" %d:%.3f", i+2, s)
+	}
+	fmt.Println()
+	return best, nil
+}
+
+// print prints the topics of a clustering, with the labels of their members,
+// and how well they agree.
+func (c *corpus) print(clustering Clustering, flags clusterFlags) {
+	byID := make(map[string]string, len(c.docs))
+	for i, doc := range c.docs {
+		byID[doc.ID] = c.labels[i]
+	}
+	for _, t := range Describe(c.docs, clustering, flags.reps, flags.terms) {
+		var found []string
+		counts := make(map[string]int)
+		for _, id := range t.IDs {
+			if counts[byID[id]]++; counts[byID[id]] == 1 {
+				found = append(found, byID[id])
+			}
+		}
+		slices.SortStableFunc(found, func(a, b string) int { return counts[b] - counts[a] })
+		for i, label := range found {
+			found[i] = fmt.Sprintf("%s×%d", label, counts[label])
+		}
+		if t.Cluster == Noise {
+			fmt.Printf("   noise (%d): %s - really %s\n", len(t.IDs), strings.Join(t.IDs, ", "), strings.Join(found, " "))
+			continue
+		}
+		var terms []string
+		for _, term := range t.Terms {
+			terms = append(terms, term.Word)
+		}
+		fmt.Printf("   %d. %s (%d, cohesion %.2f) - really %s\n", t.Cluster, t.Label(), len(t.IDs), t.Cohesion, strings.Join(found, " "))
+		fmt.Printf("      terms: %s\n", strings.Join(terms, ", "))
+		for _, id := range t.Representatives {
+			fmt.Printf("      %s: %s\n", id, c.content(id))
+		}
+	}
+	c.summary(clustering)
+}
+
+// summary prints how many clusters a clustering found and how well they
+// agree with the labels.
+func (c *corpus) summary(clustering Clustering) {
+	noise := 0
+	for _, l := range clustering.Labels {
+		if l == Noise {
+			noise++
+		}
+	}
+	fmt.Printf("   %-16s %2d clusters, %2d noise, silhouette %.3f, purity %.2f\n",
+		clustering.Algorithm+":", clustering.K, noise, Silhouette(c.vectors, clustering), Purity(c.labels, clustering))
+}
+
+func (c *corpus) content(id string) string {
+	for _, doc := range c.docs {
+		if doc.ID == id {
+			return doc.Content
+		}
+	}
+	return ""
+}
+
+func printResults(results []chromem.Result) {
+	for i, r := range results {
+		fmt.Printf("   %d. [%s] %.4f  kmeans=%-2s hdbscan=%-5s %s\n", i+1, r.ID, r.Similarity,
+			r.Metadata["kmeans"], r.Metadata["hdbscan"], r.Content)
+	}
+}
+
+// formatMetadata returns metadata as sorted key=value pairs.
+func formatMetadata(metadata map[string]string) string {
+	keys := slices.Sorted(maps.Keys(metadata))
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + metadata[k]
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,156 @@
+package main
+
+import "github.com/philippgille/chromem-go"
+
+// createDocumentationSnippets returns the snippets of 04_semantic_snippets.
+// Their category metadata is the grouping a person chose; the clusterers
+// never see it.
+func createDocumentationSnippets() []chromem.Document {
+	return []chromem.Document{
+		// Backend Development
+		{
+			ID:       "be-001",
+			Content:  "Set up a REST API using Node.js and Express framework. Configure middleware for logging, CORS, and authentication. Define routes for CRUD operations.",
+			Metadata: map[string]string{"category": "backend", "difficulty": "intermediate", "topic": "api"},
+		},
+		{
+			ID:       "be-002",
+			Content:  "Database connection pooling in PostgreSQL. Configure maximum connections, timeout settings, and connection retry logic for production environments.",
+			Metadata: map[string]string{"category": "backend", "difficulty": "advanced", "topic": "database"},
+		},
+		{
+			ID:       "be-003",
+			Content:  "Implement caching strategies using Redis. Set up cache invalidation policies, handle distributed caching, and optimize cache hit ratios.",
+			Metadata: map[string]string{"category": "backend", "difficulty": "advanced", "topic": "performance"},
+		},
+		{
+			ID:       "be-004",
+			Content:  "Handle file uploads securely. Validate file types, limit file sizes, scan for malware, and store files in cloud storage with proper access controls.",
+			Metadata: map[string]string{"category": "backend", "difficulty": "intermediate", "topic": "security"},
+		},
+
+		// Frontend Development
+		{
+			ID:       "fe-001",
+			Content:  "Create responsive layouts using CSS Grid and Flexbox. Implement mobile-first design patterns and ensure cross-browser compatibility.",
+			Metadata: map[string]string{"category": "frontend", "difficulty": "intermediate", "topic": "css"},
+		},
+		{
+			ID:       "fe-002",
+			Content:  "State management in React applications. Use Redux Toolkit for complex state, Context API for simple state, and implement proper state normalization.",
+			Metadata: map[string]string{"category": "frontend", "difficulty": "advanced", "topic": "react"},
+		},
+		{
+			ID:       "fe-003",
+			Content:  "Optimize web performance using lazy loading, code splitting, and image optimization. Implement service workers for offline functionality.",
+			Metadata: map[string]string{"category": "frontend", "difficulty": "advanced", "topic": "performance"},
+		},
+		{
+			ID:       "fe-004",
+			Content:  "Form validation and user input handling. Implement client-side validation, sanitize inputs, provide meaningful error messages, and handle accessibility.",
+			Metadata: map[string]string{"category": "frontend", "difficulty": "intermediate", "topic": "forms"},
+		},
+
+		// DevOps & Infrastructure
+		{
+			ID:       "do-001",
+			Content:  "Deploy applications using Docker containers. Create optimized Dockerfiles, manage multi-stage builds, and implement container orchestration with Kubernetes.",
+			Metadata: map[string]string{"category": "devops", "difficulty": "advanced", "topic": "containers"},
+		},
+		{
+			ID:       "do-002",
+			Content:  "Set up CI/CD pipelines using GitHub Actions. Automate testing, building, and deployment processes. Configure environment-specific deployments.",
+			Metadata: map[string]string{"category": "devops", "difficulty": "intermediate", "topic": "cicd"},
+		},
+		{
+			ID:       "do-003",
+			Content:  "Monitor applications using Prometheus and Grafana. Set up metrics collection, create alerting rules, and build comprehensive dashboards.",
+			Metadata: map[string]string{"category": "devops", "difficulty": "advanced", "topic": "monitoring"},
+		},
+		{
+			ID:       "do-004",
+			Content:  "Infrastructure as Code using Terraform. Define cloud resources, manage state files, and implement proper resource lifecycle management.",
+			Metadata: map[string]string{"category": "devops", "difficulty": "advanced", "topic": "infrastructure"},
+		},
+
+		// Security
+		{
+			ID:       "sec-001",
+			Content:  "Implement OAuth 2.0 authentication flow. Configure authorization servers, handle token refresh, and secure API endpoints with proper scopes.",
+			Metadata: map[string]string{"category": "security", "difficulty": "advanced", "topic": "authentication"},
+		},
+		{
+			ID:       "sec-002",
+			Content:  "Secure API endpoints against common attacks. Implement rate limiting, input validation, SQL injection prevention, and CSRF protection.",
+			Metadata: map[string]string{"category": "security", "difficulty": "intermediate", "topic": "api-security"},
+		},
+		{
+			ID:       "sec-003",
+			Content:  "Data encryption at rest and in transit. Use AES encryption for stored data, implement TLS properly, and manage encryption keys securely.",
+			Metadata: map[string]string{"category": "security", "difficulty": "advanced", "topic": "encryption"},
+		},
+
+		// Database
+		{
+			ID:       "db-001",
+			Content:  "Optimize database queries for better performance. Use proper indexing strategies, analyze query execution plans, and implement query caching.",
+			Metadata: map[string]string{"category": "database", "difficulty": "advanced", "topic": "performance"},
+		},
+		{
+			ID:       "db-002",
+			Content:  "Database backup and recovery strategies. Implement automated backups, test restore procedures, and set up point-in-time recovery.",
+			Metadata: map[string]string{"category": "database", "difficulty": "intermediate", "topic": "backup"},
+		},
+		{
+			ID:       "db-003",
+			Content:  "Database migration best practices. Plan schema changes, handle data transformations, and ensure zero-downtime deployments.",
+			Metadata: map[string]string{"category": "database", "difficulty": "intermediate", "topic": "migration"},
+		},
+
+		// Testing
+		{
+			ID:       "test-001",
+			Content:  "Write comprehensive unit tests using Jest and React Testing Library. Test components, hooks, and async operations with proper mocking.",
+			Metadata: map[string]string{"category": "testing", "difficulty": "intermediate", "topic": "unit-testing"},
+		},
+		{
+			ID:       "test-002",
+			Content:  "Integration testing for API endpoints. Test database interactions, external service calls, and end-to-end workflows with realistic data.",
+			Metadata: map[string]string{"category": "testing", "difficulty": "advanced", "topic": "integration-testing"},
+		},
+		{
+			ID:       "test-003",
+			Content:  "Automated browser testing with Playwright. Create reliable end-to-end tests, handle dynamic content, and implement visual regression testing.",
+			Metadata: map[string]string{"category": "testing", "difficulty": "advanced", "topic": "e2e-testing"},
+		},
+
+		// Performance
+		{
+			ID:       "perf-001",
+			Content:  "Application performance monitoring and optimization. Use profiling tools, identify bottlenecks, and implement performance improvements.",
+			Metadata: map[string]string{"category": "performance", "difficulty": "advanced", "topic": "monitoring"},
+		},
+		{
+			ID:       "perf-002",
+			Content:  "Load testing and capacity planning. Use tools like JMeter or k6 to simulate traffic, identify system limits, and plan for scaling.",
+			Metadata: map[string]string{"category": "performance", "difficulty": "advanced", "topic": "load-testing"},
+		},
+
+		// Debugging
+		{
+			ID:       "debug-001",
+			Content:  "Debug production issues using logging and monitoring tools. Set up structured logging, analyze error patterns, and implement alerting.",
+			Metadata: map[string]string{"category": "debugging", "difficulty": "intermediate", "topic": "production"},
+		},
+		{
+			ID:       "debug-002",
+			Content:  "Memory leak detection and resolution. Use memory profiling tools, identify leak sources, and implement proper memory management.",
+			Metadata: map[string]string{"category": "debugging", "difficulty": "advanced", "topic": "memory"},
+		},
+		{
+			ID:       "debug-003",
+			Content:  "Distributed tracing for microservices. Implement OpenTelemetry, trace requests across services, and analyze performance bottlenecks.",
+			Metadata: map[string]string{"category": "debugging", "difficulty": "advanced", "topic": "tracing"},
+		},
+	}
+}
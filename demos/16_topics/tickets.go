@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// supportTickets returns a support inbox nobody has sorted: questions about
+// a handful of recurring problems, in the customers' own words, and a few
+// that are about nothing else in the inbox. The queue metadata is where each
+// ticket ended up; the clusterers never see it.
+func supportTickets() []chromem.Document {
+	queues := []struct {
+		queue   string
+		tickets []string
+	}{
+		{"login", []string{
+			"I can't log in, the password reset email never arrives",
+			"Password reset link expired before I could log in",
+			"Locked out of my account after too many wrong password attempts",
+			"The login page says my password is wrong but I just reset it",
+			"Two-factor code is not accepted when I log in",
+			"How do I reset my password if I no longer have the email address",
+			"Login keeps failing with the right password since yesterday",
+		}},
+		{"billing", []string{
+			"I was charged twice for my subscription this month",
+			"Please refund the duplicate charge on my credit card",
+			"Where can I download the invoice for last month's subscription",
+			"My credit card was charged after I cancelled the subscription",
+			"The invoice shows the wrong company name and VAT number",
+			"Why did the subscription price go up on this invoice",
+			"Refund request: charged for the annual plan instead of monthly",
+		}},
+		{"shipping", []string{
+			"My order has not arrived, the tracking number shows no update",
+			"Package delivered to the wrong address according to tracking",
+			"How long does shipping take for an order to Canada",
+			"The tracking page says delivered but no package arrived",
+			"Can I change the delivery address of an order that already shipped",
+			"Order shipped a week ago and tracking has not moved since",
+		}},
+		{"crash", []string{
+			"The app crashes on startup after the latest update",
+			"App crashes every time I open the camera screen on Android",
+			"Since the update the app freezes and then crashes when syncing",
+			"Crash when uploading a photo from the gallery in the app",
+			"The iPhone app closes itself right after the splash screen since the update",
+			"App keeps crashing when I switch to dark mode",
+		}},
+		{"export", []string{
+			"How do I export all my data to a CSV file",
+			"The CSV export is missing the notes column",
+			"Export to Excel cuts off rows after 1000",
+			"Can I schedule a weekly export of my reports as CSV",
+			"Exported CSV file has broken characters in names with accents",
+		}},
+		{"other", []string{
+			"Do you have a discount for nonprofits",
+			"Your office dog in the newsletter is adorable",
+			"Are you hiring designers in Berlin",
+		}},
+	}
+
+	// Round robin, so neither the IDs nor their order give the queues away
+	var docs []chromem.Document
+	longest := 0
+	for _, q := range queues {
+		longest = max(longest, len(q.tickets))
+	}
+	for i := range longest {
+		for _, q := range queues {
+			if i < len(q.tickets) {
+				docs = append(docs, chromem.Document{
+					ID:       fmt.Sprintf("t%02d", len(docs)+1),
+					Content:  q.tickets[i],
+					Metadata: map[string]string{"queue": q.queue},
+				})
+			}
+		}
+	}
+	return docs
+}
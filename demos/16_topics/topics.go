@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Topic is a cluster of documents described for people.
+type Topic struct {
+	Cluster int      // the label, or Noise
+	IDs     []string // the members, most central first
+	// Representatives are the members most similar to the cluster's centroid.
+	Representatives []string
+	// Terms are the words most frequent in the cluster relative to the rest
+	// of the collection, by class-based TF-IDF.
+	Terms    []Term
+	Cohesion float32 // mean similarity of the members to the centroid
+}
+
+// Term is a distinctive word of a topic.
+type Term struct {
+	Word   string
+	Weight float64
+}
+
+// Label returns the topic's first three terms, the way to tell topics apart
+// at a glance.
+func (t Topic) Label() string {
+	var ws []string
+	for _, term := range t.Terms[:min(3, len(t.Terms))] {
+		ws = append(ws, term.Word)
+	}
+	return strings.Join(ws, " · ")
+}
+
+// Describe returns a topic per cluster of c, the clustering of the documents'
+// embeddings in order, then one for the noise if there is any. Each topic
+// has up to reps representatives and terms terms.
+//
+// A term's weight is class-based TF-IDF, as in BERTopic: its share of the
+// cluster's words, times the log of how much more often the average cluster
+// has words than the collection has this one - so a word is distinctive if
+// the cluster uses it often and the others don't.
+func Describe(docs []chromem.Document, c Clustering, reps, terms int) []Topic {
+	counts := make([]map[string]int, c.K)
+	totals := make([]int, c.K)
+	overall := make(map[string]int)
+	for i := range counts {
+		counts[i] = make(map[string]int)
+	}
+	for i, doc := range docs {
+		for _, word := range words(doc.Content) {
+			if len(word) <= 2 {
+				continue // "ci", "cd", "k6": too short to tell topics apart
+			}
+			overall[word]++
+			if l := c.Labels[i]; l != Noise {
+				counts[l][word]++
+				totals[l]++
+			}
+		}
+	}
+	var average float64
+	for _, t := range totals {
+		average += float64(t) / float64(c.K)
+	}
+
+	vectors := make([][]float32, len(docs))
+	for i, doc := range docs {
+		vectors[i] = doc.Embedding
+	}
+	centroids := centroidsOf(vectors, c.Labels, c.K)
+	topics := make([]Topic, c.K)
+	for i := range topics {
+		topics[i].Cluster = i
+	}
+	noise := Topic{Cluster: Noise}
+	sims := make([]float32, len(docs))
+	for i, doc := range docs {
+		l := c.Labels[i]
+		if l == Noise {
+			noise.IDs = append(noise.IDs, doc.ID)
+			continue
+		}
+		sims[i] = dot(vectors[i], centroids[l])
+		topics[l].IDs = append(topics[l].IDs, doc.ID)
+		topics[l].Cohesion += sims[i]
+	}
+
+	index := make(map[string]int, len(docs))
+	for i, doc := range docs {
+		index[doc.ID] = i
+	}
+	for i := range topics {
+		t := &topics[i]
+		if len(t.IDs) == 0 {
+			continue
+		}
+		t.Cohesion /= float32(len(t.IDs))
+		slices.SortStableFunc(t.IDs, func(a, b string) int { return cmp.Compare(sims[index[b]], sims[index[a]]) })
+		t.Representatives = t.IDs[:min(reps, len(t.IDs))]
+
+		for word, n := range counts[i] {
+			tf := float64(n) / float64(totals[i])
+			t.Terms = append(t.Terms, Term{word, tf * math.Log(1+average/float64(overall[word]))})
+		}
+		slices.SortFunc(t.Terms, func(a, b Term) int {
+			return cmp.Or(cmp.Compare(b.Weight, a.Weight), strings.Compare(a.Word, b.Word))
+		})
+		t.Terms = t.Terms[:min(terms, len(t.Terms))]
+	}
+	if len(noise.IDs) > 0 {
+		topics = append(topics, noise)
+	}
+	return topics
+}
+
+// LabelDocuments writes each document's cluster to its metadata under key -
+// its number, or "noise" - and stores the documents again with the
+// embeddings they have, so chromem-go doesn't embed them again. Queries can
+// then filter on a cluster with where: {key: "3"}.
+func LabelDocuments(ctx context.Context, collection *chromem.Collection, docs []chromem.Document, c Clustering, key string) error {
+	labelled := make([]chromem.Document, len(docs))
+	for i, doc := range docs {
+		metadata := maps.Clone(doc.Metadata)
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[key] = ClusterValue(c.Labels[i])
+		doc.Metadata = metadata
+		labelled[i] = doc
+	}
+	if err := collection.AddDocuments(ctx, labelled, 1); err != nil {
+		return fmt.Errorf("couldn't store the cluster labels: %w", err)
+	}
+	return nil
+}
+
+// ClusterValue is how LabelDocuments writes a label.
+func ClusterValue(label int) string {
+	if label == Noise {
+		return "noise"
+	}
+	return strconv.Itoa(label)
+}
+
+// Purity compares a clustering with labels a person chose: the share of
+// documents whose cluster's most common label is their own. Noise counts
+// against it. A cluster per document is perfectly pure, so read it next to
+// the number of clusters.
+func Purity(labels []string, c Clustering) float64 {
+	counts := make([]map[string]int, c.K)
+	for i := range counts {
+		counts[i] = make(map[string]int)
+	}
+	for i, l := range c.Labels {
+		if l != Noise {
+			counts[l][labels[i]]++
+		}
+	}
+	var pure int
+	for _, m := range counts {
+		most := 0
+		for _, n := range m {
+			most = max(most, n)
+		}
+		pure += most
+	}
+	return float64(pure) / float64(len(labels))
+}
+
+// readDocuments returns all documents of a collection, sorted by ID.
+func readDocuments(db *chromem.DB, name string) ([]chromem.Document, error) {
+	var buf bytes.Buffer
+	if err := db.ExportToWriter(&buf, false, "", name); err != nil {
+		return nil, fmt.Errorf("couldn't export collection %q: %w", name, err)
+	}
+	persisted := struct {
+		Collections map[string]*struct {
+			Documents map[string]*chromem.Document
+		}
+	}{}
+	if err := gob.NewDecoder(&buf).Decode(&persisted); err != nil {
+		return nil, fmt.Errorf("couldn't decode collection %q: %w", name, err)
+	}
+	pc, ok := persisted.Collections[name]
+	if !ok {
+		return nil, fmt.Errorf("collection %q not found", name)
+	}
+	docs := make([]chromem.Document, 0, len(pc.Documents))
+	for _, doc := range pc.Documents {
+		docs = append(docs, *doc)
+	}
+	slices.SortFunc(docs, func(a, b chromem.Document) int { return strings.Compare(a.ID, b.ID) })
+	return docs, nil
+}